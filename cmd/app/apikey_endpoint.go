@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"GameStoreAPI/internal/middleware"
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+type createAPIKeyRequest struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	ExpiresAt string   `json:"expires_at"` // RFC 3339 or YYYY-MM-DD; 90 days when omitted
+}
+
+// registerAPIKeyRoutes wires the API keys of the signed-in developer. Keys cannot manage
+// keys: these routes need a real session.
+//
+//	GET    /developers/me/api-keys        -> list
+//	POST   /developers/me/api-keys        -> create, the key is only shown in this response
+//	DELETE /developers/me/api-keys/:id    -> revoke
+func registerAPIKeyRoutes(g *echo.Group, ks *services.APIKeyService) {
+	keys := g.Group("/developers/me/api-keys")
+	keys.Use(middleware.JWTMiddleware(), middleware.RequireSession(), middleware.RequirePermission(model.PermAPIKeysManage))
+
+	keys.GET("", func(c echo.Context) error {
+		p, err := pageParams(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		claims := middleware.GetClaims(c)
+		list, err := ks.List(c.Request().Context(), claims.AuthID, p)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, list)
+	})
+
+	keys.POST("", func(c echo.Context) error {
+		req := new(createAPIKeyRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		expires, err := parseOptionalTime(req.ExpiresAt)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid expires_at (use RFC 3339 or YYYY-MM-DD)"})
+		}
+		claims := middleware.GetClaims(c)
+		key, err := ks.Create(c.Request().Context(), claims.AuthID, req.Name, req.Scopes, expires, c.RealIP())
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusCreated, key)
+	})
+
+	keys.DELETE("/:id", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		claims := middleware.GetClaims(c)
+		if err := ks.Revoke(c.Request().Context(), claims.AuthID, id, c.RealIP()); err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "API key revoked"})
+	})
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
 	"GameStoreAPI/internal/middleware"
+	"GameStoreAPI/internal/model"
 	"GameStoreAPI/internal/services"
 
 	"github.com/labstack/echo/v4"
@@ -20,6 +24,37 @@ type loginRequest struct {
 	Password string `json:"password"`
 }
 
+type loginTwoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"` // TOTP code or recovery code
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type tokenRequest struct {
+	Token string `json:"token"`
+}
+
+type forgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type logoutRequest struct {
+	All bool `json:"all,omitempty"` // revoke every session of the user
+}
+
 // registerPublic handles unauthenticated registration -> creates "user" role
 func registerPublic(authSvc *services.AuthService) echo.HandlerFunc {
 	return func(c echo.Context) error {
@@ -27,7 +62,10 @@ func registerPublic(authSvc *services.AuthService) echo.HandlerFunc {
 		if err := c.Bind(req); err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
 		}
-		id, err := authSvc.RegisterPublic(c.Request().Context(), req.Email, req.Password)
+		id, err := authSvc.RegisterPublic(c.Request().Context(), req.Email, req.Password, c.RealIP())
+		if locked, herr := tooManyAttempts(c, err); locked {
+			return herr
+		}
 		if err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
@@ -35,6 +73,16 @@ func registerPublic(authSvc *services.AuthService) echo.HandlerFunc {
 	}
 }
 
+// tooManyAttempts answers 429 with a Retry-After header when err is a lockout
+func tooManyAttempts(c echo.Context, err error) (bool, error) {
+	var locked *services.LockedError
+	if !errors.As(err, &locked) {
+		return false, nil
+	}
+	c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+	return true, c.JSON(http.StatusTooManyRequests, map[string]string{"error": err.Error()})
+}
+
 // adminRegister allows admin to create admin/developer/user
 func adminRegister(authSvc *services.AuthService) echo.HandlerFunc {
 	return func(c echo.Context) error {
@@ -51,32 +99,220 @@ func adminRegister(authSvc *services.AuthService) echo.HandlerFunc {
 	}
 }
 
-func loginHandler(authSvc *services.AuthService) echo.HandlerFunc {
+// loginHandler checks the password. With 2FA on it answers with a challenge to complete at
+// /auth/login/2fa instead of tokens.
+func loginHandler(authSvc *services.AuthService, sessSvc *services.SessionService, tfSvc *services.TwoFactorService) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		req := new(loginRequest)
 		if err := c.Bind(req); err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
 		}
-		user, err := authSvc.Login(c.Request().Context(), req.Email, req.Password)
+		user, err := authSvc.Login(c.Request().Context(), req.Email, req.Password, c.RealIP())
+		if locked, herr := tooManyAttempts(c, err); locked {
+			return herr
+		}
 		if err != nil {
 			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid credentials"})
 		}
-		// generate JWT token
-		claimsToken, err := middleware.GenerateToken(user.AuthID, user.Email, user.Role, 24)
+		return signIn(c, sessSvc, tfSvc, user)
+	}
+}
+
+// signIn finishes the first factor of a login: users with 2FA on get a challenge,
+// everyone else a session
+func signIn(c echo.Context, sessSvc *services.SessionService, tfSvc *services.TwoFactorService, user *model.Auth) error {
+	on, err := tfSvc.Enabled(c.Request().Context(), user.AuthID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "could not check two-factor authentication"})
+	}
+	if on {
+		challenge, err := tfSvc.Challenge(c.Request().Context(), user.AuthID)
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "could not create token"})
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "could not create login challenge"})
 		}
-		// return token plus user info (without password)
-		return c.JSON(http.StatusOK, map[string]interface{}{
-			"token":      claimsToken,
-			"expires_in": 24 * 3600,
-			"user": map[string]interface{}{
-				"authid":     user.AuthID,
-				"email":      user.Email,
-				"role":       user.Role,
-				"created_at": user.CreatedAt,
-			},
-		})
+		return c.JSON(http.StatusOK, challenge)
+	}
+	return startSession(c, sessSvc, tfSvc, user)
+}
+
+// loginTwoFactorHandler completes a login with the challenge token and a TOTP or recovery code
+func loginTwoFactorHandler(sessSvc *services.SessionService, tfSvc *services.TwoFactorService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := new(loginTwoFactorRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		user, err := tfSvc.CompleteLogin(c.Request().Context(), req.ChallengeToken, req.Code, c.RealIP())
+		if locked, herr := tooManyAttempts(c, err); locked {
+			return herr
+		}
+		if err != nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+		}
+		return startSession(c, sessSvc, tfSvc, user)
+	}
+}
+
+// startSession issues the token pair of a fully authenticated user
+func startSession(c echo.Context, sessSvc *services.SessionService, tfSvc *services.TwoFactorService, user *model.Auth) error {
+	// start a session: short-lived access token + rotating refresh token
+	tokens, err := sessSvc.Issue(c.Request().Context(), user, c.Request().UserAgent(), c.RealIP())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "could not create token"})
+	}
+	// only a complete login resets the failed attempts of the account
+	if err := tfSvc.LoginSucceeded(c.Request().Context(), user); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	setupRequired, err := tfSvc.SetupRequired(c.Request().Context(), user.AuthID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	// return tokens plus user info (without password)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"token":              tokens.AccessToken,
+		"expires_in":         tokens.ExpiresIn,
+		"refresh_token":      tokens.RefreshToken,
+		"refresh_expires_at": tokens.RefreshExpiresAt,
+		// the role's permissions are withheld until 2FA is enabled at /auth/2fa/setup
+		"two_factor_setup_required": setupRequired,
+		"user": map[string]interface{}{
+			"authid":     user.AuthID,
+			"email":      user.Email,
+			"role":       user.Role,
+			"created_at": user.CreatedAt,
+		},
+	})
+}
+
+// refreshHandler exchanges a refresh token for a new access/refresh token pair
+func refreshHandler(sessSvc *services.SessionService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := new(refreshRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		tokens, err := sessSvc.Refresh(c.Request().Context(), req.RefreshToken)
+		if err != nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, tokens)
+	}
+}
+
+// logoutHandler revokes the session of the presented access token (or all sessions)
+func logoutHandler(sessSvc *services.SessionService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		if claims == nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthenticated"})
+		}
+		req := new(logoutRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		if err := sessSvc.Logout(c.Request().Context(), claims.AuthID, claims.SessionID, req.All); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "logged out"})
+	}
+}
+
+// sessionsHandler lists the active sessions of the authenticated user
+func sessionsHandler(sessSvc *services.SessionService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		if claims == nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthenticated"})
+		}
+		list, err := sessSvc.ListActive(c.Request().Context(), claims.AuthID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, list)
+	}
+}
+
+// verifyEmailHandler consumes the token from the verification email
+func verifyEmailHandler(authSvc *services.AuthService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := new(tokenRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		if err := authSvc.VerifyEmail(c.Request().Context(), req.Token); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "email verified"})
+	}
+}
+
+// resendVerificationHandler emails a new verification link to the authenticated user
+func resendVerificationHandler(authSvc *services.AuthService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		if claims == nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthenticated"})
+		}
+		if err := authSvc.ResendVerification(c.Request().Context(), claims.AuthID); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "verification email sent"})
+	}
+}
+
+// forgotPasswordHandler emails a reset link; the answer is the same whether or not the email exists
+func forgotPasswordHandler(authSvc *services.AuthService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := new(forgotPasswordRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		if err := authSvc.ForgotPassword(c.Request().Context(), req.Email); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "could not send reset email"})
+		}
+		return c.JSON(http.StatusAccepted, map[string]string{"message": "if the address has an account, a reset link was sent"})
+	}
+}
+
+// resetPasswordHandler sets a new password from a reset token
+func resetPasswordHandler(authSvc *services.AuthService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := new(resetPasswordRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		if err := authSvc.ResetPassword(c.Request().Context(), req.Token, req.Password); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "password reset, please log in again"})
+	}
+}
+
+// changePasswordHandler changes the password of the authenticated user and signs out
+// their other sessions
+func changePasswordHandler(authSvc *services.AuthService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		if claims == nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthenticated"})
+		}
+		req := new(changePasswordRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		if err := authSvc.ChangePassword(c.Request().Context(), claims.AuthID, claims.SessionID, req.CurrentPassword, req.NewPassword); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "password changed"})
+	}
+}
+
+// jwksHandler publishes the token verification keys (signing key and keys still in rotation)
+func jwksHandler(ks *middleware.KeySet) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		c.Response().Header().Set("Cache-Control", "public, max-age=300")
+		return c.JSON(http.StatusOK, ks.JWKS())
 	}
 }
 
@@ -92,6 +328,7 @@ func meHandler() echo.HandlerFunc {
 			"authid": claims.AuthID,
 			"email":  claims.Email,
 			"role":   claims.Role,
+			"sid":    claims.SessionID,
 			"exp":    claims.ExpiresAt,
 		})
 	}
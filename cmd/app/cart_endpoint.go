@@ -1,27 +1,40 @@
 package main
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"GameStoreAPI/internal/middleware"
+	"GameStoreAPI/internal/model"
 	"GameStoreAPI/internal/services"
 
 	"github.com/labstack/echo/v4"
 )
 
 type addCartRequest struct {
-	GameID int64 `json:"gameid"`
-	Qty    int   `json:"quantity"`
+	GameID      int64  `json:"gameid"`
+	Qty         int    `json:"quantity"`
+	GiftTo      string `json:"gift_to,omitempty"` // email or username of the recipient
+	GiftMessage string `json:"gift_message,omitempty"`
+}
+
+type giftRequest struct {
+	GiftTo      string `json:"gift_to"`
+	GiftMessage string `json:"gift_message,omitempty"`
 }
 
 type updateCartRequest struct {
 	Qty int `json:"quantity"`
 }
 
+type couponRequest struct {
+	Code string `json:"code"`
+}
+
 func registerCartRoutes(g *echo.Group, cs *services.CartService) {
 	p := g.Group("/cart")
-	p.Use(middleware.JWTMiddleware())
+	p.Use(middleware.JWTMiddleware(), middleware.RequireSession())
 
 	// GET cart
 	p.GET("", func(c echo.Context) error {
@@ -34,17 +47,7 @@ func registerCartRoutes(g *echo.Group, cs *services.CartService) {
 	})
 
 	// POST /api/cart/checkout
-	p.POST("/cart/checkout", func(c echo.Context) error {
-		claims := middleware.GetClaims(c)
-		if claims == nil {
-			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthenticated"})
-		}
-		orderID, err := cs.Checkout(c.Request().Context(), claims.AuthID)
-		if err != nil {
-			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
-		}
-		return c.JSON(http.StatusOK, map[string]interface{}{"orderid": orderID})
-	})
+	p.POST("/cart/checkout", checkoutHandler(cs))
 
 	// ADD item
 	p.POST("", func(c echo.Context) error {
@@ -56,7 +59,7 @@ func registerCartRoutes(g *echo.Group, cs *services.CartService) {
 		if req.Qty == 0 {
 			req.Qty = 1
 		}
-		if err := cs.Add(c.Request().Context(), claims.AuthID, req.GameID, req.Qty); err != nil {
+		if err := cs.Add(c.Request().Context(), claims.AuthID, req.GameID, req.Qty, req.GiftTo, req.GiftMessage); err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
 		return c.JSON(http.StatusCreated, map[string]string{"message": "added"})
@@ -95,13 +98,89 @@ func registerCartRoutes(g *echo.Group, cs *services.CartService) {
 		return c.JSON(http.StatusOK, map[string]string{"message": "cleared"})
 	})
 
-	// CHECKOUT
-	p.POST("/checkout", func(c echo.Context) error {
+	// APPLY promo code
+	p.POST("/coupon", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		req := new(couponRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		cart, err := cs.ApplyCoupon(c.Request().Context(), claims.AuthID, req.Code)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, cart)
+	})
+
+	// MARK an item as a gift
+	p.PUT("/:gameid/gift", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		gameID, err := strconv.ParseInt(c.Param("gameid"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid game id"})
+		}
+		req := new(giftRequest)
+		if err := c.Bind(req); err != nil || req.GiftTo == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "gift_to is required"})
+		}
+		if err := cs.SetGift(c.Request().Context(), claims.AuthID, gameID, req.GiftTo, req.GiftMessage); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "marked as gift"})
+	})
+
+	// UNMARK a gift item (buy it for yourself)
+	p.DELETE("/:gameid/gift", func(c echo.Context) error {
 		claims := middleware.GetClaims(c)
-		orderID, err := cs.Checkout(c.Request().Context(), claims.AuthID)
+		gameID, err := strconv.ParseInt(c.Param("gameid"), 10, 64)
 		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid game id"})
+		}
+		if err := cs.SetGift(c.Request().Context(), claims.AuthID, gameID, "", ""); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "gift removed"})
+	})
+
+	// REMOVE promo code
+	p.DELETE("/coupon", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		if err := cs.RemoveCoupon(c.Request().Context(), claims.AuthID); err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
-		return c.JSON(http.StatusOK, map[string]interface{}{"orderid": orderID})
+		return c.JSON(http.StatusOK, map[string]string{"message": "coupon removed"})
 	})
+
+	// CHECKOUT
+	p.POST("/checkout", checkoutHandler(cs))
+}
+
+// checkoutHandler finalizes the open cart, charges it and returns the order with its payment status.
+// Lines that no longer match the catalog yield 409 with the per-item changes.
+func checkoutHandler(cs *services.CartService) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		if claims == nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthenticated"})
+		}
+		req := new(model.CheckoutRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		res, err := cs.Checkout(c.Request().Context(), claims.AuthID, *req)
+		var changed *services.CartChangedError
+		if errors.As(err, &changed) {
+			return c.JSON(http.StatusConflict, map[string]interface{}{"error": err.Error(), "changes": changed.Changes})
+		}
+		if errors.Is(err, services.ErrCartChangedDuringCheckout) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		if errors.Is(err, services.ErrEmailNotVerified) {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "verify your email address before checking out"})
+		}
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, res)
+	}
 }
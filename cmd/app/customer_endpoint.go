@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"GameStoreAPI/internal/middleware"
+	"GameStoreAPI/internal/model"
 	"GameStoreAPI/internal/services"
 
 	"github.com/labstack/echo/v4"
@@ -15,13 +16,24 @@ type updateCustomerRequest struct {
 	Fullname *string `json:"fullname,omitempty"`
 	Address  *string `json:"address,omitempty"`
 	Phone    *string `json:"phone,omitempty"`
+	Region   *string `json:"region,omitempty"`
+	Currency *string `json:"currency,omitempty"`
+}
+
+type banRequest struct {
+	Reason string `json:"reason"`
+	Until  string `json:"until,omitempty"` // RFC 3339 or YYYY-MM-DD; empty bans until unbanned
+}
+
+type deleteAccountRequest struct {
+	Password string `json:"password"`
 }
 
 // register customer routes (user self routes + admin user management)
-func registerCustomerRoutes(api *echo.Group, cs *services.CustomerService) {
+func registerCustomerRoutes(api *echo.Group, cs *services.CustomerService, as *services.AccountService) {
 	// User routes (require JWT)
 	userGrp := api.Group("/customers")
-	userGrp.Use(middleware.JWTMiddleware())
+	userGrp.Use(middleware.JWTMiddleware(), middleware.RequireSession())
 
 	// GET /api/customers/me
 	userGrp.GET("/me", func(c echo.Context) error {
@@ -36,6 +48,36 @@ func registerCustomerRoutes(api *echo.Group, cs *services.CustomerService) {
 		return c.JSON(http.StatusOK, cust)
 	})
 
+	// DELETE /api/customers/me -> delete the account (personal data is erased, orders are kept)
+	userGrp.DELETE("/me", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		if claims == nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthenticated"})
+		}
+		req := new(deleteAccountRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		if err := as.Delete(c.Request().Context(), claims.AuthID, req.Password, c.RealIP()); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "account deleted"})
+	})
+
+	// GET /api/customers/me/export -> JSON archive of profile, orders and owned games
+	userGrp.GET("/me/export", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		if claims == nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthenticated"})
+		}
+		exp, err := as.Export(c.Request().Context(), claims.AuthID)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="account-export.json"`)
+		return c.JSON(http.StatusOK, exp)
+	})
+
 	// PUT /api/customers/me
 	userGrp.PUT("/me", func(c echo.Context) error {
 		claims := middleware.GetClaims(c)
@@ -50,10 +92,39 @@ func registerCustomerRoutes(api *echo.Group, cs *services.CustomerService) {
 		if err := c.Bind(req); err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
 		}
-		if req.Fullname == nil && req.Address == nil && req.Phone == nil {
+		profile := req.Fullname != nil || req.Address != nil || req.Phone != nil
+		prefs := req.Region != nil || req.Currency != nil
+		if !profile && !prefs {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": "nothing to update"})
 		}
-		if err := cs.UpdateSelf(c.Request().Context(), cust.CustomerID, req.Fullname, req.Address, req.Phone); err != nil {
+		if prefs {
+			if err := cs.UpdatePreferences(c.Request().Context(), cust.CustomerID, req.Region, req.Currency); err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+			}
+		}
+		if profile {
+			if err := cs.UpdateSelf(c.Request().Context(), cust.CustomerID, req.Fullname, req.Address, req.Phone); err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+			}
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "updated"})
+	})
+
+	// PUT /api/customers/me/billing-address
+	userGrp.PUT("/me/billing-address", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		if claims == nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthenticated"})
+		}
+		cust, err := cs.GetByAuthID(c.Request().Context(), claims.AuthID)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "customer not found"})
+		}
+		req := new(model.BillingAddress)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		if err := cs.UpdateBillingAddress(c.Request().Context(), cust.CustomerID, *req); err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
 		return c.JSON(http.StatusOK, map[string]string{"message": "updated"})
@@ -62,11 +133,15 @@ func registerCustomerRoutes(api *echo.Group, cs *services.CustomerService) {
 	// Admin management group
 	admin := api.Group("/admin")
 	admin.Use(middleware.JWTMiddleware())
-	admin.Use(middleware.AdminOnly)
+	admin.Use(middleware.RequirePermission(model.PermUsersRead))
 
 	// LIST all users with role='user'
 	admin.GET("/users", func(c echo.Context) error {
-		users, err := cs.Users.ListUsersOnly(c.Request().Context())
+		p, err := pageParams(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		users, err := cs.ListUsers(c.Request().Context(), p)
 		if err != nil {
 			return c.JSON(500, map[string]string{"error": err.Error()})
 		}
@@ -89,18 +164,45 @@ func registerCustomerRoutes(api *echo.Group, cs *services.CustomerService) {
 		return c.JSON(200, user)
 	})
 
-	// BAN user (soft delete)
+	// BAN or suspend user (signs them out; a suspension ends at "until")
 	admin.POST("/users/:id/ban", func(c echo.Context) error {
 		idStr := c.Param("id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
 			return c.JSON(400, map[string]string{"error": "invalid id"})
 		}
+		req := new(banRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(400, map[string]string{"error": "invalid request"})
+		}
+		until, err := parseOptionalTime(req.Until)
+		if err != nil {
+			return c.JSON(400, map[string]string{"error": "invalid until (use RFC 3339 or YYYY-MM-DD)"})
+		}
 
-		if err := cs.BanUser(c.Request().Context(), id); err != nil {
+		claims := middleware.GetClaims(c)
+		if err := as.Ban(c.Request().Context(), claims.AuthID, id, until, req.Reason, c.RealIP()); err != nil {
 			return c.JSON(400, map[string]string{"error": err.Error()})
 		}
 
+		if until != nil {
+			return c.JSON(200, map[string]string{"message": "user suspended"})
+		}
 		return c.JSON(200, map[string]string{"message": "user banned"})
-	})
+	}, middleware.RequirePermission(model.PermUsersBan))
+
+	// UNBAN user
+	admin.POST("/users/:id/unban", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(400, map[string]string{"error": "invalid id"})
+		}
+
+		claims := middleware.GetClaims(c)
+		if err := as.Unban(c.Request().Context(), claims.AuthID, id, c.RealIP()); err != nil {
+			return c.JSON(400, map[string]string{"error": err.Error()})
+		}
+
+		return c.JSON(200, map[string]string{"message": "user unbanned"})
+	}, middleware.RequirePermission(model.PermUsersBan))
 }
@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"GameStoreAPI/internal/middleware"
+	"GameStoreAPI/internal/model"
 	"GameStoreAPI/internal/services"
 
 	"github.com/labstack/echo/v4"
@@ -13,7 +14,7 @@ import (
 func registerCustomerGamesRoutes(g *echo.Group, cgSvc *services.CustomerGamesService, cs *services.CustomerService) {
 
 	usr := g.Group("/customers/me")
-	usr.Use(middleware.JWTMiddleware())
+	usr.Use(middleware.JWTMiddleware(), middleware.RequireSession())
 
 	// GET /api/customers/me/games
 	usr.GET("/games", func(c echo.Context) error {
@@ -27,13 +28,40 @@ func registerCustomerGamesRoutes(g *echo.Group, cgSvc *services.CustomerGamesSer
 			return c.JSON(http.StatusNotFound, map[string]string{"error": "customer not found"})
 		}
 
-		owned, err := cgSvc.ListOwned(c.Request().Context(), cust.CustomerID)
+		p, err := pageParams(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		owned, err := cgSvc.ListOwned(c.Request().Context(), cust.CustomerID, p)
 		if err != nil {
 			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		}
 		return c.JSON(http.StatusOK, owned)
 	})
 
+	// POST /api/customers/me/games/:id/download
+	usr.POST("/games/:id/download", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		if claims == nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthenticated"})
+		}
+
+		cust, err := cs.GetByAuthID(c.Request().Context(), claims.AuthID)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "customer not found"})
+		}
+
+		gameID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid game id"})
+		}
+
+		if err := cgSvc.MarkDownloaded(c.Request().Context(), cust.CustomerID, gameID); err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "download recorded"})
+	})
+
 	// GET /api/customers/me/orders
 	usr.GET("/orders", func(c echo.Context) error {
 		claims := middleware.GetClaims(c)
@@ -46,7 +74,11 @@ func registerCustomerGamesRoutes(g *echo.Group, cgSvc *services.CustomerGamesSer
 			return c.JSON(http.StatusNotFound, map[string]string{"error": "customer not found"})
 		}
 
-		orders, err := cgSvc.ListOrders(c.Request().Context(), cust.CustomerID)
+		p, err := pageParams(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		orders, err := cgSvc.ListOrders(c.Request().Context(), cust.CustomerID, p)
 		if err != nil {
 			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		}
@@ -81,12 +113,40 @@ func registerCustomerGamesRoutes(g *echo.Group, cgSvc *services.CustomerGamesSer
 		})
 	})
 
+	// GET /api/customers/me/orders/:id/receipt
+	usr.GET("/orders/:id/receipt", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		if claims == nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthenticated"})
+		}
+
+		cust, err := cs.GetByAuthID(c.Request().Context(), claims.AuthID)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "customer not found"})
+		}
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid order id"})
+		}
+
+		receipt, err := cgSvc.Receipt(c.Request().Context(), cust.CustomerID, id)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, receipt)
+	})
+
 	admin := g.Group("/admin")
 	admin.Use(middleware.JWTMiddleware())
-	admin.Use(middleware.AdminOnly)
+	admin.Use(middleware.RequirePermission(model.PermOrdersReadAny))
 
 	admin.GET("/orders", func(c echo.Context) error {
-		orders, err := cgSvc.ListAllOrders(c.Request().Context())
+		p, err := pageParams(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		orders, err := cgSvc.ListAllOrders(c.Request().Context(), p)
 		if err != nil {
 			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		}
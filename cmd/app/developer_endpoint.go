@@ -28,7 +28,11 @@ type updateDeveloperRequest struct {
 func registerDeveloperRoutes(g *echo.Group, devSvc *services.DeveloperService) {
 	// public list
 	g.GET("/developers", func(c echo.Context) error {
-		list, err := devSvc.ListDevelopers(c.Request().Context())
+		p, err := pageParams(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		list, err := devSvc.ListDevelopers(c.Request().Context(), p)
 		if err != nil {
 			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		}
@@ -51,7 +55,7 @@ func registerDeveloperRoutes(g *echo.Group, devSvc *services.DeveloperService) {
 
 	// admin-only create
 	g.POST("/developers", func(c echo.Context) error {
-		// middleware should ensure admin role when route is mounted with AdminOnly
+		// middleware should ensure developers:manage when route is mounted under /admin
 		req := new(createDeveloperRequest)
 		if err := c.Bind(req); err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
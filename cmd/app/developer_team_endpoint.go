@@ -0,0 +1,257 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"GameStoreAPI/internal/middleware"
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+type developerApplicationRequest struct {
+	DeveloperName string `json:"developername"`
+	Website       string `json:"website"`
+	Message       string `json:"message"`
+}
+
+type reviewApplicationRequest struct {
+	Comment string `json:"comment"`
+}
+
+type inviteMemberRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"` // owner, admin or member (default)
+}
+
+type updateMemberRequest struct {
+	Role string `json:"role"`
+}
+
+// teamError maps team authorization failures to 403 and anything else to 400
+func teamError(c echo.Context, err error) error {
+	if errors.Is(err, services.ErrNotTeamMember) || errors.Is(err, services.ErrNotTeamManager) || errors.Is(err, services.ErrNotTeamOwner) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+}
+
+// registerDeveloperTeamRoutes wires developer applications and studio teams. They need a
+// signed-in user, API keys are refused.
+//
+//	POST   /developers/me/applications            -> apply to become a developer
+//	GET    /developers/me/applications            -> my applications
+//	GET    /developers/me/studios                 -> developers I am a member of, with my role
+//	GET    /developers/me/invites                 -> my open invites
+//	POST   /developers/me/invites/:id/accept      -> join the team of developer :id
+//	POST   /developers/me/invites/:id/decline     -> turn the invite down
+//	GET    /developers/:id/members                -> team (members)
+//	POST   /developers/:id/members                -> invite by email (owners and admins)
+//	PUT    /developers/:id/members/:authid        -> change team role (owners and admins)
+//	DELETE /developers/:id/members/:authid        -> remove, or leave the team
+//
+// Admin (developers:manage, also acts as owner of every team):
+//
+//	GET  /admin/developer-applications?status=
+//	POST /admin/developer-applications/:id/approve, POST /admin/developer-applications/:id/reject
+func registerDeveloperTeamRoutes(g *echo.Group, ds *services.DeveloperService) {
+	me := g.Group("/developers/me")
+	me.Use(middleware.JWTMiddleware(), middleware.RequireSession())
+
+	me.POST("/applications", func(c echo.Context) error {
+		req := new(developerApplicationRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		claims := middleware.GetClaims(c)
+		a, err := ds.Apply(c.Request().Context(), claims.AuthID, req.DeveloperName, req.Website, req.Message)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusCreated, a)
+	})
+
+	me.GET("/applications", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		p, err := pageParams(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		list, err := ds.MyApplications(c.Request().Context(), claims.AuthID, p)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, list)
+	})
+
+	me.GET("/studios", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		list, err := ds.Memberships(c.Request().Context(), claims.AuthID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, list)
+	})
+
+	me.GET("/invites", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		list, err := ds.Invites(c.Request().Context(), claims.AuthID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, list)
+	})
+
+	me.POST("/invites/:id/accept", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		claims := middleware.GetClaims(c)
+		m, err := ds.AcceptInvite(c.Request().Context(), id, claims.AuthID, c.RealIP())
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, m)
+	})
+
+	me.POST("/invites/:id/decline", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		claims := middleware.GetClaims(c)
+		if err := ds.DeclineInvite(c.Request().Context(), id, claims.AuthID); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "invite declined"})
+	})
+
+	team := g.Group("/developers/:id/members")
+	team.Use(middleware.JWTMiddleware(), middleware.RequireSession())
+
+	team.GET("", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		p, err := pageParams(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		claims := middleware.GetClaims(c)
+		list, err := ds.ListMembers(c.Request().Context(), id, claims.AuthID, middleware.HasPermission(c, model.PermDevelopersManage), p)
+		if err != nil {
+			return teamError(c, err)
+		}
+		return c.JSON(http.StatusOK, list)
+	})
+
+	team.POST("", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		req := new(inviteMemberRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		claims := middleware.GetClaims(c)
+		inv, err := ds.InviteMember(c.Request().Context(), id, claims.AuthID, middleware.HasPermission(c, model.PermDevelopersManage),
+			req.Email, req.Role, c.RealIP())
+		if err != nil {
+			return teamError(c, err)
+		}
+		return c.JSON(http.StatusCreated, inv)
+	})
+
+	team.PUT("/:authid", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		authID, err := strconv.ParseInt(c.Param("authid"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid authid"})
+		}
+		req := new(updateMemberRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		claims := middleware.GetClaims(c)
+		if err := ds.UpdateMember(c.Request().Context(), id, claims.AuthID, middleware.HasPermission(c, model.PermDevelopersManage),
+			authID, req.Role, c.RealIP()); err != nil {
+			return teamError(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "updated"})
+	})
+
+	team.DELETE("/:authid", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		authID, err := strconv.ParseInt(c.Param("authid"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid authid"})
+		}
+		claims := middleware.GetClaims(c)
+		if err := ds.RemoveMember(c.Request().Context(), id, claims.AuthID, middleware.HasPermission(c, model.PermDevelopersManage),
+			authID, c.RealIP()); err != nil {
+			return teamError(c, err)
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "removed"})
+	})
+
+	admin := g.Group("/admin/developer-applications")
+	admin.Use(middleware.JWTMiddleware())
+	admin.Use(middleware.RequirePermission(model.PermDevelopersManage))
+
+	admin.GET("", func(c echo.Context) error {
+		p, err := pageParams(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		list, err := ds.ListApplications(c.Request().Context(), c.QueryParam("status"), p)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, list)
+	})
+
+	admin.POST("/:id/approve", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		req := new(reviewApplicationRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		claims := middleware.GetClaims(c)
+		a, err := ds.ApproveApplication(c.Request().Context(), id, claims.AuthID, req.Comment, c.RealIP())
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, a)
+	})
+
+	admin.POST("/:id/reject", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		req := new(reviewApplicationRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		claims := middleware.GetClaims(c)
+		if err := ds.RejectApplication(c.Request().Context(), id, claims.AuthID, req.Comment, c.RealIP()); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "application rejected"})
+	})
+}
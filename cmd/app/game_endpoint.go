@@ -1,12 +1,15 @@
 package main
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"GameStoreAPI/internal/middleware"
 	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/money"
 	"GameStoreAPI/internal/services"
 
 	"github.com/labstack/echo/v4"
@@ -14,51 +17,105 @@ import (
 
 // request payloads
 type createGameRequest struct {
-	DeveloperID int64   `json:"developerid"`
-	Title       string  `json:"title"`
-	Price       float64 `json:"price"`
-	ReleaseDate string  `json:"releasedate,omitempty"` // YYYY-MM-DD expected
+	DeveloperID  int64                     `json:"developerid"`
+	Title        string                    `json:"title"`
+	Blurb        *string                   `json:"blurb,omitempty"`
+	Description  *string                   `json:"description,omitempty"`
+	Price        money.Amount              `json:"price"`
+	ReleaseDate  string                    `json:"releasedate,omitempty"` // YYYY-MM-DD expected
+	PEGIRating   *int                      `json:"pegirating,omitempty"`
+	ESRBRating   *string                   `json:"esrbrating,omitempty"`
+	Descriptions []model.GameDescription   `json:"descriptions,omitempty"`
+	Media        []model.GameMedia         `json:"media,omitempty"`
+	Requirements []model.SystemRequirement `json:"requirements,omitempty"`
+	Languages    []model.GameLanguage      `json:"languages,omitempty"`
 }
 
+// updateGameRequest replaces the game; descriptions, media, requirements and languages
+// are kept when left out, and cleared with an empty list
 type updateGameRequest struct {
-	DeveloperID int64   `json:"developerid"`
-	Title       string  `json:"title"`
-	Price       float64 `json:"price"`
-	ReleaseDate string  `json:"releasedate,omitempty"`
+	DeveloperID  int64                     `json:"developerid"`
+	Title        string                    `json:"title"`
+	Blurb        *string                   `json:"blurb,omitempty"`
+	Description  *string                   `json:"description,omitempty"`
+	Price        money.Amount              `json:"price"`
+	ReleaseDate  string                    `json:"releasedate,omitempty"`
+	PEGIRating   *int                      `json:"pegirating,omitempty"`
+	ESRBRating   *string                   `json:"esrbrating,omitempty"`
+	Descriptions []model.GameDescription   `json:"descriptions,omitempty"`
+	Media        []model.GameMedia         `json:"media,omitempty"`
+	Requirements []model.SystemRequirement `json:"requirements,omitempty"`
+	Languages    []model.GameLanguage      `json:"languages,omitempty"`
+}
+
+// gameStatusRequest is the optional body of publishing actions
+type gameStatusRequest struct {
+	Comment string `json:"comment"`
 }
 
 // registerGameRoutes mounts game endpoints to the provided group.
-// Public:
+// Public (published games only):
+//
+//	GET /games         -> list (pagination via ?limit=&cursor=&total=true)
+//	GET /games/search  -> full-text search with filters, sorting and facets
+//	GET /games/:id     -> get, with media, system requirements and languages
 //
-//	GET /games         -> list (pagination via ?limit=&offset=)
-//	GET /games/:id     -> get
+// Prices are returned in ?currency= if given, else in the caller's preferred currency.
+// ?locale= picks the localized description of a game when it has one.
 //
-// Protected (admin OR developer):
+// Protected (games:write:any, or games:write:own for games of developers whose team the caller is on):
 //
-//	POST /games        -> create
-//	PUT /games/:id     -> update
+//	POST /games        -> create (as a draft)
+//	PUT /games/:id     -> update (games:write:own: drafts and rejected games only)
 //	DELETE /games/:id  -> soft delete
+//	POST /games/:id/submit|withdraw|publish|delist|revise -> publishing workflow
+//	GET /games/:id/transitions -> publishing history (also games:review)
+//
+// Review (games:review):
+//
+//	GET  /admin/games?status=       -> games by status, submissions by default
+//	POST /admin/games/:id/approve|reject|relist  (reject needs a comment)
 func registerGameRoutes(g *echo.Group, gs *services.GameService) {
 	// public list
 	g.GET("/games", func(c echo.Context) error {
-		// If request includes Authorization header and it belongs to a developer,
-		// disallow this endpoint and instruct to use developer-only endpoint.
-		claims := middleware.TryGetClaimsFromAuthHeader(c)
-		if claims != nil && claims.Role == "developer" {
+		// If request includes Authorization header and it belongs to a developer (who can
+		// only write their own games), disallow this endpoint and instruct to use
+		// developer-only endpoint.
+		if middleware.HasPermission(c, model.PermGamesWriteOwn) && !middleware.HasPermission(c, model.PermGamesWriteAny) {
 			return c.JSON(http.StatusForbidden, map[string]string{"error": "developers must use /api/developer/games to view their games"})
 		}
 
-		limitStr := c.QueryParam("limit")
-		offsetStr := c.QueryParam("offset")
-		limit, _ := strconv.Atoi(limitStr)
-		offset, _ := strconv.Atoi(offsetStr)
-		list, err := gs.ListGames(c.Request().Context(), limit, offset)
+		p, err := pageParams(c)
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		list, err := gs.ListGames(c.Request().Context(), p, requestCurrency(c, gs.Pricing))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
 		return c.JSON(http.StatusOK, list)
 	})
 
+	// public search:
+	// ?q=&genre=1,2&developer=3&min_price=&max_price=&released_from=&released_to=
+	// &sort=relevance|price|release_date|popularity&order=asc|desc&limit=&cursor=&total=true
+	// (price bounds and the price sort use the prices shown, in the currency of the response)
+	g.GET("/games/search", func(c echo.Context) error {
+		p, err := bindGameSearch(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		pg, err := pageParams(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		res, err := gs.SearchGames(c.Request().Context(), p, pg, requestCurrency(c, gs.Pricing))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, res)
+	})
+
 	// public get
 	g.GET("/games/:id", func(c echo.Context) error {
 		idStr := c.Param("id")
@@ -66,9 +123,15 @@ func registerGameRoutes(g *echo.Group, gs *services.GameService) {
 		if err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
 		}
-		game, err := gs.GetGame(c.Request().Context(), id)
+		game, err := gs.GetGameDetails(c.Request().Context(), id, requestCurrency(c, gs.Pricing), c.QueryParam("locale"))
 		if err != nil {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "game not found"})
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		// unpublished games are only shown to their team and to reviewers
+		if game.Status != model.GameStatusPublished && !middleware.HasPermission(c, model.PermGamesReview) {
+			if status, _ := checkGameOwner(c, gs, id); status != 0 {
+				return c.JSON(http.StatusNotFound, map[string]string{"error": "game not found"})
+			}
 		}
 		return c.JSON(http.StatusOK, game)
 	})
@@ -81,21 +144,25 @@ func registerGameRoutes(g *echo.Group, gs *services.GameService) {
 		if claims == nil {
 			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthenticated"})
 		}
-		if claims.Role != "developer" {
-			return c.JSON(http.StatusForbidden, map[string]string{"error": "developer role required"})
+		if !middleware.HasPermission(c, model.PermGamesWriteOwn) {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "missing permission " + model.PermGamesWriteOwn})
 		}
-		// find developer by authid
-		dev, err := gs.DeveloperRepo.GetByAuthID(c.Request().Context(), claims.AuthID)
+		// games of every team the caller is on, or of one of them with ?developerid=
+		var developerID int64
+		if v := c.QueryParam("developerid"); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid developerid"})
+			}
+			developerID = id
+		}
+		p, err := pageParams(c)
 		if err != nil {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "developer record not found for this account"})
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
-		limitStr := c.QueryParam("limit")
-		offsetStr := c.QueryParam("offset")
-		limit, _ := strconv.Atoi(limitStr)
-		offset, _ := strconv.Atoi(offsetStr)
-		list, err := gs.ListGamesByDeveloper(c.Request().Context(), dev.DeveloperID, limit, offset)
+		list, err := gs.ListGamesForMember(c.Request().Context(), claims.AuthID, developerID, p)
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
 		return c.JSON(http.StatusOK, list)
 	})
@@ -104,7 +171,7 @@ func registerGameRoutes(g *echo.Group, gs *services.GameService) {
 	protected := g.Group("")
 	protected.Use(middleware.JWTMiddleware())
 
-	// create - admin or developer
+	// create
 	protected.POST("/games", func(c echo.Context) error {
 		claims := middleware.GetClaims(c)
 		if claims == nil {
@@ -124,26 +191,26 @@ func registerGameRoutes(g *echo.Group, gs *services.GameService) {
 			}
 			rd = &t
 		}
-		// authorization: admin can create any developerid; developer can only create games for their own developer record
-		if claims.Role == "developer" {
-			// ensure developer's authid matches the developer record
-			dev, err := gs.DeveloperRepo.GetByID(c.Request().Context(), req.DeveloperID)
-			if err != nil {
-				return c.JSON(http.StatusBadRequest, map[string]string{"error": "developer not found"})
-			}
-			if dev.AuthID == nil || *dev.AuthID != claims.AuthID {
-				return c.JSON(http.StatusForbidden, map[string]string{"error": "developers can only create games for their own developer record"})
-			}
-		} else if claims.Role != "admin" {
-			return c.JSON(http.StatusForbidden, map[string]string{"error": "only admin or developer roles can create games"})
+		// authorization: games:write:any can create for any developerid; games:write:own only
+		// for the caller's own developer record
+		if status, msg := checkDeveloperOwner(c, gs, req.DeveloperID); status != 0 {
+			return c.JSON(status, map[string]string{"error": msg})
 		}
 		game := &model.Game{
-			DeveloperID: req.DeveloperID,
-			Title:       req.Title,
-			Price:       req.Price,
-			ReleaseDate: rd,
+			DeveloperID:  req.DeveloperID,
+			Title:        req.Title,
+			Blurb:        req.Blurb,
+			Description:  req.Description,
+			Price:        req.Price,
+			ReleaseDate:  rd,
+			PEGIRating:   req.PEGIRating,
+			ESRBRating:   req.ESRBRating,
+			Descriptions: req.Descriptions,
+			Media:        req.Media,
+			Requirements: req.Requirements,
+			Languages:    req.Languages,
 		}
-		id, err := gs.CreateGame(c.Request().Context(), game)
+		id, err := gs.CreateGame(c.Request().Context(), game, claims.AuthID)
 		if err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
@@ -173,29 +240,32 @@ func registerGameRoutes(g *echo.Group, gs *services.GameService) {
 			}
 			rd = &t
 		}
-		// fetch existing game to check ownership
-		existing, err := gs.GetGame(c.Request().Context(), id)
-		if err != nil {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "game not found"})
+		// check ownership of the game, and of the developer record it is moved to
+		if status, msg := checkGameOwner(c, gs, id); status != 0 {
+			return c.JSON(status, map[string]string{"error": msg})
 		}
-		if claims.Role == "developer" {
-			// ensure the developer making the request owns the game
-			dev, err := gs.DeveloperRepo.GetByID(c.Request().Context(), existing.DeveloperID)
-			if err != nil {
-				return c.JSON(http.StatusBadRequest, map[string]string{"error": "developer not found"})
-			}
-			if dev.AuthID == nil || *dev.AuthID != claims.AuthID {
-				return c.JSON(http.StatusForbidden, map[string]string{"error": "developers can only update their own games"})
+		if status, msg := checkDeveloperOwner(c, gs, req.DeveloperID); status != 0 {
+			return c.JSON(status, map[string]string{"error": msg})
+		}
+		if !middleware.HasPermission(c, model.PermGamesWriteAny) {
+			if err := gs.EnsureEditable(c.Request().Context(), id); err != nil {
+				return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
 			}
-		} else if claims.Role != "admin" {
-			return c.JSON(http.StatusForbidden, map[string]string{"error": "only admin or developer roles can update games"})
 		}
 		update := &model.Game{
-			GameID:      id,
-			DeveloperID: req.DeveloperID,
-			Title:       req.Title,
-			Price:       req.Price,
-			ReleaseDate: rd,
+			GameID:       id,
+			DeveloperID:  req.DeveloperID,
+			Title:        req.Title,
+			Blurb:        req.Blurb,
+			Description:  req.Description,
+			Price:        req.Price,
+			ReleaseDate:  rd,
+			PEGIRating:   req.PEGIRating,
+			ESRBRating:   req.ESRBRating,
+			Descriptions: req.Descriptions,
+			Media:        req.Media,
+			Requirements: req.Requirements,
+			Languages:    req.Languages,
 		}
 		if err := gs.UpdateGame(c.Request().Context(), update); err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
@@ -214,24 +284,168 @@ func registerGameRoutes(g *echo.Group, gs *services.GameService) {
 		if err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
 		}
-		existing, err := gs.GetGame(c.Request().Context(), id)
+		if status, msg := checkGameOwner(c, gs, id); status != 0 {
+			return c.JSON(status, map[string]string{"error": msg})
+		}
+		if err := gs.DeleteGame(c.Request().Context(), id); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "deleted"})
+	})
+
+	// publishing actions of the team
+	for _, action := range []string{model.GameActionSubmit, model.GameActionWithdraw, model.GameActionPublish, model.GameActionDelist, model.GameActionRevise} {
+		protected.POST("/games/:id/"+action, gameStatusHandler(gs, action, true))
+	}
+
+	protected.GET("/games/:id/transitions", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "game not found"})
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
 		}
-		if claims.Role == "developer" {
-			dev, err := gs.DeveloperRepo.GetByID(c.Request().Context(), existing.DeveloperID)
-			if err != nil {
-				return c.JSON(http.StatusBadRequest, map[string]string{"error": "developer not found"})
+		if !middleware.HasPermission(c, model.PermGamesReview) {
+			if status, msg := checkGameOwner(c, gs, id); status != 0 {
+				return c.JSON(status, map[string]string{"error": msg})
 			}
-			if dev.AuthID == nil || *dev.AuthID != claims.AuthID {
-				return c.JSON(http.StatusForbidden, map[string]string{"error": "developers can only delete their own games"})
-			}
-		} else if claims.Role != "admin" {
-			return c.JSON(http.StatusForbidden, map[string]string{"error": "only admin or developer roles can delete games"})
 		}
-		if err := gs.DeleteGame(c.Request().Context(), id); err != nil {
+		list, err := gs.Transitions(c.Request().Context(), id)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, list)
+	})
+
+	review := g.Group("/admin/games")
+	review.Use(middleware.JWTMiddleware())
+	review.Use(middleware.RequirePermission(model.PermGamesReview))
+
+	review.GET("", func(c echo.Context) error {
+		p, err := pageParams(c)
+		if err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 		}
-		return c.JSON(http.StatusOK, map[string]string{"message": "deleted"})
+		list, err := gs.ListByStatus(c.Request().Context(), c.QueryParam("status"), p)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, list)
 	})
+
+	for _, action := range []string{model.GameActionApprove, model.GameActionReject, model.GameActionRelist} {
+		review.POST("/:id/"+action, gameStatusHandler(gs, action, false))
+	}
+}
+
+// gameStatusHandler applies a publishing action to the game :id. Team actions need the
+// caller to be allowed to edit the game; review routes are guarded by their group.
+func gameStatusHandler(gs *services.GameService, action string, team bool) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		if claims == nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthenticated"})
+		}
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		if team {
+			if status, msg := checkGameOwner(c, gs, id); status != 0 {
+				return c.JSON(status, map[string]string{"error": msg})
+			}
+		}
+		req := new(gameStatusRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		t, err := gs.ChangeStatus(c.Request().Context(), id, action, claims.AuthID, req.Comment)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, t)
+	}
+}
+
+// bindGameSearch reads the search parameters from the query string. Genre and developer
+// ids may be repeated or comma separated.
+func bindGameSearch(c echo.Context) (*model.GameSearch, error) {
+	p := &model.GameSearch{
+		Query: c.QueryParam("q"),
+		Sort:  c.QueryParam("sort"),
+		Order: c.QueryParam("order"),
+	}
+	var err error
+	if p.GenreIDs, err = queryIDs(c, "genre"); err != nil {
+		return nil, err
+	}
+	if p.DeveloperIDs, err = queryIDs(c, "developer"); err != nil {
+		return nil, err
+	}
+	if p.MinPrice, err = queryAmount(c, "min_price"); err != nil {
+		return nil, err
+	}
+	if p.MaxPrice, err = queryAmount(c, "max_price"); err != nil {
+		return nil, err
+	}
+	if p.ReleasedFrom, err = queryDate(c, "released_from"); err != nil {
+		return nil, err
+	}
+	if p.ReleasedTo, err = queryDate(c, "released_to"); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// queryIDs parses a repeated and/or comma separated list of ids
+func queryIDs(c echo.Context, name string) ([]int64, error) {
+	var ids []int64
+	for _, v := range c.QueryParams()[name] {
+		for _, part := range strings.Split(v, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			id, err := strconv.ParseInt(part, 10, 64)
+			if err != nil || id <= 0 {
+				return nil, errors.New("invalid " + name + " id " + part)
+			}
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func queryAmount(c echo.Context, name string) (*money.Amount, error) {
+	v := c.QueryParam(name)
+	if v == "" {
+		return nil, nil
+	}
+	a, err := money.Parse(v)
+	if err != nil {
+		return nil, errors.New("invalid " + name)
+	}
+	return &a, nil
+}
+
+func queryDate(c echo.Context, name string) (*time.Time, error) {
+	v := c.QueryParam(name)
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return nil, errors.New("invalid " + name + " (use YYYY-MM-DD)")
+	}
+	return &t, nil
+}
+
+// requestCurrency picks the currency prices should be shown in: the ?currency= query
+// parameter, else the authenticated customer's preference, else "" (base currency).
+func requestCurrency(c echo.Context, ps *services.PricingService) string {
+	if cur := c.QueryParam("currency"); cur != "" {
+		return cur
+	}
+	if claims := middleware.TryGetClaimsFromAuthHeader(c); claims != nil {
+		return ps.CustomerCurrency(c.Request().Context(), claims.AuthID)
+	}
+	return ""
 }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"GameStoreAPI/internal/repository"
+	"GameStoreAPI/internal/services"
+)
+
+func TestGetGameVisibility(t *testing.T) {
+	a := newTestAPI(t)
+	pool := a.pool
+	gameRepo := repository.NewGameRepository(pool)
+	pricing := services.NewPricingService(repository.NewPricingRepository(pool), gameRepo, repository.NewCustomerRepository(pool), "USD")
+	registerGameRoutes(a.api, services.NewGameService(gameRepo, repository.NewDeveloperRepository(pool), repository.NewDeveloperMemberRepository(pool), pricing))
+
+	devID := a.queryInt(t, `INSERT INTO developers (developername) VALUES ('Northwind') RETURNING developerid`)
+	game := func(status string, deleted bool) string {
+		id := a.queryInt(t, `INSERT INTO games (developerid, title, price, status, deleted_at)
+			VALUES ($1, 'Game', 10, $2, CASE WHEN $3 THEN now() END) RETURNING gameid`, devID, status, deleted)
+		return "/api/games/" + strconv.FormatInt(id, 10)
+	}
+	published, draft, deleted := game("published", false), game("draft", false), game("published", true)
+
+	memberID, member := a.account(t, "member", "developer", true)
+	a.queryInt(t, `INSERT INTO developermembers (developerid, authid, role) VALUES ($1, $2, 'member') RETURNING developerid`, devID, memberID)
+	_, outsider := a.account(t, "outsider", "developer", true)
+	_, reviewer := a.account(t, "reviewer", "admin", true)
+
+	tests := []struct {
+		name, path, token string
+		want              int
+	}{
+		{"published, anonymous", published, "", http.StatusOK},
+		{"draft, anonymous", draft, "", http.StatusNotFound},
+		{"draft, team member", draft, member, http.StatusOK},
+		{"draft, other developer", draft, outsider, http.StatusNotFound},
+		{"draft, reviewer", draft, reviewer, http.StatusOK},
+		{"deleted, anonymous", deleted, "", http.StatusNotFound},
+		{"deleted, team member", deleted, member, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if rec := a.do(http.MethodGet, tt.path, tt.token); rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d (%s)", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
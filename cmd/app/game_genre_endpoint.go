@@ -13,7 +13,7 @@ type gameGenreRequest struct {
 	GenreID int64 `json:"genreid"`
 }
 
-func registerGameGenreRoutes(g *echo.Group, gs *services.GameGenreService) {
+func registerGameGenreRoutes(g *echo.Group, gs *services.GameGenreService, games *services.GameService) {
 	// public get all genres of a game
 	g.GET("/games/:id/genres", func(c echo.Context) error {
 		idStr := c.Param("id")
@@ -28,25 +28,19 @@ func registerGameGenreRoutes(g *echo.Group, gs *services.GameGenreService) {
 		return c.JSON(200, list)
 	})
 
-	// protected routes for admin and developer
+	// protected routes for callers allowed to edit the game
 	p := g.Group("")
 	p.Use(middleware.JWTMiddleware())
 
 	p.POST("/games/:id/genres", func(c echo.Context) error {
-		// only admin or developer allowed
-		cl := middleware.GetClaims(c)
-		if cl == nil {
-			return c.JSON(401, map[string]string{"error": "unauthenticated"})
-		}
-		if cl.Role != "admin" && cl.Role != "developer" {
-			return c.JSON(403, map[string]string{"error": "forbidden"})
-		}
-
 		idStr := c.Param("id")
 		gameID, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
 			return c.JSON(400, map[string]string{"error": "invalid id"})
 		}
+		if status, msg := checkGameOwner(c, games, gameID); status != 0 {
+			return c.JSON(status, map[string]string{"error": msg})
+		}
 
 		req := new(gameGenreRequest)
 		if err := c.Bind(req); err != nil {
@@ -60,16 +54,11 @@ func registerGameGenreRoutes(g *echo.Group, gs *services.GameGenreService) {
 	})
 
 	p.DELETE("/games/:id/genres/:genreid", func(c echo.Context) error {
-		cl := middleware.GetClaims(c)
-		if cl == nil {
-			return c.JSON(401, map[string]string{"error": "unauthenticated"})
-		}
-		if cl.Role != "admin" && cl.Role != "developer" {
-			return c.JSON(403, map[string]string{"error": "forbidden"})
-		}
-
 		gameID, _ := strconv.ParseInt(c.Param("id"), 10, 64)
 		genreID, _ := strconv.ParseInt(c.Param("genreid"), 10, 64)
+		if status, msg := checkGameOwner(c, games, gameID); status != 0 {
+			return c.JSON(status, map[string]string{"error": msg})
+		}
 
 		if err := gs.Remove(c.Request().Context(), gameID, genreID); err != nil {
 			return c.JSON(400, map[string]string{"error": err.Error()})
@@ -4,6 +4,7 @@ import (
 	"strconv"
 
 	"GameStoreAPI/internal/middleware"
+	"GameStoreAPI/internal/model"
 	"GameStoreAPI/internal/services"
 
 	"github.com/labstack/echo/v4"
@@ -21,7 +22,11 @@ func registerGenreRoutes(g *echo.Group, gs *services.GenreService) {
 
 	// PUBLIC — list genres
 	g.GET("/genres", func(c echo.Context) error {
-		list, err := gs.List(c.Request().Context())
+		p, err := pageParams(c)
+		if err != nil {
+			return c.JSON(400, map[string]string{"error": err.Error()})
+		}
+		list, err := gs.List(c.Request().Context(), p)
 		if err != nil {
 			return c.JSON(500, map[string]string{"error": err.Error()})
 		}
@@ -44,7 +49,7 @@ func registerGenreRoutes(g *echo.Group, gs *services.GenreService) {
 	// PROTECTED — admin only write operations
 	admin := g.Group("/genres")
 	admin.Use(middleware.JWTMiddleware())
-	admin.Use(middleware.AdminOnly)
+	admin.Use(middleware.RequirePermission(model.PermGenresWrite))
 
 	// CREATE
 	admin.POST("", func(c echo.Context) error {
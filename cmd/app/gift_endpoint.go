@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"GameStoreAPI/internal/middleware"
+	"GameStoreAPI/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// registerGiftRoutes wires the gifts of the authenticated customer.
+//
+//	GET  /customers/me/gifts              -> gifts received (?status=pending|accepted|declined|revoked)
+//	GET  /customers/me/gifts/sent         -> gifts bought for others
+//	POST /customers/me/gifts/:id/accept   -> accept a pending gift (the game is added to the library)
+//	POST /customers/me/gifts/:id/decline  -> decline a pending gift (the sender is refunded)
+func registerGiftRoutes(g *echo.Group, gs *services.GiftService, cs *services.CustomerService) {
+	usr := g.Group("/customers/me/gifts")
+	usr.Use(middleware.JWTMiddleware(), middleware.RequireSession())
+
+	usr.GET("", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		cust, err := cs.GetByAuthID(c.Request().Context(), claims.AuthID)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "customer not found"})
+		}
+		p, err := pageParams(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		list, err := gs.ListReceived(c.Request().Context(), cust.CustomerID, c.QueryParam("status"), p)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, list)
+	})
+
+	usr.GET("/sent", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		cust, err := cs.GetByAuthID(c.Request().Context(), claims.AuthID)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "customer not found"})
+		}
+		p, err := pageParams(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		list, err := gs.ListSent(c.Request().Context(), cust.CustomerID, p)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, list)
+	})
+
+	usr.POST("/:id/accept", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		cust, err := cs.GetByAuthID(c.Request().Context(), claims.AuthID)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "customer not found"})
+		}
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid gift id"})
+		}
+		gift, err := gs.Accept(c.Request().Context(), cust.CustomerID, id)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, gift)
+	})
+
+	usr.POST("/:id/decline", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		cust, err := cs.GetByAuthID(c.Request().Context(), claims.AuthID)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "customer not found"})
+		}
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid gift id"})
+		}
+		gift, err := gs.Decline(c.Request().Context(), claims.AuthID, cust.CustomerID, id)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, gift)
+	})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+
+	"GameStoreAPI/internal/middleware"
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// registerLockoutRoutes wires the admin view of login lockouts and the audit log.
+//
+//	GET    /admin/lockouts                      -> lockouts:manage
+//	DELETE /admin/lockouts?kind=account&key=... -> lockouts:manage (kind: account, ip or register)
+//	GET    /admin/audit?event=                  -> audit:read
+func registerLockoutRoutes(g *echo.Group, ls *services.LockoutService, as *services.AuditService) {
+	admin := g.Group("/admin")
+	admin.Use(middleware.JWTMiddleware())
+
+	manage := middleware.RequirePermission(model.PermLockoutsManage)
+
+	admin.GET("/lockouts", func(c echo.Context) error {
+		list, err := ls.ListLocked(c.Request().Context())
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, list)
+	}, manage)
+
+	admin.DELETE("/lockouts", func(c echo.Context) error {
+		kind, key := c.QueryParam("kind"), c.QueryParam("key")
+		if kind == "" || key == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "kind and key are required"})
+		}
+		claims := middleware.GetClaims(c)
+		if err := ls.Clear(c.Request().Context(), claims.AuthID, kind, key, c.RealIP()); err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "lockout cleared"})
+	}, manage)
+
+	admin.GET("/audit", func(c echo.Context) error {
+		p, err := pageParams(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		list, err := as.List(c.Request().Context(), c.QueryParam("event"), p)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, list)
+	}, middleware.RequirePermission(model.PermAuditRead))
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -9,10 +10,16 @@ import (
 	"strings"
 	"time"
 
+	"GameStoreAPI/internal/blob"
 	"GameStoreAPI/internal/db"
+	"GameStoreAPI/internal/mailer"
 	"GameStoreAPI/internal/middleware"
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/oidc"
+	"GameStoreAPI/internal/payment"
 	"GameStoreAPI/internal/repository"
 	"GameStoreAPI/internal/services"
+	"GameStoreAPI/internal/tax"
 
 	"github.com/labstack/echo/v4"
 	echomw "github.com/labstack/echo/v4/middleware"
@@ -32,9 +39,18 @@ func main() {
 		log.Fatalf("cannot ping db: %v", err)
 	}
 
+	// JWT signing keys: refuse to start without one rather than fall back to an insecure default
+	keySet, err := middleware.LoadKeySetFromEnv()
+	if err != nil {
+		log.Fatalf("jwt keys: %v", err)
+	}
+	middleware.SetKeySet(keySet)
+
 	// repositories
 	authRepo := repository.NewAuthRepository(pool)
 	devRepo := repository.NewDeveloperRepository(pool)
+	devMemberRepo := repository.NewDeveloperMemberRepository(pool)
+	devApplicationRepo := repository.NewDeveloperApplicationRepository(pool)
 	gameRepo := repository.NewGameRepository(pool)
 	genreRepo := repository.NewGenreRepository(pool)
 	gameGenreRepo := repository.NewGameGenreRepository(pool)
@@ -42,48 +58,157 @@ func main() {
 	customerRepo := repository.NewCustomerRepository(pool)
 	orderRepo := repository.NewOrderRepository(pool)
 	customerGamesRepo := repository.NewCustomerGamesRepository(pool)
+	paymentRepo := repository.NewPaymentRepository(pool)
+	refundRepo := repository.NewRefundRepository(pool)
+	saleRepo := repository.NewSaleRepository(pool)
+	pricingRepo := repository.NewPricingRepository(pool)
+	promoRepo := repository.NewPromoRepository(pool)
+	giftRepo := repository.NewGiftRepository(pool)
+	sessionRepo := repository.NewSessionRepository(pool)
+	roleRepo := repository.NewRoleRepository(pool)
+	authTokenRepo := repository.NewAuthTokenRepository(pool)
+	twoFactorRepo := repository.NewTwoFactorRepository(pool)
+	authAttemptRepo := repository.NewAuthAttemptRepository(pool)
+	auditRepo := repository.NewAuditRepository(pool)
+	apiKeyRepo := repository.NewAPIKeyRepository(pool)
+	identityRepo := repository.NewIdentityRepository(pool)
+	mediaAssetRepo := repository.NewMediaAssetRepository(pool)
+
+	// payment gateway: PAYMENT_PROVIDER must be set, the store never falls back to the fake
+	gateway, err := newPaymentGateway()
+	if err != nil {
+		log.Fatalf("payment gateway: %v", err)
+	}
+
+	// outgoing email (written to MAIL_DIR when set, otherwise kept in memory)
+	mail, err := newMailer()
+	if err != nil {
+		log.Fatalf("mailer: %v", err)
+	}
+
+	// tax rates (built-in table unless TAX_RATES_FILE points to a JSON rate table)
+	taxCalc, err := taxCalculator()
+	if err != nil {
+		log.Fatalf("tax rates: %v", err)
+	}
+
+	// external identity providers (OIDC_PROVIDERS / OIDC_PROVIDERS_FILE; none by default)
+	oidcConfigs, err := oidc.LoadConfigsFromEnv()
+	if err != nil {
+		log.Fatalf("oidc providers: %v", err)
+	}
+	oidcProviders, err := oidc.NewRegistry(oidcConfigs)
+	if err != nil {
+		log.Fatalf("oidc providers: %v", err)
+	}
+
+	// uploaded game media (MEDIA_STORE=s3 for an S3-compatible bucket, else files in MEDIA_DIR)
+	blobStore, err := newBlobStore()
+	if err != nil {
+		log.Fatalf("media store: %v", err)
+	}
 
 	// services
-	authSvc := services.NewAuthService(authRepo, customerRepo)
-	devSvc := services.NewDeveloperService(devRepo)
-	gameSvc := services.NewGameService(gameRepo, devRepo)
+	lockoutSvc := services.NewLockoutService(authAttemptRepo, auditRepo, services.DefaultLockoutPolicy)
+	auditSvc := services.NewAuditService(auditRepo)
+	accountSvc := services.NewAccountService(authRepo, customerRepo, sessionRepo, twoFactorRepo, authTokenRepo, authAttemptRepo, cartRepo, customerGamesRepo, identityRepo, apiKeyRepo, auditRepo)
+	authSvc := services.NewAuthService(authRepo, customerRepo, authTokenRepo, sessionRepo, mail, lockoutSvc, os.Getenv("APP_BASE_URL"))
+	sessionSvc := services.NewSessionService(sessionRepo, authRepo, accessTokenTTL(), refreshTokenTTL())
+	devSvc := services.NewDeveloperService(devRepo, devMemberRepo, devApplicationRepo, authRepo, roleRepo, auditRepo)
+	pricingSvc := services.NewPricingService(pricingRepo, gameRepo, customerRepo, os.Getenv("BASE_CURRENCY"))
+	gameSvc := services.NewGameService(gameRepo, devRepo, devMemberRepo, pricingSvc)
+	promoSvc := services.NewPromoService(promoRepo, pricingSvc)
+	saleSvc := services.NewSaleService(saleRepo, gameRepo)
 	genreSvc := services.NewGenreService(genreRepo)
 	gameGenreSvc := services.NewGameGenreService(gameGenreRepo, gameRepo, genreRepo)
-	cartSvc := services.NewCartService(cartRepo, orderRepo, customerGamesRepo, authRepo, customerRepo)
-	customerSvc := services.NewCustomerService(customerRepo, authRepo)
-	customerGameSvc := services.NewCustomerGamesService(customerGamesRepo, cartRepo)
+	paymentSvc := services.NewPaymentService(paymentRepo, orderRepo, customerGamesRepo, refundRepo, promoRepo, giftRepo, gateway)
+	refundSvc := services.NewRefundService(refundRepo, orderRepo, paymentRepo, customerGamesRepo, paymentSvc, refundWindow())
+	cartSvc := services.NewCartService(cartRepo, orderRepo, customerGamesRepo, authRepo, customerRepo, paymentRepo, paymentSvc, pricingSvc, promoSvc, taxCalc, giftRepo)
+	customerSvc := services.NewCustomerService(customerRepo, authRepo, pricingSvc)
+	customerGameSvc := services.NewCustomerGamesService(customerGamesRepo, cartRepo, paymentRepo)
+	giftSvc := services.NewGiftService(giftRepo, customerGamesRepo, paymentRepo, paymentSvc)
+	roleSvc := services.NewRoleService(roleRepo)
+	apiKeySvc := services.NewAPIKeyService(apiKeyRepo, roleRepo, auditRepo)
+	oidcSvc := services.NewOIDCService(oidcProviders, identityRepo, authRepo, authSvc, auditRepo)
+	mediaSvc := services.NewMediaService(gameRepo, mediaAssetRepo, blobStore)
+	twoFactorSvc := services.NewTwoFactorService(twoFactorRepo, authRepo, roleRepo, authTokenRepo, sessionRepo, lockoutSvc, os.Getenv("TOTP_ISSUER"))
 
 	// Echo
 	e := echo.New()
+	// client IPs key the login throttling: only trust X-Forwarded-For behind a known proxy
+	if os.Getenv("TRUST_PROXY") == "true" {
+		e.IPExtractor = echo.ExtractIPFromXFFHeader()
+	} else {
+		e.IPExtractor = echo.ExtractIPDirect()
+	}
 	e.Use(echomw.Logger())
 	e.Use(echomw.Recover())
 
+	// uploads kept on the local filesystem are served by the API itself
+	if local, ok := blobStore.(*blob.LocalStore); ok {
+		e.Static("/media", local.Dir)
+	}
+
+	// public keys for services verifying our tokens
+	e.GET("/.well-known/jwks.json", jwksHandler(keySet))
+
 	api := e.Group("/api")
 
+	// every authenticated request checks that its session is still active
+	middleware.SetSessionValidator(sessionSvc.Validate)
+	// permission checks resolve the caller's role against rolepermissions
+	middleware.SetPermissionResolver(roleSvc.Permissions)
+	// developers' API keys are accepted wherever access tokens are, within their scopes
+	middleware.SetAPIKeyAuthenticator(apiKeySvc.Authenticate)
+
 	// ======================
 	// AUTH ENDPOINTS
 	// ======================
 	api.POST("/auth/register", registerPublic(authSvc))
-	api.POST("/auth/login", loginHandler(authSvc))
+	api.POST("/auth/login", loginHandler(authSvc, sessionSvc, twoFactorSvc))
+	api.POST("/auth/login/2fa", loginTwoFactorHandler(sessionSvc, twoFactorSvc))
+	api.POST("/auth/refresh", refreshHandler(sessionSvc))
+	api.POST("/auth/email/verify", verifyEmailHandler(authSvc))
+	api.POST("/auth/password/forgot", forgotPasswordHandler(authSvc))
+	api.POST("/auth/password/reset", resetPasswordHandler(authSvc))
 
 	authGroup := api.Group("/auth")
-	authGroup.Use(middleware.JWTMiddleware())
+	// account self-service needs a signed-in user, API keys are refused
+	authGroup.Use(middleware.JWTMiddleware(), middleware.RequireSession())
 	authGroup.GET("/me", meHandler())
+	authGroup.POST("/logout", logoutHandler(sessionSvc))
+	authGroup.GET("/sessions", sessionsHandler(sessionSvc))
+	authGroup.POST("/email/resend", resendVerificationHandler(authSvc))
+	authGroup.PUT("/password", changePasswordHandler(authSvc))
 
-	registerCustomerRoutes(api, customerSvc)
+	registerCustomerRoutes(api, customerSvc, accountSvc)
 	registerDeveloperRoutes(api, devSvc)
+	registerDeveloperTeamRoutes(api, devSvc)
 	registerGameRoutes(api, gameSvc)
+	registerMediaRoutes(api, mediaSvc, gameSvc)
+	registerPricingRoutes(api, pricingSvc, gameSvc)
+	registerPromoRoutes(api, promoSvc)
+	registerSaleRoutes(api, saleSvc, gameSvc)
 	registerGenreRoutes(api, genreSvc)
-	registerGameGenreRoutes(api, gameGenreSvc)
+	registerGameGenreRoutes(api, gameGenreSvc, gameSvc)
 	registerCartRoutes(api, cartSvc)
 	registerCustomerGamesRoutes(api, customerGameSvc, customerSvc)
+	registerPaymentRoutes(api, paymentSvc, customerSvc)
+	registerRefundRoutes(api, refundSvc, customerSvc)
+	registerGiftRoutes(api, giftSvc, customerSvc)
+	registerRoleRoutes(api, roleSvc)
+	registerTwoFactorRoutes(api, twoFactorSvc)
+	registerLockoutRoutes(api, lockoutSvc, auditSvc)
+	registerOIDCRoutes(api, oidcSvc, sessionSvc, twoFactorSvc)
+	registerAPIKeyRoutes(api, apiKeySvc)
 
 	adminGroup := api.Group("/admin")
 	adminGroup.Use(middleware.JWTMiddleware())
-	adminGroup.Use(middleware.AdminOnly)
 
 	// Admin can register admin/dev accounts
-	adminGroup.POST("/auth/register", adminRegister(authSvc))
+	adminGroup.POST("/auth/register", adminRegister(authSvc), middleware.RequirePermission(model.PermAccountsCreate))
+
+	manageDevelopers := middleware.RequirePermission(model.PermDevelopersManage)
 
 	// Admin CRUD developer
 	adminGroup.POST("/developers", func(c echo.Context) error {
@@ -99,7 +224,7 @@ func main() {
 			return c.JSON(400, map[string]string{"error": err.Error()})
 		}
 		return c.JSON(201, map[string]interface{}{"developerid": id})
-	})
+	}, manageDevelopers)
 
 	adminGroup.PUT("/developers/:id", func(c echo.Context) error {
 		idStr := c.Param("id")
@@ -129,7 +254,7 @@ func main() {
 		}
 
 		return c.JSON(200, map[string]string{"message": "updated"})
-	})
+	}, manageDevelopers)
 
 	adminGroup.DELETE("/developers/:id", func(c echo.Context) error {
 		idStr := c.Param("id")
@@ -141,7 +266,7 @@ func main() {
 			return c.JSON(400, map[string]string{"error": err.Error()})
 		}
 		return c.JSON(200, map[string]string{"message": "deleted"})
-	})
+	}, manageDevelopers)
 
 	// ======================
 	// START SERVER
@@ -159,3 +284,90 @@ func main() {
 
 	e.Logger.Fatal(e.Start(":" + port))
 }
+
+// refundWindow reads REFUND_WINDOW_DAYS (default 14)
+func refundWindow() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("REFUND_WINDOW_DAYS"))
+	if err != nil || days <= 0 {
+		return services.DefaultRefundWindow
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// accessTokenTTL reads ACCESS_TOKEN_TTL_MINUTES (default 15)
+func accessTokenTTL() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("ACCESS_TOKEN_TTL_MINUTES"))
+	if err != nil || minutes <= 0 {
+		return services.DefaultAccessTokenTTL
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// refreshTokenTTL reads REFRESH_TOKEN_TTL_DAYS (default 30)
+func refreshTokenTTL() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("REFRESH_TOKEN_TTL_DAYS"))
+	if err != nil || days <= 0 {
+		return services.DefaultRefreshTokenTTL
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// taxCalculator loads the tax table from TAX_RATES_FILE, or uses the built-in rates
+func taxCalculator() (tax.Calculator, error) {
+	if path := os.Getenv("TAX_RATES_FILE"); path != "" {
+		return tax.LoadTableCalculator(path)
+	}
+	return tax.NewTableCalculator(tax.DefaultRates)
+}
+
+// newPaymentGateway returns the gateway named by PAYMENT_PROVIDER. Only "fake" (the
+// in-process FakeGateway for local runs, it takes no real money) exists so far; it is
+// never picked implicitly and needs PAYMENT_WEBHOOK_SECRET for its webhooks.
+func newPaymentGateway() (payment.PaymentGateway, error) {
+	switch provider := os.Getenv("PAYMENT_PROVIDER"); provider {
+	case "fake":
+		secret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+		if secret == "" {
+			return nil, errors.New("PAYMENT_WEBHOOK_SECRET is required")
+		}
+		return payment.NewFakeGateway(payment.DefaultFakeConfig([]byte(secret))), nil
+	case "":
+		return nil, errors.New("PAYMENT_PROVIDER is not set (supported: fake)")
+	default:
+		return nil, fmt.Errorf("unsupported PAYMENT_PROVIDER %q (supported: fake)", provider)
+	}
+}
+
+// newMailer writes emails as files into MAIL_DIR, or keeps them in memory when unset
+func newMailer() (mailer.Mailer, error) {
+	if dir := os.Getenv("MAIL_DIR"); dir != "" {
+		return mailer.NewFileMailer(dir, os.Getenv("MAIL_FROM"))
+	}
+	return mailer.NewMemoryMailer(), nil
+}
+
+// newBlobStore stores uploads in the S3-compatible bucket configured by S3_ENDPOINT,
+// S3_REGION, S3_BUCKET, S3_ACCESS_KEY, S3_SECRET_KEY and S3_PUBLIC_URL when MEDIA_STORE=s3,
+// otherwise as files in MEDIA_DIR (default "media") served at /media, or at MEDIA_BASE_URL
+// when a CDN fronts them
+func newBlobStore() (blob.BlobStore, error) {
+	if os.Getenv("MEDIA_STORE") == "s3" {
+		return blob.NewS3Store(blob.S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			PublicURL: os.Getenv("S3_PUBLIC_URL"),
+		})
+	}
+	dir := os.Getenv("MEDIA_DIR")
+	if dir == "" {
+		dir = "media"
+	}
+	baseURL := os.Getenv("MEDIA_BASE_URL")
+	if baseURL == "" {
+		baseURL = "/media"
+	}
+	return blob.NewLocalStore(dir, baseURL)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"GameStoreAPI/internal/db/dbtest"
+	"GameStoreAPI/internal/middleware"
+	"GameStoreAPI/internal/repository"
+	"GameStoreAPI/internal/services"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/echo/v4"
+)
+
+// testAPI is an echo server with a database of its own; routes are registered by each test
+type testAPI struct {
+	*echo.Echo
+	pool *pgxpool.Pool
+	api  *echo.Group
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	pool := dbtest.New(t)
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks := middleware.NewKeySet()
+	if err := ks.AddPEM("test", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.SetSigningKey("test"); err != nil {
+		t.Fatal(err)
+	}
+	middleware.SetKeySet(ks)
+	middleware.SetPermissionResolver(services.NewRoleService(repository.NewRoleRepository(pool)).Permissions)
+	t.Cleanup(func() { middleware.SetPermissionResolver(nil) })
+
+	e := echo.New()
+	return &testAPI{Echo: e, pool: pool, api: e.Group("/api")}
+}
+
+func (a *testAPI) queryInt(t *testing.T, sql string, args ...interface{}) int64 {
+	t.Helper()
+	var n int64
+	if err := a.pool.QueryRow(context.Background(), sql, args...).Scan(&n); err != nil {
+		t.Fatalf("query %q: %v", sql, err)
+	}
+	return n
+}
+
+// account creates a verified account with the role; enrolled accounts have two-factor
+// authentication enabled. It returns the authid and an access token.
+func (a *testAPI) account(t *testing.T, name, role string, enrolled bool) (int64, string) {
+	t.Helper()
+	email := name + "@example.com"
+	authID := a.queryInt(t, `INSERT INTO userauth (email, passwordhash, role, email_verified_at)
+		VALUES ($1, 'x', $2, now()) RETURNING authid`, email, role)
+	if enrolled {
+		a.queryInt(t, `INSERT INTO twofactor (authid, secret, enabled_at) VALUES ($1, 'JBSWY3DPEHPK3PXP', now()) RETURNING authid`, authID)
+	}
+	token, err := middleware.GenerateToken(authID, email, role, "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authID, token
+}
+
+// do sends a request with the token (none when empty) and returns the recorded response
+func (a *testAPI) do(method, path, token string) *httptest.ResponseRecorder {
+	return a.send(method, path, token, nil)
+}
+
+// send is do with a JSON body (none when nil)
+func (a *testAPI) send(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	var r io.Reader
+	if body != nil {
+		b, _ := json.Marshal(body)
+		r = bytes.NewReader(b)
+	}
+	req := httptest.NewRequest(method, path, r)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestNewPaymentGateway(t *testing.T) {
+	tests := []struct {
+		provider, secret string
+		ok               bool
+	}{
+		{"", "whsec", false},
+		{"stripe", "whsec", false},
+		{"fake", "", false},
+		{"fake", "whsec", true},
+	}
+	for _, tt := range tests {
+		t.Setenv("PAYMENT_PROVIDER", tt.provider)
+		t.Setenv("PAYMENT_WEBHOOK_SECRET", tt.secret)
+		if _, err := newPaymentGateway(); (err == nil) != tt.ok {
+			t.Errorf("PAYMENT_PROVIDER=%q secret=%q: err = %v", tt.provider, tt.secret, err)
+		}
+	}
+}
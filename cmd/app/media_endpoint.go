@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"GameStoreAPI/internal/media"
+	"GameStoreAPI/internal/middleware"
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/services"
+
+	"github.com/labstack/echo/v4"
+	echomw "github.com/labstack/echo/v4/middleware"
+)
+
+// registerMediaRoutes wires image uploads for game pages (games:write:any, or
+// games:write:own for games of developers whose team the caller is on; like updates,
+// games:write:own only for drafts and rejected games):
+//
+//	POST /games/:id/media  -> multipart form with file (JPEG, PNG or GIF, at most 10 MB) and kind=cover|screenshot
+//
+// Uploads are appended to the media of the game, a cover replaces the previous one. The
+// response carries the image URL, its thumbnail URL and its dimensions.
+func registerMediaRoutes(g *echo.Group, ms *services.MediaService, gs *services.GameService) {
+	protected := g.Group("")
+	protected.Use(middleware.JWTMiddleware())
+
+	// the multipart envelope adds a little to the file itself
+	bodyLimit := echomw.BodyLimit(strconv.Itoa(media.MaxUploadBytes>>20+1) + "M")
+
+	protected.POST("/games/:id/media", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		if claims == nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthenticated"})
+		}
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		if status, msg := checkGameOwner(c, gs, id); status != 0 {
+			return c.JSON(status, map[string]string{"error": msg})
+		}
+		if !middleware.HasPermission(c, model.PermGamesWriteAny) {
+			if err := gs.EnsureEditable(c.Request().Context(), id); err != nil {
+				return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+			}
+		}
+
+		fh, err := c.FormFile("file")
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "file is required"})
+		}
+		if fh.Size > media.MaxUploadBytes {
+			return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": media.ErrTooLarge.Error()})
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid file"})
+		}
+		defer f.Close()
+		data, err := io.ReadAll(io.LimitReader(f, media.MaxUploadBytes+1))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid file"})
+		}
+
+		m, err := ms.Upload(c.Request().Context(), id, c.FormValue("kind"), data, claims.AuthID)
+		switch {
+		case errors.Is(err, media.ErrTooLarge):
+			return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": err.Error()})
+		case errors.Is(err, media.ErrUnsupported):
+			return c.JSON(http.StatusUnsupportedMediaType, map[string]string{"error": err.Error()})
+		case err != nil:
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusCreated, m)
+	}, bodyLimit)
+}
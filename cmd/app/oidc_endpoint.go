@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"GameStoreAPI/internal/middleware"
+	"GameStoreAPI/internal/oidc"
+	"GameStoreAPI/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// registerOIDCRoutes wires sign-in with external OpenID Connect providers and the linking
+// of providers to the signed-in account.
+//
+//	GET    /auth/oidc/providers            -> configured provider names
+//	GET    /auth/oidc/:provider/start      -> redirect to the provider
+//	GET    /auth/oidc/:provider/callback   -> tokens (or a 2FA challenge), or the linked account
+//	GET    /auth/identities                -> providers linked to the account
+//	POST   /auth/identities/:provider      -> authorization URL that links the provider
+//	DELETE /auth/identities/:provider      -> unlink
+func registerOIDCRoutes(g *echo.Group, ids *services.OIDCService, sessSvc *services.SessionService, tfSvc *services.TwoFactorService) {
+	g.GET("/auth/oidc/providers", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string][]string{"providers": ids.ProviderNames()})
+	})
+
+	g.GET("/auth/oidc/:provider/start", func(c echo.Context) error {
+		url, err := ids.Start(c.Request().Context(), c.Param("provider"), nil)
+		if errors.Is(err, oidc.ErrUnknownProvider) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		if err != nil {
+			return c.JSON(http.StatusBadGateway, map[string]string{"error": err.Error()})
+		}
+		return c.Redirect(http.StatusFound, url)
+	})
+
+	g.GET("/auth/oidc/:provider/callback", func(c echo.Context) error {
+		if e := c.QueryParam("error"); e != "" {
+			msg := e
+			if desc := c.QueryParam("error_description"); desc != "" {
+				msg += ": " + desc
+			}
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "sign-in cancelled by the provider (" + msg + ")"})
+		}
+		res, err := ids.Callback(c.Request().Context(), c.Param("provider"), c.QueryParam("code"), c.QueryParam("state"), c.RealIP())
+		if locked, herr := tooManyAttempts(c, err); locked {
+			return herr
+		}
+		switch {
+		case errors.Is(err, oidc.ErrUnknownProvider):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		case errors.Is(err, services.ErrIdentityConflict):
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		case err != nil:
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+		}
+		if res.Linked {
+			return c.JSON(http.StatusOK, map[string]interface{}{"message": "provider linked", "authid": res.User.AuthID})
+		}
+		return signIn(c, sessSvc, tfSvc, res.User)
+	})
+
+	me := g.Group("/auth/identities")
+	me.Use(middleware.JWTMiddleware(), middleware.RequireSession())
+
+	me.GET("", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		list, err := ids.List(c.Request().Context(), claims.AuthID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, list)
+	})
+
+	me.POST("/:provider", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		url, err := ids.Start(c.Request().Context(), c.Param("provider"), &claims.AuthID)
+		if errors.Is(err, oidc.ErrUnknownProvider) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		if err != nil {
+			return c.JSON(http.StatusBadGateway, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"authorization_url": url})
+	})
+
+	me.DELETE("/:provider", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		if err := ids.Unlink(c.Request().Context(), claims.AuthID, c.Param("provider"), c.RealIP()); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "provider unlinked"})
+	})
+}
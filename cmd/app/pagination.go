@@ -0,0 +1,12 @@
+package main
+
+import (
+	"GameStoreAPI/internal/pagination"
+
+	"github.com/labstack/echo/v4"
+)
+
+// pageParams reads the ?limit=&cursor=&total=true query parameters of list endpoints
+func pageParams(c echo.Context) (pagination.Params, error) {
+	return pagination.Parse(c.QueryParam("limit"), c.QueryParam("cursor"), c.QueryParam("total"))
+}
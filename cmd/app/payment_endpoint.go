@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"GameStoreAPI/internal/middleware"
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/payment"
+	"GameStoreAPI/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+type payOrderRequest struct {
+	PaymentMethodID *int64 `json:"paymentmethodid,omitempty"`
+	CardToken       string `json:"cardtoken"`
+}
+
+type createPaymentMethodRequest struct {
+	Name string `json:"name"`
+}
+
+// registerPaymentRoutes wires payment endpoints.
+// Customer:
+//
+//	GET  /payment-methods                 -> list available methods
+//	GET  /customers/me/payments           -> all my payments
+//	GET  /customers/me/orders/:id/payments -> payment attempts of one order
+//	POST /customers/me/orders/:id/pay     -> pay a checked-out order
+//
+// Provider:
+//
+//	POST /payments/webhook                -> signed gateway notifications
+//
+// Admin:
+//
+//	GET  /admin/payment-methods, POST /admin/payment-methods
+//	GET  /admin/payments?status=, GET /admin/payments/:id
+//	POST /admin/payments/:id/fail
+func registerPaymentRoutes(g *echo.Group, ps *services.PaymentService, cs *services.CustomerService) {
+	methods := g.Group("/payment-methods")
+	methods.Use(middleware.JWTMiddleware())
+	methods.GET("", func(c echo.Context) error {
+		list, err := ps.ListMethods(c.Request().Context())
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, list)
+	})
+
+	// provider webhook: authenticated by signature, not JWT
+	g.POST("/payments/webhook", func(c echo.Context) error {
+		body, err := io.ReadAll(io.LimitReader(c.Request().Body, 1<<20))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		if err := ps.HandleWebhook(c.Request().Context(), body, c.Request().Header.Get("X-Webhook-Signature")); err != nil {
+			if errors.Is(err, payment.ErrInvalidSignature) {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+			}
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "ok"})
+	})
+
+	usr := g.Group("/customers/me")
+	usr.Use(middleware.JWTMiddleware(), middleware.RequireSession())
+
+	// GET /api/customers/me/payments
+	usr.GET("/payments", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		if claims == nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthenticated"})
+		}
+		cust, err := cs.GetByAuthID(c.Request().Context(), claims.AuthID)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "customer not found"})
+		}
+		p, err := pageParams(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		list, err := ps.ListForCustomer(c.Request().Context(), cust.CustomerID, p)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, list)
+	})
+
+	// GET /api/customers/me/orders/:id/payments
+	usr.GET("/orders/:id/payments", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		if claims == nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthenticated"})
+		}
+		cust, err := cs.GetByAuthID(c.Request().Context(), claims.AuthID)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "customer not found"})
+		}
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid order id"})
+		}
+		list, err := ps.ListForOrder(c.Request().Context(), cust.CustomerID, id)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, list)
+	})
+
+	// POST /api/customers/me/orders/:id/pay
+	usr.POST("/orders/:id/pay", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		if claims == nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthenticated"})
+		}
+		cust, err := cs.GetByAuthID(c.Request().Context(), claims.AuthID)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "customer not found"})
+		}
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid order id"})
+		}
+		req := new(payOrderRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		p, err := ps.Pay(c.Request().Context(), cust.CustomerID, id, req.PaymentMethodID, req.CardToken)
+		if errors.Is(err, services.ErrPaymentInProgress) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, p)
+	})
+
+	admin := g.Group("/admin")
+	admin.Use(middleware.JWTMiddleware())
+	admin.Use(middleware.RequirePermission(model.PermPaymentsManage))
+
+	admin.GET("/payment-methods", func(c echo.Context) error {
+		list, err := ps.ListMethods(c.Request().Context())
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, list)
+	})
+
+	admin.POST("/payment-methods", func(c echo.Context) error {
+		req := new(createPaymentMethodRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		id, err := ps.CreateMethod(c.Request().Context(), req.Name)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusCreated, map[string]interface{}{"paymentmethodid": id})
+	})
+
+	admin.GET("/payments", func(c echo.Context) error {
+		p, err := pageParams(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		list, err := ps.ListAll(c.Request().Context(), c.QueryParam("status"), p)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, list)
+	})
+
+	admin.GET("/payments/:id", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		p, logs, err := ps.GetWithLogs(c.Request().Context(), id)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"payment": p,
+			"logs":    logs,
+		})
+	})
+
+	admin.POST("/payments/:id/fail", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		if err := ps.MarkFailed(c.Request().Context(), id); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "payment failed"})
+	})
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"GameStoreAPI/internal/middleware"
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/money"
+	"GameStoreAPI/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+type setGamePriceRequest struct {
+	Amount money.Amount `json:"amount"`
+}
+
+type setExchangeRateRequest struct {
+	Rate json.Number `json:"rate"`
+}
+
+// registerPricingRoutes wires regional prices and exchange rates.
+// Public:
+//
+//	GET /games/:id/prices   -> explicit regional prices of a game
+//	GET /exchange-rates     -> configured rates against the base currency
+//
+// Protected (admin OR owning developer):
+//
+//	PUT    /games/:id/prices/:currency
+//	DELETE /games/:id/prices/:currency
+//
+// Admin:
+//
+//	PUT    /admin/exchange-rates/:currency
+//	DELETE /admin/exchange-rates/:currency
+func registerPricingRoutes(g *echo.Group, ps *services.PricingService, gs *services.GameService) {
+	g.GET("/games/:id/prices", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		list, err := ps.ListGamePrices(c.Request().Context(), id)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"base_currency": ps.BaseCurrency,
+			"prices":        list,
+		})
+	})
+
+	g.GET("/exchange-rates", func(c echo.Context) error {
+		list, err := ps.ListRates(c.Request().Context())
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"base_currency": ps.BaseCurrency,
+			"rates":         list,
+		})
+	})
+
+	protected := g.Group("")
+	protected.Use(middleware.JWTMiddleware())
+
+	protected.PUT("/games/:id/prices/:currency", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		if status, msg := checkGameOwner(c, gs, id); status != 0 {
+			return c.JSON(status, map[string]string{"error": msg})
+		}
+		req := new(setGamePriceRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		if err := ps.SetGamePrice(c.Request().Context(), id, c.Param("currency"), req.Amount); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "price set"})
+	})
+
+	protected.DELETE("/games/:id/prices/:currency", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		if status, msg := checkGameOwner(c, gs, id); status != 0 {
+			return c.JSON(status, map[string]string{"error": msg})
+		}
+		if err := ps.DeleteGamePrice(c.Request().Context(), id, c.Param("currency")); err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "price removed"})
+	})
+
+	admin := g.Group("/admin")
+	admin.Use(middleware.JWTMiddleware())
+	admin.Use(middleware.RequirePermission(model.PermPricingManage))
+
+	admin.PUT("/exchange-rates/:currency", func(c echo.Context) error {
+		req := new(setExchangeRateRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		if err := ps.SetRate(c.Request().Context(), c.Param("currency"), req.Rate.String()); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "rate set"})
+	})
+
+	admin.DELETE("/exchange-rates/:currency", func(c echo.Context) error {
+		if err := ps.DeleteRate(c.Request().Context(), c.Param("currency")); err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "rate removed"})
+	})
+}
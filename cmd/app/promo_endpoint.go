@@ -0,0 +1,165 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"GameStoreAPI/internal/middleware"
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/money"
+	"GameStoreAPI/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// promoRequest is the body of create/update promo code; dates are RFC 3339 or YYYY-MM-DD
+type promoRequest struct {
+	Code           string              `json:"code"`
+	DiscountType   string              `json:"discounttype"`
+	Value          money.Amount        `json:"value"`
+	MinimumTotal   *money.Amount       `json:"minimumtotal,omitempty"`
+	MaxRedemptions *int                `json:"maxredemptions,omitempty"`
+	MaxPerCustomer *int                `json:"maxpercustomer,omitempty"`
+	StartsAt       string              `json:"starts_at,omitempty"`
+	ExpiresAt      string              `json:"expires_at,omitempty"`
+	Targets        []model.PromoTarget `json:"targets,omitempty"`
+}
+
+func (r *promoRequest) toModel() (*model.PromoCode, error) {
+	p := &model.PromoCode{
+		Code:           r.Code,
+		DiscountType:   r.DiscountType,
+		Value:          r.Value,
+		MinimumTotal:   r.MinimumTotal,
+		MaxRedemptions: r.MaxRedemptions,
+		MaxPerCustomer: r.MaxPerCustomer,
+		Targets:        r.Targets,
+	}
+	var err error
+	if p.StartsAt, err = parseOptionalTime(r.StartsAt); err != nil {
+		return nil, err
+	}
+	if p.ExpiresAt, err = parseOptionalTime(r.ExpiresAt); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// parseOptionalTime accepts "", RFC 3339 timestamps and YYYY-MM-DD dates
+func parseOptionalTime(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// registerPromoRoutes wires admin promo code management.
+//
+//	GET    /admin/promocodes
+//	POST   /admin/promocodes
+//	GET    /admin/promocodes/:id
+//	PUT    /admin/promocodes/:id
+//	DELETE /admin/promocodes/:id
+//	GET    /admin/promocodes/:id/redemptions
+//
+// Customers apply codes through /cart/coupon (see registerCartRoutes).
+func registerPromoRoutes(g *echo.Group, ps *services.PromoService) {
+	admin := g.Group("/admin/promocodes")
+	admin.Use(middleware.JWTMiddleware())
+	admin.Use(middleware.RequirePermission(model.PermPromosManage))
+
+	admin.GET("", func(c echo.Context) error {
+		p, err := pageParams(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		list, err := ps.List(c.Request().Context(), p)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, list)
+	})
+
+	admin.POST("", func(c echo.Context) error {
+		req := new(promoRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		p, err := req.toModel()
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid date (use RFC 3339 or YYYY-MM-DD)"})
+		}
+		id, err := ps.Create(c.Request().Context(), p)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusCreated, map[string]interface{}{"promoid": id})
+	})
+
+	admin.GET("/:id", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		p, err := ps.Get(c.Request().Context(), id)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, p)
+	})
+
+	admin.PUT("/:id", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		req := new(promoRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		p, err := req.toModel()
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid date (use RFC 3339 or YYYY-MM-DD)"})
+		}
+		p.PromoID = id
+		if err := ps.Update(c.Request().Context(), p); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "updated"})
+	})
+
+	admin.DELETE("/:id", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		if err := ps.Delete(c.Request().Context(), id); err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "deleted"})
+	})
+
+	admin.GET("/:id/redemptions", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		p, err := pageParams(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		list, err := ps.ListRedemptions(c.Request().Context(), id, p)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, list)
+	})
+}
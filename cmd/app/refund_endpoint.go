@@ -0,0 +1,167 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"GameStoreAPI/internal/middleware"
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+type refundRequest struct {
+	OrderItemID *int64 `json:"orderitemid,omitempty"` // omit to refund the whole order
+	Reason      string `json:"reason"`
+}
+
+type reviewRefundRequest struct {
+	Comment string `json:"comment"`
+}
+
+// registerRefundRoutes wires refund and cancellation endpoints.
+// Customer:
+//
+//	POST /customers/me/orders/:id/refunds -> refund order or one item (auto-approved inside the window)
+//	POST /customers/me/orders/:id/cancel  -> cancel an unpaid order
+//	GET  /customers/me/refunds            -> my refunds
+//
+// Admin:
+//
+//	GET  /admin/refunds?status=
+//	POST /admin/refunds/:id/approve, POST /admin/refunds/:id/reject
+//	POST /admin/orders/:id/refunds        -> refund immediately
+func registerRefundRoutes(g *echo.Group, rs *services.RefundService, cs *services.CustomerService) {
+	usr := g.Group("/customers/me")
+	usr.Use(middleware.JWTMiddleware(), middleware.RequireSession())
+
+	usr.POST("/orders/:id/refunds", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		if claims == nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthenticated"})
+		}
+		cust, err := cs.GetByAuthID(c.Request().Context(), claims.AuthID)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "customer not found"})
+		}
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid order id"})
+		}
+		req := new(refundRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		rf, err := rs.RequestRefund(c.Request().Context(), claims.AuthID, cust.CustomerID, id, req.OrderItemID, req.Reason)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusCreated, rf)
+	})
+
+	usr.POST("/orders/:id/cancel", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		if claims == nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthenticated"})
+		}
+		cust, err := cs.GetByAuthID(c.Request().Context(), claims.AuthID)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "customer not found"})
+		}
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid order id"})
+		}
+		if err := rs.CancelOrder(c.Request().Context(), cust.CustomerID, id); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "order cancelled"})
+	})
+
+	usr.GET("/refunds", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		if claims == nil {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthenticated"})
+		}
+		cust, err := cs.GetByAuthID(c.Request().Context(), claims.AuthID)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "customer not found"})
+		}
+		p, err := pageParams(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		list, err := rs.ListForCustomer(c.Request().Context(), cust.CustomerID, p)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, list)
+	})
+
+	admin := g.Group("/admin")
+	admin.Use(middleware.JWTMiddleware())
+	admin.Use(middleware.RequirePermission(model.PermRefundsReview))
+
+	admin.GET("/refunds", func(c echo.Context) error {
+		p, err := pageParams(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		list, err := rs.ListAll(c.Request().Context(), c.QueryParam("status"), p)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, list)
+	})
+
+	admin.POST("/refunds/:id/approve", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		req := new(reviewRefundRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		rf, err := rs.Approve(c.Request().Context(), id, claims.AuthID, req.Comment)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, rf)
+	})
+
+	admin.POST("/refunds/:id/reject", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		req := new(reviewRefundRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		if err := rs.Reject(c.Request().Context(), id, claims.AuthID, req.Comment); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "refund rejected"})
+	})
+
+	admin.POST("/orders/:id/refunds", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		req := new(refundRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		rf, err := rs.RefundByAdmin(c.Request().Context(), claims.AuthID, id, req.OrderItemID, req.Reason)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusCreated, rf)
+	})
+}
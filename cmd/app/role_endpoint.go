@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"GameStoreAPI/internal/middleware"
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+type roleRequest struct {
+	Role        string   `json:"role"`
+	Description *string  `json:"description"`
+	Permissions []string `json:"permissions"`
+	Requires2FA bool     `json:"requires_2fa"`
+}
+
+type assignRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// registerRoleRoutes wires role and permission management (roles:manage).
+//
+//	GET    /admin/permissions
+//	GET    /admin/roles
+//	POST   /admin/roles
+//	PUT    /admin/roles/:role         -> replaces description and permissions
+//	DELETE /admin/roles/:role         -> custom roles nobody holds
+//	PUT    /admin/users/:authid/role  -> assign a role to an account
+func registerRoleRoutes(g *echo.Group, rs *services.RoleService) {
+	admin := g.Group("/admin")
+	admin.Use(middleware.JWTMiddleware())
+	admin.Use(middleware.RequirePermission(model.PermRolesManage))
+
+	admin.GET("/permissions", func(c echo.Context) error {
+		list, err := rs.ListPermissions(c.Request().Context())
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, list)
+	})
+
+	admin.GET("/roles", func(c echo.Context) error {
+		list, err := rs.List(c.Request().Context())
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, list)
+	})
+
+	admin.POST("/roles", func(c echo.Context) error {
+		req := new(roleRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		ro := &model.Role{Role: req.Role, Description: req.Description, Permissions: req.Permissions, Requires2FA: req.Requires2FA}
+		if err := rs.Create(c.Request().Context(), ro); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusCreated, ro)
+	})
+
+	admin.PUT("/roles/:role", func(c echo.Context) error {
+		req := new(roleRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		ro := &model.Role{Role: c.Param("role"), Description: req.Description, Permissions: req.Permissions, Requires2FA: req.Requires2FA}
+		if err := rs.Update(c.Request().Context(), ro); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "updated"})
+	})
+
+	admin.DELETE("/roles/:role", func(c echo.Context) error {
+		if err := rs.Delete(c.Request().Context(), c.Param("role")); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "deleted"})
+	})
+
+	admin.PUT("/users/:authid/role", func(c echo.Context) error {
+		authID, err := strconv.ParseInt(c.Param("authid"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid authid"})
+		}
+		req := new(assignRoleRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		claims := middleware.GetClaims(c)
+		if err := rs.Assign(c.Request().Context(), claims.AuthID, authID, req.Role); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "role assigned"})
+	})
+}
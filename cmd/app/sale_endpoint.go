@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"GameStoreAPI/internal/middleware"
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/money"
+	"GameStoreAPI/internal/pagination"
+	"GameStoreAPI/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// saleRequest schedules a sale: either discountpercent or saleprice, with RFC 3339 timestamps
+type saleRequest struct {
+	DiscountPercent *money.Amount `json:"discountpercent,omitempty"`
+	SalePrice       *money.Amount `json:"saleprice,omitempty"`
+	StartsAt        time.Time     `json:"starts_at"`
+	EndsAt          time.Time     `json:"ends_at"`
+}
+
+// registerSaleRoutes wires scheduled sales (admin OR the developer owning the game).
+//
+//	GET    /games/:id/sales          -> upcoming and running sales (?all=true includes past ones)
+//	POST   /games/:id/sales          -> schedule a sale
+//	PUT    /games/:id/sales/:saleid  -> change a sale
+//	DELETE /games/:id/sales/:saleid  -> cancel a sale
+func registerSaleRoutes(g *echo.Group, ss *services.SaleService, gs *services.GameService) {
+	p := g.Group("/games/:id/sales")
+	p.Use(middleware.JWTMiddleware())
+
+	p.GET("", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		if status, msg := checkGameOwner(c, gs, id); status != 0 {
+			return c.JSON(status, map[string]string{"error": msg})
+		}
+		pg, err := pageParams(c)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		list, err := ss.List(c.Request().Context(), id, c.QueryParam("all") == "true", pg)
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, list)
+	})
+
+	p.POST("", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		if status, msg := checkGameOwner(c, gs, id); status != 0 {
+			return c.JSON(status, map[string]string{"error": msg})
+		}
+		req := new(saleRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		authID := middleware.GetClaims(c).AuthID
+		sale := &model.GameSale{
+			GameID:          id,
+			DiscountPercent: req.DiscountPercent,
+			SalePrice:       req.SalePrice,
+			StartsAt:        req.StartsAt,
+			EndsAt:          req.EndsAt,
+			CreatedBy:       &authID,
+		}
+		saleID, err := ss.Schedule(c.Request().Context(), sale)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusCreated, map[string]interface{}{"saleid": saleID})
+	})
+
+	p.PUT("/:saleid", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		saleID, err := strconv.ParseInt(c.Param("saleid"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid sale id"})
+		}
+		if status, msg := checkGameOwner(c, gs, id); status != 0 {
+			return c.JSON(status, map[string]string{"error": msg})
+		}
+		req := new(saleRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		sale := &model.GameSale{
+			SaleID:          saleID,
+			GameID:          id,
+			DiscountPercent: req.DiscountPercent,
+			SalePrice:       req.SalePrice,
+			StartsAt:        req.StartsAt,
+			EndsAt:          req.EndsAt,
+		}
+		if err := ss.Update(c.Request().Context(), sale); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "updated"})
+	})
+
+	p.DELETE("/:saleid", func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		}
+		saleID, err := strconv.ParseInt(c.Param("saleid"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid sale id"})
+		}
+		if status, msg := checkGameOwner(c, gs, id); status != 0 {
+			return c.JSON(status, map[string]string{"error": msg})
+		}
+		if err := ss.Cancel(c.Request().Context(), id, saleID); err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "sale cancelled"})
+	})
+}
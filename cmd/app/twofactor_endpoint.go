@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"GameStoreAPI/internal/middleware"
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+type twoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type disableTwoFactorRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// registerTwoFactorRoutes wires TOTP enrollment for the signed-in user and the admin reset.
+// Enrollment needs a signed-in user but no permission: roles that require 2FA (admin and
+// developer) grant nothing until it is enabled, so this is how such accounts, the first
+// admin included, get their permissions. Keep RequirePermission off the /auth/2fa group.
+//
+//	GET    /auth/2fa                  -> status
+//	POST   /auth/2fa/setup            -> new secret + otpauth:// provisioning URI
+//	POST   /auth/2fa/enable           -> confirm a code, returns recovery codes
+//	POST   /auth/2fa/disable          -> password + code
+//	POST   /auth/2fa/recovery-codes   -> code, returns a new set of recovery codes
+//	DELETE /admin/users/:authid/2fa   -> users:2fa:reset
+func registerTwoFactorRoutes(g *echo.Group, tfs *services.TwoFactorService) {
+	me := g.Group("/auth/2fa")
+	me.Use(middleware.JWTMiddleware(), middleware.RequireSession())
+
+	me.GET("", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		st, err := tfs.Status(c.Request().Context(), claims.AuthID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, st)
+	})
+
+	me.POST("/setup", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		setup, err := tfs.Setup(c.Request().Context(), claims.AuthID)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, setup)
+	})
+
+	me.POST("/enable", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		req := new(twoFactorCodeRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		codes, err := tfs.Enable(c.Request().Context(), claims.AuthID, req.Code)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{"recovery_codes": codes})
+	})
+
+	me.POST("/disable", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		req := new(disableTwoFactorRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		if err := tfs.Disable(c.Request().Context(), claims.AuthID, req.Password, req.Code); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "two-factor authentication disabled"})
+	})
+
+	me.POST("/recovery-codes", func(c echo.Context) error {
+		claims := middleware.GetClaims(c)
+		req := new(twoFactorCodeRequest)
+		if err := c.Bind(req); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
+		codes, err := tfs.RegenerateRecoveryCodes(c.Request().Context(), claims.AuthID, req.Code)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{"recovery_codes": codes})
+	})
+
+	admin := g.Group("/admin")
+	admin.Use(middleware.JWTMiddleware())
+	admin.Use(middleware.RequirePermission(model.PermUsers2FAReset))
+
+	admin.DELETE("/users/:authid/2fa", func(c echo.Context) error {
+		authID, err := strconv.ParseInt(c.Param("authid"), 10, 64)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid authid"})
+		}
+		claims := middleware.GetClaims(c)
+		if err := tfs.Reset(c.Request().Context(), claims.AuthID, authID); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusOK, map[string]string{"message": "two-factor authentication reset"})
+	})
+}
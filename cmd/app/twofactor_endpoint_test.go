@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/repository"
+	"GameStoreAPI/internal/services"
+	"GameStoreAPI/internal/totp"
+)
+
+func TestTwoFactorBootstrap(t *testing.T) {
+	a := newTestAPI(t)
+	roleRepo := repository.NewRoleRepository(a.pool)
+	tfs := services.NewTwoFactorService(repository.NewTwoFactorRepository(a.pool), repository.NewAuthRepository(a.pool), roleRepo,
+		repository.NewAuthTokenRepository(a.pool), repository.NewSessionRepository(a.pool),
+		services.NewLockoutService(repository.NewAuthAttemptRepository(a.pool), repository.NewAuditRepository(a.pool), services.DefaultLockoutPolicy), "GameStore")
+	registerRoleRoutes(a.api, services.NewRoleService(roleRepo))
+	registerTwoFactorRoutes(a.api, tfs)
+
+	_, enrolled := a.account(t, "root", "admin", true)
+	if rec := a.do(http.MethodGet, "/api/admin/roles", enrolled); rec.Code != http.StatusOK {
+		t.Fatalf("enrolled admin: %d %s", rec.Code, rec.Body)
+	}
+
+	_, fresh := a.account(t, "newadmin", "admin", false)
+	if rec := a.do(http.MethodGet, "/api/admin/roles", fresh); rec.Code != http.StatusForbidden {
+		t.Fatalf("admin without 2FA: %d, want 403", rec.Code)
+	}
+
+	// enrollment needs no permission
+	rec := a.do(http.MethodPost, "/api/auth/2fa/setup", fresh)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("setup: %d %s", rec.Code, rec.Body)
+	}
+	var setup model.TwoFactorSetup
+	if err := json.Unmarshal(rec.Body.Bytes(), &setup); err != nil {
+		t.Fatal(err)
+	}
+	code, err := totp.CodeAt(setup.Secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := a.send(http.MethodPost, "/api/auth/2fa/enable", fresh, map[string]string{"code": code}); rec.Code != http.StatusOK {
+		t.Fatalf("enable: %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := a.do(http.MethodGet, "/api/admin/roles", fresh); rec.Code != http.StatusOK {
+		t.Fatalf("admin after enabling 2FA: %d %s", rec.Code, rec.Body)
+	}
+}
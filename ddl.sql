@@ -1,3 +1,5 @@
+create extension if not exists pg_trgm;
+
 create table public.customers (
   customerid serial not null,
   authid integer not null,
@@ -6,6 +8,11 @@ create table public.customers (
   email character varying(150) not null,
   address text null,
   phone character varying(20) null,
+  region character varying(10) null,
+  currency character(3) null,
+  billing_country character(2) null,
+  billing_region character varying(50) null,
+  billing_postalcode character varying(20) null,
   created_at timestamp without time zone null default CURRENT_TIMESTAMP,
   deleted_at timestamp without time zone null,
   constraint customers_pkey primary key (customerid),
@@ -39,12 +46,123 @@ create table public.games (
   gameid serial not null,
   developerid integer not null,
   title character varying(200) not null,
+  description text null,
+  blurb character varying(300) null,
   price numeric(10, 2) not null,
   releasedate date null,
+  pegirating smallint null,
+  esrbrating character varying(4) null,
+  status character varying(20) not null default 'draft'::character varying,
   created_at timestamp without time zone null default CURRENT_TIMESTAMP,
   deleted_at timestamp without time zone null,
+  -- catalog search: title matches rank above description matches
+  searchvector tsvector generated always as (
+    setweight(to_tsvector('english'::regconfig, coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('english'::regconfig, coalesce(description, '')), 'B')
+  ) stored,
   constraint games_pkey primary key (gameid),
-  constraint games_developerid_fkey foreign KEY (developerid) references developers (developerid)
+  constraint games_developerid_fkey foreign KEY (developerid) references developers (developerid),
+  constraint games_pegirating_check check (pegirating = any (array[3, 7, 12, 16, 18])),
+  constraint games_esrbrating_check check (
+    (esrbrating)::text = any (array['E'::text, 'E10+'::text, 'T'::text, 'M'::text, 'AO'::text, 'RP'::text])
+  ),
+  constraint games_status_check check (
+    (status)::text = any (array['draft'::text, 'submitted'::text, 'approved'::text, 'rejected'::text, 'published'::text, 'delisted'::text])
+  )
+) TABLESPACE pg_default;
+
+create index games_status_idx on public.games using btree (status, gameid);
+
+-- publishing workflow history; fromstatus is null for the creation of the game
+create table public.gametransitions (
+  transitionid serial not null,
+  gameid integer not null,
+  fromstatus character varying(20) null,
+  tostatus character varying(20) not null,
+  actorid integer null,
+  comment text null,
+  created_at timestamp without time zone not null default CURRENT_TIMESTAMP,
+  constraint gametransitions_pkey primary key (transitionid),
+  constraint gametransitions_gameid_fkey foreign KEY (gameid) references games (gameid),
+  constraint gametransitions_actorid_fkey foreign KEY (actorid) references userauth (authid)
+) TABLESPACE pg_default;
+
+create index gametransitions_gameid_idx on public.gametransitions using btree (gameid, transitionid);
+
+create index games_searchvector_idx on public.games using gin (searchvector);
+-- fuzzy title matching for misspelled search terms
+create index games_title_trgm_idx on public.games using gin (title gin_trgm_ops);
+
+-- localized long descriptions; games.description is the default one
+create table public.gamedescriptions (
+  gameid integer not null,
+  locale character varying(10) not null,
+  description text not null,
+  constraint gamedescriptions_pkey primary key (gameid, locale),
+  constraint gamedescriptions_gameid_fkey foreign KEY (gameid) references games (gameid)
+) TABLESPACE pg_default;
+
+create table public.gamemedia (
+  mediaid serial not null,
+  gameid integer not null,
+  kind character varying(20) not null,
+  url text not null,
+  position integer not null default 0,
+  created_at timestamp without time zone null default CURRENT_TIMESTAMP,
+  constraint gamemedia_pkey primary key (mediaid),
+  constraint gamemedia_gameid_fkey foreign KEY (gameid) references games (gameid),
+  constraint gamemedia_kind_check check (
+    (kind)::text = any (array['cover'::text, 'screenshot'::text, 'trailer'::text])
+  )
+) TABLESPACE pg_default;
+
+create index gamemedia_gameid_idx on public.gamemedia using btree (gameid, position);
+
+-- uploaded images, stored content-addressed by their SHA-256 so re-uploads are deduplicated;
+-- gamemedia rows refer to them by url
+create table public.mediaassets (
+  sha256 character(64) not null,
+  contenttype character varying(50) not null,
+  size integer not null,
+  width integer not null,
+  height integer not null,
+  url text not null,
+  thumbnailurl text not null,
+  uploadedby integer null,
+  created_at timestamp without time zone null default CURRENT_TIMESTAMP,
+  constraint mediaassets_pkey primary key (sha256),
+  constraint mediaassets_url_key unique (url),
+  constraint mediaassets_uploadedby_fkey foreign KEY (uploadedby) references userauth (authid)
+) TABLESPACE pg_default;
+
+create table public.gamerequirements (
+  gameid integer not null,
+  platform character varying(20) not null,
+  tier character varying(20) not null,
+  os character varying(200) null,
+  processor character varying(200) null,
+  memory character varying(100) null,
+  graphics character varying(200) null,
+  storage character varying(100) null,
+  notes character varying(500) null,
+  constraint gamerequirements_pkey primary key (gameid, platform, tier),
+  constraint gamerequirements_gameid_fkey foreign KEY (gameid) references games (gameid),
+  constraint gamerequirements_platform_check check (
+    (platform)::text = any (array['windows'::text, 'macos'::text, 'linux'::text])
+  ),
+  constraint gamerequirements_tier_check check (
+    (tier)::text = any (array['minimum'::text, 'recommended'::text])
+  )
+) TABLESPACE pg_default;
+
+create table public.gamelanguages (
+  gameid integer not null,
+  language character varying(10) not null,
+  interface boolean not null default true,
+  audio boolean not null default false,
+  subtitles boolean not null default false,
+  constraint gamelanguages_pkey primary key (gameid, language),
+  constraint gamelanguages_gameid_fkey foreign KEY (gameid) references games (gameid)
 ) TABLESPACE pg_default;
 
 create table public.genres (
@@ -62,10 +180,18 @@ create table public.orderitems (
   gameid integer not null,
   quantity integer not null default 1,
   priceatpurchase numeric(10, 2) not null,
+  currency character(3) not null default 'USD'::bpchar,
+  discount numeric(10, 2) not null default 0,
+  taxamount numeric(10, 2) not null default 0,
+  linetotal numeric(10, 2) null,
+  giftrecipientid integer null,
+  giftmessage character varying(500) null,
   created_at timestamp without time zone null default CURRENT_TIMESTAMP,
   deleted_at timestamp without time zone null,
+  refunded_at timestamp without time zone null,
   constraint orderitems_pkey primary key (orderitemid),
   constraint orderitems_gameid_fkey foreign KEY (gameid) references games (gameid),
+  constraint orderitems_giftrecipientid_fkey foreign KEY (giftrecipientid) references customers (customerid),
   constraint orderitems_orderid_fkey foreign KEY (orderid) references orders (orderid)
 ) TABLESPACE pg_default;
 
@@ -74,8 +200,21 @@ create table public.orders (
   customerid integer not null,
   orderdate timestamp without time zone null default CURRENT_TIMESTAMP,
   totalprice numeric(10, 2) null,
+  refundedtotal numeric(10, 2) not null default 0,
+  currency character(3) not null default 'USD'::bpchar,
+  promoid integer null,
+  discount numeric(10, 2) not null default 0,
+  subtotal numeric(10, 2) null,
+  taxamount numeric(10, 2) not null default 0,
+  taxname character varying(50) null,
+  taxrate numeric(7, 5) null,
+  taxinclusive boolean not null default false,
+  billing_country character(2) null,
+  billing_region character varying(50) null,
+  billing_postalcode character varying(20) null,
   created_at timestamp without time zone null default CURRENT_TIMESTAMP,
   deleted_at timestamp without time zone null,
+  cancelled_at timestamp without time zone null,
   constraint orders_pkey primary key (orderid),
   constraint orders_customerid_fkey foreign KEY (customerid) references customers (customerid)
 ) TABLESPACE pg_default;
@@ -104,11 +243,37 @@ create table public.payments (
   paymentstatus character varying(20) not null default 'Pending'::character varying,
   createdat timestamp without time zone null default CURRENT_TIMESTAMP,
   paidat timestamp without time zone null,
+  providerref character varying(100) null,
+  chargestartedat timestamp without time zone null,
+  currency character(3) not null default 'USD'::bpchar,
   constraint payments_pkey primary key (paymentid),
   constraint payments_orderid_fkey foreign KEY (orderid) references orders (orderid),
   constraint payments_paymentmethodid_fkey foreign KEY (paymentmethodid) references paymentmethods (paymentmethodid)
 ) TABLESPACE pg_default;
 
+create table public.roles (
+  role character varying(20) not null,
+  description character varying(255) null,
+  builtin boolean not null default false,
+  requires_2fa boolean not null default false,
+  created_at timestamp without time zone null default CURRENT_TIMESTAMP,
+  constraint roles_pkey primary key (role)
+) TABLESPACE pg_default;
+
+create table public.permissions (
+  permission character varying(60) not null,
+  description character varying(255) null,
+  constraint permissions_pkey primary key (permission)
+) TABLESPACE pg_default;
+
+create table public.rolepermissions (
+  role character varying(20) not null,
+  permission character varying(60) not null,
+  constraint rolepermissions_pkey primary key (role, permission),
+  constraint rolepermissions_role_fkey foreign KEY (role) references roles (role) on update CASCADE on delete CASCADE,
+  constraint rolepermissions_permission_fkey foreign KEY (permission) references permissions (permission) on delete CASCADE
+) TABLESPACE pg_default;
+
 create table public.userauth (
   authid serial not null,
   email character varying(150) not null,
@@ -116,21 +281,13 @@ create table public.userauth (
   role character varying(20) not null,
   created_at timestamp without time zone null default CURRENT_TIMESTAMP,
   deleted_at timestamp without time zone null,
+  email_verified_at timestamp without time zone null,
+  banned_at timestamp without time zone null,
+  banned_until timestamp without time zone null,
+  banreason character varying(255) null,
   constraint userauth_pkey primary key (authid),
   constraint userauth_email_key unique (email),
-  constraint userauth_role_check check (
-    (
-      (role)::text = any (
-        (
-          array[
-            'admin'::character varying,
-            'user'::character varying,
-            'developer'::character varying
-          ]
-        )::text[]
-      )
-    )
-  )
+  constraint userauth_role_fkey foreign KEY (role) references roles (role) on update CASCADE
 ) TABLESPACE pg_default;
 
 CREATE TABLE customer_games (
@@ -138,5 +295,372 @@ CREATE TABLE customer_games (
   customerid BIGINT NOT NULL REFERENCES customers(customerid),
   gameid BIGINT NOT NULL REFERENCES games(gameid),
   purchased_at TIMESTAMPTZ DEFAULT now(),
+  downloaded_at TIMESTAMPTZ NULL,
   UNIQUE(customerid, gameid)
-);
\ No newline at end of file
+);
+
+create table public.refunds (
+  refundid serial not null,
+  orderid integer not null,
+  orderitemid integer null,
+  paymentid integer not null,
+  amount numeric(10, 2) not null,
+  reason text null,
+  status character varying(20) not null default 'Requested'::character varying,
+  requestedby integer null,
+  reviewedby integer null,
+  reviewcomment text null,
+  created_at timestamp without time zone null default CURRENT_TIMESTAMP,
+  reviewed_at timestamp without time zone null,
+  constraint refunds_pkey primary key (refundid),
+  constraint refunds_orderid_fkey foreign KEY (orderid) references orders (orderid),
+  constraint refunds_orderitemid_fkey foreign KEY (orderitemid) references orderitems (orderitemid),
+  constraint refunds_paymentid_fkey foreign KEY (paymentid) references payments (paymentid),
+  constraint refunds_requestedby_fkey foreign KEY (requestedby) references userauth (authid),
+  constraint refunds_reviewedby_fkey foreign KEY (reviewedby) references userauth (authid)
+) TABLESPACE pg_default;
+
+create table public.gameprices (
+  gameid integer not null,
+  currency character(3) not null,
+  amount numeric(10, 2) not null,
+  updated_at timestamp without time zone null default CURRENT_TIMESTAMP,
+  constraint gameprices_pkey primary key (gameid, currency),
+  constraint gameprices_gameid_fkey foreign KEY (gameid) references games (gameid),
+  constraint gameprices_amount_check check (amount >= 0)
+) TABLESPACE pg_default;
+
+-- units of currency per one unit of the base currency (BASE_CURRENCY, default USD)
+create table public.exchangerates (
+  currency character(3) not null,
+  rate numeric(18, 8) not null,
+  updated_at timestamp without time zone null default CURRENT_TIMESTAMP,
+  constraint exchangerates_pkey primary key (currency),
+  constraint exchangerates_rate_check check (rate > 0)
+) TABLESPACE pg_default;
+
+-- a sale takes either a percentage off or a fixed sale price (base currency)
+create table public.gamesales (
+  saleid serial not null,
+  gameid integer not null,
+  discountpercent numeric(5, 2) null,
+  saleprice numeric(10, 2) null,
+  starts_at timestamp without time zone not null,
+  ends_at timestamp without time zone not null,
+  createdby integer null,
+  created_at timestamp without time zone null default CURRENT_TIMESTAMP,
+  deleted_at timestamp without time zone null,
+  constraint gamesales_pkey primary key (saleid),
+  constraint gamesales_gameid_fkey foreign KEY (gameid) references games (gameid),
+  constraint gamesales_createdby_fkey foreign KEY (createdby) references userauth (authid),
+  constraint gamesales_kind_check check ((discountpercent is null) <> (saleprice is null)),
+  constraint gamesales_discountpercent_check check (discountpercent > 0 and discountpercent < 100),
+  constraint gamesales_saleprice_check check (saleprice >= 0),
+  constraint gamesales_period_check check (ends_at > starts_at)
+) TABLESPACE pg_default;
+
+-- fixed discounts and minimum totals are expressed in the base currency
+create table public.promocodes (
+  promoid serial not null,
+  code character varying(50) not null,
+  discounttype character varying(10) not null,
+  value numeric(10, 2) not null,
+  minimumtotal numeric(10, 2) null,
+  maxredemptions integer null,
+  maxpercustomer integer null,
+  redemptioncount integer not null default 0,
+  starts_at timestamp without time zone null,
+  expires_at timestamp without time zone null,
+  created_at timestamp without time zone null default CURRENT_TIMESTAMP,
+  deleted_at timestamp without time zone null,
+  constraint promocodes_pkey primary key (promoid),
+  constraint promocodes_code_key unique (code),
+  constraint promocodes_discounttype_check check (
+    (discounttype)::text = any (array['percent'::text, 'fixed'::text])
+  ),
+  constraint promocodes_value_check check (value > 0)
+) TABLESPACE pg_default;
+
+-- a promo without targets applies to every game
+create table public.promocode_targets (
+  promoid integer not null,
+  targettype character varying(10) not null,
+  targetid integer not null,
+  constraint promocode_targets_pkey primary key (promoid, targettype, targetid),
+  constraint promocode_targets_promoid_fkey foreign KEY (promoid) references promocodes (promoid) on delete CASCADE,
+  constraint promocode_targets_targettype_check check (
+    (targettype)::text = any (array['game'::text, 'genre'::text, 'developer'::text])
+  )
+) TABLESPACE pg_default;
+
+create table public.promoredemptions (
+  redemptionid serial not null,
+  promoid integer not null,
+  customerid integer not null,
+  orderid integer not null,
+  discount numeric(10, 2) not null,
+  currency character(3) not null,
+  created_at timestamp without time zone null default CURRENT_TIMESTAMP,
+  constraint promoredemptions_pkey primary key (redemptionid),
+  constraint promoredemptions_orderid_key unique (orderid),
+  constraint promoredemptions_promoid_fkey foreign KEY (promoid) references promocodes (promoid),
+  constraint promoredemptions_customerid_fkey foreign KEY (customerid) references customers (customerid),
+  constraint promoredemptions_orderid_fkey foreign KEY (orderid) references orders (orderid)
+) TABLESPACE pg_default;
+
+create table public.gifts (
+  giftid serial not null,
+  orderid integer not null,
+  orderitemid integer not null,
+  gameid integer not null,
+  senderid integer not null,
+  recipientid integer not null,
+  message character varying(500) null,
+  status character varying(20) not null default 'pending'::character varying,
+  created_at timestamp without time zone null default CURRENT_TIMESTAMP,
+  responded_at timestamp without time zone null,
+  constraint gifts_pkey primary key (giftid),
+  constraint gifts_orderitemid_key unique (orderitemid),
+  constraint gifts_orderid_fkey foreign KEY (orderid) references orders (orderid),
+  constraint gifts_orderitemid_fkey foreign KEY (orderitemid) references orderitems (orderitemid),
+  constraint gifts_gameid_fkey foreign KEY (gameid) references games (gameid),
+  constraint gifts_senderid_fkey foreign KEY (senderid) references customers (customerid),
+  constraint gifts_recipientid_fkey foreign KEY (recipientid) references customers (customerid),
+  constraint gifts_status_check check (
+    (status)::text = any (array['pending'::text, 'accepted'::text, 'declined'::text, 'revoked'::text])
+  )
+) TABLESPACE pg_default;
+
+create table public.authsessions (
+  sessionid character varying(64) not null,
+  authid integer not null,
+  useragent character varying(255) null,
+  ipaddress character varying(45) null,
+  created_at timestamp without time zone null default CURRENT_TIMESTAMP,
+  last_used_at timestamp without time zone null,
+  expires_at timestamp without time zone not null,
+  revoked_at timestamp without time zone null,
+  revokedreason character varying(50) null,
+  constraint authsessions_pkey primary key (sessionid),
+  constraint authsessions_authid_fkey foreign KEY (authid) references userauth (authid)
+) TABLESPACE pg_default;
+
+create table public.refreshtokens (
+  tokenid serial not null,
+  sessionid character varying(64) not null,
+  tokenhash character(64) not null,
+  created_at timestamp without time zone null default CURRENT_TIMESTAMP,
+  expires_at timestamp without time zone not null,
+  used_at timestamp without time zone null,
+  constraint refreshtokens_pkey primary key (tokenid),
+  constraint refreshtokens_tokenhash_key unique (tokenhash),
+  constraint refreshtokens_sessionid_fkey foreign KEY (sessionid) references authsessions (sessionid) on delete CASCADE
+) TABLESPACE pg_default;
+
+create table public.authtokens (
+  tokenid serial not null,
+  authid integer not null,
+  purpose character varying(20) not null,
+  tokenhash character(64) not null,
+  created_at timestamp without time zone null default CURRENT_TIMESTAMP,
+  expires_at timestamp without time zone not null,
+  used_at timestamp without time zone null,
+  constraint authtokens_pkey primary key (tokenid),
+  constraint authtokens_tokenhash_key unique (tokenhash),
+  constraint authtokens_authid_fkey foreign KEY (authid) references userauth (authid),
+  constraint authtokens_purpose_check check (
+    (purpose)::text = any (array['verify_email'::text, 'password_reset'::text, 'login_2fa'::text])
+  )
+) TABLESPACE pg_default;
+
+create table public.twofactor (
+  authid integer not null,
+  secret character varying(64) not null,
+  created_at timestamp without time zone null default CURRENT_TIMESTAMP,
+  enabled_at timestamp without time zone null,
+  last_used_step bigint not null default 0,
+  constraint twofactor_pkey primary key (authid),
+  constraint twofactor_authid_fkey foreign KEY (authid) references userauth (authid)
+) TABLESPACE pg_default;
+
+create table public.recoverycodes (
+  codeid serial not null,
+  authid integer not null,
+  codehash character(64) not null,
+  created_at timestamp without time zone null default CURRENT_TIMESTAMP,
+  used_at timestamp without time zone null,
+  constraint recoverycodes_pkey primary key (codeid),
+  constraint recoverycodes_authid_codehash_key unique (authid, codehash),
+  constraint recoverycodes_authid_fkey foreign KEY (authid) references userauth (authid)
+) TABLESPACE pg_default;
+
+create table public.authattempts (
+  kind character varying(20) not null,
+  key character varying(150) not null,
+  failures integer not null default 0,
+  first_failed_at timestamp without time zone not null,
+  last_failed_at timestamp without time zone not null,
+  locked_until timestamp without time zone null,
+  constraint authattempts_pkey primary key (kind, key),
+  constraint authattempts_kind_check check (
+    (kind)::text = any (array['account'::text, 'ip'::text, 'register'::text])
+  )
+) TABLESPACE pg_default;
+
+create table public.auditlog (
+  auditid serial not null,
+  actorauthid integer null,
+  event character varying(50) not null,
+  subject character varying(200) null,
+  detail text null,
+  ipaddress character varying(45) null,
+  created_at timestamp without time zone null default CURRENT_TIMESTAMP,
+  constraint auditlog_pkey primary key (auditid),
+  constraint auditlog_actorauthid_fkey foreign KEY (actorauthid) references userauth (authid)
+) TABLESPACE pg_default;
+
+create table public.identities (
+  identityid serial not null,
+  authid integer not null,
+  provider character varying(50) not null,
+  subject character varying(255) not null,
+  email character varying(150) null,
+  created_at timestamp without time zone null default CURRENT_TIMESTAMP,
+  last_login_at timestamp without time zone null,
+  constraint identities_pkey primary key (identityid),
+  constraint identities_provider_subject_key unique (provider, subject),
+  constraint identities_authid_provider_key unique (authid, provider),
+  constraint identities_authid_fkey foreign KEY (authid) references userauth (authid)
+) TABLESPACE pg_default;
+
+create table public.oidcflows (
+  statehash character(64) not null,
+  provider character varying(50) not null,
+  nonce character varying(64) not null,
+  codeverifier character varying(128) not null,
+  authid integer null,
+  created_at timestamp without time zone null default CURRENT_TIMESTAMP,
+  expires_at timestamp without time zone not null,
+  constraint oidcflows_pkey primary key (statehash),
+  constraint oidcflows_authid_fkey foreign KEY (authid) references userauth (authid) on delete CASCADE
+) TABLESPACE pg_default;
+
+create table public.apikeys (
+  keyid serial not null,
+  authid integer not null,
+  name character varying(100) not null,
+  prefix character varying(20) not null,
+  keyhash character(64) not null,
+  scopes text[] not null,
+  created_at timestamp without time zone null default CURRENT_TIMESTAMP,
+  expires_at timestamp without time zone not null,
+  last_used_at timestamp without time zone null,
+  last_used_ip character varying(45) null,
+  revoked_at timestamp without time zone null,
+  constraint apikeys_pkey primary key (keyid),
+  constraint apikeys_prefix_key unique (prefix),
+  constraint apikeys_keyhash_key unique (keyhash),
+  constraint apikeys_authid_fkey foreign KEY (authid) references userauth (authid)
+) TABLESPACE pg_default;
+
+-- team of a developer; every developer has at least one owner
+create table public.developermembers (
+  developerid integer not null,
+  authid integer not null,
+  role character varying(10) not null,
+  addedby integer null,
+  created_at timestamp without time zone null default CURRENT_TIMESTAMP,
+  constraint developermembers_pkey primary key (developerid, authid),
+  constraint developermembers_developerid_fkey foreign KEY (developerid) references developers (developerid),
+  constraint developermembers_authid_fkey foreign KEY (authid) references userauth (authid),
+  constraint developermembers_addedby_fkey foreign KEY (addedby) references userauth (authid),
+  constraint developermembers_role_check check (
+    (role)::text = any (array['owner'::text, 'admin'::text, 'member'::text])
+  )
+) TABLESPACE pg_default;
+
+-- invitations to a developer's team; the account joins (developermembers) when it accepts
+create table public.developerinvites (
+  developerid integer not null,
+  authid integer not null,
+  role character varying(10) not null,
+  invitedby integer null,
+  created_at timestamp without time zone null default CURRENT_TIMESTAMP,
+  constraint developerinvites_pkey primary key (developerid, authid),
+  constraint developerinvites_developerid_fkey foreign KEY (developerid) references developers (developerid),
+  constraint developerinvites_authid_fkey foreign KEY (authid) references userauth (authid),
+  constraint developerinvites_invitedby_fkey foreign KEY (invitedby) references userauth (authid),
+  constraint developerinvites_role_check check (
+    (role)::text = any (array['owner'::text, 'admin'::text, 'member'::text])
+  )
+) TABLESPACE pg_default;
+
+create table public.developerapplications (
+  applicationid serial not null,
+  authid integer not null,
+  developername character varying(150) not null,
+  website character varying(255) null,
+  message text null,
+  status character varying(20) not null default 'pending'::character varying,
+  developerid integer null,
+  reviewedby integer null,
+  reviewcomment text null,
+  created_at timestamp without time zone null default CURRENT_TIMESTAMP,
+  reviewed_at timestamp without time zone null,
+  constraint developerapplications_pkey primary key (applicationid),
+  constraint developerapplications_authid_fkey foreign KEY (authid) references userauth (authid),
+  constraint developerapplications_developerid_fkey foreign KEY (developerid) references developers (developerid),
+  constraint developerapplications_reviewedby_fkey foreign KEY (reviewedby) references userauth (authid),
+  constraint developerapplications_status_check check (
+    (status)::text = any (array['pending'::text, 'approved'::text, 'rejected'::text])
+  )
+) TABLESPACE pg_default;
+
+-- an account has at most one application waiting for review
+create unique index developerapplications_pending_key on public.developerapplications (authid) where status = 'pending';
+
+alter table public.orders add constraint orders_promoid_fkey foreign KEY (promoid) references promocodes (promoid);
+
+-- admin and developer accounts get their permissions once they enable 2FA at /auth/2fa/setup
+-- and /auth/2fa/enable, which only need a signed-in user
+insert into public.roles (role, description, builtin, requires_2fa) values
+  ('admin', 'Store administrator', true, true),
+  ('developer', 'Game developer managing their own games', true, true),
+  ('user', 'Customer', true, false);
+
+insert into public.permissions (permission, description) values
+  ('games:write:own', 'Create and manage games of the own developer record'),
+  ('games:write:any', 'Create and manage any game'),
+  ('games:review', 'Review game submissions and relist delisted games'),
+  ('genres:write', 'Manage genres'),
+  ('developers:manage', 'Manage developer records'),
+  ('accounts:create', 'Create admin and developer accounts'),
+  ('users:read', 'View customer accounts'),
+  ('users:ban', 'Ban customer accounts'),
+  ('users:2fa:reset', 'Reset the two-factor authentication of an account'),
+  ('orders:read:any', 'View every order'),
+  ('payments:manage', 'Manage payment methods and payments'),
+  ('refunds:review', 'Review and issue refunds'),
+  ('pricing:manage', 'Manage exchange rates'),
+  ('promos:manage', 'Manage promo codes'),
+  ('roles:manage', 'Manage roles, permissions and role assignments'),
+  ('lockouts:manage', 'View and clear login lockouts'),
+  ('audit:read', 'Read the audit log'),
+  ('apikeys:manage', 'Create and revoke own API keys for automation');
+
+insert into public.rolepermissions (role, permission)
+  select 'admin', permission from public.permissions;
+
+insert into public.rolepermissions (role, permission) values
+  ('developer', 'games:write:own'),
+  ('developer', 'apikeys:manage');
+
+-- developers linked to an account before teams existed are owned by that account
+insert into public.developermembers (developerid, authid, role)
+  select developerid, authid, 'owner' from public.developers where authid is not null;
+
+insert into public.paymentmethods (name) values
+  ('Credit Card'),
+  ('Debit Card'),
+  ('E-Wallet'),
+  ('Bank Transfer');
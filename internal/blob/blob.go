@@ -0,0 +1,23 @@
+// Package blob stores uploaded files (game media) by key, on the local filesystem or in an
+// S3-compatible bucket.
+package blob
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore keeps immutable blobs under keys like "games/ab/ab12...png" and tells the
+// public URL they are served from.
+type BlobStore interface {
+	// Put stores data under key, replacing a blob stored there before
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	// Get returns the blob stored under key, or ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	Delete(ctx context.Context, key string) error
+	// URL is the address clients download the blob from
+	URL(key string) string
+}
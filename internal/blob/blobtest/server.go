@@ -0,0 +1,123 @@
+// Package blobtest runs a local stand-in for an S3-compatible service, for tests and local
+// runs of blob.S3Store. It keeps objects in memory and checks request signatures.
+package blobtest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"GameStoreAPI/internal/blob"
+)
+
+type object struct {
+	contentType string
+	data        []byte
+}
+
+// Server is a stand-in holding one bucket
+type Server struct {
+	*httptest.Server
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+
+	mu      sync.Mutex
+	objects map[string]object
+}
+
+// NewServer starts a stand-in for one bucket; call Close when done
+func NewServer(bucket string) *Server {
+	s := &Server{
+		Bucket:    bucket,
+		Region:    "us-east-1",
+		AccessKey: "blobtest",
+		SecretKey: "blobtest-secret",
+		objects:   map[string]object{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Config returns the store configuration pointing at the stand-in
+func (s *Server) Config() blob.S3Config {
+	return blob.S3Config{Endpoint: s.URL, Region: s.Region, Bucket: s.Bucket, AccessKey: s.AccessKey, SecretKey: s.SecretKey}
+}
+
+// Keys returns the keys of the stored objects
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.objects))
+	for k := range s.objects {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+s.Bucket+"/")
+	if !ok || key == "" {
+		s3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s3Error(w, http.StatusBadRequest, "IncompleteBody")
+		return
+	}
+	if !s.verify(r, body) {
+		s3Error(w, http.StatusForbidden, "SignatureDoesNotMatch")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		s.objects[key] = object{contentType: r.Header.Get("Content-Type"), data: body}
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet, http.MethodHead:
+		obj, ok := s.objects[key]
+		if !ok {
+			s3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Type", obj.contentType)
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(obj.data)
+		}
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// verify recomputes the signature of a request with the stand-in's credentials
+func (s *Server) verify(r *http.Request, body []byte) bool {
+	at, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+	check, err := http.NewRequest(r.Method, r.URL.String(), bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	check.Host = r.Host
+	blob.Sign(check, body, s.Region, s.AccessKey, s.SecretKey, at)
+	return check.Header.Get("Authorization") == r.Header.Get("Authorization")
+}
+
+func s3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	io.WriteString(w, "<?xml version=\"1.0\" encoding=\"UTF-8\"?><Error><Code>"+code+"</Code></Error>")
+}
@@ -0,0 +1,109 @@
+package blob_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"GameStoreAPI/internal/blob"
+	"GameStoreAPI/internal/blob/blobtest"
+)
+
+func newS3(t *testing.T) (*blob.S3Store, *blobtest.Server) {
+	t.Helper()
+	srv := blobtest.NewServer("media")
+	t.Cleanup(srv.Close)
+	store, err := blob.NewS3Store(srv.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, srv
+}
+
+func TestS3Store(t *testing.T) {
+	store, srv := newS3(t)
+	ctx := context.Background()
+	key := "games/ab/abcdef.png"
+
+	if ok, err := store.Exists(ctx, key); err != nil || ok {
+		t.Fatalf("Exists before Put = %v, %v", ok, err)
+	}
+	if _, err := store.Get(ctx, key); !errors.Is(err, blob.ErrNotFound) {
+		t.Fatalf("Get before Put: %v, want ErrNotFound", err)
+	}
+
+	data := []byte("\x89PNG not really")
+	if err := store.Put(ctx, key, "image/png", data); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if ok, err := store.Exists(ctx, key); err != nil || !ok {
+		t.Fatalf("Exists after Put = %v, %v", ok, err)
+	}
+	got, err := store.Get(ctx, key)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("Get = %q, %v", got, err)
+	}
+	if keys := srv.Keys(); !slices.Equal(keys, []string{key}) {
+		t.Fatalf("stored keys = %v", keys)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if ok, err := store.Exists(ctx, key); err != nil || ok {
+		t.Fatalf("Exists after Delete = %v, %v", ok, err)
+	}
+}
+
+func TestS3StoreEscapesKeys(t *testing.T) {
+	store, srv := newS3(t)
+	ctx := context.Background()
+	key := "games/x/cover art+1 (final).png"
+	if err := store.Put(ctx, key, "image/png", []byte("x")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if ok, err := store.Exists(ctx, key); err != nil || !ok {
+		t.Fatalf("Exists = %v, %v", ok, err)
+	}
+	if u := store.URL(key); u != srv.URL+"/media/games/x/cover%20art%2B1%20%28final%29.png" {
+		t.Errorf("URL = %s", u)
+	}
+}
+
+func TestS3StoreRejectsBadCredentials(t *testing.T) {
+	srv := blobtest.NewServer("media")
+	defer srv.Close()
+	cfg := srv.Config()
+	cfg.SecretKey = "wrong-secret"
+	store, err := blob.NewS3Store(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Put(context.Background(), "a.png", "image/png", []byte("x"))
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Fatalf("Put with a wrong secret: %v, want SignatureDoesNotMatch", err)
+	}
+	if len(srv.Keys()) != 0 {
+		t.Fatal("object stored despite the bad signature")
+	}
+}
+
+func TestS3StoreConfig(t *testing.T) {
+	if _, err := blob.NewS3Store(blob.S3Config{Endpoint: "http://localhost:9000", Bucket: "media"}); err == nil {
+		t.Error("store without credentials created")
+	}
+	store, err := blob.NewS3Store(blob.S3Config{Endpoint: "http://localhost:9000/", Bucket: "media", AccessKey: "k", SecretKey: "s",
+		PublicURL: "https://cdn.example.com/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := store.URL("games/a.png"); u != "https://cdn.example.com/games/a.png" {
+		t.Errorf("URL = %s", u)
+	}
+	if err := store.Put(context.Background(), "/abs.png", "image/png", nil); err == nil {
+		t.Error("Put with an absolute key succeeded")
+	}
+}
@@ -0,0 +1,149 @@
+// Package media validates uploaded images and renders their thumbnails.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	// MaxUploadBytes is the largest accepted upload
+	MaxUploadBytes = 10 << 20
+	// MinDimension and MaxDimension bound the width and height of images
+	MinDimension = 100
+	MaxDimension = 8192
+	// MaxPixels bounds the memory decoding an image for its thumbnail takes
+	MaxPixels = 16 << 20
+	// ThumbnailSize is the bounding box thumbnails are scaled into
+	ThumbnailSize = 320
+)
+
+var (
+	ErrTooLarge    = fmt.Errorf("file must be at most %d MB", MaxUploadBytes>>20)
+	ErrUnsupported = errors.New("file must be a JPEG, PNG or GIF image")
+)
+
+// extensions of the accepted content types
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// Info describes a validated image
+type Info struct {
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+}
+
+// Inspect checks that data is a JPEG, PNG or GIF image (judged by its content, not by
+// the name or type the client sent) of acceptable size and dimensions
+func Inspect(data []byte) (*Info, error) {
+	if len(data) > MaxUploadBytes {
+		return nil, ErrTooLarge
+	}
+	ct := http.DetectContentType(data)
+	ext, ok := extensions[ct]
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	if cfg.Width < MinDimension || cfg.Height < MinDimension {
+		return nil, fmt.Errorf("image must be at least %dx%d pixels", MinDimension, MinDimension)
+	}
+	if cfg.Width > MaxDimension || cfg.Height > MaxDimension {
+		return nil, fmt.Errorf("image must be at most %dx%d pixels", MaxDimension, MaxDimension)
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, fmt.Errorf("image must have at most %d megapixels", MaxPixels>>20)
+	}
+	return &Info{ContentType: ct, Ext: ext, Width: cfg.Width, Height: cfg.Height}, nil
+}
+
+// Thumbnail scales an inspected image down to fit ThumbnailSize x ThumbnailSize (smaller
+// images keep their size). JPEGs stay JPEGs, other images become PNGs so that
+// transparency survives. It returns the encoded thumbnail, its content type and extension.
+func Thumbnail(data []byte, info *Info) ([]byte, string, string, error) {
+	var src image.Image
+	var err error
+	switch info.ContentType {
+	case "image/jpeg":
+		src, err = jpeg.Decode(bytes.NewReader(data))
+	case "image/png":
+		src, err = png.Decode(bytes.NewReader(data))
+	case "image/gif":
+		src, err = gif.Decode(bytes.NewReader(data))
+	default:
+		return nil, "", "", ErrUnsupported
+	}
+	if err != nil {
+		return nil, "", "", ErrUnsupported
+	}
+	thumb := scaleDown(src, ThumbnailSize)
+
+	var buf bytes.Buffer
+	if info.ContentType == "image/jpeg" {
+		if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85}); err != nil {
+			return nil, "", "", err
+		}
+		return buf.Bytes(), "image/jpeg", ".jpg", nil
+	}
+	if err := png.Encode(&buf, thumb); err != nil {
+		return nil, "", "", err
+	}
+	return buf.Bytes(), "image/png", ".png", nil
+}
+
+// scaleDown fits img into a size x size box keeping its aspect ratio. Each target pixel
+// is the average of the source pixels it covers (a box filter), which is good enough for
+// downscaling.
+func scaleDown(img image.Image, size int) *image.RGBA {
+	b := img.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	src := image.NewRGBA(image.Rect(0, 0, sw, sh))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	dw, dh := sw, sh
+	if sw > size || sh > size {
+		if sw >= sh {
+			dw, dh = size, max(1, sh*size/sw)
+		} else {
+			dw, dh = max(1, sw*size/sh), size
+		}
+	}
+	if dw == sw && dh == sh {
+		return src
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*sh/dh, max((y+1)*sh/dh, y*sh/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*sw/dw, max((x+1)*sw/dw, x*sw/dw+1)
+			var r, g, bl, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r, g, bl, a = r+int(p[0]), g+int(p[1]), bl+int(p[2]), a+int(p[3])
+					n++
+				}
+			}
+			o := dst.PixOffset(x, y)
+			dst.Pix[o], dst.Pix[o+1], dst.Pix[o+2], dst.Pix[o+3] = uint8(r/n), uint8(g/n), uint8(bl/n), uint8(a/n)
+		}
+	}
+	return dst
+}
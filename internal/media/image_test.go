@@ -0,0 +1,134 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func encode(t *testing.T, format string, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// pngHeader returns a PNG claiming the dimensions without carrying their pixels; Inspect
+// only reads the header
+func pngHeader(t *testing.T, w, h int) []byte {
+	t.Helper()
+	data := encode(t, "png", 1, 1)
+	// signature (8) + IHDR length (4) + "IHDR" (4), then width and height
+	binary.BigEndian.PutUint32(data[16:], uint32(w))
+	binary.BigEndian.PutUint32(data[20:], uint32(h))
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+func TestInspect(t *testing.T) {
+	for _, tt := range []struct {
+		format, contentType, ext string
+	}{
+		{"png", "image/png", ".png"},
+		{"jpeg", "image/jpeg", ".jpg"},
+		{"gif", "image/gif", ".gif"},
+	} {
+		info, err := Inspect(encode(t, tt.format, 400, 300))
+		if err != nil {
+			t.Errorf("%s: %v", tt.format, err)
+			continue
+		}
+		if info.ContentType != tt.contentType || info.Ext != tt.ext || info.Width != 400 || info.Height != 300 {
+			t.Errorf("%s: info = %+v", tt.format, info)
+		}
+	}
+}
+
+func TestInspectRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string // error substring
+	}{
+		{"text", []byte("<html><body>not an image</body></html>"), "JPEG, PNG or GIF"},
+		{"webp", append([]byte("RIFF\x00\x00\x00\x00WEBPVP8 "), make([]byte, 64)...), "JPEG, PNG or GIF"},
+		{"truncated png", encode(t, "png", 400, 300)[:20], "JPEG, PNG or GIF"},
+		{"too small", encode(t, "png", 99, 400), "at least 100x100"},
+		{"too wide", pngHeader(t, MaxDimension+1, 200), "at most 8192x8192"},
+		{"too many pixels", pngHeader(t, MaxDimension, MaxPixels/MaxDimension+1), "megapixels"},
+	}
+	for _, tt := range tests {
+		_, err := Inspect(tt.data)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+
+	// the pixel cap itself is allowed
+	if _, err := Inspect(pngHeader(t, MaxDimension, MaxPixels/MaxDimension)); err != nil {
+		t.Errorf("image of exactly MaxPixels: %v", err)
+	}
+}
+
+func TestInspectTooLarge(t *testing.T) {
+	data := make([]byte, MaxUploadBytes+1)
+	copy(data, encode(t, "png", 200, 200))
+	if _, err := Inspect(data); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("upload over MaxUploadBytes: %v, want ErrTooLarge", err)
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	for _, tt := range []struct {
+		format    string
+		w, h      int
+		tw, th    int
+		thumbType string
+	}{
+		{"jpeg", 1000, 500, 320, 160, "image/jpeg"},
+		{"png", 400, 1600, 80, 320, "image/png"},
+		{"gif", 200, 150, 200, 150, "image/png"},
+	} {
+		data := encode(t, tt.format, tt.w, tt.h)
+		info, err := Inspect(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		thumb, ct, _, err := Thumbnail(data, info)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.format, err)
+		}
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(thumb))
+		if err != nil {
+			t.Fatalf("%s: thumbnail does not decode: %v", tt.format, err)
+		}
+		if ct != tt.thumbType || cfg.Width != tt.tw || cfg.Height != tt.th {
+			t.Errorf("%s %dx%d: thumbnail %s %dx%d, want %s %dx%d", tt.format, tt.w, tt.h, ct, cfg.Width, cfg.Height, tt.thumbType, tt.tw, tt.th)
+		}
+	}
+}
@@ -1,8 +1,9 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
@@ -15,57 +16,83 @@ type Claims struct {
 	AuthID int64  `json:"authid"`
 	Email  string `json:"email"`
 	Role   string `json:"role"`
+	// SessionID ties the access token to a login session so it can be revoked server-side
+	SessionID string `json:"sid,omitempty"`
+	// APIKeyID and Scopes are set (never signed into tokens) when the request used an API
+	// key; the caller's permissions are then limited to Scopes
+	APIKeyID int64    `json:"-"`
+	Scopes   []string `json:"-"`
 	jwt.RegisteredClaims
 }
 
-var jwtSecret []byte
+// APIKeyPrefix starts every API key, telling keys apart from JWTs in the Authorization header
+const APIKeyPrefix = "gsk_"
 
-func init() {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "dev-secret-please-change"
-	}
-	jwtSecret = []byte(secret)
+// APIKeyAuthenticator resolves an API key to the claims of its owner, or says why it is
+// not accepted
+type APIKeyAuthenticator func(ctx context.Context, key, ip string) (*Claims, error)
+
+var apiKeyAuthenticator APIKeyAuthenticator
+
+// SetAPIKeyAuthenticator enables API keys (X-API-Key header, or a Bearer credential
+// starting with APIKeyPrefix) as an alternative to access tokens
+func SetAPIKeyAuthenticator(a APIKeyAuthenticator) {
+	apiKeyAuthenticator = a
+}
+
+// SessionValidator reports why the session behind a token is no longer valid
+// (revoked, expired, user banned); nil means the token may be used.
+type SessionValidator func(ctx context.Context, claims *Claims) error
+
+var sessionValidator SessionValidator
+
+// SetSessionValidator installs the server-side check run for every authenticated request
+func SetSessionValidator(v SessionValidator) {
+	sessionValidator = v
 }
 
-// GenerateToken creates a signed token for the given user details and expiry (in hours)
-func GenerateToken(authid int64, email, role string, hours int) (string, error) {
+var keys *KeySet
+
+// validMethods are the only algorithms accepted when parsing tokens
+var validMethods = []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}
+
+// tokenIssuer is the iss claim of the access tokens this API signs and accepts
+const tokenIssuer = "yesh-api"
+
+// SetKeySet installs the keys used to sign and verify tokens; it must be called at startup
+func SetKeySet(ks *KeySet) {
+	keys = ks
+}
+
+// CurrentKeySet returns the installed key set
+func CurrentKeySet() *KeySet {
+	return keys
+}
+
+// GenerateToken creates a signed access token for the given user details, session and lifetime
+func GenerateToken(authid int64, email, role, sessionID string, ttl time.Duration) (string, error) {
 	claims := &Claims{
-		AuthID: authid,
-		Email:  email,
-		Role:   role,
+		AuthID:    authid,
+		Email:     email,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(hours) * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    "yesh-api",
+			Issuer:    tokenIssuer,
 		},
 	}
-	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return t.SignedString(jwtSecret)
+	return keys.sign(claims)
 }
 
-// JWTMiddleware returns an Echo middleware that validates token and sets "user" context
+// JWTMiddleware returns an Echo middleware that validates token and sets "user" context.
+// API keys are accepted as well once an APIKeyAuthenticator is installed.
 func JWTMiddleware() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			auth := c.Request().Header.Get("Authorization")
-			if auth == "" {
-				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "missing authorization header"})
-			}
-			parts := strings.Fields(auth)
-			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid authorization header"})
-			}
-			tokenString := parts[1]
-			token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(t *jwt.Token) (interface{}, error) {
-				return jwtSecret, nil
-			})
-			if err != nil || !token.Valid {
-				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid or expired token"})
-			}
-			claims, ok := token.Claims.(*Claims)
-			if !ok {
-				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid token claims"})
+			claims, err := authenticate(c)
+			if err != nil {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
 			}
 			// attach claims to context
 			c.Set("auth_claims", claims)
@@ -74,6 +101,63 @@ func JWTMiddleware() echo.MiddlewareFunc {
 	}
 }
 
+// authenticate reads the credential of the request: an API key or a bearer access token
+func authenticate(c echo.Context) (*Claims, error) {
+	if key := c.Request().Header.Get("X-API-Key"); key != "" {
+		return authenticateAPIKey(c, key)
+	}
+	auth := c.Request().Header.Get("Authorization")
+	if auth == "" {
+		return nil, errors.New("missing authorization header")
+	}
+	parts := strings.Fields(auth)
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		return nil, errors.New("invalid authorization header")
+	}
+	tokenString := parts[1]
+	if strings.HasPrefix(tokenString, APIKeyPrefix) {
+		return authenticateAPIKey(c, tokenString)
+	}
+	claims, err := keys.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if sessionValidator != nil {
+		if err := sessionValidator(c.Request().Context(), claims); err != nil {
+			return nil, err
+		}
+	}
+	return claims, nil
+}
+
+func authenticateAPIKey(c echo.Context, key string) (*Claims, error) {
+	if apiKeyAuthenticator == nil {
+		return nil, errors.New("API keys are not accepted")
+	}
+	claims, err := apiKeyAuthenticator(c.Request().Context(), key, c.RealIP())
+	if err != nil {
+		return nil, err
+	}
+	if claims.APIKeyID == 0 {
+		return nil, errors.New("invalid API key")
+	}
+	return claims, nil
+}
+
+// RequireSession rejects requests authenticated with an API key. It guards account
+// self-service (password, 2FA, sessions, the keys themselves) that only a signed-in
+// user may use. It must run after JWTMiddleware.
+func RequireSession() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if claims := GetClaims(c); claims != nil && claims.APIKeyID != 0 {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": "not available with an API key, sign in instead"})
+			}
+			return next(c)
+		}
+	}
+}
+
 // Helper to extract claims
 func GetClaims(c echo.Context) *Claims {
 	v := c.Get("auth_claims")
@@ -89,35 +173,12 @@ func GetClaims(c echo.Context) *Claims {
 // TryGetClaimsFromAuthHeader checks Authorization header and parses token if present.
 // Returns claims or nil (no error). If token is present but invalid, returns nil.
 func TryGetClaimsFromAuthHeader(c echo.Context) *Claims {
-	auth := c.Request().Header.Get("Authorization")
-	if auth == "" {
-		return nil
-	}
-	parts := strings.Fields(auth)
-	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+	if c.Request().Header.Get("Authorization") == "" && c.Request().Header.Get("X-API-Key") == "" {
 		return nil
 	}
-	tokenString := parts[1]
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(t *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
-	if err != nil || !token.Valid {
-		return nil
-	}
-	claims, ok := token.Claims.(*Claims)
-	if !ok {
+	claims, err := authenticate(c)
+	if err != nil {
 		return nil
 	}
 	return claims
 }
-
-// AdminOnly middleware requires role == admin
-func AdminOnly(next echo.HandlerFunc) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		claims := GetClaims(c)
-		if claims == nil || claims.Role != "admin" {
-			return c.JSON(http.StatusForbidden, map[string]string{"error": "admin role required"})
-		}
-		return next(c)
-	}
-}
@@ -0,0 +1,257 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrNoSigningKey is returned when no private key is configured to sign tokens
+var ErrNoSigningKey = errors.New("jwt: no signing key configured (set JWT_PRIVATE_KEY or JWT_KEYS_DIR)")
+
+// jwtKey is one key of the key set; private is nil for verification-only keys
+type jwtKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// KeySet holds the key used to sign new tokens and every key still accepted for
+// verification. During a rotation the old public key stays in the set until the
+// tokens it signed have expired.
+type KeySet struct {
+	signing *jwtKey
+	keys    map[string]*jwtKey
+}
+
+// NewKeySet returns an empty key set
+func NewKeySet() *KeySet {
+	return &KeySet{keys: map[string]*jwtKey{}}
+}
+
+// AddPEM parses a PEM encoded key (PKCS#8 or PKCS#1 private key, or PKIX public key;
+// RSA or Ed25519) and adds it under kid
+func (ks *KeySet) AddPEM(kid string, data []byte) error {
+	if kid == "" {
+		return errors.New("jwt: key id is required")
+	}
+	if _, dup := ks.keys[kid]; dup {
+		return fmt.Errorf("jwt: duplicate key id %q", kid)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return fmt.Errorf("jwt: key %q is not PEM encoded", kid)
+	}
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return fmt.Errorf("jwt: key %q has unsupported PEM type %q", kid, block.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("jwt: key %q: %w", kid, err)
+	}
+
+	k := &jwtKey{kid: kid}
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		k.method, k.private, k.public = jwt.SigningMethodRS256, key, &key.PublicKey
+	case *rsa.PublicKey:
+		k.method, k.public = jwt.SigningMethodRS256, key
+	case ed25519.PrivateKey:
+		k.method, k.private, k.public = jwt.SigningMethodEdDSA, key, key.Public()
+	case ed25519.PublicKey:
+		k.method, k.public = jwt.SigningMethodEdDSA, key
+	default:
+		return fmt.Errorf("jwt: key %q must be RSA or Ed25519", kid)
+	}
+	if rk, ok := k.public.(*rsa.PublicKey); ok && rk.N.BitLen() < 2048 {
+		return fmt.Errorf("jwt: RSA key %q must be at least 2048 bits", kid)
+	}
+	ks.keys[kid] = k
+	return nil
+}
+
+// SetSigningKey selects the private key used to sign new tokens
+func (ks *KeySet) SetSigningKey(kid string) error {
+	k, ok := ks.keys[kid]
+	if !ok {
+		return fmt.Errorf("jwt: signing key %q not found", kid)
+	}
+	if k.private == nil {
+		return fmt.Errorf("jwt: key %q has no private key", kid)
+	}
+	ks.signing = k
+	return nil
+}
+
+// SigningKeyID returns the kid new tokens are signed with
+func (ks *KeySet) SigningKeyID() string {
+	if ks.signing == nil {
+		return ""
+	}
+	return ks.signing.kid
+}
+
+// LoadKeySetFromEnv builds the key set from the environment:
+//
+//	JWT_PRIVATE_KEY  PEM private key used for signing (kid from JWT_KEY_ID, default "default")
+//	JWT_KEYS_DIR     directory of <kid>.pem files: private keys and/or public keys kept
+//	                 for verification while tokens signed by a retired key expire
+//	JWT_SIGNING_KID  kid of the signing key when JWT_KEYS_DIR holds several private keys
+//
+// It fails when no signing key can be determined.
+func LoadKeySetFromEnv() (*KeySet, error) {
+	ks := NewKeySet()
+	signingKid := os.Getenv("JWT_SIGNING_KID")
+
+	if pemData := os.Getenv("JWT_PRIVATE_KEY"); pemData != "" {
+		kid := os.Getenv("JWT_KEY_ID")
+		if kid == "" {
+			kid = "default"
+		}
+		if err := ks.AddPEM(kid, []byte(pemData)); err != nil {
+			return nil, err
+		}
+		if signingKid == "" {
+			signingKid = kid
+		}
+	}
+
+	if dir := os.Getenv("JWT_KEYS_DIR"); dir != "" {
+		files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+		for _, f := range files {
+			data, err := os.ReadFile(f)
+			if err != nil {
+				return nil, err
+			}
+			if err := ks.AddPEM(strings.TrimSuffix(filepath.Base(f), ".pem"), data); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if signingKid == "" {
+		// exactly one private key: use it
+		for kid, k := range ks.keys {
+			if k.private == nil {
+				continue
+			}
+			if signingKid != "" {
+				return nil, errors.New("jwt: several private keys configured, set JWT_SIGNING_KID")
+			}
+			signingKid = kid
+		}
+	}
+	if signingKid == "" {
+		return nil, ErrNoSigningKey
+	}
+	if err := ks.SetSigningKey(signingKid); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// sign signs claims with the signing key and sets the kid header
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	if ks == nil || ks.signing == nil {
+		return "", ErrNoSigningKey
+	}
+	t := jwt.NewWithClaims(ks.signing.method, claims)
+	t.Header["kid"] = ks.signing.kid
+	return t.SignedString(ks.signing.private)
+}
+
+// keyFunc resolves the verification key from the kid header, refusing an algorithm
+// other than the one of that key
+func (ks *KeySet) keyFunc(t *jwt.Token) (interface{}, error) {
+	if ks == nil {
+		return nil, ErrNoSigningKey
+	}
+	kid, _ := t.Header["kid"].(string)
+	k, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("jwt: unknown key id %q", kid)
+	}
+	if t.Method.Alg() != k.method.Alg() {
+		return nil, fmt.Errorf("jwt: unexpected signing method %s", t.Method.Alg())
+	}
+	return k.public, nil
+}
+
+// parse verifies an access token signed by a key of the set and returns its claims
+func (ks *KeySet) parse(tokenString string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, ks.keyFunc,
+		jwt.WithValidMethods(validMethods), jwt.WithIssuer(tokenIssuer))
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid or expired token")
+	}
+	claims, ok := token.Claims.(*Claims)
+	if !ok {
+		return nil, errors.New("invalid token claims")
+	}
+	return claims, nil
+}
+
+// JWK is one entry of a JSON Web Key Set (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS returns the public keys of the set, for /.well-known/jwks.json
+func (ks *KeySet) JWKS() map[string][]JWK {
+	kids := make([]string, 0, len(ks.keys))
+	for kid := range ks.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	out := make([]JWK, 0, len(kids))
+	enc := base64.RawURLEncoding
+	for _, kid := range kids {
+		k := ks.keys[kid]
+		j := JWK{Kid: kid, Use: "sig", Alg: k.method.Alg()}
+		switch pub := k.public.(type) {
+		case *rsa.PublicKey:
+			j.Kty = "RSA"
+			j.N = enc.EncodeToString(pub.N.Bytes())
+			j.E = enc.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			j.Kty = "OKP"
+			j.Crv = "Ed25519"
+			j.X = enc.EncodeToString(pub)
+		}
+		out = append(out, j)
+	}
+	return map[string][]JWK{"keys": out}
+}
@@ -0,0 +1,347 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	rsaOnce sync.Once
+	rsaKey  *rsa.PrivateKey
+)
+
+// testRSAKey returns a 2048-bit key shared by the tests, generating one is slow
+func testRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	rsaOnce.Do(func() {
+		rsaKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+	})
+	if rsaKey == nil {
+		t.Fatal("generating an RSA key failed")
+	}
+	return rsaKey
+}
+
+func testEd25519Key(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv
+}
+
+func pkcs8PEM(t *testing.T, key interface{}) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func publicPEM(t *testing.T, key interface{}) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+// signingKeySet returns a key set signing with the private key stored under kid
+func signingKeySet(t *testing.T, kid string, privatePEM []byte) *KeySet {
+	t.Helper()
+	ks := NewKeySet()
+	if err := ks.AddPEM(kid, privatePEM); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.SetSigningKey(kid); err != nil {
+		t.Fatal(err)
+	}
+	return ks
+}
+
+func testClaims() *Claims {
+	return &Claims{
+		AuthID: 7,
+		Email:  "ana@example.com",
+		Role:   "user",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    tokenIssuer,
+		},
+	}
+}
+
+func TestAddPEM(t *testing.T) {
+	rsaPriv := testRSAKey(t)
+	edPriv := testEd25519Key(t)
+	smallRSA, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		alg     string
+		private bool
+		wantErr string
+	}{
+		{"RSA PKCS#8", pkcs8PEM(t, rsaPriv), "RS256", true, ""},
+		{"RSA PKCS#1", pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaPriv)}), "RS256", true, ""},
+		{"RSA public", publicPEM(t, &rsaPriv.PublicKey), "RS256", false, ""},
+		{"Ed25519", pkcs8PEM(t, edPriv), "EdDSA", true, ""},
+		{"Ed25519 public", publicPEM(t, edPriv.Public()), "EdDSA", false, ""},
+		{"RSA 1024", pkcs8PEM(t, smallRSA), "", false, "at least 2048 bits"},
+		{"RSA 1024 public", publicPEM(t, &smallRSA.PublicKey), "", false, "at least 2048 bits"},
+		{"ECDSA", pkcs8PEM(t, ecPriv), "", false, "must be RSA or Ed25519"},
+		{"certificate", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte{1}}), "", false, "unsupported PEM type"},
+		{"not PEM", []byte("secret"), "", false, "not PEM encoded"},
+		{"garbage DER", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte{1, 2, 3}}), "", false, `key "k"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks := NewKeySet()
+			err := ks.AddPEM("k", tt.data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				if len(ks.keys) != 0 {
+					t.Fatal("a rejected key was added")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			k := ks.keys["k"]
+			if k.method.Alg() != tt.alg || (k.private != nil) != tt.private {
+				t.Fatalf("key = %s private=%v, want %s private=%v", k.method.Alg(), k.private != nil, tt.alg, tt.private)
+			}
+			if err := ks.SetSigningKey("k"); (err == nil) != tt.private {
+				t.Fatalf("SetSigningKey = %v, private=%v", err, tt.private)
+			}
+		})
+	}
+
+	ks := NewKeySet()
+	if err := ks.AddPEM("", pkcs8PEM(t, edPriv)); err == nil {
+		t.Fatal("key without an id accepted")
+	}
+	if err := ks.AddPEM("k", pkcs8PEM(t, edPriv)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.AddPEM("k", pkcs8PEM(t, testEd25519Key(t))); err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Fatalf("duplicate kid: %v", err)
+	}
+	if err := ks.SetSigningKey("missing"); err == nil {
+		t.Fatal("unknown signing key selected")
+	}
+}
+
+func TestLoadKeySetFromEnv(t *testing.T) {
+	edA, edB := pkcs8PEM(t, testEd25519Key(t)), pkcs8PEM(t, testEd25519Key(t))
+	retired := publicPEM(t, testEd25519Key(t).Public())
+
+	keysDir := func(t *testing.T, files map[string][]byte) string {
+		dir := t.TempDir()
+		for name, data := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+
+	tests := []struct {
+		name       string
+		privateKey []byte
+		keyID      string
+		files      map[string][]byte
+		signingKid string
+		want       string // signing kid, empty when loading must fail
+		wantErr    error
+	}{
+		{name: "private key", privateKey: edA, want: "default"},
+		{name: "private key with id", privateKey: edA, keyID: "2026-10", want: "2026-10"},
+		{name: "private key and retired keys", privateKey: edA, keyID: "new", files: map[string][]byte{"old.pem": retired}, want: "new"},
+		{name: "one private key in dir", files: map[string][]byte{"a.pem": edA, "old.pem": retired, "notes.txt": []byte("x")}, want: "a"},
+		{name: "several private keys", files: map[string][]byte{"a.pem": edA, "b.pem": edB}},
+		{name: "several private keys and a signing kid", files: map[string][]byte{"a.pem": edA, "b.pem": edB}, signingKid: "b", want: "b"},
+		{name: "signing kid overrides private key", privateKey: edA, files: map[string][]byte{"b.pem": edB}, signingKid: "b", want: "b"},
+		{name: "signing kid of a public key", files: map[string][]byte{"a.pem": edA, "old.pem": retired}, signingKid: "old"},
+		{name: "unknown signing kid", files: map[string][]byte{"a.pem": edA}, signingKid: "c"},
+		{name: "public keys only", files: map[string][]byte{"old.pem": retired}, wantErr: ErrNoSigningKey},
+		{name: "nothing", wantErr: ErrNoSigningKey},
+		{name: "same kid twice", privateKey: edA, keyID: "a", files: map[string][]byte{"a.pem": edB}},
+		{name: "bad file", files: map[string][]byte{"a.pem": edA, "bad.pem": []byte("x")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("JWT_PRIVATE_KEY", string(tt.privateKey))
+			t.Setenv("JWT_KEY_ID", tt.keyID)
+			t.Setenv("JWT_SIGNING_KID", tt.signingKid)
+			dir := ""
+			if tt.files != nil {
+				dir = keysDir(t, tt.files)
+			}
+			t.Setenv("JWT_KEYS_DIR", dir)
+
+			ks, err := LoadKeySetFromEnv()
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("loaded a key set signing with %q", ks.SigningKeyID())
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ks.SigningKeyID() != tt.want {
+				t.Fatalf("signing kid = %q, want %q", ks.SigningKeyID(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseToken(t *testing.T) {
+	rsaPriv := testRSAKey(t)
+	edPriv := testEd25519Key(t)
+	ks := signingKeySet(t, "ed", pkcs8PEM(t, edPriv))
+	if err := ks.AddPEM("rsa", publicPEM(t, &rsaPriv.PublicKey)); err != nil {
+		t.Fatal(err)
+	}
+
+	signed := func(method jwt.SigningMethod, kid string, claims jwt.Claims, key interface{}) string {
+		t.Helper()
+		tok := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			tok.Header["kid"] = kid
+		}
+		s, err := tok.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	withClaims := func(edit func(c *Claims)) *Claims {
+		c := testClaims()
+		edit(c)
+		return c
+	}
+
+	token, err := ks.sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ks.parse(token)
+	if err != nil {
+		t.Fatalf("own token: %v", err)
+	}
+	if claims.AuthID != 7 || claims.Email != "ana@example.com" {
+		t.Fatalf("claims = %+v", claims)
+	}
+	// a key kept for verification only still verifies the tokens it signed
+	if _, err := ks.parse(signed(jwt.SigningMethodRS256, "rsa", testClaims(), rsaPriv)); err != nil {
+		t.Fatalf("token of a retired key: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		// the RSA public key is published in the JWKS; it must not work as an HMAC secret
+		{"HS256 signed with the public key", signed(jwt.SigningMethodHS256, "rsa", testClaims(), publicPEM(t, &rsaPriv.PublicKey))},
+		{"none", signed(jwt.SigningMethodNone, "ed", testClaims(), jwt.UnsafeAllowNoneSignatureType)},
+		{"EdDSA under an RSA kid", signed(jwt.SigningMethodEdDSA, "rsa", testClaims(), edPriv)},
+		{"RS256 under an EdDSA kid", signed(jwt.SigningMethodRS256, "ed", testClaims(), rsaPriv)},
+		{"unknown kid", signed(jwt.SigningMethodEdDSA, "other", testClaims(), edPriv)},
+		{"no kid", signed(jwt.SigningMethodEdDSA, "", testClaims(), edPriv)},
+		{"foreign key", signed(jwt.SigningMethodEdDSA, "ed", testClaims(), testEd25519Key(t))},
+		{"other issuer", signed(jwt.SigningMethodEdDSA, "ed", withClaims(func(c *Claims) { c.Issuer = "someone-else" }), edPriv)},
+		{"no issuer", signed(jwt.SigningMethodEdDSA, "ed", withClaims(func(c *Claims) { c.Issuer = "" }), edPriv)},
+		{"expired", signed(jwt.SigningMethodEdDSA, "ed", withClaims(func(c *Claims) {
+			c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+		}), edPriv)},
+		{"tampered", token[:len(token)-4] + "AAAA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ks.parse(tt.token); err == nil {
+				t.Fatal("token accepted")
+			}
+		})
+	}
+
+	var empty *KeySet
+	if _, err := empty.sign(testClaims()); !errors.Is(err, ErrNoSigningKey) {
+		t.Fatalf("sign without keys: %v", err)
+	}
+	if _, err := empty.parse(token); err == nil {
+		t.Fatal("token accepted without keys")
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	rsaPriv := testRSAKey(t)
+	edPriv := testEd25519Key(t)
+	ks := signingKeySet(t, "b-ed", pkcs8PEM(t, edPriv))
+	if err := ks.AddPEM("a-rsa", publicPEM(t, &rsaPriv.PublicKey)); err != nil {
+		t.Fatal(err)
+	}
+
+	set := ks.JWKS()["keys"]
+	if len(set) != 2 || set[0].Kid != "a-rsa" || set[1].Kid != "b-ed" {
+		t.Fatalf("keys = %+v, want a-rsa and b-ed in order", set)
+	}
+	enc := base64.RawURLEncoding
+
+	r := set[0]
+	if r.Kty != "RSA" || r.Alg != "RS256" || r.Use != "sig" || r.Crv != "" || r.X != "" {
+		t.Fatalf("RSA key = %+v", r)
+	}
+	n, err := enc.DecodeString(r.N)
+	if err != nil || new(big.Int).SetBytes(n).Cmp(rsaPriv.N) != 0 {
+		t.Fatalf("n does not match the key (%v)", err)
+	}
+	e, err := enc.DecodeString(r.E)
+	if err != nil || new(big.Int).SetBytes(e).Int64() != int64(rsaPriv.E) {
+		t.Fatalf("e does not match the key (%v)", err)
+	}
+
+	ed := set[1]
+	if ed.Kty != "OKP" || ed.Crv != "Ed25519" || ed.Alg != "EdDSA" || ed.Use != "sig" || ed.N != "" || ed.E != "" {
+		t.Fatalf("Ed25519 key = %+v", ed)
+	}
+	x, err := enc.DecodeString(ed.X)
+	if err != nil || !edPriv.Public().(ed25519.PublicKey).Equal(ed25519.PublicKey(x)) {
+		t.Fatalf("x does not match the key (%v)", err)
+	}
+}
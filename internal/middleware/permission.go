@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// PermissionResolver returns the permissions currently granted to the account behind claims
+type PermissionResolver func(ctx context.Context, claims *Claims) ([]string, error)
+
+var permissionResolver PermissionResolver
+
+// SetPermissionResolver installs the lookup used by RequirePermission and HasPermission
+func SetPermissionResolver(r PermissionResolver) {
+	permissionResolver = r
+}
+
+// permissions resolves (once per request) the permission set of the caller
+func permissions(c echo.Context) map[string]bool {
+	if v, ok := c.Get("auth_permissions").(map[string]bool); ok {
+		return v
+	}
+	set := map[string]bool{}
+	claims := GetClaims(c)
+	if claims == nil {
+		claims = TryGetClaimsFromAuthHeader(c)
+	}
+	if claims != nil && permissionResolver != nil {
+		if perms, err := permissionResolver(c.Request().Context(), claims); err == nil {
+			for _, p := range perms {
+				set[p] = true
+			}
+		}
+	}
+	if claims != nil && claims.APIKeyID != 0 {
+		// an API key holds the owner's permissions that are also in its scopes
+		scoped := map[string]bool{}
+		for _, p := range claims.Scopes {
+			if set[p] {
+				scoped[p] = true
+			}
+		}
+		set = scoped
+	}
+	c.Set("auth_permissions", set)
+	return set
+}
+
+// HasPermission reports whether the caller holds the permission
+func HasPermission(c echo.Context, perm string) bool {
+	return permissions(c)[perm]
+}
+
+// HasAnyPermission reports whether the caller holds at least one of the permissions
+func HasAnyPermission(c echo.Context, perms ...string) bool {
+	set := permissions(c)
+	for _, p := range perms {
+		if set[p] {
+			return true
+		}
+	}
+	return false
+}
+
+// RequirePermission rejects requests whose caller lacks any of the given permissions.
+// It must run after JWTMiddleware.
+func RequirePermission(perms ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if GetClaims(c) == nil {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "unauthenticated"})
+			}
+			set := permissions(c)
+			for _, p := range perms {
+				if !set[p] {
+					return c.JSON(http.StatusForbidden, map[string]string{"error": "missing permission " + p})
+				}
+			}
+			return next(c)
+		}
+	}
+}
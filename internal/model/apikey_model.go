@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// APIKey is a long-lived credential for automation (CI publishing). It acts as its owner,
+// limited to Scopes; only the SHA-256 of the key is stored, Prefix identifies it in lists.
+type APIKey struct {
+	KeyID      int64      `json:"keyid"`
+	AuthID     int64      `json:"authid"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP *string    `json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CreatedAPIKey is returned once, when the key is created; Key cannot be retrieved later
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,52 @@
+package model
+
+import "time"
+
+// Audit events
+const (
+	AuditLoginLocked      = "login.locked"
+	AuditRegisterLocked   = "register.locked"
+	AuditLockoutCleared   = "lockout.cleared"
+	AuditUserBanned       = "user.banned"
+	AuditUserUnbanned     = "user.unbanned"
+	AuditAccountDeleted   = "account.deleted"
+	AuditIdentityLinked   = "identity.linked"
+	AuditIdentityUnlinked = "identity.unlinked"
+	AuditAPIKeyCreated    = "apikey.created"
+	AuditAPIKeyRevoked    = "apikey.revoked"
+
+	AuditDevApplicationApproved = "devapplication.approved"
+	AuditDevApplicationRejected = "devapplication.rejected"
+	AuditDevMemberInvited       = "devmember.invited"
+	AuditDevMemberAdded         = "devmember.added"
+	AuditDevMemberUpdated       = "devmember.updated"
+	AuditDevMemberRemoved       = "devmember.removed"
+)
+
+// AuditEntry is a row of auditlog. ActorAuthID is nil for events without a signed-in actor.
+type AuditEntry struct {
+	AuditID     int64      `json:"auditid"`
+	ActorAuthID *int64     `json:"actorauthid,omitempty"`
+	Event       string     `json:"event"`
+	Subject     *string    `json:"subject,omitempty"`
+	Detail      *string    `json:"detail,omitempty"`
+	IPAddress   *string    `json:"ipaddress,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+}
+
+// Kinds of authattempts counters
+const (
+	AttemptAccount  = "account"  // failed logins per email
+	AttemptIP       = "ip"       // failed logins per client IP
+	AttemptRegister = "register" // registrations per client IP
+)
+
+// AuthAttempt is a failed-attempt counter with its current lockout
+type AuthAttempt struct {
+	Kind          string     `json:"kind"`
+	Key           string     `json:"key"`
+	Failures      int        `json:"failures"`
+	FirstFailedAt time.Time  `json:"first_failed_at"`
+	LastFailedAt  time.Time  `json:"last_failed_at"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+}
@@ -9,4 +9,22 @@ type Auth struct {
 	Role         string     `json:"role"`
 	CreatedAt    *time.Time `json:"created_at,omitempty"`
 	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
+	// EmailVerifiedAt is nil until the emailed verification link is used
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	// BannedAt is set while the account is banned; a ban with BannedUntil is a suspension
+	// that ends on its own. DeletedAt marks an account its owner deleted.
+	BannedAt    *time.Time `json:"banned_at,omitempty"`
+	BannedUntil *time.Time `json:"banned_until,omitempty"`
+	BanReason   *string    `json:"banreason,omitempty"`
 }
+
+// Purposes of single-use tokens sent by email (authtokens.purpose)
+const (
+	TokenVerifyEmail   = "verify_email"
+	TokenPasswordReset = "password_reset"
+	TokenLogin2FA      = "login_2fa"
+)
+
+// NoPassword is stored as the password hash of accounts without a password (created by
+// signing in with an identity provider, or deleted); no password matches it.
+const NoPassword = "!"
@@ -1,40 +1,147 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"GameStoreAPI/internal/money"
+)
+
+// Order statuses derived from payments, refunds and cancellation
+const (
+	OrderAwaitingPayment   = "awaiting_payment"
+	OrderPaid              = "paid"
+	OrderPartiallyRefunded = "partially_refunded"
+	OrderRefunded          = "refunded"
+	OrderCancelled         = "cancelled"
+)
 
 // Order represents an entry in the orders table
 type Order struct {
-	OrderID    int64      `json:"orderid"`
-	CustomerID int64      `json:"customerid"`
-	OrderDate  *time.Time `json:"orderdate,omitempty"`
-	TotalPrice *float64   `json:"totalprice,omitempty"`
-	CreatedAt  *time.Time `json:"created_at,omitempty"`
-	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	OrderID           int64         `json:"orderid"`
+	CustomerID        int64         `json:"customerid"`
+	OrderDate         *time.Time    `json:"orderdate,omitempty"`
+	TotalPrice        *money.Amount `json:"totalprice,omitempty"`
+	RefundedTotal     money.Amount  `json:"refundedtotal"`
+	Currency          string        `json:"currency"`
+	PromoID           *int64        `json:"promoid,omitempty"`
+	Subtotal          *money.Amount `json:"subtotal,omitempty"`
+	Discount          money.Amount  `json:"discount"`
+	TaxAmount         money.Amount  `json:"taxamount"`
+	TaxName           *string       `json:"taxname,omitempty"`
+	TaxRate           *string       `json:"taxrate,omitempty"`
+	TaxInclusive      bool          `json:"taxinclusive"`
+	BillingCountry    *string       `json:"billing_country,omitempty"`
+	BillingRegion     *string       `json:"billing_region,omitempty"`
+	BillingPostalCode *string       `json:"billing_postalcode,omitempty"`
+	Status            string        `json:"status,omitempty"`
+	CreatedAt         *time.Time    `json:"created_at,omitempty"`
+	DeletedAt         *time.Time    `json:"deleted_at,omitempty"`
+	CancelledAt       *time.Time    `json:"cancelled_at,omitempty"`
 }
 
 // OrderItem represents a row in the orderitems table
 type OrderItem struct {
-	OrderItemID     int64      `json:"orderitemid"`
-	OrderID         int64      `json:"orderid"`
-	GameID          int64      `json:"gameid"`
-	Quantity        int        `json:"quantity"`
-	PriceAtPurchase float64    `json:"priceatpurchase"`
-	CreatedAt       *time.Time `json:"created_at,omitempty"`
-	DeletedAt       *time.Time `json:"deleted_at,omitempty"`
+	OrderItemID     int64         `json:"orderitemid"`
+	OrderID         int64         `json:"orderid"`
+	GameID          int64         `json:"gameid"`
+	Title           string        `json:"title,omitempty"`
+	Quantity        int           `json:"quantity"`
+	PriceAtPurchase money.Amount  `json:"priceatpurchase"`
+	Currency        string        `json:"currency"`
+	Discount        money.Amount  `json:"discount"`
+	TaxAmount       money.Amount  `json:"taxamount"`
+	LineTotal       *money.Amount `json:"linetotal,omitempty"`
+	CreatedAt       *time.Time    `json:"created_at,omitempty"`
+	DeletedAt       *time.Time    `json:"deleted_at,omitempty"`
+	RefundedAt      *time.Time    `json:"refunded_at,omitempty"`
 }
 
 // CartItem is what the API exposes (joined with games.title)
 type CartItem struct {
-	OrderItemID     int64   `json:"orderitemid"`
-	GameID          int64   `json:"gameid"`
-	Title           string  `json:"title"`
-	Quantity        int     `json:"quantity"`
-	PriceAtPurchase float64 `json:"priceatpurchase"`
-	Subtotal        float64 `json:"subtotal"`
+	OrderItemID     int64        `json:"orderitemid"`
+	GameID          int64        `json:"gameid"`
+	Title           string       `json:"title"`
+	Quantity        int          `json:"quantity"`
+	PriceAtPurchase money.Amount `json:"priceatpurchase"`
+	Currency        string       `json:"currency"`
+	Subtotal        money.Amount `json:"subtotal"`
+	GiftTo          *int64       `json:"gift_to,omitempty"` // recipient customerid when the line is a gift
+	GiftMessage     *string      `json:"gift_message,omitempty"`
 }
 
-// CartResponse is returned when calling GET /api/cart
+// CartResponse is returned when calling GET /api/cart. Total is Subtotal minus Discount, plus Tax
+// when prices exclude it; PromoError explains why an applied code currently gives no discount and
+// Changes lists lines that no longer match the catalog (they must be resolved before checkout).
 type CartResponse struct {
-	Items []CartItem `json:"items"`
-	Total float64    `json:"total"`
+	Items        []CartItem       `json:"items"`
+	Subtotal     money.Amount     `json:"subtotal"`
+	PromoCode    *string          `json:"promocode,omitempty"`
+	Discount     money.Amount     `json:"discount"`
+	PromoError   string           `json:"promoerror,omitempty"`
+	Tax          money.Amount     `json:"tax"`
+	TaxName      string           `json:"taxname,omitempty"`
+	TaxInclusive bool             `json:"taxinclusive"`
+	TaxNote      string           `json:"taxnote,omitempty"`
+	Total        money.Amount     `json:"total"`
+	Currency     string           `json:"currency"`
+	Changes      []CartItemChange `json:"changes,omitempty"`
+}
+
+// CheckoutRequest is the body of POST /api/cart/checkout
+type CheckoutRequest struct {
+	PaymentMethodID int64  `json:"paymentmethodid"`
+	CardToken       string `json:"cardtoken"`
+	// AcceptPriceChanges re-prices lines whose price changed since they were added
+	// instead of rejecting the checkout
+	AcceptPriceChanges bool `json:"accept_price_changes"`
+}
+
+// Reasons a cart line no longer matches the catalog
+const (
+	CartChangePrice       = "price_changed"
+	CartChangeDeleted     = "game_deleted"
+	CartChangeUnreleased  = "game_unreleased"
+	CartChangeUnavailable = "game_unavailable"
+)
+
+// CartItemChange describes a cart line that differs from the current catalog state
+type CartItemChange struct {
+	OrderItemID int64         `json:"orderitemid"`
+	GameID      int64         `json:"gameid"`
+	Title       string        `json:"title"`
+	Reason      string        `json:"reason"`
+	OldPrice    money.Amount  `json:"oldprice"`
+	NewPrice    *money.Amount `json:"newprice,omitempty"`
+}
+
+// CheckoutResult is returned when an open order is checked out
+type CheckoutResult struct {
+	OrderID       int64        `json:"orderid"`
+	PaymentID     int64        `json:"paymentid"`
+	PaymentStatus string       `json:"paymentstatus"`
+	Subtotal      money.Amount `json:"subtotal"`
+	Discount      money.Amount `json:"discount"`
+	Tax           money.Amount `json:"tax"`
+	TaxInclusive  bool         `json:"taxinclusive"`
+	Total         money.Amount `json:"total"`
+	Currency      string       `json:"currency"`
+}
+
+// Receipt is the customer-facing breakdown of a checked-out order
+type Receipt struct {
+	OrderID        int64           `json:"orderid"`
+	OrderDate      *time.Time      `json:"orderdate,omitempty"`
+	Status         string          `json:"status"`
+	Currency       string          `json:"currency"`
+	BillingAddress *BillingAddress `json:"billing_address,omitempty"`
+	Lines          []OrderItem     `json:"lines"`
+	Subtotal       money.Amount    `json:"subtotal"`
+	Discount       money.Amount    `json:"discount"`
+	TaxName        *string         `json:"taxname,omitempty"`
+	TaxRate        *string         `json:"taxrate,omitempty"`
+	TaxInclusive   bool            `json:"taxinclusive"`
+	Tax            money.Amount    `json:"tax"`
+	Total          money.Amount    `json:"total"`
+	RefundedTotal  money.Amount    `json:"refundedtotal"`
+	Payment        *Payment        `json:"payment,omitempty"`
 }
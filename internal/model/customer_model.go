@@ -2,14 +2,42 @@ package model
 
 import "time"
 
+// BillingAddress is the structured billing address that determines the tax jurisdiction
+type BillingAddress struct {
+	Country    string `json:"country"`
+	Region     string `json:"region,omitempty"`
+	PostalCode string `json:"postalcode,omitempty"`
+}
+
 type Customer struct {
-	CustomerID int64      `json:"customerid"`
-	AuthID     int64      `json:"authid"`
-	Username   *string    `json:"username,omitempty"`
-	Fullname   *string    `json:"fullname,omitempty"`
-	Email      string     `json:"email"`
-	Address    *string    `json:"address,omitempty"`
-	Phone      *string    `json:"phone,omitempty"`
-	CreatedAt  *time.Time `json:"created_at,omitempty"`
-	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	CustomerID        int64      `json:"customerid"`
+	AuthID            int64      `json:"authid"`
+	Username          *string    `json:"username,omitempty"`
+	Fullname          *string    `json:"fullname,omitempty"`
+	Email             string     `json:"email"`
+	Address           *string    `json:"address,omitempty"`
+	Phone             *string    `json:"phone,omitempty"`
+	Region            *string    `json:"region,omitempty"`
+	Currency          *string    `json:"currency,omitempty"`
+	BillingCountry    *string    `json:"billing_country,omitempty"`
+	BillingRegion     *string    `json:"billing_region,omitempty"`
+	BillingPostalCode *string    `json:"billing_postalcode,omitempty"`
+	CreatedAt         *time.Time `json:"created_at,omitempty"`
+	DeletedAt         *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ExportedOrder is an order with its lines, as included in an AccountExport
+type ExportedOrder struct {
+	Order
+	Items []OrderItem `json:"items"`
+}
+
+// AccountExport is the archive of a customer's personal data (GET /customers/me/export)
+type AccountExport struct {
+	ExportedAt time.Time       `json:"exported_at"`
+	Account    *Auth           `json:"account"`
+	Profile    *Customer       `json:"profile"`
+	Orders     []ExportedOrder `json:"orders"`
+	OwnedGames []Game          `json:"owned_games"`
+	Identities []Identity      `json:"identities"`
 }
@@ -9,3 +9,62 @@ type Developer struct {
 	CreatedAt     *time.Time `json:"created_at,omitempty"`
 	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
 }
+
+// Team roles of developermembers.role. Every member can manage the developer's games;
+// admins also manage members, owners additionally manage owners.
+const (
+	DevRoleOwner  = "owner"
+	DevRoleAdmin  = "admin"
+	DevRoleMember = "member"
+)
+
+// DeveloperMember is an account on the team of a developer (studio)
+type DeveloperMember struct {
+	DeveloperID int64      `json:"developerid"`
+	AuthID      int64      `json:"authid"`
+	Email       string     `json:"email"`
+	Role        string     `json:"role"`
+	AddedBy     *int64     `json:"addedby,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+}
+
+// DeveloperInvite is an invitation to the team of a developer; the account joins with
+// Role when it accepts
+type DeveloperInvite struct {
+	DeveloperID   int64      `json:"developerid"`
+	DeveloperName string     `json:"developername"`
+	AuthID        int64      `json:"authid"`
+	Email         string     `json:"email"`
+	Role          string     `json:"role"`
+	InvitedBy     *int64     `json:"invitedby,omitempty"`
+	CreatedAt     *time.Time `json:"created_at,omitempty"`
+}
+
+// DeveloperMembership is a developer the caller belongs to, with their team role
+type DeveloperMembership struct {
+	Developer
+	Role string `json:"role"`
+}
+
+// Developer application statuses stored in developerapplications.status
+const (
+	DevApplicationPending  = "pending"
+	DevApplicationApproved = "approved"
+	DevApplicationRejected = "rejected"
+)
+
+// DeveloperApplication is an account's request to become a developer. Approving it
+// creates the developer with the applicant as owner; DeveloperID is set then.
+type DeveloperApplication struct {
+	ApplicationID int64      `json:"applicationid"`
+	AuthID        int64      `json:"authid"`
+	DeveloperName string     `json:"developername"`
+	Website       *string    `json:"website,omitempty"`
+	Message       *string    `json:"message,omitempty"`
+	Status        string     `json:"status"`
+	DeveloperID   *int64     `json:"developerid,omitempty"`
+	ReviewedBy    *int64     `json:"reviewedby,omitempty"`
+	ReviewComment *string    `json:"reviewcomment,omitempty"`
+	CreatedAt     *time.Time `json:"created_at,omitempty"`
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty"`
+}
@@ -1,13 +1,153 @@
 package model
 
-import "time"
+import (
+	"time"
 
+	"GameStoreAPI/internal/money"
+)
+
+// Game is a row of the games table. Price is the effective price (the sale price while a
+// sale is running), OriginalPrice the regular price. Descriptions, Media, Requirements and
+// Languages are only loaded for the game detail, lists leave them nil.
 type Game struct {
-	GameID      int64      `json:"gameid"`
-	DeveloperID int64      `json:"developerid"`
-	Title       string     `json:"title"`
-	Price       float64    `json:"price"`
-	ReleaseDate *time.Time `json:"releasedate,omitempty"`
-	CreatedAt   *time.Time `json:"created_at,omitempty"`
-	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	GameID        int64               `json:"gameid"`
+	DeveloperID   int64               `json:"developerid"`
+	Title         string              `json:"title"`
+	Blurb         *string             `json:"blurb,omitempty"`
+	Description   *string             `json:"description,omitempty"`
+	Price         money.Amount        `json:"price"`
+	OriginalPrice money.Amount        `json:"originalprice"`
+	Currency      string              `json:"currency,omitempty"`
+	Sale          *GameSale           `json:"sale,omitempty"`
+	ReleaseDate   *time.Time          `json:"releasedate,omitempty"`
+	PEGIRating    *int                `json:"pegirating,omitempty"`
+	ESRBRating    *string             `json:"esrbrating,omitempty"`
+	Status        string              `json:"status"`
+	Descriptions  []GameDescription   `json:"descriptions,omitempty"`
+	Media         []GameMedia         `json:"media,omitempty"`
+	Requirements  []SystemRequirement `json:"requirements,omitempty"`
+	Languages     []GameLanguage      `json:"languages,omitempty"`
+	CreatedAt     *time.Time          `json:"created_at,omitempty"`
+	DeletedAt     *time.Time          `json:"deleted_at,omitempty"`
+}
+
+// Publishing states of games.status. Only published games are listed, searched and sold.
+const (
+	GameStatusDraft     = "draft"
+	GameStatusSubmitted = "submitted"
+	GameStatusApproved  = "approved"
+	GameStatusRejected  = "rejected"
+	GameStatusPublished = "published"
+	GameStatusDelisted  = "delisted"
+)
+
+// Publishing actions, each moving a game from some states to one other:
+// submit (draft, rejected -> submitted), withdraw (submitted -> draft),
+// approve and reject (submitted -> approved/rejected, by reviewers), publish (approved -> published),
+// delist (published -> delisted), relist (delisted -> published, by reviewers) and
+// revise (delisted -> draft).
+const (
+	GameActionSubmit   = "submit"
+	GameActionWithdraw = "withdraw"
+	GameActionApprove  = "approve"
+	GameActionReject   = "reject"
+	GameActionPublish  = "publish"
+	GameActionDelist   = "delist"
+	GameActionRelist   = "relist"
+	GameActionRevise   = "revise"
+)
+
+// GameTransition is a row of gametransitions: a change of the publishing state of a game.
+// FromStatus is nil for the creation of the game.
+type GameTransition struct {
+	TransitionID int64     `json:"transitionid"`
+	GameID       int64     `json:"gameid"`
+	FromStatus   *string   `json:"fromstatus,omitempty"`
+	ToStatus     string    `json:"tostatus"`
+	ActorID      *int64    `json:"actorid,omitempty"`
+	Comment      *string   `json:"comment,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// GameDescription is the long description of a game in one locale (like "de" or "pt-BR")
+type GameDescription struct {
+	Locale      string `json:"locale"`
+	Description string `json:"description"`
+}
+
+// Kinds of gamemedia.kind
+const (
+	MediaCover      = "cover"
+	MediaScreenshot = "screenshot"
+	MediaTrailer    = "trailer"
+)
+
+// GameMedia references an image or video shown on the game page, in Position order.
+// ThumbnailURL, Width and Height are known for uploaded images.
+type GameMedia struct {
+	MediaID      int64   `json:"mediaid,omitempty"`
+	Kind         string  `json:"kind"`
+	URL          string  `json:"url"`
+	Position     int     `json:"position"`
+	ThumbnailURL *string `json:"thumbnailurl,omitempty"`
+	Width        *int    `json:"width,omitempty"`
+	Height       *int    `json:"height,omitempty"`
+}
+
+// MediaAsset is an uploaded image, stored once per content (SHA256)
+type MediaAsset struct {
+	SHA256       string     `json:"sha256"`
+	ContentType  string     `json:"contenttype"`
+	Size         int        `json:"size"`
+	Width        int        `json:"width"`
+	Height       int        `json:"height"`
+	URL          string     `json:"url"`
+	ThumbnailURL string     `json:"thumbnailurl"`
+	UploadedBy   *int64     `json:"uploadedby,omitempty"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+}
+
+// Platforms and tiers of gamerequirements
+const (
+	PlatformWindows = "windows"
+	PlatformMacOS   = "macos"
+	PlatformLinux   = "linux"
+
+	RequirementMinimum     = "minimum"
+	RequirementRecommended = "recommended"
+)
+
+// SystemRequirement is the minimum or recommended hardware for one platform. The values
+// are free text as shown on the store page ("8 GB RAM").
+type SystemRequirement struct {
+	Platform  string  `json:"platform"`
+	Tier      string  `json:"tier"`
+	OS        *string `json:"os,omitempty"`
+	Processor *string `json:"processor,omitempty"`
+	Memory    *string `json:"memory,omitempty"`
+	Graphics  *string `json:"graphics,omitempty"`
+	Storage   *string `json:"storage,omitempty"`
+	Notes     *string `json:"notes,omitempty"`
+}
+
+// GameLanguage is a language (like "en" or "zh-Hans") the game supports, and how
+type GameLanguage struct {
+	Language  string `json:"language"`
+	Interface bool   `json:"interface"`
+	Audio     bool   `json:"audio"`
+	Subtitles bool   `json:"subtitles"`
+}
+
+// GameSale is a time-boxed price override: either a percentage off (20.00 = 20%) or a
+// fixed sale price in the base currency.
+type GameSale struct {
+	SaleID          int64         `json:"saleid"`
+	GameID          int64         `json:"gameid"`
+	DiscountPercent *money.Amount `json:"discountpercent,omitempty"`
+	SalePrice       *money.Amount `json:"saleprice,omitempty"`
+	StartsAt        time.Time     `json:"starts_at"`
+	EndsAt          time.Time     `json:"ends_at"`
+	CreatedBy       *int64        `json:"createdby,omitempty"`
+	CreatedAt       *time.Time    `json:"created_at,omitempty"`
+	DeletedAt       *time.Time    `json:"deleted_at,omitempty"`
 }
@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// Gift statuses stored in gifts.status
+const (
+	GiftPending  = "pending"
+	GiftAccepted = "accepted"
+	GiftDeclined = "declined"
+	GiftRevoked  = "revoked"
+)
+
+// Gift is a game bought by one customer for another. The recipient only owns the
+// game once the gift is accepted; a refund of the order item revokes it.
+type Gift struct {
+	GiftID      int64      `json:"giftid"`
+	OrderID     int64      `json:"orderid"`
+	OrderItemID int64      `json:"orderitemid"`
+	GameID      int64      `json:"gameid"`
+	Title       string     `json:"title"`
+	SenderID    int64      `json:"senderid"`
+	SenderName  *string    `json:"sendername,omitempty"`
+	RecipientID int64      `json:"recipientid"`
+	Message     *string    `json:"message,omitempty"`
+	Status      string     `json:"status"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	RespondedAt *time.Time `json:"responded_at,omitempty"`
+}
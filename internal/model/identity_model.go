@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// Identity links an account of an external OpenID Connect provider (provider + subject)
+// to a userauth row
+type Identity struct {
+	IdentityID  int64      `json:"identityid"`
+	AuthID      int64      `json:"authid"`
+	Provider    string     `json:"provider"`
+	Subject     string     `json:"subject"`
+	Email       *string    `json:"email,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// OIDCFlow is a pending authorization request, looked up by its state when the provider
+// redirects back. AuthID is set when a signed-in user is linking a provider.
+type OIDCFlow struct {
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	AuthID       *int64
+	ExpiresAt    time.Time
+}
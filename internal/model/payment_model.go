@@ -0,0 +1,45 @@
+package model
+
+import (
+	"time"
+
+	"GameStoreAPI/internal/money"
+)
+
+// Payment statuses stored in payments.paymentstatus
+const (
+	PaymentPending   = "Pending"
+	PaymentPaid      = "Paid"
+	PaymentFailed    = "Failed"
+	PaymentRefunded  = "Refunded"
+	PaymentCancelled = "Cancelled"
+)
+
+// PaymentMethod represents a row in the paymentmethods table
+type PaymentMethod struct {
+	PaymentMethodID int64  `json:"paymentmethodid"`
+	Name            string `json:"name"`
+}
+
+// Payment represents a row in the payments table
+type Payment struct {
+	PaymentID       int64        `json:"paymentid"`
+	OrderID         int64        `json:"orderid"`
+	PaymentMethodID int64        `json:"paymentmethodid"`
+	MethodName      string       `json:"methodname,omitempty"`
+	AmountPaid      money.Amount `json:"amountpaid"`
+	Currency        string       `json:"currency"`
+	PaymentStatus   string       `json:"paymentstatus"`
+	ProviderRef     *string      `json:"providerref,omitempty"`
+	CreatedAt       *time.Time   `json:"createdat,omitempty"`
+	PaidAt          *time.Time   `json:"paidat,omitempty"`
+}
+
+// PaymentLog represents a status transition recorded in paymentlogs
+type PaymentLog struct {
+	LogID     int64      `json:"logid"`
+	PaymentID int64      `json:"paymentid"`
+	OldStatus *string    `json:"oldstatus,omitempty"`
+	NewStatus *string    `json:"newstatus,omitempty"`
+	ChangedAt *time.Time `json:"changedat,omitempty"`
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+
+	"GameStoreAPI/internal/money"
+)
+
+// GamePrice is an explicit regional price for a game (gameprices table)
+type GamePrice struct {
+	GameID    int64        `json:"gameid"`
+	Currency  string       `json:"currency"`
+	Amount    money.Amount `json:"amount"`
+	UpdatedAt *time.Time   `json:"updated_at,omitempty"`
+}
+
+// ExchangeRate converts from the base currency: 1 base unit = Rate units of Currency
+type ExchangeRate struct {
+	Currency  string     `json:"currency"`
+	Rate      string     `json:"rate"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
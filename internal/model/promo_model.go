@@ -0,0 +1,55 @@
+package model
+
+import (
+	"time"
+
+	"GameStoreAPI/internal/money"
+)
+
+// Promo discount types
+const (
+	PromoPercent = "percent"
+	PromoFixed   = "fixed"
+)
+
+// Promo target types
+const (
+	PromoTargetGame      = "game"
+	PromoTargetGenre     = "genre"
+	PromoTargetDeveloper = "developer"
+)
+
+// PromoCode is an admin-managed discount code. Value is a percentage (15.00 = 15%) for
+// percent codes and an amount in the base currency for fixed codes.
+type PromoCode struct {
+	PromoID         int64         `json:"promoid"`
+	Code            string        `json:"code"`
+	DiscountType    string        `json:"discounttype"`
+	Value           money.Amount  `json:"value"`
+	MinimumTotal    *money.Amount `json:"minimumtotal,omitempty"`
+	MaxRedemptions  *int          `json:"maxredemptions,omitempty"`
+	MaxPerCustomer  *int          `json:"maxpercustomer,omitempty"`
+	RedemptionCount int           `json:"redemptioncount"`
+	StartsAt        *time.Time    `json:"starts_at,omitempty"`
+	ExpiresAt       *time.Time    `json:"expires_at,omitempty"`
+	CreatedAt       *time.Time    `json:"created_at,omitempty"`
+	DeletedAt       *time.Time    `json:"deleted_at,omitempty"`
+	Targets         []PromoTarget `json:"targets"`
+}
+
+// PromoTarget restricts a promo code to a game, genre or developer
+type PromoTarget struct {
+	TargetType string `json:"targettype"`
+	TargetID   int64  `json:"targetid"`
+}
+
+// PromoRedemption records a promo code used by a checked-out order
+type PromoRedemption struct {
+	RedemptionID int64        `json:"redemptionid"`
+	PromoID      int64        `json:"promoid"`
+	CustomerID   int64        `json:"customerid"`
+	OrderID      int64        `json:"orderid"`
+	Discount     money.Amount `json:"discount"`
+	Currency     string       `json:"currency"`
+	CreatedAt    *time.Time   `json:"created_at,omitempty"`
+}
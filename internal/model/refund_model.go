@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+
+	"GameStoreAPI/internal/money"
+)
+
+// Refund statuses stored in refunds.status
+const (
+	RefundRequested = "Requested"
+	RefundCompleted = "Completed"
+	RefundRejected  = "Rejected"
+)
+
+// Refund represents a row in the refunds table. OrderItemID is nil for a
+// refund of the whole (remaining) order.
+type Refund struct {
+	RefundID      int64        `json:"refundid"`
+	OrderID       int64        `json:"orderid"`
+	OrderItemID   *int64       `json:"orderitemid,omitempty"`
+	PaymentID     int64        `json:"paymentid"`
+	Amount        money.Amount `json:"amount"`
+	Reason        *string      `json:"reason,omitempty"`
+	Status        string       `json:"status"`
+	RequestedBy   *int64       `json:"requestedby,omitempty"`
+	ReviewedBy    *int64       `json:"reviewedby,omitempty"`
+	ReviewComment *string      `json:"reviewcomment,omitempty"`
+	CreatedAt     *time.Time   `json:"created_at,omitempty"`
+	ReviewedAt    *time.Time   `json:"reviewed_at,omitempty"`
+}
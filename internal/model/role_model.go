@@ -0,0 +1,42 @@
+package model
+
+import "time"
+
+// Permissions checked by the API. Roles are mapped to sets of them in rolepermissions.
+const (
+	PermGamesWriteOwn    = "games:write:own"
+	PermGamesWriteAny    = "games:write:any"
+	PermGamesReview      = "games:review"
+	PermGenresWrite      = "genres:write"
+	PermDevelopersManage = "developers:manage"
+	PermAccountsCreate   = "accounts:create"
+	PermUsersRead        = "users:read"
+	PermUsersBan         = "users:ban"
+	PermUsers2FAReset    = "users:2fa:reset"
+	PermOrdersReadAny    = "orders:read:any"
+	PermPaymentsManage   = "payments:manage"
+	PermRefundsReview    = "refunds:review"
+	PermPricingManage    = "pricing:manage"
+	PermPromosManage     = "promos:manage"
+	PermRolesManage      = "roles:manage"
+	PermLockoutsManage   = "lockouts:manage"
+	PermAuditRead        = "audit:read"
+	PermAPIKeysManage    = "apikeys:manage"
+)
+
+// Role is a named set of permissions assigned to accounts (userauth.role)
+type Role struct {
+	Role        string  `json:"role"`
+	Description *string `json:"description,omitempty"`
+	Builtin     bool    `json:"builtin"`
+	// Requires2FA withholds the role's permissions until the account enables two-factor authentication
+	Requires2FA bool       `json:"requires_2fa"`
+	Permissions []string   `json:"permissions"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+}
+
+// Permission is a row of the permissions table
+type Permission struct {
+	Permission  string  `json:"permission"`
+	Description *string `json:"description,omitempty"`
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"time"
+
+	"GameStoreAPI/internal/money"
+	"GameStoreAPI/internal/pagination"
+)
+
+// Sort keys of catalog searches
+const (
+	SortRelevance   = "relevance"
+	SortPrice       = "price"
+	SortReleaseDate = "release_date"
+	SortPopularity  = "popularity"
+)
+
+// GameSearch holds the filters of a catalog search. Empty filters match every game;
+// price bounds apply to the effective price in Currency.
+type GameSearch struct {
+	Query        string
+	GenreIDs     []int64
+	DeveloperIDs []int64
+	MinPrice     *money.Amount
+	MaxPrice     *money.Amount
+	ReleasedFrom *time.Time
+	ReleasedTo   *time.Time
+	Sort         string
+	Order        string // asc or desc; price sorts ascending by default, the others descending
+	// Currency is the resolved currency prices are filtered and sorted in, "" for the base
+	// currency; SearchGames sets it from the currency the results are shown in
+	Currency string
+}
+
+// GameSearchResult is a page of matching games with the facets of all matches
+type GameSearchResult struct {
+	*pagination.Page[Game]
+	Facets SearchFacets `json:"facets"`
+}
+
+// SearchFacets count the matches per genre and per developer. Each facet ignores its own
+// filter, so the other genres (developers) can be offered with their counts.
+type SearchFacets struct {
+	Genres     []FacetCount `json:"genres"`
+	Developers []FacetCount `json:"developers"`
+}
+
+// FacetCount is the number of matching games for one genre or developer
+type FacetCount struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+// Reasons stored in authsessions.revokedreason
+const (
+	SessionLogout          = "logout"
+	SessionLogoutAll       = "logout_all"
+	SessionTokenReuse      = "refresh_token_reuse"
+	SessionUserBlocked     = "user_blocked"
+	SessionPasswordChanged = "password_changed"
+	SessionPasswordReset   = "password_reset"
+	SessionTwoFactorReset  = "2fa_reset"
+	SessionAccountDeleted  = "account_deleted"
+)
+
+// Session is a login: one family of rotating refresh tokens. Access tokens carry its id
+// (the "sid" claim) so that revoking the session invalidates them as well.
+type Session struct {
+	SessionID     string     `json:"sessionid"`
+	AuthID        int64      `json:"authid"`
+	UserAgent     *string    `json:"useragent,omitempty"`
+	IPAddress     *string    `json:"ipaddress,omitempty"`
+	CreatedAt     *time.Time `json:"created_at,omitempty"`
+	LastUsedAt    *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	RevokedReason *string    `json:"revokedreason,omitempty"`
+}
+
+// RefreshToken is a row of refreshtokens; only the SHA-256 of the token is stored
+type RefreshToken struct {
+	TokenID   int64
+	SessionID string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+// TokenPair is returned by login and refresh
+type TokenPair struct {
+	AccessToken      string    `json:"token"`
+	ExpiresIn        int       `json:"expires_in"` // seconds
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	SessionID        string    `json:"sessionid"`
+}
@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// TwoFactor is a row of twofactor. The row exists from setup on; 2FA is only on
+// once EnabledAt is set (the user proved the authenticator app works).
+type TwoFactor struct {
+	AuthID       int64      `json:"authid"`
+	Secret       string     `json:"-"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+	EnabledAt    *time.Time `json:"enabled_at,omitempty"`
+	LastUsedStep int64      `json:"-"` // TOTP step of the last accepted code, to reject replays
+}
+
+// TwoFactorSetup is returned when enrolling: the secret to import into an authenticator app
+type TwoFactorSetup struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// TwoFactorStatus describes the 2FA state of an account
+type TwoFactorStatus struct {
+	Enabled           bool       `json:"enabled"`
+	EnabledAt         *time.Time `json:"enabled_at,omitempty"`
+	Required          bool       `json:"required"` // enforced by the account's role
+	RecoveryCodesLeft int        `json:"recovery_codes_left"`
+}
+
+// LoginChallenge is returned by login instead of tokens when 2FA is on; the token must be
+// sent back with a TOTP or recovery code to finish signing in.
+type LoginChallenge struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int    `json:"expires_in"` // seconds
+}
@@ -0,0 +1,215 @@
+// Package money provides an exact monetary amount type stored as integer
+// hundredths, matching the numeric(10,2) columns used throughout the schema.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Scale is the number of decimal places an Amount carries
+const Scale = 2
+
+const unit = 100 // 10^Scale
+
+// Amount is a monetary value in hundredths of the currency unit (e.g. cents).
+// It scans from and encodes to Postgres numeric, and marshals to JSON as an exact
+// decimal number (12.30), so no float64 rounding ever happens.
+type Amount int64
+
+// Zero is the zero amount
+const Zero Amount = 0
+
+// FromCents builds an Amount from a number of hundredths
+func FromCents(c int64) Amount { return Amount(c) }
+
+// FromUnits builds an Amount from whole currency units
+func FromUnits(u int64) Amount { return Amount(u * unit) }
+
+// Cents returns the amount in hundredths
+func (a Amount) Cents() int64 { return int64(a) }
+
+// Mul multiplies the amount by a quantity
+func (a Amount) Mul(qty int) Amount { return a * Amount(qty) }
+
+// MulRat multiplies the amount by an exact rational factor (a percentage, tax rate or
+// exchange rate), rounding half away from zero to the nearest hundredth.
+func (a Amount) MulRat(r *big.Rat) Amount {
+	v := new(big.Rat).Mul(new(big.Rat).SetInt64(int64(a)), r)
+	return Amount(roundRat(v))
+}
+
+// Round rounds the amount to the minor unit of the currency, half away from zero.
+// Currencies without minor units (JPY, KRW, ...) round to whole units.
+func (a Amount) Round(currency string) Amount {
+	step := int64(1)
+	for e := Exponent(currency); e < Scale; e++ {
+		step *= 10
+	}
+	if step == 1 {
+		return a
+	}
+	return Amount(roundRat(big.NewRat(int64(a), step)) * step)
+}
+
+// Sum adds amounts
+func Sum(amounts ...Amount) Amount {
+	var t Amount
+	for _, a := range amounts {
+		t += a
+	}
+	return t
+}
+
+// roundRat rounds a rational to the nearest integer, half away from zero
+func roundRat(r *big.Rat) int64 {
+	num := new(big.Int).Set(r.Num())
+	den := r.Denom()
+	neg := num.Sign() < 0
+	num.Abs(num)
+	q, m := new(big.Int).QuoRem(num, den, new(big.Int))
+	if m.Lsh(m, 1).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if neg {
+		q.Neg(q)
+	}
+	return q.Int64()
+}
+
+// String formats the amount with exactly two decimals, e.g. "12.30"
+func (a Amount) String() string {
+	v := uint64(a)
+	sign := ""
+	if a < 0 {
+		sign = "-"
+		v = -v // two's complement: also right for the most negative amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/unit, v%unit)
+}
+
+// Parse reads a decimal string such as "12", "12.3" or "-0.05".
+// More than two decimal places is an error rather than a silent rounding, and so is an
+// amount that does not fit an Amount.
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, errors.New("empty amount")
+	}
+	neg := false
+	switch s[0] {
+	case '-':
+		neg = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if len(frac) > Scale {
+		return 0, fmt.Errorf("amount %q has more than %d decimal places", s, Scale)
+	}
+	for _, part := range []string{whole, frac} {
+		for _, ch := range part {
+			if ch < '0' || ch > '9' {
+				return 0, fmt.Errorf("invalid amount %q", s)
+			}
+		}
+	}
+	var w int64
+	if whole != "" {
+		var err error
+		if w, err = strconv.ParseInt(whole, 10, 64); err != nil {
+			return 0, fmt.Errorf("invalid amount %q", s)
+		}
+	}
+	frac += strings.Repeat("0", Scale-len(frac))
+	f, _ := strconv.ParseInt(frac, 10, 64)
+	if w > (math.MaxInt64-f)/unit {
+		return 0, fmt.Errorf("amount %q is out of range", s)
+	}
+	v := w*unit + f
+	if neg {
+		v = -v
+	}
+	return Amount(v), nil
+}
+
+// MarshalJSON encodes the amount as a JSON number with two decimals
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a numeric string
+func (a *Amount) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "null" {
+		return nil
+	}
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// ScanNumeric implements pgtype.NumericScanner. Values with more than two decimals
+// (e.g. results of division) are rounded half away from zero.
+func (a *Amount) ScanNumeric(n pgtype.Numeric) error {
+	if !n.Valid {
+		return errors.New("cannot scan NULL into money.Amount")
+	}
+	if n.NaN || n.InfinityModifier != pgtype.Finite {
+		return errors.New("cannot scan non-finite numeric into money.Amount")
+	}
+	exp := n.Exp + Scale
+	v := new(big.Rat).SetInt(n.Int)
+	p := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs32(exp))), nil)
+	if exp >= 0 {
+		v.Mul(v, new(big.Rat).SetInt(p))
+	} else {
+		v.Quo(v, new(big.Rat).SetInt(p))
+	}
+	*a = Amount(roundRat(v))
+	return nil
+}
+
+// NumericValue implements pgtype.NumericValuer
+func (a Amount) NumericValue() (pgtype.Numeric, error) {
+	return pgtype.Numeric{Int: big.NewInt(int64(a)), Exp: -Scale, Valid: true}, nil
+}
+
+func abs32(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// exponents lists currencies whose minor unit differs from two decimals.
+// Amounts are stored with two decimals, so three-decimal currencies are not supported.
+var exponents = map[string]int{
+	"JPY": 0,
+	"KRW": 0,
+	"VND": 0,
+	"CLP": 0,
+	"ISK": 0,
+	"IDR": 0,
+}
+
+// Exponent returns the number of decimals used by a currency (ISO 4217 code)
+func Exponent(currency string) int {
+	if e, ok := exponents[strings.ToUpper(currency)]; ok {
+		return e
+	}
+	return Scale
+}
@@ -0,0 +1,141 @@
+package money
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Amount
+		ok   bool
+	}{
+		{"12", 1200, true},
+		{"12.3", 1230, true},
+		{"12.30", 1230, true},
+		{" 0.05 ", 5, true},
+		{".5", 50, true},
+		{"7.", 700, true},
+		{"+3.10", 310, true},
+		{"-0.05", -5, true},
+		{"-12.34", -1234, true},
+		{"92233720368547758.07", math.MaxInt64, true},
+		{"-92233720368547758.07", -math.MaxInt64, true},
+		{"92233720368547758.08", 0, false},
+		{"92233720368547759", 0, false},
+		{"99999999999999999999", 0, false},
+		{"1.005", 0, false}, // no silent rounding
+		{"", 0, false},
+		{"-", 0, false},
+		{".", 0, false},
+		{"1.2.3", 0, false},
+		{"1e3", 0, false},
+		{"--1", 0, false},
+		{"12,30", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("Parse(%q) error = %v, want ok=%v", tt.in, err, tt.ok)
+			continue
+		}
+		if tt.ok && got != tt.want {
+			t.Errorf("Parse(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		in   Amount
+		want string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{1230, "12.30"},
+		{-5, "-0.05"},
+		{-1234, "-12.34"},
+		{math.MaxInt64, "92233720368547758.07"},
+		{math.MinInt64, "-92233720368547758.08"},
+	}
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("Amount(%d).String() = %q, want %q", int64(tt.in), got, tt.want)
+		}
+	}
+	// what String writes, Parse reads back
+	for _, a := range []Amount{0, 1, -1, 99, -100, 123456789, math.MaxInt64, -math.MaxInt64} {
+		if got, err := Parse(a.String()); err != nil || got != a {
+			t.Errorf("Parse(%q) = %d, %v, want %d", a.String(), got, err, a)
+		}
+	}
+}
+
+func TestMul(t *testing.T) {
+	tests := []struct {
+		a    Amount
+		qty  int
+		want Amount
+	}{
+		{1999, 3, 5997},
+		{1999, 0, 0},
+		{-250, 2, -500},
+		{250, -2, -500},
+		{1, 1000000, 1000000},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Mul(tt.qty); got != tt.want {
+			t.Errorf("%s.Mul(%d) = %s, want %s", tt.a, tt.qty, got, tt.want)
+		}
+	}
+}
+
+func TestMulRat(t *testing.T) {
+	tests := []struct {
+		a    Amount
+		r    string
+		want Amount
+	}{
+		{1000, "0.19", 190},
+		{999, "0.2", 200},    // 199.8
+		{1, "0.5", 1},        // 0.5 rounds away from zero
+		{-1, "0.5", -1},      // and so does -0.5
+		{3, "0.5", 2},        // 1.5
+		{-3, "0.5", -2},      // -1.5
+		{1, "0.49", 0},       // 0.49
+		{1999, "1/3", 666},   // 666.33
+		{2000, "1/3", 667},   // 666.67
+		{-2000, "1/3", -667}, // -666.67
+		{12345, "0", 0},
+	}
+	for _, tt := range tests {
+		r, ok := new(big.Rat).SetString(tt.r)
+		if !ok {
+			t.Fatalf("bad rational %q", tt.r)
+		}
+		if got := tt.a.MulRat(r); got != tt.want {
+			t.Errorf("%s.MulRat(%s) = %s, want %s", tt.a, tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		a        Amount
+		currency string
+		want     Amount
+	}{
+		{1234, "USD", 1234},
+		{1249, "JPY", 1200},
+		{1250, "JPY", 1300},
+		{-1250, "jpy", -1300},
+		{-1249, "KRW", -1200},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Round(tt.currency); got != tt.want {
+			t.Errorf("%s.Round(%s) = %s, want %s", tt.a, tt.currency, got, tt.want)
+		}
+	}
+}
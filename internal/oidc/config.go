@@ -0,0 +1,83 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LoadConfigsFromEnv reads the providers listed in OIDC_PROVIDERS (comma separated
+// names). Each provider NAME is configured with OIDC_NAME_ISSUER, OIDC_NAME_CLIENT_ID,
+// OIDC_NAME_CLIENT_SECRET, OIDC_NAME_REDIRECT_URL and optionally OIDC_NAME_SCOPES.
+// OIDC_PROVIDERS_FILE may instead point to a JSON array of Config.
+func LoadConfigsFromEnv() ([]Config, error) {
+	if path := os.Getenv("OIDC_PROVIDERS_FILE"); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var configs []Config
+		if err := json.Unmarshal(b, &configs); err != nil {
+			return nil, fmt.Errorf("oidc providers %s: %w", path, err)
+		}
+		return configs, nil
+	}
+	var configs []Config
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		configs = append(configs, Config{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		})
+	}
+	return configs, nil
+}
+
+// ErrUnknownProvider is returned for provider names that are not configured
+var ErrUnknownProvider = errors.New("unknown identity provider")
+
+// Registry holds the configured providers by name
+type Registry struct {
+	providers map[string]*Provider
+	names     []string
+}
+
+func NewRegistry(configs []Config) (*Registry, error) {
+	r := &Registry{providers: map[string]*Provider{}}
+	for _, cfg := range configs {
+		cfg.Name = strings.ToLower(strings.TrimSpace(cfg.Name))
+		if cfg.Name == "" || cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+			return nil, fmt.Errorf("oidc: provider %q needs a name, issuer, client_id and redirect_url", cfg.Name)
+		}
+		if _, dup := r.providers[cfg.Name]; dup {
+			return nil, fmt.Errorf("oidc: duplicate provider %q", cfg.Name)
+		}
+		r.providers[cfg.Name] = NewProvider(cfg)
+		r.names = append(r.names, cfg.Name)
+	}
+	return r, nil
+}
+
+// Get returns the provider registered under name
+func (r *Registry) Get(name string) (*Provider, error) {
+	p, ok := r.providers[strings.ToLower(name)]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+// Names lists the configured providers in configuration order
+func (r *Registry) Names() []string {
+	return append([]string{}, r.names...)
+}
@@ -0,0 +1,74 @@
+package oidc_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"GameStoreAPI/internal/oidc"
+	"GameStoreAPI/internal/oidc/oidctest"
+)
+
+func newProvider(t *testing.T) (*oidc.Provider, *oidctest.Server) {
+	t.Helper()
+	srv, err := oidctest.NewServer("store", "store-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	return oidc.NewProvider(srv.Config("stub", "http://store.test/auth/oidc/stub/callback")), srv
+}
+
+// authorize runs the browser part of the flow and returns the code
+func authorize(t *testing.T, p *oidc.Provider, srv *oidctest.Server, state, nonce, verifier string) string {
+	t.Helper()
+	u, err := p.AuthCodeURL(context.Background(), state, nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, gotState, err := srv.Authorize(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotState != state {
+		t.Fatalf("state = %q, want %q", gotState, state)
+	}
+	return code
+}
+
+func TestExchange(t *testing.T) {
+	p, srv := newProvider(t)
+	srv.SetUser(oidctest.User{Subject: "sub-1", Email: "jo@example.com", EmailVerified: true, Name: "Jo"})
+
+	code := authorize(t, p, srv, "state", "nonce", "verifier-verifier-verifier-verifier-verifier")
+	claims, err := p.Exchange(context.Background(), code, "verifier-verifier-verifier-verifier-verifier", "nonce")
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	if claims.Subject != "sub-1" || claims.Email != "jo@example.com" || !claims.EmailVerified {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	// codes are single use
+	if _, err := p.Exchange(context.Background(), code, "verifier-verifier-verifier-verifier-verifier", "nonce"); err == nil {
+		t.Error("second exchange of a code succeeded")
+	}
+}
+
+func TestExchangeNonceMismatch(t *testing.T) {
+	p, srv := newProvider(t)
+	code := authorize(t, p, srv, "state", "nonce-sent", "verifier-verifier-verifier-verifier-verifier")
+	_, err := p.Exchange(context.Background(), code, "verifier-verifier-verifier-verifier-verifier", "nonce-expected")
+	if err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Fatalf("exchange with another nonce: %v, want a nonce mismatch", err)
+	}
+}
+
+func TestExchangeBadVerifier(t *testing.T) {
+	p, srv := newProvider(t)
+	code := authorize(t, p, srv, "state", "nonce", "verifier-verifier-verifier-verifier-verifier")
+	_, err := p.Exchange(context.Background(), code, "another-verifier-another-verifier-another", "nonce")
+	if err == nil || !strings.Contains(err.Error(), "PKCE") {
+		t.Fatalf("exchange with another verifier: %v, want the PKCE check to fail", err)
+	}
+}
@@ -0,0 +1,144 @@
+// Package pagination implements keyset pagination for list endpoints. Lists are ordered by
+// a unique id; a page ends with an opaque cursor naming the id of its last item, and the
+// next page continues after it, so deep pages cost as much as the first one. Lists ordered
+// by something else (like search results) carry that sort key in the cursor as well, with
+// the id breaking ties.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Params selects a page: at most Limit items following the item After (nil for the first
+// page). AfterKey is the sort key of that item, only set by cursors of keyed lists.
+// WithTotal asks for the number of items of the whole list as well.
+type Params struct {
+	Limit     int
+	After     *int64
+	AfterKey  string
+	WithTotal bool
+}
+
+// Parse builds Params from the limit, cursor and total query parameters. Limits outside
+// 1..MaxLimit fall back to DefaultLimit.
+func Parse(limit, cursor, total string) (Params, error) {
+	p := Params{Limit: DefaultLimit, WithTotal: total == "true"}
+	if n, err := strconv.Atoi(limit); err == nil && n > 0 && n <= MaxLimit {
+		p.Limit = n
+	}
+	if cursor != "" {
+		key, id, err := DecodeKeyed(cursor)
+		if err != nil {
+			return Params{}, err
+		}
+		p.After, p.AfterKey = &id, key
+	}
+	return p, nil
+}
+
+// Fetch is the number of rows to query: one more than Limit, which tells whether another
+// page follows
+func (p Params) Fetch() int {
+	return p.Limit + 1
+}
+
+// Page is the response envelope of list endpoints. NextCursor is empty on the last page,
+// Total only set when it was asked for.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      *int64 `json:"total,omitempty"`
+}
+
+// NewPage cuts rows queried with p.Fetch() down to a page. id returns the key the list is
+// ordered by; count is only called when p.WithTotal is set.
+func NewPage[T any](rows []T, p Params, id func(T) int64, count func() (int64, error)) (*Page[T], error) {
+	return NewKeyedPage(rows, p, func(t T) (string, int64) { return "", id(t) }, count)
+}
+
+// NewKeyedPage is NewPage for lists ordered by a sort key and then by id; key returns both
+// for an item
+func NewKeyedPage[T any](rows []T, p Params, key func(T) (string, int64), count func() (int64, error)) (*Page[T], error) {
+	pg := &Page[T]{Items: rows}
+	if pg.Items == nil {
+		pg.Items = []T{}
+	}
+	if len(pg.Items) > p.Limit {
+		pg.Items = pg.Items[:p.Limit]
+		pg.NextCursor = EncodeKeyed(key(pg.Items[len(pg.Items)-1]))
+	}
+	if p.WithTotal {
+		n, err := count()
+		if err != nil {
+			return nil, err
+		}
+		pg.Total = &n
+	}
+	return pg, nil
+}
+
+type cursor struct {
+	Key string `json:"key,omitempty"`
+	ID  int64  `json:"id"`
+}
+
+// Encode returns the cursor continuing after the item with the given id
+func Encode(id int64) string {
+	return EncodeKeyed("", id)
+}
+
+// EncodeKeyed returns the cursor continuing after the item with the given sort key and id
+func EncodeKeyed(key string, id int64) string {
+	b, _ := json.Marshal(cursor{Key: key, ID: id})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Decode returns the id a cursor continues after (ids are serial columns)
+func Decode(s string) (int64, error) {
+	_, id, err := DecodeKeyed(s)
+	return id, err
+}
+
+// DecodeKeyed returns the sort key ("" for cursors of id-ordered lists) and the id a cursor
+// continues after
+func DecodeKeyed(s string) (string, int64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return "", 0, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID <= 0 || c.ID > math.MaxInt32 {
+		return "", 0, ErrInvalidCursor
+	}
+	return c.Key, c.ID, nil
+}
+
+// Collect reads a list page by page until its end, for internal uses (like data exports)
+// that need all of it
+func Collect[T any](fetch func(Params) ([]T, error), id func(T) int64) ([]T, error) {
+	all := []T{}
+	p := Params{Limit: MaxLimit}
+	for {
+		rows, err := fetch(p)
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) <= p.Limit {
+			return append(all, rows...), nil
+		}
+		all = append(all, rows[:p.Limit]...)
+		last := id(rows[p.Limit-1])
+		p.After = &last
+	}
+}
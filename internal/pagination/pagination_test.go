@@ -0,0 +1,69 @@
+package pagination
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	id, err := Decode(Encode(42))
+	if err != nil || id != 42 {
+		t.Fatalf("Decode(Encode(42)) = %d, %v", id, err)
+	}
+	key, id, err := DecodeKeyed(EncodeKeyed("12.50", 7))
+	if err != nil || key != "12.50" || id != 7 {
+		t.Fatalf("DecodeKeyed = %q, %d, %v", key, id, err)
+	}
+	// a keyed cursor still names an id for lists ordered by id
+	if id, err := Decode(EncodeKeyed("x", 9)); err != nil || id != 9 {
+		t.Fatalf("Decode of a keyed cursor = %d, %v", id, err)
+	}
+	for _, c := range []string{"!!", "e30", Encode(0), Encode(1 << 40)} {
+		if _, _, err := DecodeKeyed(c); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("DecodeKeyed(%q): %v, want ErrInvalidCursor", c, err)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	p, err := Parse("500", EncodeKeyed("2026-01-02", 3), "true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Limit != DefaultLimit || p.After == nil || *p.After != 3 || p.AfterKey != "2026-01-02" || !p.WithTotal {
+		t.Fatalf("Parse = %+v", p)
+	}
+	if _, err := Parse("", "not a cursor", ""); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("Parse of a bad cursor: %v", err)
+	}
+}
+
+type item struct {
+	rank string
+	id   int64
+}
+
+func TestNewKeyedPage(t *testing.T) {
+	rows := []item{{"a", 5}, {"a", 9}, {"b", 2}}
+	key := func(i item) (string, int64) { return i.rank, i.id }
+
+	pg, err := NewKeyedPage(rows, Params{Limit: 2}, key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(pg.Items, rows[:2]) || pg.Total != nil {
+		t.Fatalf("page = %+v", pg)
+	}
+	if k, id, err := DecodeKeyed(pg.NextCursor); err != nil || k != "a" || id != 9 {
+		t.Fatalf("next cursor = %q, %d, %v, want the last item of the page", k, id, err)
+	}
+
+	last, err := NewKeyedPage(rows[2:], Params{Limit: 2, WithTotal: true}, key, func() (int64, error) { return 3, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.NextCursor != "" || last.Total == nil || *last.Total != 3 {
+		t.Fatalf("last page = %+v", last)
+	}
+}
@@ -0,0 +1,169 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"GameStoreAPI/internal/money"
+)
+
+// FakeConfig controls the outcome of FakeGateway calls.
+type FakeConfig struct {
+	WebhookSecret []byte
+	// Card tokens that are always declined / always time out. Timeout tokens are
+	// authorized, their capture times out (the provider may still take the money).
+	DeclineTokens []string
+	TimeoutTokens []string
+	// Amounts strictly above these limits are declined / time out at authorization
+	// (0 disables the check)
+	DeclineAbove money.Amount
+	TimeoutAbove money.Amount
+}
+
+// DefaultFakeConfig declines "tok_decline" and times out capturing "tok_timeout".
+func DefaultFakeConfig(secret []byte) FakeConfig {
+	return FakeConfig{
+		WebhookSecret: secret,
+		DeclineTokens: []string{"tok_decline"},
+		TimeoutTokens: []string{"tok_timeout"},
+	}
+}
+
+type fakeCharge struct {
+	timeout    bool // the capture times out
+	authorized money.Amount
+	captured   money.Amount
+	refunded   money.Amount
+}
+
+// FakeGateway is a deterministic in-process PaymentGateway for local runs and tests.
+// Outcomes depend only on the card token and amount, never on timing.
+type FakeGateway struct {
+	cfg     FakeConfig
+	mu      sync.Mutex
+	charges map[string]*fakeCharge
+}
+
+func NewFakeGateway(cfg FakeConfig) *FakeGateway {
+	return &FakeGateway{cfg: cfg, charges: map[string]*fakeCharge{}}
+}
+
+func (g *FakeGateway) Authorize(ctx context.Context, req AuthorizeRequest) (*Authorization, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, ErrTimeout
+	}
+	if req.Amount < 0 {
+		return nil, errors.New("invalid amount")
+	}
+	if g.cfg.TimeoutAbove > 0 && req.Amount > g.cfg.TimeoutAbove {
+		return nil, ErrTimeout
+	}
+	if req.CardToken == "" || slices.Contains(g.cfg.DeclineTokens, req.CardToken) || (g.cfg.DeclineAbove > 0 && req.Amount > g.cfg.DeclineAbove) {
+		return nil, ErrDeclined
+	}
+
+	ref := fmt.Sprintf("fake_%d", req.PaymentID)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	// authorizing a payment again adds to it, like a provider without idempotency keys
+	ch, ok := g.charges[ref]
+	if !ok {
+		ch = &fakeCharge{}
+		g.charges[ref] = ch
+	}
+	ch.authorized += req.Amount
+	ch.timeout = slices.Contains(g.cfg.TimeoutTokens, req.CardToken)
+	return &Authorization{Reference: ref}, nil
+}
+
+// Held returns the money the fake provider holds for a reference: captured minus refunded
+func (g *FakeGateway) Held(reference string) money.Amount {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ch, ok := g.charges[reference]
+	if !ok {
+		return 0
+	}
+	return ch.captured - ch.refunded
+}
+
+func (g *FakeGateway) Capture(ctx context.Context, reference string, amount money.Amount) error {
+	if err := ctx.Err(); err != nil {
+		return ErrTimeout
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ch, ok := g.charges[reference]
+	if !ok {
+		return errors.New("unknown payment reference")
+	}
+	if ch.timeout {
+		return ErrTimeout
+	}
+	if amount > ch.authorized-ch.captured {
+		return ErrDeclined
+	}
+	ch.captured += amount
+	return nil
+}
+
+func (g *FakeGateway) Refund(ctx context.Context, reference string, amount money.Amount) error {
+	if err := ctx.Err(); err != nil {
+		return ErrTimeout
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ch, ok := g.charges[reference]
+	if !ok {
+		return errors.New("unknown payment reference")
+	}
+	if amount > ch.captured-ch.refunded {
+		return ErrDeclined
+	}
+	ch.refunded += amount
+	return nil
+}
+
+func (g *FakeGateway) ParseWebhook(payload []byte, signature string) (*WebhookEvent, error) {
+	if !VerifySignature(g.cfg.WebhookSecret, payload, signature) {
+		return nil, ErrInvalidSignature
+	}
+	var ev WebhookEvent
+	if err := json.Unmarshal(payload, &ev); err != nil {
+		return nil, fmt.Errorf("invalid webhook payload: %w", err)
+	}
+	switch ev.Type {
+	case EventCaptured, EventFailed, EventRefunded:
+	default:
+		return nil, fmt.Errorf("unsupported webhook event %q", ev.Type)
+	}
+	if ev.PaymentID <= 0 {
+		return nil, errors.New("webhook event missing paymentid")
+	}
+	return &ev, nil
+}
+
+// Event builds a signed webhook delivery as the fake provider would send it, e.g. to
+// resolve a payment whose authorize call timed out. A captured event also registers
+// the charge so that it can be refunded later; a refunded event (amount is the total
+// refunded so far) refunds it at the provider.
+func (g *FakeGateway) Event(typ string, paymentID int64, amount money.Amount) (payload []byte, signature string, err error) {
+	ref := fmt.Sprintf("fake_%d", paymentID)
+	g.mu.Lock()
+	switch ch := g.charges[ref]; {
+	case typ == EventCaptured:
+		g.charges[ref] = &fakeCharge{authorized: amount, captured: amount}
+	case typ == EventRefunded && ch != nil:
+		ch.refunded = amount
+	}
+	g.mu.Unlock()
+	payload, err = json.Marshal(WebhookEvent{Type: typ, PaymentID: paymentID, Reference: ref, Amount: amount})
+	if err != nil {
+		return nil, "", err
+	}
+	return payload, SignPayload(g.cfg.WebhookSecret, payload), nil
+}
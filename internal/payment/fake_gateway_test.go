@@ -0,0 +1,105 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"GameStoreAPI/internal/money"
+)
+
+var secret = []byte("fake-secret")
+
+func TestFakeGatewayOutcomes(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		token        string
+		authorizeErr error
+		captureErr   error
+	}{
+		{"tok_ok", nil, nil},
+		{"tok_decline", ErrDeclined, nil},
+		{"", ErrDeclined, nil},
+		{"tok_timeout", nil, ErrTimeout},
+	}
+	for i, tt := range tests {
+		g := NewFakeGateway(DefaultFakeConfig(secret))
+		auth, err := g.Authorize(ctx, AuthorizeRequest{PaymentID: int64(i + 1), Amount: money.FromUnits(10), CardToken: tt.token})
+		if !errors.Is(err, tt.authorizeErr) {
+			t.Errorf("%q: authorize error = %v, want %v", tt.token, err, tt.authorizeErr)
+		}
+		if err != nil {
+			continue
+		}
+		if err := g.Capture(ctx, auth.Reference, money.FromUnits(10)); !errors.Is(err, tt.captureErr) {
+			t.Errorf("%q: capture error = %v, want %v", tt.token, err, tt.captureErr)
+		}
+	}
+}
+
+func TestFakeGatewayLimits(t *testing.T) {
+	ctx := context.Background()
+	g := NewFakeGateway(FakeConfig{WebhookSecret: secret, DeclineAbove: money.FromUnits(100), TimeoutAbove: money.FromUnits(500)})
+	if _, err := g.Authorize(ctx, AuthorizeRequest{PaymentID: 1, Amount: money.FromUnits(200), CardToken: "tok"}); !errors.Is(err, ErrDeclined) {
+		t.Errorf("above DeclineAbove: %v, want ErrDeclined", err)
+	}
+	if _, err := g.Authorize(ctx, AuthorizeRequest{PaymentID: 2, Amount: money.FromUnits(600), CardToken: "tok"}); !errors.Is(err, ErrTimeout) {
+		t.Errorf("above TimeoutAbove: %v, want ErrTimeout", err)
+	}
+
+	auth, err := g.Authorize(ctx, AuthorizeRequest{PaymentID: 3, Amount: money.FromUnits(50), CardToken: "tok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Capture(ctx, auth.Reference, money.FromUnits(60)); !errors.Is(err, ErrDeclined) {
+		t.Errorf("capture above the authorization: %v, want ErrDeclined", err)
+	}
+	if err := g.Capture(ctx, auth.Reference, money.FromUnits(50)); err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+	if err := g.Refund(ctx, auth.Reference, money.FromUnits(30)); err != nil {
+		t.Fatalf("refund: %v", err)
+	}
+	if err := g.Refund(ctx, auth.Reference, money.FromUnits(30)); !errors.Is(err, ErrDeclined) {
+		t.Errorf("refund above what is left: %v, want ErrDeclined", err)
+	}
+}
+
+func TestFakeGatewayWebhook(t *testing.T) {
+	g := NewFakeGateway(DefaultFakeConfig(secret))
+	body, sig, err := g.Event(EventCaptured, 7, money.FromUnits(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev, err := g.ParseWebhook(body, sig)
+	if err != nil {
+		t.Fatalf("parse signed event: %v", err)
+	}
+	if ev.Type != EventCaptured || ev.PaymentID != 7 || ev.Reference != "fake_7" {
+		t.Errorf("event = %+v", ev)
+	}
+	// a captured event registers the charge so that it can be refunded
+	if err := g.Refund(context.Background(), ev.Reference, money.FromUnits(10)); err != nil {
+		t.Errorf("refund after captured event: %v", err)
+	}
+
+	tampered := append([]byte{}, body...)
+	tampered[len(tampered)-2]++
+	for name, tc := range map[string]struct {
+		body []byte
+		sig  string
+	}{
+		"no signature":    {body, ""},
+		"no prefix":       {body, sig[len("sha256="):]},
+		"wrong secret":    {body, SignPayload([]byte("other"), body)},
+		"tampered body":   {tampered, sig},
+		"not hexadecimal": {body, "sha256=zz"},
+	} {
+		if _, err := g.ParseWebhook(tc.body, tc.sig); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: %v, want ErrInvalidSignature", name, err)
+		}
+	}
+	if VerifySignature(nil, body, SignPayload(nil, body)) {
+		t.Error("signatures verify without a secret")
+	}
+}
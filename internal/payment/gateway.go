@@ -0,0 +1,86 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"GameStoreAPI/internal/money"
+)
+
+var (
+	// ErrDeclined is returned when the provider refuses the operation
+	ErrDeclined = errors.New("payment declined")
+	// ErrTimeout is returned when the provider did not answer in time; the outcome
+	// is unknown and will be delivered later through a webhook.
+	ErrTimeout = errors.New("payment gateway timeout")
+	// ErrInvalidSignature is returned by ParseWebhook for unsigned or tampered payloads
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// Webhook event types
+const (
+	EventCaptured = "payment.captured"
+	EventFailed   = "payment.failed"
+	EventRefunded = "payment.refunded"
+)
+
+// AuthorizeRequest describes a charge to authorize. PaymentID is our own reference
+// and is echoed back by the provider in webhook events.
+type AuthorizeRequest struct {
+	PaymentID int64
+	OrderID   int64
+	Amount    money.Amount
+	Currency  string
+	CardToken string
+}
+
+// Authorization is the provider's answer to a successful authorize call
+type Authorization struct {
+	Reference string
+}
+
+// WebhookEvent is a verified notification sent by the provider. Amount is what was
+// captured, or for refunds the total refunded on the payment so far.
+type WebhookEvent struct {
+	Type      string       `json:"type"`
+	PaymentID int64        `json:"paymentid"`
+	Reference string       `json:"reference"`
+	Amount    money.Amount `json:"amount"`
+}
+
+// PaymentGateway is implemented by payment providers the checkout talks to.
+type PaymentGateway interface {
+	Authorize(ctx context.Context, req AuthorizeRequest) (*Authorization, error)
+	Capture(ctx context.Context, reference string, amount money.Amount) error
+	Refund(ctx context.Context, reference string, amount money.Amount) error
+	ParseWebhook(payload []byte, signature string) (*WebhookEvent, error)
+}
+
+// SignPayload returns the signature header value for a webhook payload ("sha256=<hex>").
+func SignPayload(secret, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks a "sha256=<hex>" signature against the payload in constant time.
+func VerifySignature(secret, payload []byte, signature string) bool {
+	if len(secret) == 0 {
+		return false
+	}
+	sig, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return hmac.Equal(got, mac.Sum(nil))
+}
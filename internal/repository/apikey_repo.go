@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/pagination"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// APIKeyRepository stores the API keys of developer accounts
+type APIKeyRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewAPIKeyRepository(db *pgxpool.Pool) *APIKeyRepository {
+	return &APIKeyRepository{DB: db}
+}
+
+const apiKeyColumns = `k.keyid, k.authid, k.name, k.prefix, k.scopes, k.created_at, k.expires_at, k.last_used_at, k.last_used_ip, k.revoked_at`
+
+func scanAPIKey(row pgx.Row, k *model.APIKey, extra ...interface{}) error {
+	dest := append([]interface{}{&k.KeyID, &k.AuthID, &k.Name, &k.Prefix, &k.Scopes, &k.CreatedAt, &k.ExpiresAt,
+		&k.LastUsedAt, &k.LastUsedIP, &k.RevokedAt}, extra...)
+	return row.Scan(dest...)
+}
+
+// Create stores a new key and fills in its id and creation time
+func (r *APIKeyRepository) Create(ctx context.Context, k *model.APIKey, keyHash string) error {
+	now := time.Now()
+	query := `
+		INSERT INTO apikeys (authid, name, prefix, keyhash, scopes, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING keyid
+	`
+	if err := r.DB.QueryRow(ctx, query, k.AuthID, k.Name, k.Prefix, keyHash, k.Scopes, now, k.ExpiresAt).Scan(&k.KeyID); err != nil {
+		return err
+	}
+	k.CreatedAt = &now
+	return nil
+}
+
+// GetActiveByHash returns an unrevoked, unexpired key with its owner
+func (r *APIKeyRepository) GetActiveByHash(ctx context.Context, keyHash string) (*model.APIKey, *model.Auth, error) {
+	var k model.APIKey
+	var u model.Auth
+	query := `
+		SELECT ` + apiKeyColumns + `, u.email, u.role, u.deleted_at, u.banned_at, u.banned_until
+		FROM apikeys k
+		JOIN userauth u ON u.authid = k.authid
+		WHERE k.keyhash=$1 AND k.revoked_at IS NULL AND k.expires_at > $2
+	`
+	if err := scanAPIKey(r.DB.QueryRow(ctx, query, keyHash, time.Now()), &k,
+		&u.Email, &u.Role, &u.DeletedAt, &u.BannedAt, &u.BannedUntil); err != nil {
+		return nil, nil, errors.New("invalid or expired API key")
+	}
+	u.AuthID = k.AuthID
+	return &k, &u, nil
+}
+
+// ListByAuthID returns the keys of an account, newest first (revoked and expired included)
+func (r *APIKeyRepository) ListByAuthID(ctx context.Context, authID int64, p pagination.Params) ([]model.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM apikeys k WHERE k.authid=$1 AND ($2::int IS NULL OR k.keyid < $2) ORDER BY k.keyid DESC LIMIT $3`
+	rows, err := r.DB.Query(ctx, query, authID, p.After, p.Fetch())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []model.APIKey{}
+	for rows.Next() {
+		var k model.APIKey
+		if err := scanAPIKey(rows, &k); err != nil {
+			return nil, err
+		}
+		list = append(list, k)
+	}
+	return list, rows.Err()
+}
+
+// CountByAuthID returns the number of keys of an account, revoked and expired ones included
+func (r *APIKeyRepository) CountByAuthID(ctx context.Context, authID int64) (int64, error) {
+	return countRows(ctx, r.DB, `SELECT count(*) FROM apikeys WHERE authid=$1`, authID)
+}
+
+// CountActive returns the number of usable keys of an account
+func (r *APIKeyRepository) CountActive(ctx context.Context, authID int64) (int, error) {
+	var n int
+	query := `SELECT count(*) FROM apikeys WHERE authid=$1 AND revoked_at IS NULL AND expires_at > $2`
+	if err := r.DB.QueryRow(ctx, query, authID, time.Now()).Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Revoke revokes a key of an account and returns its name
+func (r *APIKeyRepository) Revoke(ctx context.Context, authID, keyID int64) (string, error) {
+	var name string
+	query := `UPDATE apikeys SET revoked_at=$1 WHERE keyid=$2 AND authid=$3 AND revoked_at IS NULL RETURNING name`
+	if err := r.DB.QueryRow(ctx, query, time.Now(), keyID, authID).Scan(&name); err != nil {
+		return "", errors.New("API key not found or already revoked")
+	}
+	return name, nil
+}
+
+// RevokeAllTx revokes every key of an account
+func (r *APIKeyRepository) RevokeAllTx(ctx context.Context, tx pgx.Tx, authID int64) error {
+	_, err := tx.Exec(ctx, `UPDATE apikeys SET revoked_at=$1 WHERE authid=$2 AND revoked_at IS NULL`, time.Now(), authID)
+	return err
+}
+
+// TouchUsed records the use of a key. Writes are skipped while the stored time is less
+// than a minute old, so a busy CI job does not update the row on every request.
+func (r *APIKeyRepository) TouchUsed(ctx context.Context, keyID int64, ip string, now time.Time) error {
+	query := `
+		UPDATE apikeys SET last_used_at=$1, last_used_ip=$2
+		WHERE keyid=$3 AND (last_used_at IS NULL OR last_used_at < $4 OR last_used_ip IS DISTINCT FROM $2)
+	`
+	_, err := r.DB.Exec(ctx, query, now, ip, keyID, now.Add(-time.Minute))
+	return err
+}
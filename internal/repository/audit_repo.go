@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/pagination"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AuditRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewAuditRepository(db *pgxpool.Pool) *AuditRepository {
+	return &AuditRepository{DB: db}
+}
+
+// Record appends an entry to the audit log
+func (r *AuditRepository) Record(ctx context.Context, e *model.AuditEntry) error {
+	query := `
+		INSERT INTO auditlog (actorauthid, event, subject, detail, ipaddress, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING auditid
+	`
+	return r.DB.QueryRow(ctx, query, e.ActorAuthID, e.Event, e.Subject, e.Detail, e.IPAddress, time.Now()).Scan(&e.AuditID)
+}
+
+// List returns entries newest first, optionally only those of one event
+func (r *AuditRepository) List(ctx context.Context, event string, p pagination.Params) ([]model.AuditEntry, error) {
+	query := `
+		SELECT auditid, actorauthid, event, subject, detail, ipaddress, created_at
+		FROM auditlog
+		WHERE ($1 = '' OR event = $1) AND ($2::int IS NULL OR auditid < $2)
+		ORDER BY auditid DESC
+		LIMIT $3
+	`
+	rows, err := r.DB.Query(ctx, query, event, p.After, p.Fetch())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.AuditEntry{}
+	for rows.Next() {
+		var e model.AuditEntry
+		if err := rows.Scan(&e.AuditID, &e.ActorAuthID, &e.Event, &e.Subject, &e.Detail, &e.IPAddress, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
+
+// Count is the total of List
+func (r *AuditRepository) Count(ctx context.Context, event string) (int64, error) {
+	return countRows(ctx, r.DB, `SELECT count(*) FROM auditlog WHERE ($1 = '' OR event = $1)`, event)
+}
@@ -6,7 +6,9 @@ import (
 	"time"
 
 	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/pagination"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -19,19 +21,28 @@ func NewAuthRepository(db *pgxpool.Pool) *AuthRepository {
 }
 
 type MinimalUser struct {
-	AuthID     int64     `json:"authid"`
-	Email      string    `json:"email"`
-	Role       string    `json:"role"`
-	CustomerID int64     `json:"customerid"`
-	CreatedAt  time.Time `json:"created_at"`
-	Banned     bool      `json:"banned"`
+	AuthID      int64      `json:"authid"`
+	Email       string     `json:"email"`
+	Role        string     `json:"role"`
+	CustomerID  int64      `json:"customerid"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Banned      bool       `json:"banned"`
+	BannedUntil *time.Time `json:"banned_until,omitempty"`
+	BanReason   *string    `json:"banreason,omitempty"`
+	Deleted     bool       `json:"deleted"`
 }
 
-// CreateUser inserts a new user and returns the created authid
-func (r *AuthRepository) CreateUser(ctx context.Context, email, passwordhash, role string) (int64, error) {
+// CreateUser inserts a new user and returns the created authid. Verified accounts get
+// their email marked as verified right away.
+func (r *AuthRepository) CreateUser(ctx context.Context, email, passwordhash, role string, verified bool) (int64, error) {
 	var id int64
-	query := `INSERT INTO userauth (email, passwordhash, role, created_at) VALUES ($1, $2, $3, $4) RETURNING authid`
-	if err := r.DB.QueryRow(ctx, query, email, passwordhash, role, time.Now()).Scan(&id); err != nil {
+	now := time.Now()
+	var verifiedAt *time.Time
+	if verified {
+		verifiedAt = &now
+	}
+	query := `INSERT INTO userauth (email, passwordhash, role, created_at, email_verified_at) VALUES ($1, $2, $3, $4, $5) RETURNING authid`
+	if err := r.DB.QueryRow(ctx, query, email, passwordhash, role, now, verifiedAt).Scan(&id); err != nil {
 		return 0, err
 	}
 	return id, nil
@@ -39,8 +50,9 @@ func (r *AuthRepository) CreateUser(ctx context.Context, email, passwordhash, ro
 
 func (r *AuthRepository) GetByEmail(ctx context.Context, email string) (*model.Auth, error) {
 	var u model.Auth
-	query := `SELECT authid, email, passwordhash, role, created_at, deleted_at FROM userauth WHERE email=$1`
-	if err := r.DB.QueryRow(ctx, query, email).Scan(&u.AuthID, &u.Email, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.DeletedAt); err != nil {
+	query := `SELECT authid, email, passwordhash, role, created_at, deleted_at, email_verified_at, banned_at, banned_until, banreason FROM userauth WHERE email=$1`
+	if err := r.DB.QueryRow(ctx, query, email).Scan(&u.AuthID, &u.Email, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.DeletedAt, &u.EmailVerifiedAt,
+		&u.BannedAt, &u.BannedUntil, &u.BanReason); err != nil {
 		return nil, errors.New("user not found")
 	}
 	return &u, nil
@@ -48,13 +60,41 @@ func (r *AuthRepository) GetByEmail(ctx context.Context, email string) (*model.A
 
 func (r *AuthRepository) GetByID(ctx context.Context, id int64) (*model.Auth, error) {
 	var u model.Auth
-	query := `SELECT authid, email, role, created_at, deleted_at FROM userauth WHERE authid=$1`
-	if err := r.DB.QueryRow(ctx, query, id).Scan(&u.AuthID, &u.Email, &u.Role, &u.CreatedAt, &u.DeletedAt); err != nil {
+	query := `SELECT authid, email, role, created_at, deleted_at, email_verified_at, banned_at, banned_until, banreason FROM userauth WHERE authid=$1`
+	if err := r.DB.QueryRow(ctx, query, id).Scan(&u.AuthID, &u.Email, &u.Role, &u.CreatedAt, &u.DeletedAt, &u.EmailVerifiedAt,
+		&u.BannedAt, &u.BannedUntil, &u.BanReason); err != nil {
 		return nil, errors.New("user not found")
 	}
 	return &u, nil
 }
 
+// GetPasswordHash returns the stored bcrypt hash of a user
+func (r *AuthRepository) GetPasswordHash(ctx context.Context, id int64) (string, error) {
+	var hash string
+	if err := r.DB.QueryRow(ctx, `SELECT passwordhash FROM userauth WHERE authid=$1`, id).Scan(&hash); err != nil {
+		return "", errors.New("user not found")
+	}
+	return hash, nil
+}
+
+// SetPasswordTx replaces the password hash of a user
+func (r *AuthRepository) SetPasswordTx(ctx context.Context, tx pgx.Tx, id int64, passwordhash string) error {
+	tag, err := tx.Exec(ctx, `UPDATE userauth SET passwordhash=$1 WHERE authid=$2`, passwordhash, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
+
+// MarkEmailVerifiedTx records that the user proved ownership of their email address
+func (r *AuthRepository) MarkEmailVerifiedTx(ctx context.Context, tx pgx.Tx, id int64) error {
+	_, err := tx.Exec(ctx, `UPDATE userauth SET email_verified_at=$1 WHERE authid=$2 AND email_verified_at IS NULL`, time.Now(), id)
+	return err
+}
+
 func (r *AuthRepository) EmailExists(ctx context.Context, email string) (bool, error) {
 	var exists bool
 	query := `SELECT EXISTS (SELECT 1 FROM userauth WHERE email=$1)`
@@ -65,17 +105,20 @@ func (r *AuthRepository) EmailExists(ctx context.Context, email string) (bool, e
 }
 
 // LIST ONLY USERS (role=user)
-func (r *AuthRepository) ListUsersOnly(ctx context.Context) ([]MinimalUser, error) {
+func (r *AuthRepository) ListUsersOnly(ctx context.Context, p pagination.Params) ([]MinimalUser, error) {
 	q := `
         SELECT u.authid, u.email, u.role, u.created_at,
                COALESCE(c.customerid, 0) AS customerid,
-               (u.deleted_at IS NOT NULL) AS banned
+               (u.banned_at IS NOT NULL AND (u.banned_until IS NULL OR u.banned_until > $1)) AS banned,
+               u.banned_until, u.banreason,
+               (u.deleted_at IS NOT NULL) AS deleted
         FROM userauth u
-        LEFT JOIN customer c ON c.authid = u.authid
-        WHERE u.role = 'user'
-        ORDER BY u.authid;
+        LEFT JOIN customers c ON c.authid = u.authid
+        WHERE u.role = 'user' AND ($2::int IS NULL OR u.authid > $2)
+        ORDER BY u.authid
+        LIMIT $3;
     `
-	rows, err := r.DB.Query(ctx, q)
+	rows, err := r.DB.Query(ctx, q, time.Now(), p.After, p.Fetch())
 	if err != nil {
 		return nil, err
 	}
@@ -84,7 +127,7 @@ func (r *AuthRepository) ListUsersOnly(ctx context.Context) ([]MinimalUser, erro
 	list := []MinimalUser{}
 	for rows.Next() {
 		var m MinimalUser
-		if err := rows.Scan(&m.AuthID, &m.Email, &m.Role, &m.CreatedAt, &m.CustomerID, &m.Banned); err != nil {
+		if err := rows.Scan(&m.AuthID, &m.Email, &m.Role, &m.CreatedAt, &m.CustomerID, &m.Banned, &m.BannedUntil, &m.BanReason, &m.Deleted); err != nil {
 			return nil, err
 		}
 		list = append(list, m)
@@ -92,20 +135,27 @@ func (r *AuthRepository) ListUsersOnly(ctx context.Context) ([]MinimalUser, erro
 	return list, nil
 }
 
+// CountUsersOnly is the total of ListUsersOnly
+func (r *AuthRepository) CountUsersOnly(ctx context.Context) (int64, error) {
+	return countRows(ctx, r.DB, `SELECT count(*) FROM userauth WHERE role = 'user'`)
+}
+
 // GET one user (role=user only)
 func (r *AuthRepository) GetUserOnlyByID(ctx context.Context, authID int64) (*MinimalUser, error) {
 	q := `
         SELECT u.authid, u.email, u.role, u.created_at,
                COALESCE(c.customerid, 0) AS customerid,
-               (u.deleted_at IS NOT NULL) AS banned
+               (u.banned_at IS NOT NULL AND (u.banned_until IS NULL OR u.banned_until > $1)) AS banned,
+               u.banned_until, u.banreason,
+               (u.deleted_at IS NOT NULL) AS deleted
         FROM userauth u
-        LEFT JOIN customer c ON c.authid = u.authid
-        WHERE u.role = 'user' AND u.authid = $1;
+        LEFT JOIN customers c ON c.authid = u.authid
+        WHERE u.role = 'user' AND u.authid = $2;
     `
 
 	var m MinimalUser
-	err := r.DB.QueryRow(ctx, q, authID).
-		Scan(&m.AuthID, &m.Email, &m.Role, &m.CreatedAt, &m.CustomerID, &m.Banned)
+	err := r.DB.QueryRow(ctx, q, time.Now(), authID).
+		Scan(&m.AuthID, &m.Email, &m.Role, &m.CreatedAt, &m.CustomerID, &m.Banned, &m.BannedUntil, &m.BanReason, &m.Deleted)
 
 	if err != nil {
 		return nil, err
@@ -113,15 +163,47 @@ func (r *AuthRepository) GetUserOnlyByID(ctx context.Context, authID int64) (*Mi
 	return &m, nil
 }
 
-// BanUser soft-deletes a user (sets deleted_at)
-func (r *AuthRepository) BanUser(ctx context.Context, authID int64) error {
-	query := `UPDATE userauth SET deleted_at=$1 WHERE authid=$2 AND deleted_at IS NULL`
-	tag, err := r.DB.Exec(ctx, query, time.Now(), authID)
+// BanTx bans a user, until a given time when until is set (a suspension). Banning a banned
+// user replaces the ban.
+func (r *AuthRepository) BanTx(ctx context.Context, tx pgx.Tx, authID int64, until *time.Time, reason string) error {
+	query := `UPDATE userauth SET banned_at=$1, banned_until=$2, banreason=$3 WHERE authid=$4 AND deleted_at IS NULL`
+	tag, err := tx.Exec(ctx, query, time.Now(), until, reason, authID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("user not found or deleted")
+	}
+	return nil
+}
+
+// UnbanTx lifts the ban of a user
+func (r *AuthRepository) UnbanTx(ctx context.Context, tx pgx.Tx, authID int64) error {
+	query := `UPDATE userauth SET banned_at=NULL, banned_until=NULL, banreason=NULL WHERE authid=$1 AND banned_at IS NOT NULL AND deleted_at IS NULL`
+	tag, err := tx.Exec(ctx, query, authID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("user not found or not banned")
+	}
+	return nil
+}
+
+// AnonymizeTx marks an account as deleted and drops its credentials. The email is replaced
+// by a placeholder so that the address can register again.
+func (r *AuthRepository) AnonymizeTx(ctx context.Context, tx pgx.Tx, authID int64) error {
+	query := `
+		UPDATE userauth
+		SET email='deleted-' || authid || '@deleted.invalid', passwordhash='!', email_verified_at=NULL, deleted_at=$1
+		WHERE authid=$2 AND deleted_at IS NULL
+	`
+	tag, err := tx.Exec(ctx, query, time.Now(), authID)
 	if err != nil {
 		return err
 	}
 	if tag.RowsAffected() == 0 {
-		return errors.New("user not found or already banned")
+		return errors.New("user not found or already deleted")
 	}
 	return nil
 }
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"GameStoreAPI/internal/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AuthAttemptRepository keeps the failed-attempt counters behind login throttling in the
+// database, so lockouts survive restarts and are shared by every instance.
+type AuthAttemptRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewAuthAttemptRepository(db *pgxpool.Pool) *AuthAttemptRepository {
+	return &AuthAttemptRepository{DB: db}
+}
+
+const authAttemptColumns = `kind, key, failures, first_failed_at, last_failed_at, locked_until`
+
+func scanAuthAttempt(row pgx.Row, a *model.AuthAttempt) error {
+	return row.Scan(&a.Kind, &a.Key, &a.Failures, &a.FirstFailedAt, &a.LastFailedAt, &a.LockedUntil)
+}
+
+// LockedUntil returns the end of the current lockout of a counter, or nil when not locked
+func (r *AuthAttemptRepository) LockedUntil(ctx context.Context, kind, key string, now time.Time) (*time.Time, error) {
+	var until *time.Time
+	query := `SELECT locked_until FROM authattempts WHERE kind=$1 AND key=$2 AND locked_until > $3`
+	err := r.DB.QueryRow(ctx, query, kind, key, now).Scan(&until)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return until, err
+}
+
+// RecordFailure counts one more failure and returns the counter. Counters whose last
+// failure is older than resetBefore start over from 1.
+func (r *AuthAttemptRepository) RecordFailure(ctx context.Context, kind, key string, now, resetBefore time.Time) (*model.AuthAttempt, error) {
+	query := `
+		INSERT INTO authattempts (kind, key, failures, first_failed_at, last_failed_at)
+		VALUES ($1, $2, 1, $3, $3)
+		ON CONFLICT (kind, key) DO UPDATE SET
+			failures = CASE WHEN authattempts.last_failed_at < $4 THEN 1 ELSE authattempts.failures + 1 END,
+			first_failed_at = CASE WHEN authattempts.last_failed_at < $4 THEN $3 ELSE authattempts.first_failed_at END,
+			last_failed_at = $3
+		RETURNING ` + authAttemptColumns
+	var a model.AuthAttempt
+	if err := scanAuthAttempt(r.DB.QueryRow(ctx, query, kind, key, now, resetBefore), &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Lock locks a counter until the given time (an existing longer lockout is kept)
+func (r *AuthAttemptRepository) Lock(ctx context.Context, kind, key string, until time.Time) error {
+	query := `UPDATE authattempts SET locked_until = GREATEST(locked_until, $1) WHERE kind=$2 AND key=$3`
+	_, err := r.DB.Exec(ctx, query, until, kind, key)
+	return err
+}
+
+// Clear removes a counter and its lockout; it reports whether there was one
+func (r *AuthAttemptRepository) Clear(ctx context.Context, kind, key string) (bool, error) {
+	tag, err := r.DB.Exec(ctx, `DELETE FROM authattempts WHERE kind=$1 AND key=$2`, kind, key)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ListLocked returns the counters that are locked at now, longest lockout first
+func (r *AuthAttemptRepository) ListLocked(ctx context.Context, now time.Time) ([]model.AuthAttempt, error) {
+	query := `SELECT ` + authAttemptColumns + ` FROM authattempts WHERE locked_until > $1 ORDER BY locked_until DESC`
+	rows, err := r.DB.Query(ctx, query, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.AuthAttempt{}
+	for rows.Next() {
+		var a model.AuthAttempt
+		if err := scanAuthAttempt(rows, &a); err != nil {
+			return nil, err
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
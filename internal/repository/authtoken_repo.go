@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AuthTokenRepository stores the single-use tokens sent by email (verification, password
+// reset). Only the SHA-256 of a token is stored.
+type AuthTokenRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewAuthTokenRepository(db *pgxpool.Pool) *AuthTokenRepository {
+	return &AuthTokenRepository{DB: db}
+}
+
+// Create stores a new token for the user and invalidates the unused ones issued earlier
+// for the same purpose, so only the latest email link works.
+func (r *AuthTokenRepository) Create(ctx context.Context, authID int64, purpose, tokenHash string, expiresAt time.Time) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+	query := `UPDATE authtokens SET used_at=$1 WHERE authid=$2 AND purpose=$3 AND used_at IS NULL`
+	if _, err := tx.Exec(ctx, query, now, authID, purpose); err != nil {
+		return err
+	}
+	query = `INSERT INTO authtokens (authid, purpose, tokenhash, created_at, expires_at) VALUES ($1, $2, $3, $4, $5)`
+	if _, err := tx.Exec(ctx, query, authID, purpose, tokenHash, now, expiresAt); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ConsumeTx marks an unused, unexpired token as used and returns the user it was issued to
+func (r *AuthTokenRepository) ConsumeTx(ctx context.Context, tx pgx.Tx, purpose, tokenHash string) (int64, error) {
+	var authID int64
+	now := time.Now()
+	query := `
+		UPDATE authtokens SET used_at=$1
+		WHERE tokenhash=$2 AND purpose=$3 AND used_at IS NULL AND expires_at > $1
+		RETURNING authid
+	`
+	if err := tx.QueryRow(ctx, query, now, tokenHash, purpose).Scan(&authID); err != nil {
+		return 0, errors.New("invalid or expired token")
+	}
+	return authID, nil
+}
+
+// DeleteAllTx removes every token of a user
+func (r *AuthTokenRepository) DeleteAllTx(ctx context.Context, tx pgx.Tx, authID int64) error {
+	_, err := tx.Exec(ctx, `DELETE FROM authtokens WHERE authid=$1`, authID)
+	return err
+}
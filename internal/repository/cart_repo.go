@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/money"
+	"GameStoreAPI/internal/tax"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -39,18 +41,52 @@ func (r *CartRepository) FindOpenOrder(ctx context.Context, customerID int64) (i
 	return orderID, nil
 }
 
-// createOpenOrder creates a new order with totalprice = NULL and returns orderid
-func (r *CartRepository) CreateOpenOrder(ctx context.Context, customerID int64) (int64, error) {
+// createOpenOrder creates a new order with totalprice = NULL in the given currency and returns orderid
+func (r *CartRepository) CreateOpenOrder(ctx context.Context, customerID int64, currency string) (int64, error) {
 	var orderID int64
-	query := `INSERT INTO orders (customerid, orderdate, totalprice, created_at) VALUES ($1, $2, NULL, $3) RETURNING orderid`
-	if err := r.DB.QueryRow(ctx, query, customerID, time.Now(), time.Now()).Scan(&orderID); err != nil {
+	query := `INSERT INTO orders (customerid, orderdate, totalprice, currency, created_at) VALUES ($1, $2, NULL, $3, $4) RETURNING orderid`
+	if err := r.DB.QueryRow(ctx, query, customerID, time.Now(), currency, time.Now()).Scan(&orderID); err != nil {
 		return 0, err
 	}
 	return orderID, nil
 }
 
+// GetOrderCurrency returns the currency an order is priced in
+func (r *CartRepository) GetOrderCurrency(ctx context.Context, orderID int64) (string, error) {
+	var currency string
+	query := `SELECT currency FROM orders WHERE orderid=$1`
+	if err := r.DB.QueryRow(ctx, query, orderID).Scan(&currency); err != nil {
+		return "", errors.New("order not found")
+	}
+	return currency, nil
+}
+
+// SetOrderCurrency switches an open order to another currency (only used while it is empty)
+func (r *CartRepository) SetOrderCurrency(ctx context.Context, orderID int64, currency string) error {
+	query := `UPDATE orders SET currency=$1 WHERE orderid=$2 AND totalprice IS NULL`
+	_, err := r.DB.Exec(ctx, query, currency, orderID)
+	return err
+}
+
+// GetOrderPromo returns the promo code applied to an open order, if any
+func (r *CartRepository) GetOrderPromo(ctx context.Context, orderID int64) (*int64, error) {
+	var promoID *int64
+	query := `SELECT promoid FROM orders WHERE orderid=$1`
+	if err := r.DB.QueryRow(ctx, query, orderID).Scan(&promoID); err != nil {
+		return nil, errors.New("order not found")
+	}
+	return promoID, nil
+}
+
+// SetOrderPromo applies (or with nil removes) a promo code on an open order
+func (r *CartRepository) SetOrderPromo(ctx context.Context, orderID int64, promoID *int64) error {
+	query := `UPDATE orders SET promoid=$1 WHERE orderid=$2 AND totalprice IS NULL`
+	_, err := r.DB.Exec(ctx, query, promoID, orderID)
+	return err
+}
+
 // getGamePrice gets the current games.price (numeric) and title
-func (r *CartRepository) GetGameInfo(ctx context.Context, gameID int64) (title string, price float64, err error) {
+func (r *CartRepository) GetGameInfo(ctx context.Context, gameID int64) (title string, price money.Amount, err error) {
 	query := `SELECT title, price FROM games WHERE gameid=$1 AND deleted_at IS NULL`
 	if err := r.DB.QueryRow(ctx, query, gameID).Scan(&title, &price); err != nil {
 		return "", 0, errors.New("game not found")
@@ -59,18 +95,48 @@ func (r *CartRepository) GetGameInfo(ctx context.Context, gameID int64) (title s
 }
 
 // addOrIncrementOrderItem inserts or increments an item quantity for an order
-func (r *CartRepository) AddOrIncrementOrderItem(ctx context.Context, orderID, gameID int64, qty int, priceAtPurchase float64) error {
+func (r *CartRepository) AddOrIncrementOrderItem(ctx context.Context, orderID, gameID int64, qty int, priceAtPurchase money.Amount, currency string) error {
 	// If orderitem exists, update quantity; else insert
 	query := `
-		INSERT INTO orderitems (orderid, gameid, quantity, priceatpurchase, created_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO orderitems (orderid, gameid, quantity, priceatpurchase, currency, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		ON CONFLICT (orderid, gameid)
 		DO UPDATE SET quantity = orderitems.quantity + EXCLUDED.quantity
 	`
-	_, err := r.DB.Exec(ctx, query, orderID, gameID, qty, priceAtPurchase, time.Now())
+	_, err := r.DB.Exec(ctx, query, orderID, gameID, qty, priceAtPurchase, currency, time.Now())
 	return err
 }
 
+// SetOrderItemPriceTx re-prices a line of an open order inside the checkout transaction
+func (r *CartRepository) SetOrderItemPriceTx(ctx context.Context, tx pgx.Tx, orderID, orderItemID int64, price money.Amount) error {
+	query := `
+		UPDATE orderitems oi SET priceatpurchase=$1
+		FROM orders o
+		WHERE oi.orderitemid=$2 AND oi.orderid=$3 AND o.orderid = oi.orderid AND o.totalprice IS NULL AND oi.deleted_at IS NULL
+	`
+	tag, err := tx.Exec(ctx, query, price, orderItemID, orderID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("cart item not found")
+	}
+	return nil
+}
+
+// SetOrderItemGift marks a cart line as a gift to another customer (nil recipient makes it a normal line again)
+func (r *CartRepository) SetOrderItemGift(ctx context.Context, orderID, gameID int64, recipientID *int64, message *string) error {
+	query := `UPDATE orderitems SET giftrecipientid=$1, giftmessage=$2 WHERE orderid=$3 AND gameid=$4 AND deleted_at IS NULL`
+	tag, err := r.DB.Exec(ctx, query, recipientID, message, orderID, gameID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("cart item not found")
+	}
+	return nil
+}
+
 // setOrderItemQuantity sets exact quantity for an orderitem
 func (r *CartRepository) SetOrderItemQuantity(ctx context.Context, orderID, gameID int64, qty int) error {
 	query := `UPDATE orderitems SET quantity=$1 WHERE orderid=$2 AND gameid=$3 AND deleted_at IS NULL`
@@ -99,44 +165,122 @@ func (r *CartRepository) ClearOrderItems(ctx context.Context, orderID int64) err
 }
 
 // getOrderItems returns cart items for an order, with priceatpurchase and title
-func (r *CartRepository) GetOrderItems(ctx context.Context, orderID int64) ([]model.CartItem, float64, error) {
-	query := `
-		SELECT oi.orderitemid, oi.gameid, g.title, oi.quantity, oi.priceatpurchase
-		FROM orderitems oi
-		JOIN games g ON g.gameid = oi.gameid
-		WHERE oi.orderid=$1 AND oi.deleted_at IS NULL
-	`
-	rows, err := r.DB.Query(ctx, query, orderID)
+func (r *CartRepository) GetOrderItems(ctx context.Context, orderID int64) ([]model.CartItem, money.Amount, error) {
+	rows, err := r.DB.Query(ctx, orderItemsQuery, orderID)
 	if err != nil {
 		return nil, 0, err
 	}
+	return scanOrderItems(rows)
+}
+
+// GetOrderItemsTx is GetOrderItems inside a transaction; the lines stay locked until it ends
+func (r *CartRepository) GetOrderItemsTx(ctx context.Context, tx pgx.Tx, orderID int64) ([]model.CartItem, money.Amount, error) {
+	rows, err := tx.Query(ctx, orderItemsQuery+` FOR UPDATE OF oi`, orderID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return scanOrderItems(rows)
+}
+
+const orderItemsQuery = `
+	SELECT oi.orderitemid, oi.gameid, g.title, oi.quantity, oi.priceatpurchase, oi.currency, oi.giftrecipientid, oi.giftmessage
+	FROM orderitems oi
+	JOIN games g ON g.gameid = oi.gameid
+	WHERE oi.orderid=$1 AND oi.deleted_at IS NULL
+	ORDER BY oi.orderitemid`
+
+func scanOrderItems(rows pgx.Rows) ([]model.CartItem, money.Amount, error) {
 	defer rows.Close()
 
 	var items []model.CartItem
-	var total float64
+	var total money.Amount
 	for rows.Next() {
 		var it model.CartItem
-		if err := rows.Scan(&it.OrderItemID, &it.GameID, &it.Title, &it.Quantity, &it.PriceAtPurchase); err != nil {
+		if err := rows.Scan(&it.OrderItemID, &it.GameID, &it.Title, &it.Quantity, &it.PriceAtPurchase, &it.Currency, &it.GiftTo, &it.GiftMessage); err != nil {
 			return nil, 0, err
 		}
-		it.Subtotal = it.PriceAtPurchase * float64(it.Quantity)
+		it.Subtotal = it.PriceAtPurchase.Mul(it.Quantity)
 		items = append(items, it)
 		total += it.Subtotal
 	}
-	return items, total, nil
+	return items, total, rows.Err()
 }
 
 // checkoutOrder sets totalprice on order to finalize it
-func (r *CartRepository) CheckoutOrder(ctx context.Context, orderID int64, total float64) error {
+func (r *CartRepository) CheckoutOrder(ctx context.Context, orderID int64, total money.Amount) error {
 	query := `UPDATE orders SET totalprice=$1, created_at=created_at WHERE orderid=$2` // keep created_at, orderdate default used by DB
 	_, err := r.DB.Exec(ctx, query, total, orderID)
 	return err
 }
 
+// SetDiscountTx stores the order discount and its allocation over the order items
+func (r *CartRepository) SetDiscountTx(ctx context.Context, tx pgx.Tx, orderID int64, discount money.Amount, perItem map[int64]money.Amount) error {
+	if _, err := tx.Exec(ctx, `UPDATE orders SET discount=$1 WHERE orderid=$2`, discount, orderID); err != nil {
+		return err
+	}
+	for itemID, amount := range perItem {
+		query := `UPDATE orderitems SET discount=$1 WHERE orderitemid=$2 AND orderid=$3`
+		if _, err := tx.Exec(ctx, query, amount, itemID, orderID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetTaxTx stores the subtotal, the tax breakdown and the billing address the tax was computed for
+func (r *CartRepository) SetTaxTx(ctx context.Context, tx pgx.Tx, orderID int64, subtotal money.Amount, res *tax.Result, addr tax.Address) error {
+	query := `
+		UPDATE orders SET subtotal=$1, taxamount=$2, taxname=NULLIF($3, ''), taxrate=$4::numeric, taxinclusive=$5,
+			billing_country=$6, billing_region=NULLIF($7, ''), billing_postalcode=NULLIF($8, '')
+		WHERE orderid=$9
+	`
+	_, err := tx.Exec(ctx, query, subtotal, res.Tax, res.Name, res.Rate, res.Inclusive, addr.Country, addr.Region, addr.PostalCode, orderID)
+	return err
+}
+
+// SetLineTotalsTx stores the tax and the final amount paid for each order item
+func (r *CartRepository) SetLineTotalsTx(ctx context.Context, tx pgx.Tx, orderID int64, taxes, totals map[int64]money.Amount) error {
+	for itemID, total := range totals {
+		query := `UPDATE orderitems SET taxamount=$1, linetotal=$2 WHERE orderitemid=$3 AND orderid=$4`
+		if _, err := tx.Exec(ctx, query, taxes[itemID], total, itemID, orderID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DiscardOpenOrderTx soft-deletes the open order (cart) of a customer, if any
+func (r *CartRepository) DiscardOpenOrderTx(ctx context.Context, tx pgx.Tx, customerID int64) error {
+	query := `UPDATE orders SET deleted_at=$1 WHERE customerid=$2 AND totalprice IS NULL AND deleted_at IS NULL`
+	_, err := tx.Exec(ctx, query, time.Now(), customerID)
+	return err
+}
+
+// LockOpenOrderTx locks the order for the rest of the tx and reports whether it is still
+// an open cart; a concurrent checkout of the same cart waits here and then sees it closed
+func (r *CartRepository) LockOpenOrderTx(ctx context.Context, tx pgx.Tx, orderID int64) (bool, error) {
+	query := `SELECT orderid FROM orders WHERE orderid=$1 AND totalprice IS NULL AND deleted_at IS NULL FOR UPDATE`
+	var id int64
+	if err := tx.QueryRow(ctx, query, orderID).Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
 // CheckoutOrderTx updates order totalprice and orderdate inside a transaction.
-func (r *CartRepository) CheckoutOrderTx(ctx context.Context, tx pgx.Tx, orderID int64, total float64) error {
+// Only an open cart is checked out, an order is never priced twice.
+func (r *CartRepository) CheckoutOrderTx(ctx context.Context, tx pgx.Tx, orderID int64, total money.Amount) error {
 	// set totalprice and update orderdate to now
-	query := `UPDATE orders SET totalprice=$1, orderdate=$2 WHERE orderid=$3`
-	_, err := tx.Exec(ctx, query, total, time.Now(), orderID)
-	return err
+	query := `UPDATE orders SET totalprice=$1, orderdate=$2 WHERE orderid=$3 AND totalprice IS NULL`
+	tag, err := tx.Exec(ctx, query, total, time.Now(), orderID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("order already checked out")
+	}
+	return nil
 }
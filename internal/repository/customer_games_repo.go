@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/pagination"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -69,6 +70,49 @@ func (r *CustomerGamesRepository) CreateCustomerGamesTx(ctx context.Context, tx
 	return err
 }
 
+// DeleteCustomerGamesTx revokes ownership records inside the provided tx.
+func (r *CustomerGamesRepository) DeleteCustomerGamesTx(ctx context.Context, tx pgx.Tx, customerID int64, gameIDs []int64) error {
+	if len(gameIDs) == 0 {
+		return nil
+	}
+	_, err := tx.Exec(ctx, `DELETE FROM customer_games WHERE customerid = $1 AND gameid = ANY($2)`, customerID, gameIDs)
+	return err
+}
+
+// AnyDownloadedTx reports whether the customer has downloaded any of the given games. The
+// ownership rows stay locked until the tx ends, so no first download slips in meanwhile.
+func (r *CustomerGamesRepository) AnyDownloadedTx(ctx context.Context, tx pgx.Tx, customerID int64, gameIDs []int64) (bool, error) {
+	q := `SELECT downloaded_at IS NOT NULL FROM customer_games WHERE customerid = $1 AND gameid = ANY($2) FOR UPDATE`
+	rows, err := tx.Query(ctx, q, customerID, gameIDs)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	downloaded := false
+	for rows.Next() {
+		var d bool
+		if err := rows.Scan(&d); err != nil {
+			return false, err
+		}
+		downloaded = downloaded || d
+	}
+	return downloaded, rows.Err()
+}
+
+// MarkDownloaded records the first download of an owned game
+func (r *CustomerGamesRepository) MarkDownloaded(ctx context.Context, customerID, gameID int64) error {
+	q := `UPDATE customer_games SET downloaded_at = COALESCE(downloaded_at, now()) WHERE customerid = $1 AND gameid = $2`
+	tag, err := r.DB.Exec(ctx, q, customerID, gameID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("game not owned")
+	}
+	return nil
+}
+
 // NOTE: If you want non-TX helpers later, add them here.
 
 // InsertPurchased inserts only NEW ownership records
@@ -87,14 +131,17 @@ func (r *CustomerGamesRepository) InsertPurchased(ctx context.Context, customerI
 }
 
 // ListOwnedGames returns all games the customer owns
-func (r *CustomerGamesRepository) ListOwnedGames(ctx context.Context, customerID int64) ([]model.Game, error) {
+func (r *CustomerGamesRepository) ListOwnedGames(ctx context.Context, customerID int64, p pagination.Params) ([]model.Game, error) {
 	query := `
         SELECT g.gameid, g.title, g.price, g.releasedate, g.developerid
         FROM customer_games cg
         JOIN games g ON g.gameid = cg.gameid
         WHERE cg.customerid = $1 AND g.deleted_at IS NULL
+          AND ($2::int IS NULL OR g.gameid > $2)
+        ORDER BY g.gameid
+        LIMIT $3
     `
-	rows, err := r.DB.Query(ctx, query, customerID)
+	rows, err := r.DB.Query(ctx, query, customerID, p.After, p.Fetch())
 	if err != nil {
 		return nil, err
 	}
@@ -117,15 +164,28 @@ func (r *CustomerGamesRepository) ListOwnedGames(ctx context.Context, customerID
 	return list, nil
 }
 
-// ListOrders returns all completed order headers
-func (r *CustomerGamesRepository) ListOrders(ctx context.Context, customerID int64) ([]model.Order, error) {
+// CountOwnedGames is the total of ListOwnedGames
+func (r *CustomerGamesRepository) CountOwnedGames(ctx context.Context, customerID int64) (int64, error) {
 	query := `
-        SELECT orderid, customerid, orderdate, totalprice, created_at
-        FROM orders
-        WHERE customerid=$1 AND totalprice IS NOT NULL
-        ORDER BY created_at DESC
+        SELECT count(*)
+        FROM customer_games cg
+        JOIN games g ON g.gameid = cg.gameid
+        WHERE cg.customerid = $1 AND g.deleted_at IS NULL
     `
-	rows, err := r.DB.Query(ctx, query, customerID)
+	return countRows(ctx, r.DB, query, customerID)
+}
+
+// ListOrders returns completed order headers, newest first
+func (r *CustomerGamesRepository) ListOrders(ctx context.Context, customerID int64, p pagination.Params) ([]model.Order, error) {
+	query := `
+        SELECT ` + orderColumns + `
+        FROM orders o
+        WHERE o.customerid=$1 AND o.totalprice IS NOT NULL
+          AND ($2::int IS NULL OR o.orderid < $2)
+        ORDER BY o.orderid DESC
+        LIMIT $3
+    `
+	rows, err := r.DB.Query(ctx, query, customerID, p.After, p.Fetch())
 	if err != nil {
 		return nil, err
 	}
@@ -134,7 +194,7 @@ func (r *CustomerGamesRepository) ListOrders(ctx context.Context, customerID int
 	var list []model.Order
 	for rows.Next() {
 		var o model.Order
-		if err := rows.Scan(&o.OrderID, &o.CustomerID, &o.OrderDate, &o.TotalPrice, &o.CreatedAt); err != nil {
+		if err := scanOrder(rows, &o); err != nil {
 			return nil, err
 		}
 		list = append(list, o)
@@ -142,24 +202,29 @@ func (r *CustomerGamesRepository) ListOrders(ctx context.Context, customerID int
 	return list, nil
 }
 
+// CountOrders is the total of ListOrders
+func (r *CustomerGamesRepository) CountOrders(ctx context.Context, customerID int64) (int64, error) {
+	return countRows(ctx, r.DB, `SELECT count(*) FROM orders WHERE customerid=$1 AND totalprice IS NOT NULL`, customerID)
+}
+
 // GetOrderDetails returns one order with items
 func (r *CustomerGamesRepository) GetOrderDetails(ctx context.Context, customerID, orderID int64) (*model.Order, []model.OrderItem, error) {
 	var o model.Order
 	q1 := `
-        SELECT orderid, customerid, orderdate, totalprice, created_at
-        FROM orders
-        WHERE orderid=$1 AND customerid=$2
+        SELECT ` + orderColumns + `
+        FROM orders o
+        WHERE o.orderid=$1 AND o.customerid=$2
     `
-	if err := r.DB.QueryRow(ctx, q1, orderID, customerID).Scan(
-		&o.OrderID, &o.CustomerID, &o.OrderDate, &o.TotalPrice, &o.CreatedAt,
-	); err != nil {
+	if err := scanOrder(r.DB.QueryRow(ctx, q1, orderID, customerID), &o); err != nil {
 		return nil, nil, errors.New("order not found")
 	}
 
 	q2 := `
-        SELECT orderitemid, gameid, quantity, priceatpurchase, created_at
-        FROM orderitems
-        WHERE orderid=$1 AND deleted_at IS NULL
+        SELECT oi.orderitemid, oi.gameid, g.title, oi.quantity, oi.priceatpurchase, oi.currency, oi.discount, oi.taxamount, oi.linetotal, oi.created_at, oi.refunded_at
+        FROM orderitems oi
+        JOIN games g ON g.gameid = oi.gameid
+        WHERE oi.orderid=$1 AND oi.deleted_at IS NULL
+        ORDER BY oi.orderitemid
     `
 	rows, err := r.DB.Query(ctx, q2, orderID)
 	if err != nil {
@@ -170,7 +235,7 @@ func (r *CustomerGamesRepository) GetOrderDetails(ctx context.Context, customerI
 	var items []model.OrderItem
 	for rows.Next() {
 		var it model.OrderItem
-		if err := rows.Scan(&it.OrderItemID, &it.GameID, &it.Quantity, &it.PriceAtPurchase, &it.CreatedAt); err != nil {
+		if err := rows.Scan(&it.OrderItemID, &it.GameID, &it.Title, &it.Quantity, &it.PriceAtPurchase, &it.Currency, &it.Discount, &it.TaxAmount, &it.LineTotal, &it.CreatedAt, &it.RefundedAt); err != nil {
 			return nil, nil, err
 		}
 		items = append(items, it)
@@ -179,15 +244,17 @@ func (r *CustomerGamesRepository) GetOrderDetails(ctx context.Context, customerI
 	return &o, items, nil
 }
 
-// ListAllOrders returns all completed orders across all users
-func (r *CustomerGamesRepository) ListAllOrders(ctx context.Context) ([]model.Order, error) {
+// ListAllOrders returns completed orders across all users, newest first
+func (r *CustomerGamesRepository) ListAllOrders(ctx context.Context, p pagination.Params) ([]model.Order, error) {
 	query := `
-        SELECT orderid, customerid, orderdate, totalprice, created_at
-        FROM orders
-        WHERE totalprice IS NOT NULL
-        ORDER BY created_at DESC
+        SELECT ` + orderColumns + `
+        FROM orders o
+        WHERE o.totalprice IS NOT NULL
+          AND ($1::int IS NULL OR o.orderid < $1)
+        ORDER BY o.orderid DESC
+        LIMIT $2
     `
-	rows, err := r.DB.Query(ctx, query)
+	rows, err := r.DB.Query(ctx, query, p.After, p.Fetch())
 	if err != nil {
 		return nil, err
 	}
@@ -196,7 +263,7 @@ func (r *CustomerGamesRepository) ListAllOrders(ctx context.Context) ([]model.Or
 	var list []model.Order
 	for rows.Next() {
 		var o model.Order
-		if err := rows.Scan(&o.OrderID, &o.CustomerID, &o.OrderDate, &o.TotalPrice, &o.CreatedAt); err != nil {
+		if err := scanOrder(rows, &o); err != nil {
 			return nil, err
 		}
 		list = append(list, o)
@@ -204,24 +271,29 @@ func (r *CustomerGamesRepository) ListAllOrders(ctx context.Context) ([]model.Or
 	return list, nil
 }
 
+// CountAllOrders is the total of ListAllOrders
+func (r *CustomerGamesRepository) CountAllOrders(ctx context.Context) (int64, error) {
+	return countRows(ctx, r.DB, `SELECT count(*) FROM orders WHERE totalprice IS NOT NULL`)
+}
+
 // GetOrderDetailsAdmin returns order and items without checking customer ownership
 func (r *CustomerGamesRepository) GetOrderDetailsAdmin(ctx context.Context, orderID int64) (*model.Order, []model.OrderItem, error) {
 	var o model.Order
 	q1 := `
-        SELECT orderid, customerid, orderdate, totalprice, created_at
-        FROM orders
-        WHERE orderid=$1
+        SELECT ` + orderColumns + `
+        FROM orders o
+        WHERE o.orderid=$1
     `
-	if err := r.DB.QueryRow(ctx, q1, orderID).Scan(
-		&o.OrderID, &o.CustomerID, &o.OrderDate, &o.TotalPrice, &o.CreatedAt,
-	); err != nil {
+	if err := scanOrder(r.DB.QueryRow(ctx, q1, orderID), &o); err != nil {
 		return nil, nil, errors.New("order not found")
 	}
 
 	q2 := `
-        SELECT orderitemid, gameid, quantity, priceatpurchase, created_at
-        FROM orderitems
-        WHERE orderid=$1 AND deleted_at IS NULL
+        SELECT oi.orderitemid, oi.gameid, g.title, oi.quantity, oi.priceatpurchase, oi.currency, oi.discount, oi.taxamount, oi.linetotal, oi.created_at, oi.refunded_at
+        FROM orderitems oi
+        JOIN games g ON g.gameid = oi.gameid
+        WHERE oi.orderid=$1 AND oi.deleted_at IS NULL
+        ORDER BY oi.orderitemid
     `
 	rows, err := r.DB.Query(ctx, q2, orderID)
 	if err != nil {
@@ -232,7 +304,7 @@ func (r *CustomerGamesRepository) GetOrderDetailsAdmin(ctx context.Context, orde
 	var items []model.OrderItem
 	for rows.Next() {
 		var it model.OrderItem
-		if err := rows.Scan(&it.OrderItemID, &it.GameID, &it.Quantity, &it.PriceAtPurchase, &it.CreatedAt); err != nil {
+		if err := rows.Scan(&it.OrderItemID, &it.GameID, &it.Title, &it.Quantity, &it.PriceAtPurchase, &it.Currency, &it.Discount, &it.TaxAmount, &it.LineTotal, &it.CreatedAt, &it.RefundedAt); err != nil {
 			return nil, nil, err
 		}
 		items = append(items, it)
@@ -7,6 +7,7 @@ import (
 
 	"GameStoreAPI/internal/model"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -35,8 +36,8 @@ func (r *CustomerRepository) Create(ctx context.Context, authID int64, email str
 // GetByAuthID returns a customer by authid
 func (r *CustomerRepository) GetByAuthID(ctx context.Context, authID int64) (*model.Customer, error) {
 	var c model.Customer
-	query := `SELECT customerid, authid, username, fullname, email, address, phone, created_at, deleted_at FROM customers WHERE authid=$1 AND deleted_at IS NULL`
-	if err := r.DB.QueryRow(ctx, query, authID).Scan(&c.CustomerID, &c.AuthID, &c.Username, &c.Fullname, &c.Email, &c.Address, &c.Phone, &c.CreatedAt, &c.DeletedAt); err != nil {
+	query := `SELECT customerid, authid, username, fullname, email, address, phone, region, currency, billing_country, billing_region, billing_postalcode, created_at, deleted_at FROM customers WHERE authid=$1 AND deleted_at IS NULL`
+	if err := r.DB.QueryRow(ctx, query, authID).Scan(&c.CustomerID, &c.AuthID, &c.Username, &c.Fullname, &c.Email, &c.Address, &c.Phone, &c.Region, &c.Currency, &c.BillingCountry, &c.BillingRegion, &c.BillingPostalCode, &c.CreatedAt, &c.DeletedAt); err != nil {
 		return nil, errors.New("customer not found")
 	}
 	return &c, nil
@@ -45,8 +46,18 @@ func (r *CustomerRepository) GetByAuthID(ctx context.Context, authID int64) (*mo
 // GetByID returns a customer by customerid (internal use)
 func (r *CustomerRepository) GetByID(ctx context.Context, id int64) (*model.Customer, error) {
 	var c model.Customer
-	query := `SELECT customerid, authid, username, fullname, email, address, phone, created_at, deleted_at FROM customers WHERE customerid=$1`
-	if err := r.DB.QueryRow(ctx, query, id).Scan(&c.CustomerID, &c.AuthID, &c.Username, &c.Fullname, &c.Email, &c.Address, &c.Phone, &c.CreatedAt, &c.DeletedAt); err != nil {
+	query := `SELECT customerid, authid, username, fullname, email, address, phone, region, currency, billing_country, billing_region, billing_postalcode, created_at, deleted_at FROM customers WHERE customerid=$1`
+	if err := r.DB.QueryRow(ctx, query, id).Scan(&c.CustomerID, &c.AuthID, &c.Username, &c.Fullname, &c.Email, &c.Address, &c.Phone, &c.Region, &c.Currency, &c.BillingCountry, &c.BillingRegion, &c.BillingPostalCode, &c.CreatedAt, &c.DeletedAt); err != nil {
+		return nil, errors.New("customer not found")
+	}
+	return &c, nil
+}
+
+// FindByEmailOrUsername looks up an active customer by email (case-insensitive) or username
+func (r *CustomerRepository) FindByEmailOrUsername(ctx context.Context, login string) (*model.Customer, error) {
+	var c model.Customer
+	query := `SELECT customerid, authid, username, fullname, email, address, phone, region, currency, billing_country, billing_region, billing_postalcode, created_at, deleted_at FROM customers WHERE (lower(email)=lower($1) OR username=$1) AND deleted_at IS NULL LIMIT 1`
+	if err := r.DB.QueryRow(ctx, query, login).Scan(&c.CustomerID, &c.AuthID, &c.Username, &c.Fullname, &c.Email, &c.Address, &c.Phone, &c.Region, &c.Currency, &c.BillingCountry, &c.BillingRegion, &c.BillingPostalCode, &c.CreatedAt, &c.DeletedAt); err != nil {
 		return nil, errors.New("customer not found")
 	}
 	return &c, nil
@@ -65,10 +76,73 @@ func (r *CustomerRepository) Update(ctx context.Context, id int64, fullname, add
 	return nil
 }
 
+// UpdatePreferences sets the customer's region and currency. A nil value keeps the
+// stored preference, an empty string clears it.
+func (r *CustomerRepository) UpdatePreferences(ctx context.Context, id int64, region, currency *string) error {
+	query := `
+		UPDATE customers
+		SET region = CASE WHEN $1::text IS NULL THEN region ELSE NULLIF($1, '') END,
+		    currency = CASE WHEN $2::text IS NULL THEN currency ELSE NULLIF($2, '') END
+		WHERE customerid=$3 AND deleted_at IS NULL
+	`
+	tag, err := r.DB.Exec(ctx, query, region, currency, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("customer not found or deleted")
+	}
+	return nil
+}
+
+// UpdateBillingAddress replaces the customer's billing address
+func (r *CustomerRepository) UpdateBillingAddress(ctx context.Context, id int64, addr model.BillingAddress) error {
+	query := `
+		UPDATE customers SET billing_country=$1, billing_region=NULLIF($2, ''), billing_postalcode=NULLIF($3, '')
+		WHERE customerid=$4 AND deleted_at IS NULL
+	`
+	tag, err := r.DB.Exec(ctx, query, addr.Country, addr.Region, addr.PostalCode, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("customer not found or deleted")
+	}
+	return nil
+}
+
+// SetActiveTx deactivates the customer of an account (deleted_at set) or reactivates it
+func (r *CustomerRepository) SetActiveTx(ctx context.Context, tx pgx.Tx, authID int64, active bool) error {
+	var deletedAt *time.Time
+	if !active {
+		now := time.Now()
+		deletedAt = &now
+	}
+	_, err := tx.Exec(ctx, `UPDATE customers SET deleted_at=$1 WHERE authid=$2`, deletedAt, authID)
+	return err
+}
+
+// AnonymizeTx erases the personal data of the customer of an account and returns its id.
+// The row itself stays so that orders keep pointing at it.
+func (r *CustomerRepository) AnonymizeTx(ctx context.Context, tx pgx.Tx, authID int64) (int64, error) {
+	var id int64
+	query := `
+		UPDATE customers
+		SET username=NULL, fullname=NULL, email='deleted-' || customerid || '@deleted.invalid', address=NULL, phone=NULL,
+		    billing_country=NULL, billing_region=NULL, billing_postalcode=NULL, deleted_at=COALESCE(deleted_at, $1)
+		WHERE authid=$2
+		RETURNING customerid
+	`
+	if err := tx.QueryRow(ctx, query, time.Now(), authID).Scan(&id); err != nil {
+		return 0, errors.New("customer not found")
+	}
+	return id, nil
+}
+
 // ListAll returns all customers (admin use). Note: Personal fields are returned here;
 // admin handlers should redact if privacy requires.
 func (r *CustomerRepository) ListAll(ctx context.Context) ([]model.Customer, error) {
-	query := `SELECT customerid, authid, username, fullname, email, address, phone, created_at, deleted_at FROM customers ORDER BY customerid`
+	query := `SELECT customerid, authid, username, fullname, email, address, phone, region, currency, billing_country, billing_region, billing_postalcode, created_at, deleted_at FROM customers ORDER BY customerid`
 	rows, err := r.DB.Query(ctx, query)
 	if err != nil {
 		return nil, err
@@ -78,7 +152,7 @@ func (r *CustomerRepository) ListAll(ctx context.Context) ([]model.Customer, err
 	var out []model.Customer
 	for rows.Next() {
 		var c model.Customer
-		if err := rows.Scan(&c.CustomerID, &c.AuthID, &c.Username, &c.Fullname, &c.Email, &c.Address, &c.Phone, &c.CreatedAt, &c.DeletedAt); err != nil {
+		if err := rows.Scan(&c.CustomerID, &c.AuthID, &c.Username, &c.Fullname, &c.Email, &c.Address, &c.Phone, &c.Region, &c.Currency, &c.BillingCountry, &c.BillingRegion, &c.BillingPostalCode, &c.CreatedAt, &c.DeletedAt); err != nil {
 			return nil, err
 		}
 		out = append(out, c)
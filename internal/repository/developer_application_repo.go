@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/pagination"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DeveloperApplicationRepository stores the applications of accounts to become developers
+type DeveloperApplicationRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewDeveloperApplicationRepository(db *pgxpool.Pool) *DeveloperApplicationRepository {
+	return &DeveloperApplicationRepository{DB: db}
+}
+
+const devApplicationColumns = `a.applicationid, a.authid, a.developername, a.website, a.message, a.status, a.developerid, a.reviewedby, a.reviewcomment, a.created_at, a.reviewed_at`
+
+func scanDevApplication(row pgx.Row, a *model.DeveloperApplication) error {
+	return row.Scan(&a.ApplicationID, &a.AuthID, &a.DeveloperName, &a.Website, &a.Message, &a.Status, &a.DeveloperID,
+		&a.ReviewedBy, &a.ReviewComment, &a.CreatedAt, &a.ReviewedAt)
+}
+
+func collectDevApplications(rows pgx.Rows) ([]model.DeveloperApplication, error) {
+	defer rows.Close()
+	list := []model.DeveloperApplication{}
+	for rows.Next() {
+		var a model.DeveloperApplication
+		if err := scanDevApplication(rows, &a); err != nil {
+			return nil, err
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
+
+// Create stores a pending application and fills in its id, status and creation time
+func (r *DeveloperApplicationRepository) Create(ctx context.Context, a *model.DeveloperApplication) error {
+	now := time.Now()
+	query := `
+		INSERT INTO developerapplications (authid, developername, website, message, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING applicationid
+	`
+	if err := r.DB.QueryRow(ctx, query, a.AuthID, a.DeveloperName, a.Website, a.Message, model.DevApplicationPending, now).Scan(&a.ApplicationID); err != nil {
+		return err
+	}
+	a.Status, a.CreatedAt = model.DevApplicationPending, &now
+	return nil
+}
+
+func (r *DeveloperApplicationRepository) GetByID(ctx context.Context, id int64) (*model.DeveloperApplication, error) {
+	var a model.DeveloperApplication
+	query := `SELECT ` + devApplicationColumns + ` FROM developerapplications a WHERE a.applicationid=$1`
+	if err := scanDevApplication(r.DB.QueryRow(ctx, query, id), &a); err != nil {
+		return nil, errors.New("application not found")
+	}
+	return &a, nil
+}
+
+// HasPending reports whether the account has an application waiting for review
+func (r *DeveloperApplicationRepository) HasPending(ctx context.Context, authID int64) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM developerapplications WHERE authid=$1 AND status=$2)`
+	if err := r.DB.QueryRow(ctx, query, authID, model.DevApplicationPending).Scan(&exists); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+func (r *DeveloperApplicationRepository) ListByAuthID(ctx context.Context, authID int64, p pagination.Params) ([]model.DeveloperApplication, error) {
+	query := `SELECT ` + devApplicationColumns + ` FROM developerapplications a WHERE a.authid=$1 AND ($2::int IS NULL OR a.applicationid < $2) ORDER BY a.applicationid DESC LIMIT $3`
+	rows, err := r.DB.Query(ctx, query, authID, p.After, p.Fetch())
+	if err != nil {
+		return nil, err
+	}
+	return collectDevApplications(rows)
+}
+
+func (r *DeveloperApplicationRepository) CountByAuthID(ctx context.Context, authID int64) (int64, error) {
+	return countRows(ctx, r.DB, `SELECT count(*) FROM developerapplications WHERE authid=$1`, authID)
+}
+
+// ListAll returns applications, optionally filtered by status (admin use)
+func (r *DeveloperApplicationRepository) ListAll(ctx context.Context, status string, p pagination.Params) ([]model.DeveloperApplication, error) {
+	query := `SELECT ` + devApplicationColumns + ` FROM developerapplications a WHERE ($1 = '' OR a.status = $1) AND ($2::int IS NULL OR a.applicationid < $2) ORDER BY a.applicationid DESC LIMIT $3`
+	rows, err := r.DB.Query(ctx, query, status, p.After, p.Fetch())
+	if err != nil {
+		return nil, err
+	}
+	return collectDevApplications(rows)
+}
+
+func (r *DeveloperApplicationRepository) CountAll(ctx context.Context, status string) (int64, error) {
+	return countRows(ctx, r.DB, `SELECT count(*) FROM developerapplications WHERE ($1 = '' OR status = $1)`, status)
+}
+
+// ReviewTx records the decision on a pending application; developerID is set for approvals
+func (r *DeveloperApplicationRepository) ReviewTx(ctx context.Context, tx pgx.Tx, id int64, status string, developerID *int64, reviewerID int64, comment *string) error {
+	query := `
+		UPDATE developerapplications SET status=$1, developerid=$2, reviewedby=$3, reviewcomment=$4, reviewed_at=$5
+		WHERE applicationid=$6 AND status=$7
+	`
+	tag, err := tx.Exec(ctx, query, status, developerID, reviewerID, comment, time.Now(), id, model.DevApplicationPending)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("application not found or already reviewed")
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/pagination"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DeveloperMemberRepository stores the teams of developers (developermembers)
+type DeveloperMemberRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewDeveloperMemberRepository(db *pgxpool.Pool) *DeveloperMemberRepository {
+	return &DeveloperMemberRepository{DB: db}
+}
+
+// AddTx puts an account on the team of a developer, or changes its role when it already is
+func (r *DeveloperMemberRepository) AddTx(ctx context.Context, tx pgx.Tx, developerID, authID int64, role string, addedBy *int64) error {
+	query := `
+		INSERT INTO developermembers (developerid, authid, role, addedby, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (developerid, authid) DO UPDATE SET role = EXCLUDED.role
+	`
+	_, err := tx.Exec(ctx, query, developerID, authID, role, addedBy, time.Now())
+	return err
+}
+
+// CountByAuthIDTx counts the teams an account is on
+func (r *DeveloperMemberRepository) CountByAuthIDTx(ctx context.Context, tx pgx.Tx, authID int64) (int, error) {
+	var n int
+	if err := tx.QueryRow(ctx, `SELECT count(*) FROM developermembers WHERE authid=$1`, authID).Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// RoleOf returns the team role of an account, or "" when it is not a member of an active developer
+func (r *DeveloperMemberRepository) RoleOf(ctx context.Context, developerID, authID int64) (string, error) {
+	var role string
+	query := `
+		SELECT m.role
+		FROM developermembers m
+		JOIN developers d ON d.developerid = m.developerid
+		WHERE m.developerid=$1 AND m.authid=$2 AND d.deleted_at IS NULL
+	`
+	err := r.DB.QueryRow(ctx, query, developerID, authID).Scan(&role)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return role, err
+}
+
+// memberRank orders owners first, then admins, then members
+const memberRank = `CASE m.role WHEN 'owner' THEN 0 WHEN 'admin' THEN 1 ELSE 2 END`
+
+// MemberKey is the sort key and id of a member in List
+func MemberKey(m model.DeveloperMember) (string, int64) {
+	rank := "2"
+	switch m.Role {
+	case model.DevRoleOwner:
+		rank = "0"
+	case model.DevRoleAdmin:
+		rank = "1"
+	}
+	return rank, m.AuthID
+}
+
+// List returns the team of a developer, owners first. p.AfterKey is the MemberKey of p.After.
+func (r *DeveloperMemberRepository) List(ctx context.Context, developerID int64, p pagination.Params) ([]model.DeveloperMember, error) {
+	query := `
+		SELECT m.developerid, m.authid, u.email, m.role, m.addedby, m.created_at
+		FROM developermembers m
+		JOIN userauth u ON u.authid = m.authid
+		WHERE m.developerid=$1
+		  AND ($2::int IS NULL OR (` + memberRank + `, m.authid) > ($3::text::int, $2))
+		ORDER BY ` + memberRank + `, m.authid
+		LIMIT $4
+	`
+	var afterKey *string
+	if p.After != nil {
+		afterKey = &p.AfterKey
+	}
+	rows, err := r.DB.Query(ctx, query, developerID, p.After, afterKey, p.Fetch())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.DeveloperMember{}
+	for rows.Next() {
+		var m model.DeveloperMember
+		if err := rows.Scan(&m.DeveloperID, &m.AuthID, &m.Email, &m.Role, &m.AddedBy, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
+
+// Count returns the size of the team of a developer
+func (r *DeveloperMemberRepository) Count(ctx context.Context, developerID int64) (int64, error) {
+	return countRows(ctx, r.DB, `SELECT count(*) FROM developermembers WHERE developerid=$1`, developerID)
+}
+
+// ListByAuthID returns the active developers an account is a member of
+func (r *DeveloperMemberRepository) ListByAuthID(ctx context.Context, authID int64) ([]model.DeveloperMembership, error) {
+	query := `
+		SELECT d.developerid, d.developername, d.authid, d.created_at, d.deleted_at, m.role
+		FROM developermembers m
+		JOIN developers d ON d.developerid = m.developerid
+		WHERE m.authid=$1 AND d.deleted_at IS NULL
+		ORDER BY d.developerid
+	`
+	rows, err := r.DB.Query(ctx, query, authID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.DeveloperMembership{}
+	for rows.Next() {
+		var m model.DeveloperMembership
+		if err := rows.Scan(&m.DeveloperID, &m.DeveloperName, &m.AuthID, &m.CreatedAt, &m.DeletedAt, &m.Role); err != nil {
+			return nil, err
+		}
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
+
+// UpdateRoleTx changes the team role of a member
+func (r *DeveloperMemberRepository) UpdateRoleTx(ctx context.Context, tx pgx.Tx, developerID, authID int64, role string) error {
+	tag, err := tx.Exec(ctx, `UPDATE developermembers SET role=$1 WHERE developerid=$2 AND authid=$3`, role, developerID, authID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("member not found")
+	}
+	return nil
+}
+
+// RemoveTx takes an account off the team of a developer
+func (r *DeveloperMemberRepository) RemoveTx(ctx context.Context, tx pgx.Tx, developerID, authID int64) error {
+	tag, err := tx.Exec(ctx, `DELETE FROM developermembers WHERE developerid=$1 AND authid=$2`, developerID, authID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("member not found")
+	}
+	return nil
+}
+
+// CountOwnersTx counts the owners of a developer, locking their rows until the transaction ends
+func (r *DeveloperMemberRepository) CountOwnersTx(ctx context.Context, tx pgx.Tx, developerID int64) (int, error) {
+	var n int
+	query := `SELECT count(*) FROM (SELECT 1 FROM developermembers WHERE developerid=$1 AND role=$2 FOR UPDATE) o`
+	if err := tx.QueryRow(ctx, query, developerID, model.DevRoleOwner).Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Invite invites an account to the team of a developer, or changes the role of its open invite
+func (r *DeveloperMemberRepository) Invite(ctx context.Context, developerID, authID int64, role string, invitedBy int64) error {
+	query := `
+		INSERT INTO developerinvites (developerid, authid, role, invitedby, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (developerid, authid) DO UPDATE SET role = EXCLUDED.role, invitedby = EXCLUDED.invitedby, created_at = EXCLUDED.created_at
+	`
+	_, err := r.DB.Exec(ctx, query, developerID, authID, role, invitedBy, time.Now())
+	return err
+}
+
+// TakeInviteTx removes the open invite of an account and returns it
+func (r *DeveloperMemberRepository) TakeInviteTx(ctx context.Context, tx pgx.Tx, developerID, authID int64) (*model.DeveloperInvite, error) {
+	inv := model.DeveloperInvite{DeveloperID: developerID, AuthID: authID}
+	query := `DELETE FROM developerinvites WHERE developerid=$1 AND authid=$2 RETURNING role, invitedby, created_at`
+	if err := tx.QueryRow(ctx, query, developerID, authID).Scan(&inv.Role, &inv.InvitedBy, &inv.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("invite not found")
+		}
+		return nil, err
+	}
+	return &inv, nil
+}
+
+// DeleteInvite removes the open invite of an account
+func (r *DeveloperMemberRepository) DeleteInvite(ctx context.Context, developerID, authID int64) error {
+	tag, err := r.DB.Exec(ctx, `DELETE FROM developerinvites WHERE developerid=$1 AND authid=$2`, developerID, authID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("invite not found")
+	}
+	return nil
+}
+
+// ListInvitesByAuthID returns the open invites of an account to active developers
+func (r *DeveloperMemberRepository) ListInvitesByAuthID(ctx context.Context, authID int64) ([]model.DeveloperInvite, error) {
+	query := `
+		SELECT i.developerid, d.developername, i.authid, u.email, i.role, i.invitedby, i.created_at
+		FROM developerinvites i
+		JOIN developers d ON d.developerid = i.developerid
+		JOIN userauth u ON u.authid = i.authid
+		WHERE i.authid=$1 AND d.deleted_at IS NULL
+		ORDER BY i.developerid
+	`
+	rows, err := r.DB.Query(ctx, query, authID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.DeveloperInvite{}
+	for rows.Next() {
+		var inv model.DeveloperInvite
+		if err := rows.Scan(&inv.DeveloperID, &inv.DeveloperName, &inv.AuthID, &inv.Email, &inv.Role, &inv.InvitedBy, &inv.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, inv)
+	}
+	return list, rows.Err()
+}
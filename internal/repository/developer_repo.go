@@ -6,7 +6,9 @@ import (
 	"time"
 
 	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/pagination"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -18,11 +20,11 @@ func NewDeveloperRepository(db *pgxpool.Pool) *DeveloperRepository {
 	return &DeveloperRepository{DB: db}
 }
 
-// CreateDeveloper inserts a new developer and returns the created id.
-func (r *DeveloperRepository) CreateDeveloper(ctx context.Context, name string, authID *int64) (int64, error) {
+// CreateDeveloperTx inserts a new developer and returns the created id.
+func (r *DeveloperRepository) CreateDeveloperTx(ctx context.Context, tx pgx.Tx, name string, authID *int64) (int64, error) {
 	var id int64
 	query := `INSERT INTO developers (developername, authid, created_at) VALUES ($1, $2, $3) RETURNING developerid`
-	if err := r.DB.QueryRow(ctx, query, name, authID, time.Now()).Scan(&id); err != nil {
+	if err := tx.QueryRow(ctx, query, name, authID, time.Now()).Scan(&id); err != nil {
 		return 0, err
 	}
 	return id, nil
@@ -47,9 +49,9 @@ func (r *DeveloperRepository) GetByAuthID(ctx context.Context, authID int64) (*m
 	return &d, nil
 }
 
-func (r *DeveloperRepository) GetAll(ctx context.Context) ([]model.Developer, error) {
-	query := `SELECT developerid, developername, authid, created_at, deleted_at FROM developers WHERE deleted_at IS NULL ORDER BY developerid`
-	rows, err := r.DB.Query(ctx, query)
+func (r *DeveloperRepository) GetAll(ctx context.Context, p pagination.Params) ([]model.Developer, error) {
+	query := `SELECT developerid, developername, authid, created_at, deleted_at FROM developers WHERE deleted_at IS NULL AND ($1::int IS NULL OR developerid > $1) ORDER BY developerid LIMIT $2`
+	rows, err := r.DB.Query(ctx, query, p.After, p.Fetch())
 	if err != nil {
 		return nil, err
 	}
@@ -66,9 +68,13 @@ func (r *DeveloperRepository) GetAll(ctx context.Context) ([]model.Developer, er
 	return list, nil
 }
 
-func (r *DeveloperRepository) UpdateDeveloper(ctx context.Context, id int64, name string, authID *int64) error {
+func (r *DeveloperRepository) Count(ctx context.Context) (int64, error) {
+	return countRows(ctx, r.DB, `SELECT count(*) FROM developers WHERE deleted_at IS NULL`)
+}
+
+func (r *DeveloperRepository) UpdateDeveloperTx(ctx context.Context, tx pgx.Tx, id int64, name string, authID *int64) error {
 	query := `UPDATE developers SET developername=$1, authid=$2 WHERE developerid=$3 AND deleted_at IS NULL`
-	tag, err := r.DB.Exec(ctx, query, name, authID, id)
+	tag, err := tx.Exec(ctx, query, name, authID, id)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"GameStoreAPI/internal/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// LoadMetadata fills the localized descriptions, media, system requirements and languages
+// of a game
+func (r *GameRepository) LoadMetadata(ctx context.Context, g *model.Game) error {
+	var err error
+	if g.Descriptions, err = r.listDescriptions(ctx, g.GameID); err != nil {
+		return err
+	}
+	if g.Media, err = r.listMedia(ctx, g.GameID); err != nil {
+		return err
+	}
+	if g.Requirements, err = r.listRequirements(ctx, g.GameID); err != nil {
+		return err
+	}
+	g.Languages, err = r.listLanguages(ctx, g.GameID)
+	return err
+}
+
+func (r *GameRepository) listDescriptions(ctx context.Context, gameID int64) ([]model.GameDescription, error) {
+	rows, err := r.DB.Query(ctx, `SELECT locale, description FROM gamedescriptions WHERE gameid=$1 ORDER BY locale`, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.GameDescription{}
+	for rows.Next() {
+		var d model.GameDescription
+		if err := rows.Scan(&d.Locale, &d.Description); err != nil {
+			return nil, err
+		}
+		list = append(list, d)
+	}
+	return list, rows.Err()
+}
+
+func (r *GameRepository) listMedia(ctx context.Context, gameID int64) ([]model.GameMedia, error) {
+	query := `
+		SELECT m.mediaid, m.kind, m.url, m.position, a.thumbnailurl, a.width, a.height
+		FROM gamemedia m
+		LEFT JOIN mediaassets a ON a.url = m.url
+		WHERE m.gameid=$1
+		ORDER BY m.position, m.mediaid`
+	rows, err := r.DB.Query(ctx, query, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.GameMedia{}
+	for rows.Next() {
+		var m model.GameMedia
+		if err := rows.Scan(&m.MediaID, &m.Kind, &m.URL, &m.Position, &m.ThumbnailURL, &m.Width, &m.Height); err != nil {
+			return nil, err
+		}
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
+
+func (r *GameRepository) listRequirements(ctx context.Context, gameID int64) ([]model.SystemRequirement, error) {
+	query := `
+		SELECT platform, tier, os, processor, memory, graphics, storage, notes
+		FROM gamerequirements
+		WHERE gameid=$1
+		ORDER BY platform, tier`
+	rows, err := r.DB.Query(ctx, query, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.SystemRequirement{}
+	for rows.Next() {
+		var q model.SystemRequirement
+		if err := rows.Scan(&q.Platform, &q.Tier, &q.OS, &q.Processor, &q.Memory, &q.Graphics, &q.Storage, &q.Notes); err != nil {
+			return nil, err
+		}
+		list = append(list, q)
+	}
+	return list, rows.Err()
+}
+
+func (r *GameRepository) listLanguages(ctx context.Context, gameID int64) ([]model.GameLanguage, error) {
+	rows, err := r.DB.Query(ctx, `SELECT language, interface, audio, subtitles FROM gamelanguages WHERE gameid=$1 ORDER BY language`, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.GameLanguage{}
+	for rows.Next() {
+		var l model.GameLanguage
+		if err := rows.Scan(&l.Language, &l.Interface, &l.Audio, &l.Subtitles); err != nil {
+			return nil, err
+		}
+		list = append(list, l)
+	}
+	return list, rows.Err()
+}
+
+// ReplaceDescriptionsTx replaces the localized descriptions of a game
+func (r *GameRepository) ReplaceDescriptionsTx(ctx context.Context, tx pgx.Tx, gameID int64, list []model.GameDescription) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM gamedescriptions WHERE gameid=$1`, gameID); err != nil {
+		return err
+	}
+	for _, d := range list {
+		if _, err := tx.Exec(ctx, `INSERT INTO gamedescriptions (gameid, locale, description) VALUES ($1, $2, $3)`, gameID, d.Locale, d.Description); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplaceMediaTx replaces the media of a game; they keep the order of list
+func (r *GameRepository) ReplaceMediaTx(ctx context.Context, tx pgx.Tx, gameID int64, list []model.GameMedia) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM gamemedia WHERE gameid=$1`, gameID); err != nil {
+		return err
+	}
+	for _, m := range list {
+		query := `INSERT INTO gamemedia (gameid, kind, url, position) VALUES ($1, $2, $3, $4)`
+		if _, err := tx.Exec(ctx, query, gameID, m.Kind, m.URL, m.Position); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplaceRequirementsTx replaces the system requirements of a game
+func (r *GameRepository) ReplaceRequirementsTx(ctx context.Context, tx pgx.Tx, gameID int64, list []model.SystemRequirement) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM gamerequirements WHERE gameid=$1`, gameID); err != nil {
+		return err
+	}
+	for _, q := range list {
+		query := `
+			INSERT INTO gamerequirements (gameid, platform, tier, os, processor, memory, graphics, storage, notes)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+		if _, err := tx.Exec(ctx, query, gameID, q.Platform, q.Tier, q.OS, q.Processor, q.Memory, q.Graphics, q.Storage, q.Notes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReplaceLanguagesTx replaces the supported languages of a game
+func (r *GameRepository) ReplaceLanguagesTx(ctx context.Context, tx pgx.Tx, gameID int64, list []model.GameLanguage) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM gamelanguages WHERE gameid=$1`, gameID); err != nil {
+		return err
+	}
+	for _, l := range list {
+		query := `INSERT INTO gamelanguages (gameid, language, interface, audio, subtitles) VALUES ($1, $2, $3, $4, $5)`
+		if _, err := tx.Exec(ctx, query, gameID, l.Language, l.Interface, l.Audio, l.Subtitles); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CountMedia returns the number of media of a game
+func (r *GameRepository) CountMedia(ctx context.Context, gameID int64) (int64, error) {
+	return countRows(ctx, r.DB, `SELECT count(*) FROM gamemedia WHERE gameid=$1`, gameID)
+}
+
+// FindMedia returns the media of a game with the given kind and url, or nil
+func (r *GameRepository) FindMedia(ctx context.Context, gameID int64, kind, url string) (*model.GameMedia, error) {
+	var m model.GameMedia
+	query := `SELECT mediaid, kind, url, position FROM gamemedia WHERE gameid=$1 AND kind=$2 AND url=$3 ORDER BY mediaid LIMIT 1`
+	err := r.DB.QueryRow(ctx, query, gameID, kind, url).Scan(&m.MediaID, &m.Kind, &m.URL, &m.Position)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// AppendMediaTx adds a media after the existing ones of the game. A new cover replaces
+// the previous one.
+func (r *GameRepository) AppendMediaTx(ctx context.Context, tx pgx.Tx, gameID int64, m *model.GameMedia) error {
+	if m.Kind == model.MediaCover {
+		if _, err := tx.Exec(ctx, `DELETE FROM gamemedia WHERE gameid=$1 AND kind=$2`, gameID, model.MediaCover); err != nil {
+			return err
+		}
+	}
+	query := `
+		INSERT INTO gamemedia (gameid, kind, url, position, created_at)
+		VALUES ($1, $2, $3, (SELECT COALESCE(max(position) + 1, 0) FROM gamemedia WHERE gameid=$1), $4)
+		RETURNING mediaid, position`
+	return tx.QueryRow(ctx, query, gameID, m.Kind, m.URL, time.Now()).Scan(&m.MediaID, &m.Position)
+}
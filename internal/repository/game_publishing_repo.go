@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/pagination"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SetStatusTx moves a game from one publishing state to another. It fails when the game
+// is no longer in the from state, so concurrent transitions cannot both succeed.
+func (r *GameRepository) SetStatusTx(ctx context.Context, tx pgx.Tx, gameID int64, from, to string) error {
+	query := `UPDATE games SET status=$3 WHERE gameid=$1 AND status=$2 AND deleted_at IS NULL`
+	tag, err := tx.Exec(ctx, query, gameID, from, to)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("game not found or its status changed")
+	}
+	return nil
+}
+
+// AddTransitionTx records a change of the publishing state of a game
+func (r *GameRepository) AddTransitionTx(ctx context.Context, tx pgx.Tx, t *model.GameTransition) error {
+	query := `
+		INSERT INTO gametransitions (gameid, fromstatus, tostatus, actorid, comment, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING transitionid`
+	return tx.QueryRow(ctx, query, t.GameID, t.FromStatus, t.ToStatus, t.ActorID, t.Comment, t.CreatedAt).Scan(&t.TransitionID)
+}
+
+// ListTransitions returns the publishing history of a game, oldest first
+func (r *GameRepository) ListTransitions(ctx context.Context, gameID int64) ([]model.GameTransition, error) {
+	query := `
+		SELECT transitionid, gameid, fromstatus, tostatus, actorid, comment, created_at
+		FROM gametransitions
+		WHERE gameid=$1
+		ORDER BY transitionid`
+	rows, err := r.DB.Query(ctx, query, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.GameTransition{}
+	for rows.Next() {
+		var t model.GameTransition
+		if err := rows.Scan(&t.TransitionID, &t.GameID, &t.FromStatus, &t.ToStatus, &t.ActorID, &t.Comment, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+// ListByStatus returns a page of the games in one publishing state, oldest first (the
+// review queue for submitted games)
+func (r *GameRepository) ListByStatus(ctx context.Context, status string, p pagination.Params) ([]model.Game, error) {
+	query := `SELECT ` + gameColumns + gameFrom + `
+		WHERE g.deleted_at IS NULL AND g.status = $2 AND ($3::int IS NULL OR g.gameid > $3)
+		ORDER BY g.gameid LIMIT $4`
+	rows, err := r.DB.Query(ctx, query, time.Now(), status, p.After, p.Fetch())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.Game{}
+	for rows.Next() {
+		var g model.Game
+		if err := scanGame(rows, &g); err != nil {
+			return nil, err
+		}
+		list = append(list, g)
+	}
+	return list, rows.Err()
+}
+
+// CountByStatus is the total of ListByStatus
+func (r *GameRepository) CountByStatus(ctx context.Context, status string) (int64, error) {
+	return countRows(ctx, r.DB, `SELECT count(*) FROM games WHERE deleted_at IS NULL AND status = $1`, status)
+}
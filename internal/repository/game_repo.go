@@ -6,7 +6,9 @@ import (
 	"time"
 
 	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/pagination"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -18,10 +20,49 @@ func NewGameRepository(db *pgxpool.Pool) *GameRepository {
 	return &GameRepository{DB: db}
 }
 
-func (r *GameRepository) CreateGame(ctx context.Context, g *model.Game) (int64, error) {
+// gameColumns selects a game (aliased g) with its effective price, its base price and the
+// currently active sale, if any; it must be used together with gameFrom.
+const gameColumns = `g.gameid, g.developerid, g.title, g.blurb, g.description, COALESCE(s.saleprice, g.price), g.price, g.releasedate, g.pegirating, g.esrbrating, g.status, g.created_at, g.deleted_at,
+	s.saleid, s.discountpercent, s.rawsaleprice, s.starts_at, s.ends_at`
+
+// gameFrom joins the best sale running at $1 (the current time); percentage sales are
+// applied to the base price and a fixed sale price never exceeds it.
+const gameFrom = `
+	FROM games g
+	LEFT JOIN LATERAL (
+		SELECT gs.saleid, gs.discountpercent, gs.saleprice AS rawsaleprice, gs.starts_at, gs.ends_at,
+		       CASE
+		           WHEN gs.discountpercent IS NOT NULL THEN round(g.price * (100 - gs.discountpercent) / 100, 2)
+		           ELSE LEAST(gs.saleprice, g.price)
+		       END AS saleprice
+		FROM gamesales gs
+		WHERE gs.gameid = g.gameid AND gs.deleted_at IS NULL
+		  AND gs.starts_at <= $1 AND gs.ends_at > $1
+		ORDER BY saleprice, gs.saleid
+		LIMIT 1
+	) s ON true`
+
+func scanGame(row pgx.Row, g *model.Game) error {
+	var sale model.GameSale
+	var saleID *int64
+	var starts, ends *time.Time
+	if err := row.Scan(&g.GameID, &g.DeveloperID, &g.Title, &g.Blurb, &g.Description, &g.Price, &g.OriginalPrice, &g.ReleaseDate, &g.PEGIRating, &g.ESRBRating, &g.Status, &g.CreatedAt, &g.DeletedAt,
+		&saleID, &sale.DiscountPercent, &sale.SalePrice, &starts, &ends); err != nil {
+		return err
+	}
+	if saleID != nil {
+		sale.SaleID, sale.GameID = *saleID, g.GameID
+		sale.StartsAt, sale.EndsAt = *starts, *ends
+		g.Sale = &sale
+	}
+	return nil
+}
+
+func (r *GameRepository) CreateGameTx(ctx context.Context, tx pgx.Tx, g *model.Game) (int64, error) {
 	var id int64
-	query := `INSERT INTO games (developerid, title, price, releasedate, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING gameid`
-	if err := r.DB.QueryRow(ctx, query, g.DeveloperID, g.Title, g.Price, g.ReleaseDate, time.Now()).Scan(&id); err != nil {
+	query := `INSERT INTO games (developerid, title, blurb, description, price, releasedate, pegirating, esrbrating, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING gameid`
+	if err := tx.QueryRow(ctx, query, g.DeveloperID, g.Title, g.Blurb, g.Description, g.Price, g.ReleaseDate, g.PEGIRating, g.ESRBRating, time.Now()).Scan(&id); err != nil {
 		return 0, err
 	}
 	return id, nil
@@ -29,16 +70,19 @@ func (r *GameRepository) CreateGame(ctx context.Context, g *model.Game) (int64,
 
 func (r *GameRepository) GetByID(ctx context.Context, id int64) (*model.Game, error) {
 	var g model.Game
-	query := `SELECT gameid, developerid, title, price, releasedate, created_at, deleted_at FROM games WHERE gameid=$1`
-	if err := r.DB.QueryRow(ctx, query, id).Scan(&g.GameID, &g.DeveloperID, &g.Title, &g.Price, &g.ReleaseDate, &g.CreatedAt, &g.DeletedAt); err != nil {
+	query := `SELECT ` + gameColumns + gameFrom + ` WHERE g.gameid=$2`
+	if err := scanGame(r.DB.QueryRow(ctx, query, time.Now(), id), &g); err != nil {
 		return nil, errors.New("game not found")
 	}
 	return &g, nil
 }
 
-func (r *GameRepository) List(ctx context.Context, limit, offset int) ([]model.Game, error) {
-	query := `SELECT gameid, developerid, title, price, releasedate, created_at, deleted_at FROM games WHERE deleted_at IS NULL ORDER BY gameid LIMIT $1 OFFSET $2`
-	rows, err := r.DB.Query(ctx, query, limit, offset)
+// List returns a page of the published games
+func (r *GameRepository) List(ctx context.Context, p pagination.Params) ([]model.Game, error) {
+	query := `SELECT ` + gameColumns + gameFrom + `
+		WHERE g.deleted_at IS NULL AND g.status = 'published' AND ($2::int IS NULL OR g.gameid > $2)
+		ORDER BY g.gameid LIMIT $3`
+	rows, err := r.DB.Query(ctx, query, time.Now(), p.After, p.Fetch())
 	if err != nil {
 		return nil, err
 	}
@@ -47,7 +91,7 @@ func (r *GameRepository) List(ctx context.Context, limit, offset int) ([]model.G
 	var list []model.Game
 	for rows.Next() {
 		var g model.Game
-		if err := rows.Scan(&g.GameID, &g.DeveloperID, &g.Title, &g.Price, &g.ReleaseDate, &g.CreatedAt, &g.DeletedAt); err != nil {
+		if err := scanGame(rows, &g); err != nil {
 			return nil, err
 		}
 		list = append(list, g)
@@ -55,15 +99,20 @@ func (r *GameRepository) List(ctx context.Context, limit, offset int) ([]model.G
 	return list, nil
 }
 
-func (r *GameRepository) ListByDeveloper(ctx context.Context, developerID int64, limit, offset int) ([]model.Game, error) {
-	if limit <= 0 || limit > 200 {
-		limit = 50
-	}
-	if offset < 0 {
-		offset = 0
-	}
-	query := `SELECT gameid, developerid, title, price, releasedate, created_at, deleted_at FROM games WHERE developerid=$1 AND deleted_at IS NULL ORDER BY gameid LIMIT $2 OFFSET $3`
-	rows, err := r.DB.Query(ctx, query, developerID, limit, offset)
+// Count is the total of List
+func (r *GameRepository) Count(ctx context.Context) (int64, error) {
+	return countRows(ctx, r.DB, `SELECT count(*) FROM games WHERE deleted_at IS NULL AND status = 'published'`)
+}
+
+// ListByMember returns the games of every developer the account is a member of, or only
+// those of developerID when it is not 0
+func (r *GameRepository) ListByMember(ctx context.Context, authID, developerID int64, p pagination.Params) ([]model.Game, error) {
+	query := `SELECT ` + gameColumns + gameFrom + `
+		JOIN developermembers m ON m.developerid = g.developerid AND m.authid = $2
+		WHERE ($3 = 0 OR g.developerid = $3) AND g.deleted_at IS NULL
+		  AND ($4::int IS NULL OR g.gameid > $4)
+		ORDER BY g.gameid LIMIT $5`
+	rows, err := r.DB.Query(ctx, query, time.Now(), authID, developerID, p.After, p.Fetch())
 	if err != nil {
 		return nil, err
 	}
@@ -72,7 +121,7 @@ func (r *GameRepository) ListByDeveloper(ctx context.Context, developerID int64,
 	var list []model.Game
 	for rows.Next() {
 		var g model.Game
-		if err := rows.Scan(&g.GameID, &g.DeveloperID, &g.Title, &g.Price, &g.ReleaseDate, &g.CreatedAt, &g.DeletedAt); err != nil {
+		if err := scanGame(rows, &g); err != nil {
 			return nil, err
 		}
 		list = append(list, g)
@@ -80,9 +129,20 @@ func (r *GameRepository) ListByDeveloper(ctx context.Context, developerID int64,
 	return list, nil
 }
 
-func (r *GameRepository) UpdateGame(ctx context.Context, g *model.Game) error {
-	query := `UPDATE games SET developerid=$1, title=$2, price=$3, releasedate=$4 WHERE gameid=$5 AND deleted_at IS NULL`
-	tag, err := r.DB.Exec(ctx, query, g.DeveloperID, g.Title, g.Price, g.ReleaseDate, g.GameID)
+// CountByMember is the total of ListByMember
+func (r *GameRepository) CountByMember(ctx context.Context, authID, developerID int64) (int64, error) {
+	query := `
+		SELECT count(*)
+		FROM games g
+		JOIN developermembers m ON m.developerid = g.developerid AND m.authid = $1
+		WHERE ($2 = 0 OR g.developerid = $2) AND g.deleted_at IS NULL`
+	return countRows(ctx, r.DB, query, authID, developerID)
+}
+
+func (r *GameRepository) UpdateGameTx(ctx context.Context, tx pgx.Tx, g *model.Game) error {
+	query := `UPDATE games SET developerid=$1, title=$2, blurb=$3, description=$4, price=$5, releasedate=$6, pegirating=$7, esrbrating=$8
+		WHERE gameid=$9 AND deleted_at IS NULL`
+	tag, err := tx.Exec(ctx, query, g.DeveloperID, g.Title, g.Blurb, g.Description, g.Price, g.ReleaseDate, g.PEGIRating, g.ESRBRating, g.GameID)
 	if err != nil {
 		return err
 	}
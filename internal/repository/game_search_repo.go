@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/money"
+	"GameStoreAPI/internal/pagination"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// searchPriceFrom follows gameFrom and adds sp.price, the effective price of a game in the
+// currency $9 (NULL = base currency) rounded to its $10 decimals. It computes what
+// PricingService.ApplyCurrency shows: the regional price or the converted base price,
+// with the running sale applied to it.
+const searchPriceFrom = `
+	CROSS JOIN LATERAL (
+		SELECT CASE
+		           WHEN $9::text IS NULL THEN COALESCE(s.saleprice, g.price)
+		           WHEN s.discountpercent IS NOT NULL THEN round(round(c.original * (100 - s.discountpercent) / 100, 2), $10::int)
+		           WHEN s.saleid IS NOT NULL THEN LEAST(round(round(s.rawsaleprice * c.rate, 2), $10::int), c.original)
+		           ELSE c.original
+		       END AS price
+		FROM (
+			SELECT COALESCE(gp.amount, round(round(g.price * er.rate, 2), $10::int)) AS original, er.rate
+			FROM (VALUES (1)) v
+			LEFT JOIN exchangerates er ON er.currency = $9::text
+			LEFT JOIN gameprices gp ON gp.gameid = g.gameid AND gp.currency = $9::text
+		) c
+	) sp`
+
+// searchWhere filters published games (with gameFrom and searchPriceFrom) by the search
+// parameters: $2 query text (” = any), $3 genre ids, $4 developer ids, $5/$6 price range,
+// $7/$8 release date range; NULL parameters do not filter. Titles also match with
+// trigram word similarity so that misspelled queries still find them.
+const searchWhere = `
+	WHERE g.deleted_at IS NULL AND g.status = 'published'
+	  AND ($2 = '' OR g.searchvector @@ websearch_to_tsquery('english', $2) OR $2 <% g.title)
+	  AND ($3::int[] IS NULL OR EXISTS (
+	      SELECT 1 FROM gamegenres gg WHERE gg.gameid = g.gameid AND gg.deleted_at IS NULL AND gg.genreid = ANY($3)))
+	  AND ($4::int[] IS NULL OR g.developerid = ANY($4))
+	  AND ($5::numeric IS NULL OR sp.price >= $5)
+	  AND ($6::numeric IS NULL OR sp.price <= $6)
+	  AND ($7::date IS NULL OR g.releasedate >= $7)
+	  AND ($8::date IS NULL OR g.releasedate <= $8)`
+
+// searchRank is the relevance of a game for the query $2: full-text rank plus title similarity
+const searchRank = `CASE WHEN $2 = '' THEN 0 ELSE ts_rank(g.searchvector, websearch_to_tsquery('english', $2)) + word_similarity($2, g.title) END`
+
+// searchPopularity is the number of customers owning a game
+const searchPopularity = `(SELECT count(*) FROM customer_games cg WHERE cg.gameid = g.gameid)`
+
+// searchSort is the sort key expression of a sort (ascending, descending) and its type.
+// Games without release date sort last in both directions, so the expression never is
+// NULL and the key of the last game of a page can be compared with in the next one.
+type searchSort struct {
+	expr [2]string
+	typ  string
+}
+
+var searchSorts = map[string]searchSort{
+	model.SortRelevance:   {[2]string{searchRank, searchRank}, "real"},
+	model.SortPrice:       {[2]string{`sp.price`, `sp.price`}, "numeric"},
+	model.SortReleaseDate: {[2]string{`COALESCE(g.releasedate, 'infinity'::date)`, `COALESCE(g.releasedate, '-infinity'::date)`}, "date"},
+	model.SortPopularity:  {[2]string{searchPopularity, searchPopularity}, "bigint"},
+}
+
+func searchArgs(p *model.GameSearch, genres, developers []int64) []interface{} {
+	var currency *string
+	if p.Currency != "" {
+		currency = &p.Currency
+	}
+	return []interface{}{time.Now(), p.Query, genres, developers, p.MinPrice, p.MaxPrice, p.ReleasedFrom, p.ReleasedTo,
+		currency, money.Exponent(p.Currency)}
+}
+
+// SearchHit is a matching game with the text of its sort key, for the page cursor
+type SearchHit struct {
+	model.Game
+	SortKey string
+}
+
+// keyedRow reads the sort key following the game columns
+type keyedRow struct {
+	pgx.Row
+	key *string
+}
+
+func (r keyedRow) Scan(dest ...interface{}) error {
+	return r.Row.Scan(append(dest, r.key)...)
+}
+
+// Search returns a page of games matching p (Sort must be one of the model.Sort* keys),
+// ordered by the sort key and then by id in the same direction. pg.AfterKey is the sort key
+// of pg.After.
+func (r *GameRepository) Search(ctx context.Context, p *model.GameSearch, pg pagination.Params) ([]SearchHit, error) {
+	dir, op := ` ASC`, `>`
+	sort, ok := searchSorts[p.Sort]
+	if !ok {
+		sort = searchSorts[model.SortRelevance]
+	}
+	key := sort.expr[0]
+	if p.Order == "desc" {
+		dir, op, key = ` DESC`, `<`, sort.expr[1]
+	}
+	// NULL on the first page, an empty key would not cast
+	var afterKey *string
+	if pg.After != nil {
+		afterKey = &pg.AfterKey
+	}
+
+	query := `SELECT ` + gameColumns + `, (` + key + `)::text` + gameFrom + searchPriceFrom + searchWhere + `
+		  AND ($12::int IS NULL OR (` + key + `, g.gameid) ` + op + ` ($11::text::` + sort.typ + `, $12))
+		ORDER BY ` + key + dir + `, g.gameid` + dir + `
+		LIMIT $13`
+	rows, err := r.DB.Query(ctx, query, append(searchArgs(p, p.GenreIDs, p.DeveloperIDs), afterKey, pg.After, pg.Fetch())...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []SearchHit{}
+	for rows.Next() {
+		var h SearchHit
+		if err := scanGame(keyedRow{rows, &h.SortKey}, &h.Game); err != nil {
+			return nil, err
+		}
+		list = append(list, h)
+	}
+	return list, rows.Err()
+}
+
+// CountSearch returns the number of games matching p
+func (r *GameRepository) CountSearch(ctx context.Context, p *model.GameSearch) (int64, error) {
+	return countRows(ctx, r.DB, `SELECT count(*)`+gameFrom+searchPriceFrom+searchWhere, searchArgs(p, p.GenreIDs, p.DeveloperIDs)...)
+}
+
+// SearchFacets counts the games matching p per genre (ignoring the genre filter) and per
+// developer (ignoring the developer filter).
+func (r *GameRepository) SearchFacets(ctx context.Context, p *model.GameSearch) (*model.SearchFacets, error) {
+	genreQuery := `
+		SELECT ge.genreid, ge.genrename, count(*)
+		` + gameFrom + searchPriceFrom + `
+		JOIN gamegenres fg ON fg.gameid = g.gameid AND fg.deleted_at IS NULL
+		JOIN genres ge ON ge.genreid = fg.genreid AND ge.deleted_at IS NULL
+		` + searchWhere + `
+		GROUP BY ge.genreid, ge.genrename
+		ORDER BY count(*) DESC, ge.genrename`
+	genres, err := r.facetCounts(ctx, genreQuery, searchArgs(p, nil, p.DeveloperIDs))
+	if err != nil {
+		return nil, err
+	}
+	developerQuery := `
+		SELECT d.developerid, d.developername, count(*)
+		` + gameFrom + searchPriceFrom + `
+		JOIN developers d ON d.developerid = g.developerid
+		` + searchWhere + `
+		GROUP BY d.developerid, d.developername
+		ORDER BY count(*) DESC, d.developername`
+	developers, err := r.facetCounts(ctx, developerQuery, searchArgs(p, p.GenreIDs, nil))
+	if err != nil {
+		return nil, err
+	}
+	return &model.SearchFacets{Genres: genres, Developers: developers}, nil
+}
+
+func (r *GameRepository) facetCounts(ctx context.Context, query string, args []interface{}) ([]model.FacetCount, error) {
+	rows, err := r.DB.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.FacetCount{}
+	for rows.Next() {
+		var f model.FacetCount
+		if err := rows.Scan(&f.ID, &f.Name, &f.Count); err != nil {
+			return nil, err
+		}
+		list = append(list, f)
+	}
+	return list, rows.Err()
+}
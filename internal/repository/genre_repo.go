@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/pagination"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -36,9 +37,9 @@ func (r *GenreRepository) GetByID(ctx context.Context, id int64) (*model.Genre,
 	return &g, nil
 }
 
-func (r *GenreRepository) List(ctx context.Context) ([]model.Genre, error) {
-	query := `SELECT genreid, genrename, created_at, deleted_at FROM genres WHERE deleted_at IS NULL ORDER BY genreid`
-	rows, err := r.DB.Query(ctx, query)
+func (r *GenreRepository) List(ctx context.Context, p pagination.Params) ([]model.Genre, error) {
+	query := `SELECT genreid, genrename, created_at, deleted_at FROM genres WHERE deleted_at IS NULL AND ($1::int IS NULL OR genreid > $1) ORDER BY genreid LIMIT $2`
+	rows, err := r.DB.Query(ctx, query, p.After, p.Fetch())
 	if err != nil {
 		return nil, err
 	}
@@ -55,6 +56,10 @@ func (r *GenreRepository) List(ctx context.Context) ([]model.Genre, error) {
 	return out, nil
 }
 
+func (r *GenreRepository) Count(ctx context.Context) (int64, error) {
+	return countRows(ctx, r.DB, `SELECT count(*) FROM genres WHERE deleted_at IS NULL`)
+}
+
 func (r *GenreRepository) Update(ctx context.Context, id int64, name string) error {
 	query := `UPDATE genres SET genrename=$1 WHERE genreid=$2 AND deleted_at IS NULL`
 	tag, err := r.DB.Exec(ctx, query, name, id)
@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/pagination"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type GiftRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewGiftRepository(db *pgxpool.Pool) *GiftRepository {
+	return &GiftRepository{DB: db}
+}
+
+const giftColumns = `gf.giftid, gf.orderid, gf.orderitemid, gf.gameid, g.title, gf.senderid, COALESCE(c.username, c.fullname),
+	gf.recipientid, gf.message, gf.status, gf.created_at, gf.responded_at`
+
+const giftFrom = `
+	FROM gifts gf
+	JOIN games g ON g.gameid = gf.gameid
+	JOIN customers c ON c.customerid = gf.senderid`
+
+func scanGift(row pgx.Row, gf *model.Gift) error {
+	return row.Scan(&gf.GiftID, &gf.OrderID, &gf.OrderItemID, &gf.GameID, &gf.Title, &gf.SenderID, &gf.SenderName,
+		&gf.RecipientID, &gf.Message, &gf.Status, &gf.CreatedAt, &gf.RespondedAt)
+}
+
+func collectGifts(rows pgx.Rows) ([]model.Gift, error) {
+	defer rows.Close()
+	list := []model.Gift{}
+	for rows.Next() {
+		var gf model.Gift
+		if err := scanGift(rows, &gf); err != nil {
+			return nil, err
+		}
+		list = append(list, gf)
+	}
+	return list, rows.Err()
+}
+
+// CreateForOrderTx records a pending gift for every gift line of a paid order
+func (r *GiftRepository) CreateForOrderTx(ctx context.Context, tx pgx.Tx, orderID, senderID int64) error {
+	query := `
+		INSERT INTO gifts (orderid, orderitemid, gameid, senderid, recipientid, message, status, created_at)
+		SELECT orderid, orderitemid, gameid, $2, giftrecipientid, giftmessage, 'pending', $3
+		FROM orderitems
+		WHERE orderid=$1 AND giftrecipientid IS NOT NULL AND deleted_at IS NULL AND refunded_at IS NULL
+		ON CONFLICT (orderitemid) DO NOTHING
+	`
+	_, err := tx.Exec(ctx, query, orderID, senderID, time.Now())
+	return err
+}
+
+// GetByID returns one gift
+func (r *GiftRepository) GetByID(ctx context.Context, id int64) (*model.Gift, error) {
+	var gf model.Gift
+	query := `SELECT ` + giftColumns + giftFrom + ` WHERE gf.giftid=$1`
+	if err := scanGift(r.DB.QueryRow(ctx, query, id), &gf); err != nil {
+		return nil, errors.New("gift not found")
+	}
+	return &gf, nil
+}
+
+// ListReceived returns the gifts sent to a customer, optionally filtered by status
+func (r *GiftRepository) ListReceived(ctx context.Context, recipientID int64, status string, p pagination.Params) ([]model.Gift, error) {
+	query := `SELECT ` + giftColumns + giftFrom + ` WHERE gf.recipientid=$1 AND ($2 = '' OR gf.status = $2) AND ($3::int IS NULL OR gf.giftid < $3) ORDER BY gf.giftid DESC LIMIT $4`
+	rows, err := r.DB.Query(ctx, query, recipientID, status, p.After, p.Fetch())
+	if err != nil {
+		return nil, err
+	}
+	return collectGifts(rows)
+}
+
+// CountReceived is the total of ListReceived
+func (r *GiftRepository) CountReceived(ctx context.Context, recipientID int64, status string) (int64, error) {
+	return countRows(ctx, r.DB, `SELECT count(*) FROM gifts WHERE recipientid=$1 AND ($2 = '' OR status = $2)`, recipientID, status)
+}
+
+// ListSent returns the gifts a customer has bought for others
+func (r *GiftRepository) ListSent(ctx context.Context, senderID int64, p pagination.Params) ([]model.Gift, error) {
+	query := `SELECT ` + giftColumns + giftFrom + ` WHERE gf.senderid=$1 AND ($2::int IS NULL OR gf.giftid < $2) ORDER BY gf.giftid DESC LIMIT $3`
+	rows, err := r.DB.Query(ctx, query, senderID, p.After, p.Fetch())
+	if err != nil {
+		return nil, err
+	}
+	return collectGifts(rows)
+}
+
+// CountSent is the total of ListSent
+func (r *GiftRepository) CountSent(ctx context.Context, senderID int64) (int64, error) {
+	return countRows(ctx, r.DB, `SELECT count(*) FROM gifts WHERE senderid=$1`, senderID)
+}
+
+// HasPending returns the first of gameIDs with a pending gift to the customer, or 0
+func (r *GiftRepository) HasPending(ctx context.Context, recipientID int64, gameIDs []int64) (int64, error) {
+	var gid int64
+	query := `SELECT gameid FROM gifts WHERE recipientid=$1 AND gameid = ANY($2) AND status='pending' LIMIT 1`
+	err := r.DB.QueryRow(ctx, query, recipientID, gameIDs).Scan(&gid)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	return gid, err
+}
+
+// LockPendingTx loads a pending gift of the recipient and locks it until the tx ends
+func (r *GiftRepository) LockPendingTx(ctx context.Context, tx pgx.Tx, giftID, recipientID int64) (*model.Gift, error) {
+	var gf model.Gift
+	query := `SELECT ` + giftColumns + giftFrom + ` WHERE gf.giftid=$1 AND gf.recipientid=$2 AND gf.status='pending' FOR UPDATE OF gf`
+	if err := scanGift(tx.QueryRow(ctx, query, giftID, recipientID), &gf); err != nil {
+		return nil, errors.New("pending gift not found")
+	}
+	return &gf, nil
+}
+
+// SetStatusTx moves a gift to a new status and stamps the response time
+func (r *GiftRepository) SetStatusTx(ctx context.Context, tx pgx.Tx, giftID int64, status string) error {
+	_, err := tx.Exec(ctx, `UPDATE gifts SET status=$1, responded_at=$2 WHERE giftid=$3`, status, time.Now(), giftID)
+	return err
+}
+
+// MarkDeclined turns a gift the refund of a decline revoked into a declined one
+func (r *GiftRepository) MarkDeclined(ctx context.Context, giftID int64) error {
+	tag, err := r.DB.Exec(ctx, `UPDATE gifts SET status='declined' WHERE giftid=$1 AND status='revoked'`, giftID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("revoked gift not found")
+	}
+	return nil
+}
+
+// RevokeTx revokes the pending or accepted gifts of a refunded order item (all items when
+// orderItemID is nil) and returns the ones that were accepted, whose ownership must be revoked.
+func (r *GiftRepository) RevokeTx(ctx context.Context, tx pgx.Tx, orderID int64, orderItemID *int64) ([]model.Gift, error) {
+	query := `
+		WITH old AS (
+			SELECT giftid, status FROM gifts
+			WHERE orderid=$1 AND ($2::int IS NULL OR orderitemid = $2) AND status IN ('pending', 'accepted')
+			FOR UPDATE
+		)
+		UPDATE gifts gf SET status='revoked', responded_at=COALESCE(gf.responded_at, $3)
+		FROM old
+		WHERE gf.giftid = old.giftid
+		RETURNING gf.giftid, gf.gameid, gf.recipientid, old.status
+	`
+	rows, err := tx.Query(ctx, query, orderID, orderItemID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accepted []model.Gift
+	for rows.Next() {
+		var gf model.Gift
+		var was string
+		if err := rows.Scan(&gf.GiftID, &gf.GameID, &gf.RecipientID, &was); err != nil {
+			return nil, err
+		}
+		if was == model.GiftAccepted {
+			accepted = append(accepted, gf)
+		}
+	}
+	return accepted, rows.Err()
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"GameStoreAPI/internal/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// IdentityRepository stores the external identities linked to accounts and the pending
+// OpenID Connect authorization requests
+type IdentityRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewIdentityRepository(db *pgxpool.Pool) *IdentityRepository {
+	return &IdentityRepository{DB: db}
+}
+
+const identityColumns = `identityid, authid, provider, subject, email, created_at, last_login_at`
+
+func scanIdentity(row pgx.Row, id *model.Identity) error {
+	return row.Scan(&id.IdentityID, &id.AuthID, &id.Provider, &id.Subject, &id.Email, &id.CreatedAt, &id.LastLoginAt)
+}
+
+// Find returns the identity of a provider account
+func (r *IdentityRepository) Find(ctx context.Context, provider, subject string) (*model.Identity, error) {
+	var id model.Identity
+	query := `SELECT ` + identityColumns + ` FROM identities WHERE provider=$1 AND subject=$2`
+	if err := scanIdentity(r.DB.QueryRow(ctx, query, provider, subject), &id); err != nil {
+		return nil, errors.New("identity not found")
+	}
+	return &id, nil
+}
+
+// ListByAuthID returns the identities linked to an account
+func (r *IdentityRepository) ListByAuthID(ctx context.Context, authID int64) ([]model.Identity, error) {
+	query := `SELECT ` + identityColumns + ` FROM identities WHERE authid=$1 ORDER BY provider`
+	rows, err := r.DB.Query(ctx, query, authID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []model.Identity{}
+	for rows.Next() {
+		var id model.Identity
+		if err := scanIdentity(rows, &id); err != nil {
+			return nil, err
+		}
+		list = append(list, id)
+	}
+	return list, rows.Err()
+}
+
+// Create links a provider account to a user
+func (r *IdentityRepository) Create(ctx context.Context, authID int64, provider, subject string, email *string) error {
+	query := `INSERT INTO identities (authid, provider, subject, email, created_at, last_login_at) VALUES ($1, $2, $3, $4, $5, $5)`
+	_, err := r.DB.Exec(ctx, query, authID, provider, subject, email, time.Now())
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		if pgErr.ConstraintName == "identities_authid_provider_key" {
+			return errors.New("an account of this provider is already linked")
+		}
+		return errors.New("this provider account is already linked to another user")
+	}
+	return err
+}
+
+// TouchLogin records a sign-in through an identity and refreshes the email the provider reported
+func (r *IdentityRepository) TouchLogin(ctx context.Context, identityID int64, email *string) error {
+	query := `UPDATE identities SET last_login_at=$1, email=COALESCE($2, email) WHERE identityid=$3`
+	_, err := r.DB.Exec(ctx, query, time.Now(), email, identityID)
+	return err
+}
+
+// Delete unlinks the provider from an account
+func (r *IdentityRepository) Delete(ctx context.Context, authID int64, provider string) error {
+	tag, err := r.DB.Exec(ctx, `DELETE FROM identities WHERE authid=$1 AND provider=$2`, authID, provider)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("identity not found")
+	}
+	return nil
+}
+
+// DeleteAllTx unlinks every identity of an account
+func (r *IdentityRepository) DeleteAllTx(ctx context.Context, tx pgx.Tx, authID int64) error {
+	_, err := tx.Exec(ctx, `DELETE FROM identities WHERE authid=$1`, authID)
+	return err
+}
+
+// SaveFlow stores a pending authorization request under the hash of its state; expired
+// requests are cleaned up on the way
+func (r *IdentityRepository) SaveFlow(ctx context.Context, stateHash string, f *model.OIDCFlow) error {
+	now := time.Now()
+	if _, err := r.DB.Exec(ctx, `DELETE FROM oidcflows WHERE expires_at <= $1`, now); err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO oidcflows (statehash, provider, nonce, codeverifier, authid, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err := r.DB.Exec(ctx, query, stateHash, f.Provider, f.Nonce, f.CodeVerifier, f.AuthID, now, f.ExpiresAt)
+	return err
+}
+
+// ConsumeFlow removes and returns the unexpired authorization request of a state, so a
+// state can only be redeemed once
+func (r *IdentityRepository) ConsumeFlow(ctx context.Context, provider, stateHash string) (*model.OIDCFlow, error) {
+	var f model.OIDCFlow
+	query := `
+		DELETE FROM oidcflows WHERE statehash=$1 AND provider=$2
+		RETURNING provider, nonce, codeverifier, authid, expires_at
+	`
+	if err := r.DB.QueryRow(ctx, query, stateHash, provider).Scan(&f.Provider, &f.Nonce, &f.CodeVerifier, &f.AuthID, &f.ExpiresAt); err != nil {
+		return nil, errors.New("invalid or expired sign-in request")
+	}
+	if !f.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("invalid or expired sign-in request")
+	}
+	return &f, nil
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"GameStoreAPI/internal/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// MediaAssetRepository stores the uploaded images, one row per content hash
+type MediaAssetRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewMediaAssetRepository(db *pgxpool.Pool) *MediaAssetRepository {
+	return &MediaAssetRepository{DB: db}
+}
+
+// GetBySHA256 returns the asset with the given content hash, or nil when there is none
+func (r *MediaAssetRepository) GetBySHA256(ctx context.Context, sum string) (*model.MediaAsset, error) {
+	var a model.MediaAsset
+	query := `
+		SELECT sha256, contenttype, size, width, height, url, thumbnailurl, uploadedby, created_at
+		FROM mediaassets WHERE sha256=$1`
+	err := r.DB.QueryRow(ctx, query, sum).Scan(&a.SHA256, &a.ContentType, &a.Size, &a.Width, &a.Height, &a.URL, &a.ThumbnailURL, &a.UploadedBy, &a.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Create stores an asset; storing the same content again is a no-op
+func (r *MediaAssetRepository) Create(ctx context.Context, a *model.MediaAsset) error {
+	query := `
+		INSERT INTO mediaassets (sha256, contenttype, size, width, height, url, thumbnailurl, uploadedby, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (sha256) DO NOTHING`
+	_, err := r.DB.Exec(ctx, query, a.SHA256, a.ContentType, a.Size, a.Width, a.Height, a.URL, a.ThumbnailURL, a.UploadedBy, time.Now())
+	return err
+}
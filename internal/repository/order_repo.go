@@ -2,10 +2,13 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/money"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -13,13 +16,29 @@ type OrderRepository struct {
 	DB *pgxpool.Pool
 }
 
+// orderColumns selects an order row (aliased o) including its derived status
+const orderColumns = `o.orderid, o.customerid, o.orderdate, o.totalprice, o.refundedtotal, o.currency, o.promoid, o.subtotal, o.discount, o.taxamount, o.taxname, o.taxrate::text, o.taxinclusive,
+	o.billing_country, o.billing_region, o.billing_postalcode, o.created_at, o.deleted_at, o.cancelled_at,
+	CASE
+		WHEN o.cancelled_at IS NOT NULL THEN 'cancelled'
+		WHEN o.refundedtotal > 0 AND o.refundedtotal >= o.totalprice THEN 'refunded'
+		WHEN o.refundedtotal > 0 THEN 'partially_refunded'
+		WHEN EXISTS (SELECT 1 FROM payments p WHERE p.orderid = o.orderid AND p.paymentstatus = 'Paid') THEN 'paid'
+		ELSE 'awaiting_payment'
+	END`
+
+func scanOrder(row pgx.Row, o *model.Order) error {
+	return row.Scan(&o.OrderID, &o.CustomerID, &o.OrderDate, &o.TotalPrice, &o.RefundedTotal, &o.Currency, &o.PromoID, &o.Subtotal, &o.Discount, &o.TaxAmount, &o.TaxName, &o.TaxRate, &o.TaxInclusive,
+		&o.BillingCountry, &o.BillingRegion, &o.BillingPostalCode, &o.CreatedAt, &o.DeletedAt, &o.CancelledAt, &o.Status)
+}
+
 func NewOrderRepository(db *pgxpool.Pool) *OrderRepository {
 	return &OrderRepository{DB: db}
 }
 
 // GetOrdersByCustomer returns orders for a given customerid (completed orders where totalprice IS NOT NULL).
 func (r *OrderRepository) GetOrdersByCustomer(ctx context.Context, customerID int64) ([]model.Order, error) {
-	query := `SELECT orderid, customerid, totalprice, orderdate, created_at, deleted_at FROM orders WHERE customerid=$1 AND totalprice IS NOT NULL ORDER BY orderid DESC`
+	query := `SELECT ` + orderColumns + ` FROM orders o WHERE o.customerid=$1 AND o.totalprice IS NOT NULL ORDER BY o.orderid DESC`
 	rows, err := r.DB.Query(ctx, query, customerID)
 	if err != nil {
 		return nil, err
@@ -29,13 +48,9 @@ func (r *OrderRepository) GetOrdersByCustomer(ctx context.Context, customerID in
 	var out []model.Order
 	for rows.Next() {
 		var o model.Order
-		var tp *float64
-		var od *time.Time
-		if err := rows.Scan(&o.OrderID, &o.CustomerID, &tp, &od, &o.CreatedAt, &o.DeletedAt); err != nil {
+		if err := scanOrder(rows, &o); err != nil {
 			return nil, err
 		}
-		o.TotalPrice = tp
-		o.OrderDate = od
 		out = append(out, o)
 	}
 	return out, nil
@@ -43,14 +58,64 @@ func (r *OrderRepository) GetOrdersByCustomer(ctx context.Context, customerID in
 
 // GetOrderByID returns the order row for the given orderid
 func (r *OrderRepository) GetOrderByID(ctx context.Context, orderID int64) (*model.Order, error) {
-	query := `SELECT orderid, customerid, totalprice, orderdate, created_at, deleted_at FROM orders WHERE orderid=$1`
+	query := `SELECT ` + orderColumns + ` FROM orders o WHERE o.orderid=$1`
 	var o model.Order
-	var tp *float64
-	var od *time.Time
-	if err := r.DB.QueryRow(ctx, query, orderID).Scan(&o.OrderID, &o.CustomerID, &tp, &od, &o.CreatedAt, &o.DeletedAt); err != nil {
+	if err := scanOrder(r.DB.QueryRow(ctx, query, orderID), &o); err != nil {
 		return nil, err
 	}
-	o.TotalPrice = tp
-	o.OrderDate = od
 	return &o, nil
 }
+
+// LockOrderTx loads an order and locks its row until the tx ends, serializing refunds
+// and cancellations of the same order.
+func (r *OrderRepository) LockOrderTx(ctx context.Context, tx pgx.Tx, orderID int64) (*model.Order, error) {
+	query := `SELECT ` + orderColumns + ` FROM orders o WHERE o.orderid=$1 FOR UPDATE OF o`
+	var o model.Order
+	if err := scanOrder(tx.QueryRow(ctx, query, orderID), &o); err != nil {
+		return nil, errors.New("order not found")
+	}
+	return &o, nil
+}
+
+// AddRefundedTx increases the refunded total of an order and returns the new value
+func (r *OrderRepository) AddRefundedTx(ctx context.Context, tx pgx.Tx, orderID int64, amount money.Amount) (money.Amount, error) {
+	var refunded money.Amount
+	query := `UPDATE orders SET refundedtotal = refundedtotal + $1 WHERE orderid=$2 RETURNING refundedtotal`
+	if err := tx.QueryRow(ctx, query, amount, orderID).Scan(&refunded); err != nil {
+		return 0, err
+	}
+	return refunded, nil
+}
+
+// CancelOrderTx marks a checked-out order as cancelled
+func (r *OrderRepository) CancelOrderTx(ctx context.Context, tx pgx.Tx, orderID int64) error {
+	query := `UPDATE orders SET cancelled_at=$1 WHERE orderid=$2 AND cancelled_at IS NULL`
+	tag, err := tx.Exec(ctx, query, time.Now(), orderID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("order not found or already cancelled")
+	}
+	return nil
+}
+
+// GetOrderGameIDs returns the gameids of the active items the buyer keeps (gift lines excluded)
+func (r *OrderRepository) GetOrderGameIDs(ctx context.Context, orderID int64) ([]int64, error) {
+	query := `SELECT gameid FROM orderitems WHERE orderid=$1 AND deleted_at IS NULL AND giftrecipientid IS NULL ORDER BY orderitemid`
+	rows, err := r.DB.Query(ctx, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// List queries take a pagination.Params as two parameters: the id to continue after
+// (NULL for the first page) and the number of rows to fetch (Params.Fetch), e.g.
+//
+//	WHERE ... AND ($2::int IS NULL OR x.id > $2) ORDER BY x.id LIMIT $3
+//
+// Lists shown newest first compare with < and order descending.
+
+// countRows runs a SELECT count(*) query, for the totals of paginated lists
+func countRows(ctx context.Context, db *pgxpool.Pool, query string, args ...interface{}) (int64, error) {
+	var n int64
+	if err := db.QueryRow(ctx, query, args...).Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
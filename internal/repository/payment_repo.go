@@ -0,0 +1,273 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/money"
+	"GameStoreAPI/internal/pagination"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PaymentRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewPaymentRepository(db *pgxpool.Pool) *PaymentRepository {
+	return &PaymentRepository{DB: db}
+}
+
+const paymentColumns = `p.paymentid, p.orderid, p.paymentmethodid, pm.name, p.amountpaid, p.currency, p.paymentstatus, p.providerref, p.createdat, p.paidat`
+
+func scanPayment(row pgx.Row, p *model.Payment) error {
+	return row.Scan(&p.PaymentID, &p.OrderID, &p.PaymentMethodID, &p.MethodName, &p.AmountPaid, &p.Currency, &p.PaymentStatus, &p.ProviderRef, &p.CreatedAt, &p.PaidAt)
+}
+
+func collectPayments(rows pgx.Rows) ([]model.Payment, error) {
+	defer rows.Close()
+	list := []model.Payment{}
+	for rows.Next() {
+		var p model.Payment
+		if err := scanPayment(rows, &p); err != nil {
+			return nil, err
+		}
+		list = append(list, p)
+	}
+	return list, rows.Err()
+}
+
+// ListMethods returns all payment methods
+func (r *PaymentRepository) ListMethods(ctx context.Context) ([]model.PaymentMethod, error) {
+	query := `SELECT paymentmethodid, name FROM paymentmethods ORDER BY paymentmethodid`
+	rows, err := r.DB.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.PaymentMethod{}
+	for rows.Next() {
+		var m model.PaymentMethod
+		if err := rows.Scan(&m.PaymentMethodID, &m.Name); err != nil {
+			return nil, err
+		}
+		list = append(list, m)
+	}
+	return list, nil
+}
+
+// CreateMethod inserts a new payment method and returns its id
+func (r *PaymentRepository) CreateMethod(ctx context.Context, name string) (int64, error) {
+	var id int64
+	query := `INSERT INTO paymentmethods (name) VALUES ($1) RETURNING paymentmethodid`
+	if err := r.DB.QueryRow(ctx, query, name).Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *PaymentRepository) MethodExists(ctx context.Context, id int64) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM paymentmethods WHERE paymentmethodid=$1)`
+	if err := r.DB.QueryRow(ctx, query, id).Scan(&exists); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// CreatePaymentTx inserts a Pending payment for an order, in the order's currency, and logs the initial status.
+func (r *PaymentRepository) CreatePaymentTx(ctx context.Context, tx pgx.Tx, orderID, methodID int64, amount money.Amount) (int64, error) {
+	var id int64
+	query := `
+		INSERT INTO payments (orderid, paymentmethodid, amountpaid, currency, paymentstatus, createdat)
+		VALUES ($1, $2, $3, (SELECT currency FROM orders WHERE orderid=$1), $4, $5)
+		RETURNING paymentid
+	`
+	if err := tx.QueryRow(ctx, query, orderID, methodID, amount, model.PaymentPending, time.Now()).Scan(&id); err != nil {
+		return 0, err
+	}
+	if err := r.insertLogTx(ctx, tx, id, nil, model.PaymentPending); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// UpdateStatusTx moves a payment from one status to another and records the transition.
+// The update only applies if the payment is still in the expected status, so concurrent
+// transitions cannot both succeed.
+func (r *PaymentRepository) UpdateStatusTx(ctx context.Context, tx pgx.Tx, paymentID int64, from, to string) error {
+	query := `
+		UPDATE payments
+		SET paymentstatus=$1,
+		    paidat = CASE WHEN $1 = 'Paid' THEN $2 ELSE paidat END
+		WHERE paymentid=$3 AND paymentstatus=$4
+	`
+	tag, err := tx.Exec(ctx, query, to, time.Now(), paymentID, from)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("payment not found or status changed")
+	}
+	return r.insertLogTx(ctx, tx, paymentID, &from, to)
+}
+
+// ClaimCharge marks a Pending payment the gateway has not seen yet as being charged. Of
+// concurrent callers only one gets the claim (true); a payment is claimed once.
+func (r *PaymentRepository) ClaimCharge(ctx context.Context, paymentID int64) (bool, error) {
+	query := `
+		UPDATE payments SET chargestartedat=$1
+		WHERE paymentid=$2 AND paymentstatus='Pending' AND providerref IS NULL AND chargestartedat IS NULL
+	`
+	tag, err := r.DB.Exec(ctx, query, time.Now(), paymentID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ReleaseCharge gives a claim back when the gateway refused the request before
+// authorizing anything, so the payment can be charged again
+func (r *PaymentRepository) ReleaseCharge(ctx context.Context, paymentID int64) error {
+	query := `UPDATE payments SET chargestartedat=NULL WHERE paymentid=$1 AND providerref IS NULL AND paymentstatus='Pending'`
+	_, err := r.DB.Exec(ctx, query, paymentID)
+	return err
+}
+
+// SetProviderRef stores the gateway's reference for a payment. A payment keeps its first
+// reference: storing another one fails.
+func (r *PaymentRepository) SetProviderRef(ctx context.Context, paymentID int64, ref string) error {
+	query := `UPDATE payments SET providerref=$1 WHERE paymentid=$2 AND (providerref IS NULL OR providerref=$1)`
+	tag, err := r.DB.Exec(ctx, query, ref, paymentID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("payment not found or has another provider reference")
+	}
+	return nil
+}
+
+func (r *PaymentRepository) insertLogTx(ctx context.Context, tx pgx.Tx, paymentID int64, oldStatus *string, newStatus string) error {
+	query := `INSERT INTO paymentlogs (paymentid, oldstatus, newstatus, changedat) VALUES ($1, $2, $3, $4)`
+	_, err := tx.Exec(ctx, query, paymentID, oldStatus, newStatus, time.Now())
+	return err
+}
+
+func (r *PaymentRepository) GetByID(ctx context.Context, id int64) (*model.Payment, error) {
+	var p model.Payment
+	query := `SELECT ` + paymentColumns + ` FROM payments p JOIN paymentmethods pm ON pm.paymentmethodid = p.paymentmethodid WHERE p.paymentid=$1`
+	if err := scanPayment(r.DB.QueryRow(ctx, query, id), &p); err != nil {
+		return nil, errors.New("payment not found")
+	}
+	return &p, nil
+}
+
+// GetByIDTx is GetByID inside a transaction
+func (r *PaymentRepository) GetByIDTx(ctx context.Context, tx pgx.Tx, id int64) (*model.Payment, error) {
+	var p model.Payment
+	query := `SELECT ` + paymentColumns + ` FROM payments p JOIN paymentmethods pm ON pm.paymentmethodid = p.paymentmethodid WHERE p.paymentid=$1`
+	if err := scanPayment(tx.QueryRow(ctx, query, id), &p); err != nil {
+		return nil, errors.New("payment not found")
+	}
+	return &p, nil
+}
+
+// GetLatestByOrderTx is GetLatestByOrder inside a transaction
+func (r *PaymentRepository) GetLatestByOrderTx(ctx context.Context, tx pgx.Tx, orderID int64) (*model.Payment, error) {
+	var p model.Payment
+	query := `SELECT ` + paymentColumns + ` FROM payments p JOIN paymentmethods pm ON pm.paymentmethodid = p.paymentmethodid WHERE p.orderid=$1 ORDER BY p.paymentid DESC LIMIT 1`
+	if err := scanPayment(tx.QueryRow(ctx, query, orderID), &p); err != nil {
+		return nil, errors.New("payment not found")
+	}
+	return &p, nil
+}
+
+// GetLatestByOrder returns the most recent payment attempt for an order
+func (r *PaymentRepository) GetLatestByOrder(ctx context.Context, orderID int64) (*model.Payment, error) {
+	var p model.Payment
+	query := `SELECT ` + paymentColumns + ` FROM payments p JOIN paymentmethods pm ON pm.paymentmethodid = p.paymentmethodid WHERE p.orderid=$1 ORDER BY p.paymentid DESC LIMIT 1`
+	if err := scanPayment(r.DB.QueryRow(ctx, query, orderID), &p); err != nil {
+		return nil, errors.New("payment not found")
+	}
+	return &p, nil
+}
+
+func (r *PaymentRepository) ListByOrder(ctx context.Context, orderID int64) ([]model.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments p JOIN paymentmethods pm ON pm.paymentmethodid = p.paymentmethodid WHERE p.orderid=$1 ORDER BY p.paymentid DESC`
+	rows, err := r.DB.Query(ctx, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	return collectPayments(rows)
+}
+
+// ListByCustomer returns the payments made against the customer's orders, newest first
+func (r *PaymentRepository) ListByCustomer(ctx context.Context, customerID int64, pg pagination.Params) ([]model.Payment, error) {
+	query := `
+		SELECT ` + paymentColumns + `
+		FROM payments p
+		JOIN paymentmethods pm ON pm.paymentmethodid = p.paymentmethodid
+		JOIN orders o ON o.orderid = p.orderid
+		WHERE o.customerid=$1 AND ($2::int IS NULL OR p.paymentid < $2)
+		ORDER BY p.paymentid DESC
+		LIMIT $3
+	`
+	rows, err := r.DB.Query(ctx, query, customerID, pg.After, pg.Fetch())
+	if err != nil {
+		return nil, err
+	}
+	return collectPayments(rows)
+}
+
+// CountByCustomer is the total of ListByCustomer
+func (r *PaymentRepository) CountByCustomer(ctx context.Context, customerID int64) (int64, error) {
+	query := `SELECT count(*) FROM payments p JOIN orders o ON o.orderid = p.orderid WHERE o.customerid=$1`
+	return countRows(ctx, r.DB, query, customerID)
+}
+
+// ListAll returns payments, optionally filtered by status, newest first (admin use)
+func (r *PaymentRepository) ListAll(ctx context.Context, status string, pg pagination.Params) ([]model.Payment, error) {
+	query := `
+		SELECT ` + paymentColumns + `
+		FROM payments p
+		JOIN paymentmethods pm ON pm.paymentmethodid = p.paymentmethodid
+		WHERE ($1 = '' OR p.paymentstatus = $1) AND ($2::int IS NULL OR p.paymentid < $2)
+		ORDER BY p.paymentid DESC
+		LIMIT $3
+	`
+	rows, err := r.DB.Query(ctx, query, status, pg.After, pg.Fetch())
+	if err != nil {
+		return nil, err
+	}
+	return collectPayments(rows)
+}
+
+// CountAll is the total of ListAll
+func (r *PaymentRepository) CountAll(ctx context.Context, status string) (int64, error) {
+	return countRows(ctx, r.DB, `SELECT count(*) FROM payments WHERE ($1 = '' OR paymentstatus = $1)`, status)
+}
+
+// ListLogs returns the status history of a payment, oldest first
+func (r *PaymentRepository) ListLogs(ctx context.Context, paymentID int64) ([]model.PaymentLog, error) {
+	query := `SELECT logid, paymentid, oldstatus, newstatus, changedat FROM paymentlogs WHERE paymentid=$1 ORDER BY logid`
+	rows, err := r.DB.Query(ctx, query, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.PaymentLog{}
+	for rows.Next() {
+		var l model.PaymentLog
+		if err := rows.Scan(&l.LogID, &l.PaymentID, &l.OldStatus, &l.NewStatus, &l.ChangedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, l)
+	}
+	return list, nil
+}
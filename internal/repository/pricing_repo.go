@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/money"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PricingRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewPricingRepository(db *pgxpool.Pool) *PricingRepository {
+	return &PricingRepository{DB: db}
+}
+
+// GetRegionalPrices returns explicit prices in the currency for the given games, keyed by gameid
+func (r *PricingRepository) GetRegionalPrices(ctx context.Context, gameIDs []int64, currency string) (map[int64]money.Amount, error) {
+	out := map[int64]money.Amount{}
+	if len(gameIDs) == 0 {
+		return out, nil
+	}
+	query := `SELECT gameid, amount FROM gameprices WHERE gameid = ANY($1) AND currency = $2`
+	rows, err := r.DB.Query(ctx, query, gameIDs, currency)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var amount money.Amount
+		if err := rows.Scan(&id, &amount); err != nil {
+			return nil, err
+		}
+		out[id] = amount
+	}
+	return out, rows.Err()
+}
+
+// GetRate returns the exchange rate from the base currency; ok is false when none is configured
+func (r *PricingRepository) GetRate(ctx context.Context, currency string) (rate *big.Rat, ok bool, err error) {
+	var s string
+	query := `SELECT rate::text FROM exchangerates WHERE currency=$1`
+	if err := r.DB.QueryRow(ctx, query, currency).Scan(&s); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	rate, ok = new(big.Rat).SetString(s)
+	if !ok {
+		return nil, false, errors.New("invalid exchange rate stored for " + currency)
+	}
+	return rate, true, nil
+}
+
+func (r *PricingRepository) ListRates(ctx context.Context) ([]model.ExchangeRate, error) {
+	query := `SELECT currency, rate::text, updated_at FROM exchangerates ORDER BY currency`
+	rows, err := r.DB.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.ExchangeRate{}
+	for rows.Next() {
+		var er model.ExchangeRate
+		if err := rows.Scan(&er.Currency, &er.Rate, &er.UpdatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, er)
+	}
+	return list, nil
+}
+
+// UpsertRate creates or replaces the exchange rate of a currency
+func (r *PricingRepository) UpsertRate(ctx context.Context, currency, rate string) error {
+	query := `
+		INSERT INTO exchangerates (currency, rate, updated_at) VALUES ($1, $2::numeric, $3)
+		ON CONFLICT (currency) DO UPDATE SET rate = EXCLUDED.rate, updated_at = EXCLUDED.updated_at
+	`
+	_, err := r.DB.Exec(ctx, query, currency, rate, time.Now())
+	return err
+}
+
+func (r *PricingRepository) DeleteRate(ctx context.Context, currency string) error {
+	tag, err := r.DB.Exec(ctx, `DELETE FROM exchangerates WHERE currency=$1`, currency)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("exchange rate not found")
+	}
+	return nil
+}
+
+// ListGamePrices returns the explicit regional prices of a game
+func (r *PricingRepository) ListGamePrices(ctx context.Context, gameID int64) ([]model.GamePrice, error) {
+	query := `SELECT gameid, currency, amount, updated_at FROM gameprices WHERE gameid=$1 ORDER BY currency`
+	rows, err := r.DB.Query(ctx, query, gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.GamePrice{}
+	for rows.Next() {
+		var gp model.GamePrice
+		if err := rows.Scan(&gp.GameID, &gp.Currency, &gp.Amount, &gp.UpdatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, gp)
+	}
+	return list, nil
+}
+
+// UpsertGamePrice creates or replaces a regional price
+func (r *PricingRepository) UpsertGamePrice(ctx context.Context, gameID int64, currency string, amount money.Amount) error {
+	query := `
+		INSERT INTO gameprices (gameid, currency, amount, updated_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (gameid, currency) DO UPDATE SET amount = EXCLUDED.amount, updated_at = EXCLUDED.updated_at
+	`
+	_, err := r.DB.Exec(ctx, query, gameID, currency, amount, time.Now())
+	return err
+}
+
+func (r *PricingRepository) DeleteGamePrice(ctx context.Context, gameID int64, currency string) error {
+	tag, err := r.DB.Exec(ctx, `DELETE FROM gameprices WHERE gameid=$1 AND currency=$2`, gameID, currency)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("regional price not found")
+	}
+	return nil
+}
@@ -0,0 +1,310 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/money"
+	"GameStoreAPI/internal/pagination"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PromoRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewPromoRepository(db *pgxpool.Pool) *PromoRepository {
+	return &PromoRepository{DB: db}
+}
+
+const promoColumns = `promoid, code, discounttype, value, minimumtotal, maxredemptions, maxpercustomer, redemptioncount, starts_at, expires_at, created_at, deleted_at`
+
+func scanPromo(row pgx.Row, p *model.PromoCode) error {
+	return row.Scan(&p.PromoID, &p.Code, &p.DiscountType, &p.Value, &p.MinimumTotal, &p.MaxRedemptions, &p.MaxPerCustomer,
+		&p.RedemptionCount, &p.StartsAt, &p.ExpiresAt, &p.CreatedAt, &p.DeletedAt)
+}
+
+// Create inserts a promo code with its targets and returns its id
+func (r *PromoRepository) Create(ctx context.Context, p *model.PromoCode) (int64, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var id int64
+	query := `
+		INSERT INTO promocodes (code, discounttype, value, minimumtotal, maxredemptions, maxpercustomer, starts_at, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING promoid
+	`
+	if err := tx.QueryRow(ctx, query, p.Code, p.DiscountType, p.Value, p.MinimumTotal, p.MaxRedemptions, p.MaxPerCustomer,
+		p.StartsAt, p.ExpiresAt, time.Now()).Scan(&id); err != nil {
+		return 0, err
+	}
+	if err := r.replaceTargetsTx(ctx, tx, id, p.Targets); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// Update replaces the editable fields and targets of a promo code
+func (r *PromoRepository) Update(ctx context.Context, p *model.PromoCode) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE promocodes
+		SET code=$1, discounttype=$2, value=$3, minimumtotal=$4, maxredemptions=$5, maxpercustomer=$6, starts_at=$7, expires_at=$8
+		WHERE promoid=$9 AND deleted_at IS NULL
+	`
+	tag, err := tx.Exec(ctx, query, p.Code, p.DiscountType, p.Value, p.MinimumTotal, p.MaxRedemptions, p.MaxPerCustomer,
+		p.StartsAt, p.ExpiresAt, p.PromoID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("promo code not found")
+	}
+	if err := r.replaceTargetsTx(ctx, tx, p.PromoID, p.Targets); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *PromoRepository) replaceTargetsTx(ctx context.Context, tx pgx.Tx, promoID int64, targets []model.PromoTarget) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM promocode_targets WHERE promoid=$1`, promoID); err != nil {
+		return err
+	}
+	for _, t := range targets {
+		query := `INSERT INTO promocode_targets (promoid, targettype, targetid) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`
+		if _, err := tx.Exec(ctx, query, promoID, t.TargetType, t.TargetID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete soft-deletes a promo code; already redeemed orders keep their discount
+func (r *PromoRepository) Delete(ctx context.Context, id int64) error {
+	query := `UPDATE promocodes SET deleted_at=$1 WHERE promoid=$2 AND deleted_at IS NULL`
+	tag, err := r.DB.Exec(ctx, query, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("promo code not found")
+	}
+	return nil
+}
+
+func (r *PromoRepository) GetByID(ctx context.Context, id int64) (*model.PromoCode, error) {
+	var p model.PromoCode
+	query := `SELECT ` + promoColumns + ` FROM promocodes WHERE promoid=$1`
+	if err := scanPromo(r.DB.QueryRow(ctx, query, id), &p); err != nil {
+		return nil, errors.New("promo code not found")
+	}
+	targets, err := r.ListTargets(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	p.Targets = targets
+	return &p, nil
+}
+
+// GetByCode looks up an active (not deleted) promo code, case-insensitively
+func (r *PromoRepository) GetByCode(ctx context.Context, code string) (*model.PromoCode, error) {
+	var p model.PromoCode
+	query := `SELECT ` + promoColumns + ` FROM promocodes WHERE upper(code)=upper($1) AND deleted_at IS NULL`
+	if err := scanPromo(r.DB.QueryRow(ctx, query, code), &p); err != nil {
+		return nil, errors.New("promo code not found")
+	}
+	targets, err := r.ListTargets(ctx, p.PromoID)
+	if err != nil {
+		return nil, err
+	}
+	p.Targets = targets
+	return &p, nil
+}
+
+// List returns promo codes that are not deleted, newest first (admin use)
+func (r *PromoRepository) List(ctx context.Context, pg pagination.Params) ([]model.PromoCode, error) {
+	query := `SELECT ` + promoColumns + ` FROM promocodes WHERE deleted_at IS NULL AND ($1::int IS NULL OR promoid < $1) ORDER BY promoid DESC LIMIT $2`
+	rows, err := r.DB.Query(ctx, query, pg.After, pg.Fetch())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.PromoCode{}
+	for rows.Next() {
+		var p model.PromoCode
+		if err := scanPromo(rows, &p); err != nil {
+			return nil, err
+		}
+		list = append(list, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range list {
+		targets, err := r.ListTargets(ctx, list[i].PromoID)
+		if err != nil {
+			return nil, err
+		}
+		list[i].Targets = targets
+	}
+	return list, nil
+}
+
+// Count is the total of List
+func (r *PromoRepository) Count(ctx context.Context) (int64, error) {
+	return countRows(ctx, r.DB, `SELECT count(*) FROM promocodes WHERE deleted_at IS NULL`)
+}
+
+func (r *PromoRepository) ListTargets(ctx context.Context, promoID int64) ([]model.PromoTarget, error) {
+	query := `SELECT targettype, targetid FROM promocode_targets WHERE promoid=$1 ORDER BY targettype, targetid`
+	rows, err := r.DB.Query(ctx, query, promoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.PromoTarget{}
+	for rows.Next() {
+		var t model.PromoTarget
+		if err := rows.Scan(&t.TargetType, &t.TargetID); err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, nil
+}
+
+// EligibleGameIDs returns which of gameIDs a promo applies to: the game itself, its
+// developer or one of its genres must be targeted (or the promo has no targets at all).
+func (r *PromoRepository) EligibleGameIDs(ctx context.Context, promoID int64, gameIDs []int64) (map[int64]bool, error) {
+	query := `
+		SELECT g.gameid
+		FROM games g
+		WHERE g.gameid = ANY($2)
+		  AND (
+		    NOT EXISTS (SELECT 1 FROM promocode_targets t WHERE t.promoid = $1)
+		    OR EXISTS (
+		      SELECT 1 FROM promocode_targets t
+		      WHERE t.promoid = $1
+		        AND (
+		          (t.targettype = 'game' AND t.targetid = g.gameid)
+		          OR (t.targettype = 'developer' AND t.targetid = g.developerid)
+		          OR (t.targettype = 'genre' AND EXISTS (
+		            SELECT 1 FROM gamegenres gg WHERE gg.gameid = g.gameid AND gg.genreid = t.targetid))
+		        )
+		    )
+		  )
+	`
+	rows, err := r.DB.Query(ctx, query, promoID, gameIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out[id] = true
+	}
+	return out, rows.Err()
+}
+
+// CountCustomerRedemptions returns how many times a customer has redeemed a promo
+func (r *PromoRepository) CountCustomerRedemptions(ctx context.Context, promoID, customerID int64) (int, error) {
+	var n int
+	query := `SELECT COUNT(*) FROM promoredemptions WHERE promoid=$1 AND customerid=$2`
+	if err := r.DB.QueryRow(ctx, query, promoID, customerID).Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// LockTx loads a promo code and locks its row until the tx ends, so concurrent
+// checkouts redeeming the same code are serialized and caps hold.
+func (r *PromoRepository) LockTx(ctx context.Context, tx pgx.Tx, promoID int64) (*model.PromoCode, error) {
+	var p model.PromoCode
+	query := `SELECT ` + promoColumns + ` FROM promocodes WHERE promoid=$1 FOR UPDATE`
+	if err := scanPromo(tx.QueryRow(ctx, query, promoID), &p); err != nil {
+		return nil, errors.New("promo code not found")
+	}
+	return &p, nil
+}
+
+// CountCustomerRedemptionsTx is CountCustomerRedemptions inside a transaction
+func (r *PromoRepository) CountCustomerRedemptionsTx(ctx context.Context, tx pgx.Tx, promoID, customerID int64) (int, error) {
+	var n int
+	query := `SELECT COUNT(*) FROM promoredemptions WHERE promoid=$1 AND customerid=$2`
+	if err := tx.QueryRow(ctx, query, promoID, customerID).Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// RedeemTx records a redemption for an order and bumps the promo's usage counter
+func (r *PromoRepository) RedeemTx(ctx context.Context, tx pgx.Tx, promoID, customerID, orderID int64, discount money.Amount, currency string) error {
+	query := `INSERT INTO promoredemptions (promoid, customerid, orderid, discount, currency, created_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	if _, err := tx.Exec(ctx, query, promoID, customerID, orderID, discount, currency, time.Now()); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `UPDATE promocodes SET redemptioncount = redemptioncount + 1 WHERE promoid=$1`, promoID)
+	return err
+}
+
+// ReleaseTx gives back the redemption of an order that is cancelled before payment
+func (r *PromoRepository) ReleaseTx(ctx context.Context, tx pgx.Tx, orderID int64) error {
+	query := `DELETE FROM promoredemptions WHERE orderid=$1 RETURNING promoid`
+	var promoID int64
+	if err := tx.QueryRow(ctx, query, orderID).Scan(&promoID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	_, err := tx.Exec(ctx, `UPDATE promocodes SET redemptioncount = redemptioncount - 1 WHERE promoid=$1`, promoID)
+	return err
+}
+
+// ListRedemptions returns the redemptions of a promo code (admin use)
+func (r *PromoRepository) ListRedemptions(ctx context.Context, promoID int64, pg pagination.Params) ([]model.PromoRedemption, error) {
+	query := `SELECT redemptionid, promoid, customerid, orderid, discount, currency, created_at FROM promoredemptions
+		WHERE promoid=$1 AND ($2::int IS NULL OR redemptionid < $2) ORDER BY redemptionid DESC LIMIT $3`
+	rows, err := r.DB.Query(ctx, query, promoID, pg.After, pg.Fetch())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.PromoRedemption{}
+	for rows.Next() {
+		var pr model.PromoRedemption
+		if err := rows.Scan(&pr.RedemptionID, &pr.PromoID, &pr.CustomerID, &pr.OrderID, &pr.Discount, &pr.Currency, &pr.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, pr)
+	}
+	return list, rows.Err()
+}
+
+func (r *PromoRepository) CountRedemptions(ctx context.Context, promoID int64) (int64, error) {
+	return countRows(ctx, r.DB, `SELECT count(*) FROM promoredemptions WHERE promoid=$1`, promoID)
+}
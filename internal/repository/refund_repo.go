@@ -0,0 +1,266 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/money"
+	"GameStoreAPI/internal/pagination"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type RefundRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewRefundRepository(db *pgxpool.Pool) *RefundRepository {
+	return &RefundRepository{DB: db}
+}
+
+const refundColumns = `r.refundid, r.orderid, r.orderitemid, r.paymentid, r.amount, r.reason, r.status, r.requestedby, r.reviewedby, r.reviewcomment, r.created_at, r.reviewed_at`
+
+func scanRefund(row pgx.Row, rf *model.Refund) error {
+	return row.Scan(&rf.RefundID, &rf.OrderID, &rf.OrderItemID, &rf.PaymentID, &rf.Amount, &rf.Reason, &rf.Status,
+		&rf.RequestedBy, &rf.ReviewedBy, &rf.ReviewComment, &rf.CreatedAt, &rf.ReviewedAt)
+}
+
+func collectRefunds(rows pgx.Rows) ([]model.Refund, error) {
+	defer rows.Close()
+	list := []model.Refund{}
+	for rows.Next() {
+		var rf model.Refund
+		if err := scanRefund(rows, &rf); err != nil {
+			return nil, err
+		}
+		list = append(list, rf)
+	}
+	return list, rows.Err()
+}
+
+// CreateTx inserts a refund request and returns its id
+func (r *RefundRepository) CreateTx(ctx context.Context, tx pgx.Tx, rf *model.Refund) (int64, error) {
+	var id int64
+	query := `
+		INSERT INTO refunds (orderid, orderitemid, paymentid, amount, reason, status, requestedby, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING refundid
+	`
+	if err := tx.QueryRow(ctx, query, rf.OrderID, rf.OrderItemID, rf.PaymentID, rf.Amount, rf.Reason, rf.Status, rf.RequestedBy, time.Now()).Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// CompleteTx marks a refund as Completed, inserting it first when it has no id yet
+// (refunds initiated by the provider are recorded directly as Completed).
+func (r *RefundRepository) CompleteTx(ctx context.Context, tx pgx.Tx, rf *model.Refund) error {
+	now := time.Now()
+	if rf.RefundID == 0 {
+		query := `
+			INSERT INTO refunds (orderid, orderitemid, paymentid, amount, reason, status, requestedby, reviewedby, reviewcomment, created_at, reviewed_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $10)
+			RETURNING refundid
+		`
+		return tx.QueryRow(ctx, query, rf.OrderID, rf.OrderItemID, rf.PaymentID, rf.Amount, rf.Reason, model.RefundCompleted,
+			rf.RequestedBy, rf.ReviewedBy, rf.ReviewComment, now).Scan(&rf.RefundID)
+	}
+	query := `
+		UPDATE refunds SET status=$1, amount=$2, reviewedby=$3, reviewcomment=$4, reviewed_at=$5
+		WHERE refundid=$6 AND status=$7
+	`
+	tag, err := tx.Exec(ctx, query, model.RefundCompleted, rf.Amount, rf.ReviewedBy, rf.ReviewComment, now, rf.RefundID, model.RefundRequested)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("refund not found or already reviewed")
+	}
+	return nil
+}
+
+// Reject marks a requested refund as Rejected
+func (r *RefundRepository) Reject(ctx context.Context, id, reviewerID int64, comment *string) error {
+	query := `UPDATE refunds SET status=$1, reviewedby=$2, reviewcomment=$3, reviewed_at=$4 WHERE refundid=$5 AND status=$6`
+	tag, err := r.DB.Exec(ctx, query, model.RefundRejected, reviewerID, comment, time.Now(), id, model.RefundRequested)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("refund not found or already reviewed")
+	}
+	return nil
+}
+
+func (r *RefundRepository) GetByID(ctx context.Context, id int64) (*model.Refund, error) {
+	var rf model.Refund
+	query := `SELECT ` + refundColumns + ` FROM refunds r WHERE r.refundid=$1`
+	if err := scanRefund(r.DB.QueryRow(ctx, query, id), &rf); err != nil {
+		return nil, errors.New("refund not found")
+	}
+	return &rf, nil
+}
+
+// HasOpenRequestTx reports whether a Requested refund already covers the order or item
+func (r *RefundRepository) HasOpenRequestTx(ctx context.Context, tx pgx.Tx, orderID int64, orderItemID *int64) (bool, error) {
+	var exists bool
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM refunds
+			WHERE orderid=$1 AND status=$2
+			  AND (orderitemid IS NULL OR $3::int IS NULL OR orderitemid = $3)
+		)
+	`
+	if err := tx.QueryRow(ctx, query, orderID, model.RefundRequested, orderItemID).Scan(&exists); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// ListByCustomer returns the refunds of the customer's orders, newest first
+func (r *RefundRepository) ListByCustomer(ctx context.Context, customerID int64, p pagination.Params) ([]model.Refund, error) {
+	query := `
+		SELECT ` + refundColumns + `
+		FROM refunds r
+		JOIN orders o ON o.orderid = r.orderid
+		WHERE o.customerid=$1 AND ($2::int IS NULL OR r.refundid < $2)
+		ORDER BY r.refundid DESC
+		LIMIT $3
+	`
+	rows, err := r.DB.Query(ctx, query, customerID, p.After, p.Fetch())
+	if err != nil {
+		return nil, err
+	}
+	return collectRefunds(rows)
+}
+
+// CountByCustomer is the total of ListByCustomer
+func (r *RefundRepository) CountByCustomer(ctx context.Context, customerID int64) (int64, error) {
+	query := `SELECT count(*) FROM refunds r JOIN orders o ON o.orderid = r.orderid WHERE o.customerid=$1`
+	return countRows(ctx, r.DB, query, customerID)
+}
+
+// ListAll returns refunds, optionally filtered by status, newest first (admin use)
+func (r *RefundRepository) ListAll(ctx context.Context, status string, p pagination.Params) ([]model.Refund, error) {
+	query := `SELECT ` + refundColumns + ` FROM refunds r WHERE ($1 = '' OR r.status = $1) AND ($2::int IS NULL OR r.refundid < $2) ORDER BY r.refundid DESC LIMIT $3`
+	rows, err := r.DB.Query(ctx, query, status, p.After, p.Fetch())
+	if err != nil {
+		return nil, err
+	}
+	return collectRefunds(rows)
+}
+
+// CountAll is the total of ListAll
+func (r *RefundRepository) CountAll(ctx context.Context, status string) (int64, error) {
+	return countRows(ctx, r.DB, `SELECT count(*) FROM refunds WHERE ($1 = '' OR status = $1)`, status)
+}
+
+// RefundableAmount returns the amount (net of any promo discount) still refundable for an
+// order item, or for every not-yet-refunded item when orderItemID is nil, together with the
+// number of refundable items and the games the buyer owns through them (gift lines excluded).
+func (r *RefundRepository) RefundableAmountTx(ctx context.Context, tx pgx.Tx, orderID int64, orderItemID *int64) (money.Amount, int, []int64, error) {
+	query := `
+		SELECT gameid, COALESCE(linetotal, priceatpurchase * quantity - discount), giftrecipientid IS NOT NULL
+		FROM orderitems
+		WHERE orderid=$1 AND deleted_at IS NULL AND refunded_at IS NULL
+		  AND ($2::int IS NULL OR orderitemid = $2)
+	`
+	rows, err := tx.Query(ctx, query, orderID, orderItemID)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	defer rows.Close()
+
+	var total money.Amount
+	var items int
+	var gameIDs []int64
+	for rows.Next() {
+		var gid int64
+		var amount money.Amount
+		var gift bool
+		if err := rows.Scan(&gid, &amount, &gift); err != nil {
+			return 0, 0, nil, err
+		}
+		total += amount
+		items++
+		if !gift {
+			gameIDs = append(gameIDs, gid)
+		}
+	}
+	return total, items, gameIDs, rows.Err()
+}
+
+// AnyGiftClaimedTx reports whether a recipient has taken one of the gifts among the
+// not-yet-refunded items of an order (one item when orderItemID is not nil): the gift was
+// accepted or the recipient downloaded the game. The open gifts stay locked until the tx
+// ends, so they cannot be accepted while the refund is decided.
+func (r *RefundRepository) AnyGiftClaimedTx(ctx context.Context, tx pgx.Tx, orderID int64, orderItemID *int64) (bool, error) {
+	query := `
+		SELECT gf.status = 'accepted' OR cg.downloaded_at IS NOT NULL
+		FROM gifts gf
+		JOIN orderitems oi ON oi.orderitemid = gf.orderitemid
+		LEFT JOIN customer_games cg ON cg.customerid = gf.recipientid AND cg.gameid = gf.gameid
+		WHERE gf.orderid=$1 AND ($2::int IS NULL OR gf.orderitemid = $2) AND oi.refunded_at IS NULL
+		  AND gf.status IN ('pending', 'accepted')
+		FOR UPDATE OF gf
+	`
+	rows, err := tx.Query(ctx, query, orderID, orderItemID)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	claimed := false
+	for rows.Next() {
+		var c bool
+		if err := rows.Scan(&c); err != nil {
+			return false, err
+		}
+		claimed = claimed || c
+	}
+	return claimed, rows.Err()
+}
+
+// MarkItemsRefundedTx flags the refunded order item(s) and returns the gameids the buyer
+// owned through them (gift lines are excluded) and the refunded amount. With a nil
+// orderItemID every remaining item of the order is flagged.
+func (r *RefundRepository) MarkItemsRefundedTx(ctx context.Context, tx pgx.Tx, orderID int64, orderItemID *int64) ([]int64, money.Amount, error) {
+	query := `
+		UPDATE orderitems SET refunded_at=$1
+		WHERE orderid=$2 AND deleted_at IS NULL AND refunded_at IS NULL
+		  AND ($3::int IS NULL OR orderitemid = $3)
+		RETURNING gameid, COALESCE(linetotal, priceatpurchase * quantity - discount), giftrecipientid IS NOT NULL
+	`
+	rows, err := tx.Query(ctx, query, time.Now(), orderID, orderItemID)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	var total money.Amount
+	flagged := 0
+	for rows.Next() {
+		var id int64
+		var amount money.Amount
+		var gift bool
+		if err := rows.Scan(&id, &amount, &gift); err != nil {
+			return nil, 0, err
+		}
+		flagged++
+		if !gift {
+			ids = append(ids, id)
+		}
+		total += amount
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if flagged == 0 {
+		return nil, 0, errors.New("nothing left to refund")
+	}
+	return ids, total, nil
+}
@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"GameStoreAPI/internal/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type RoleRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewRoleRepository(db *pgxpool.Pool) *RoleRepository {
+	return &RoleRepository{DB: db}
+}
+
+// PermissionsOf returns the permissions granted to an account through its current role.
+// Roles that require 2FA grant nothing until the account has enabled it at /auth/2fa,
+// which needs no permission.
+func (r *RoleRepository) PermissionsOf(ctx context.Context, authID int64) ([]string, error) {
+	query := `
+		SELECT rp.permission
+		FROM userauth u
+		JOIN roles ro ON ro.role = u.role
+		JOIN rolepermissions rp ON rp.role = u.role
+		LEFT JOIN twofactor tf ON tf.authid = u.authid
+		WHERE u.authid=$1
+		  AND (NOT ro.requires_2fa OR tf.enabled_at IS NOT NULL)
+	`
+	rows, err := r.DB.Query(ctx, query, authID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var perms []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
+		}
+		perms = append(perms, p)
+	}
+	return perms, rows.Err()
+}
+
+// ListPermissions returns every known permission
+func (r *RoleRepository) ListPermissions(ctx context.Context) ([]model.Permission, error) {
+	rows, err := r.DB.Query(ctx, `SELECT permission, description FROM permissions ORDER BY permission`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.Permission{}
+	for rows.Next() {
+		var p model.Permission
+		if err := rows.Scan(&p.Permission, &p.Description); err != nil {
+			return nil, err
+		}
+		list = append(list, p)
+	}
+	return list, rows.Err()
+}
+
+const roleQuery = `
+	SELECT r.role, r.description, r.builtin, r.requires_2fa, r.created_at,
+	       COALESCE(array_agg(rp.permission ORDER BY rp.permission) FILTER (WHERE rp.permission IS NOT NULL), '{}')
+	FROM roles r
+	LEFT JOIN rolepermissions rp ON rp.role = r.role`
+
+func scanRole(row pgx.Row, ro *model.Role) error {
+	return row.Scan(&ro.Role, &ro.Description, &ro.Builtin, &ro.Requires2FA, &ro.CreatedAt, &ro.Permissions)
+}
+
+// List returns all roles with their permissions
+func (r *RoleRepository) List(ctx context.Context) ([]model.Role, error) {
+	rows, err := r.DB.Query(ctx, roleQuery+` GROUP BY r.role ORDER BY r.role`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.Role{}
+	for rows.Next() {
+		var ro model.Role
+		if err := scanRole(rows, &ro); err != nil {
+			return nil, err
+		}
+		list = append(list, ro)
+	}
+	return list, rows.Err()
+}
+
+// Get returns one role with its permissions
+func (r *RoleRepository) Get(ctx context.Context, role string) (*model.Role, error) {
+	var ro model.Role
+	if err := scanRole(r.DB.QueryRow(ctx, roleQuery+` WHERE r.role=$1 GROUP BY r.role`, role), &ro); err != nil {
+		return nil, errors.New("role not found")
+	}
+	return &ro, nil
+}
+
+// Create inserts a role with its permissions
+func (r *RoleRepository) Create(ctx context.Context, ro *model.Role) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `INSERT INTO roles (role, description, builtin, requires_2fa, created_at) VALUES ($1, $2, false, $3, $4)`
+	if _, err := tx.Exec(ctx, query, ro.Role, ro.Description, ro.Requires2FA, time.Now()); err != nil {
+		return err
+	}
+	if err := r.replacePermissionsTx(ctx, tx, ro.Role, ro.Permissions); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Update changes the description and 2FA requirement and replaces the permission set of a role
+func (r *RoleRepository) Update(ctx context.Context, ro *model.Role) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `UPDATE roles SET description=$1, requires_2fa=$2 WHERE role=$3`, ro.Description, ro.Requires2FA, ro.Role)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("role not found")
+	}
+	if err := r.replacePermissionsTx(ctx, tx, ro.Role, ro.Permissions); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *RoleRepository) replacePermissionsTx(ctx context.Context, tx pgx.Tx, role string, perms []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM rolepermissions WHERE role=$1`, role); err != nil {
+		return err
+	}
+	if len(perms) == 0 {
+		return nil
+	}
+	query := `INSERT INTO rolepermissions (role, permission) SELECT $1, UNNEST($2::text[])`
+	_, err := tx.Exec(ctx, query, role, perms)
+	return err
+}
+
+// Delete removes a custom role that no account is assigned to
+func (r *RoleRepository) Delete(ctx context.Context, role string) error {
+	query := `
+		DELETE FROM roles
+		WHERE role=$1 AND NOT builtin
+		  AND NOT EXISTS (SELECT 1 FROM userauth WHERE role=$1)
+	`
+	tag, err := r.DB.Exec(ctx, query, role)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("role not found, built in or still assigned")
+	}
+	return nil
+}
+
+// Assign sets the role of an account
+func (r *RoleRepository) Assign(ctx context.Context, authID int64, role string) error {
+	tag, err := r.DB.Exec(ctx, `UPDATE userauth SET role=$1 WHERE authid=$2`, role, authID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
+
+// PromoteTx gives an account the role to, but only while it holds the role from
+func (r *RoleRepository) PromoteTx(ctx context.Context, tx pgx.Tx, authID int64, from, to string) error {
+	_, err := tx.Exec(ctx, `UPDATE userauth SET role=$1 WHERE authid=$2 AND role=$3`, to, authID, from)
+	return err
+}
+
+// Requires2FA reports whether the current role of an account enforces two-factor authentication
+func (r *RoleRepository) Requires2FA(ctx context.Context, authID int64) (bool, error) {
+	var required bool
+	query := `SELECT ro.requires_2fa FROM userauth u JOIN roles ro ON ro.role = u.role WHERE u.authid=$1`
+	if err := r.DB.QueryRow(ctx, query, authID).Scan(&required); err != nil {
+		return false, errors.New("user not found")
+	}
+	return required, nil
+}
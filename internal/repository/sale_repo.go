@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/pagination"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type SaleRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewSaleRepository(db *pgxpool.Pool) *SaleRepository {
+	return &SaleRepository{DB: db}
+}
+
+const saleColumns = `saleid, gameid, discountpercent, saleprice, starts_at, ends_at, createdby, created_at, deleted_at`
+
+// Create schedules a sale and returns its id
+func (r *SaleRepository) Create(ctx context.Context, s *model.GameSale) (int64, error) {
+	var id int64
+	query := `
+		INSERT INTO gamesales (gameid, discountpercent, saleprice, starts_at, ends_at, createdby, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING saleid
+	`
+	if err := r.DB.QueryRow(ctx, query, s.GameID, s.DiscountPercent, s.SalePrice, s.StartsAt, s.EndsAt, s.CreatedBy, time.Now()).Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// Update changes the discount and period of a sale
+func (r *SaleRepository) Update(ctx context.Context, s *model.GameSale) error {
+	query := `
+		UPDATE gamesales SET discountpercent=$1, saleprice=$2, starts_at=$3, ends_at=$4
+		WHERE saleid=$5 AND gameid=$6 AND deleted_at IS NULL
+	`
+	tag, err := r.DB.Exec(ctx, query, s.DiscountPercent, s.SalePrice, s.StartsAt, s.EndsAt, s.SaleID, s.GameID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("sale not found")
+	}
+	return nil
+}
+
+// Delete cancels a sale (soft delete)
+func (r *SaleRepository) Delete(ctx context.Context, gameID, saleID int64) error {
+	query := `UPDATE gamesales SET deleted_at=$1 WHERE saleid=$2 AND gameid=$3 AND deleted_at IS NULL`
+	tag, err := r.DB.Exec(ctx, query, time.Now(), saleID, gameID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("sale not found")
+	}
+	return nil
+}
+
+// saleKeyLayout formats starts_at for page cursors, in a form the query casts back
+const saleKeyLayout = "2006-01-02 15:04:05.999999"
+
+// SaleKey is the sort key and id of a sale in ListByGame
+func SaleKey(s model.GameSale) (string, int64) {
+	return s.StartsAt.Format(saleKeyLayout), s.SaleID
+}
+
+// ListByGame returns the sales of a game that have not ended yet, unless all is set, by
+// start time. p.AfterKey is the SaleKey of p.After.
+func (r *SaleRepository) ListByGame(ctx context.Context, gameID int64, all bool, p pagination.Params) ([]model.GameSale, error) {
+	query := `
+		SELECT ` + saleColumns + `
+		FROM gamesales
+		WHERE gameid=$1 AND deleted_at IS NULL AND ($2 OR ends_at > $3)
+		  AND ($4::int IS NULL OR (starts_at, saleid) > ($5::text::timestamp, $4))
+		ORDER BY starts_at, saleid
+		LIMIT $6
+	`
+	var afterKey *string
+	if p.After != nil {
+		afterKey = &p.AfterKey
+	}
+	rows, err := r.DB.Query(ctx, query, gameID, all, time.Now(), p.After, afterKey, p.Fetch())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.GameSale{}
+	for rows.Next() {
+		var s model.GameSale
+		if err := rows.Scan(&s.SaleID, &s.GameID, &s.DiscountPercent, &s.SalePrice, &s.StartsAt, &s.EndsAt, &s.CreatedBy, &s.CreatedAt, &s.DeletedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
+
+// CountByGame returns the number of sales ListByGame lists
+func (r *SaleRepository) CountByGame(ctx context.Context, gameID int64, all bool) (int64, error) {
+	return countRows(ctx, r.DB, `SELECT count(*) FROM gamesales WHERE gameid=$1 AND deleted_at IS NULL AND ($2 OR ends_at > $3)`, gameID, all, time.Now())
+}
+
+// HasOverlap reports whether another sale of the game overlaps the period
+func (r *SaleRepository) HasOverlap(ctx context.Context, gameID int64, starts, ends time.Time, excludeSaleID int64) (bool, error) {
+	var exists bool
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM gamesales
+			WHERE gameid=$1 AND deleted_at IS NULL AND saleid <> $4
+			  AND starts_at < $3 AND ends_at > $2
+		)
+	`
+	if err := r.DB.QueryRow(ctx, query, gameID, starts, ends, excludeSaleID).Scan(&exists); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
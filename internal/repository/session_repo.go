@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"GameStoreAPI/internal/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type SessionRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewSessionRepository(db *pgxpool.Pool) *SessionRepository {
+	return &SessionRepository{DB: db}
+}
+
+// Create stores a new session together with its first refresh token
+func (r *SessionRepository) Create(ctx context.Context, s *model.Session, tokenHash string) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+	query := `
+		INSERT INTO authsessions (sessionid, authid, useragent, ipaddress, created_at, last_used_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $5, $6)
+	`
+	if _, err := tx.Exec(ctx, query, s.SessionID, s.AuthID, s.UserAgent, s.IPAddress, now, s.ExpiresAt); err != nil {
+		return err
+	}
+	query = `INSERT INTO refreshtokens (sessionid, tokenhash, created_at, expires_at) VALUES ($1, $2, $3, $4)`
+	if _, err := tx.Exec(ctx, query, s.SessionID, tokenHash, now, s.ExpiresAt); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// LockTokenTx loads a refresh token and its session, locking the session row so
+// concurrent refreshes of the same family are serialized.
+func (r *SessionRepository) LockTokenTx(ctx context.Context, tx pgx.Tx, tokenHash string) (*model.RefreshToken, *model.Session, error) {
+	query := `
+		SELECT rt.tokenid, rt.sessionid, rt.expires_at, rt.used_at,
+		       s.authid, s.expires_at, s.revoked_at, s.revokedreason
+		FROM refreshtokens rt
+		JOIN authsessions s ON s.sessionid = rt.sessionid
+		WHERE rt.tokenhash=$1
+		FOR UPDATE OF s
+	`
+	var rt model.RefreshToken
+	var s model.Session
+	err := tx.QueryRow(ctx, query, tokenHash).Scan(&rt.TokenID, &rt.SessionID, &rt.ExpiresAt, &rt.UsedAt,
+		&s.AuthID, &s.ExpiresAt, &s.RevokedAt, &s.RevokedReason)
+	if err != nil {
+		return nil, nil, errors.New("refresh token not found")
+	}
+	s.SessionID = rt.SessionID
+	return &rt, &s, nil
+}
+
+// RotateTx marks a refresh token as used and stores its successor
+func (r *SessionRepository) RotateTx(ctx context.Context, tx pgx.Tx, oldTokenID int64, sessionID, newHash string, expiresAt time.Time) error {
+	now := time.Now()
+	if _, err := tx.Exec(ctx, `UPDATE refreshtokens SET used_at=$1 WHERE tokenid=$2`, now, oldTokenID); err != nil {
+		return err
+	}
+	query := `INSERT INTO refreshtokens (sessionid, tokenhash, created_at, expires_at) VALUES ($1, $2, $3, $4)`
+	if _, err := tx.Exec(ctx, query, sessionID, newHash, now, expiresAt); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `UPDATE authsessions SET last_used_at=$1, expires_at=$2 WHERE sessionid=$3`, now, expiresAt, sessionID)
+	return err
+}
+
+// RevokeTx revokes a session (the whole refresh token family)
+func (r *SessionRepository) RevokeTx(ctx context.Context, tx pgx.Tx, sessionID, reason string) error {
+	query := `UPDATE authsessions SET revoked_at=$1, revokedreason=$2 WHERE sessionid=$3 AND revoked_at IS NULL`
+	_, err := tx.Exec(ctx, query, time.Now(), reason, sessionID)
+	return err
+}
+
+// Revoke revokes one session of a user
+func (r *SessionRepository) Revoke(ctx context.Context, authID int64, sessionID, reason string) error {
+	query := `UPDATE authsessions SET revoked_at=$1, revokedreason=$2 WHERE sessionid=$3 AND authid=$4 AND revoked_at IS NULL`
+	tag, err := r.DB.Exec(ctx, query, time.Now(), reason, sessionID, authID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("session not found or already revoked")
+	}
+	return nil
+}
+
+// RevokeAll revokes every active session of a user
+func (r *SessionRepository) RevokeAll(ctx context.Context, authID int64, reason string) error {
+	query := `UPDATE authsessions SET revoked_at=$1, revokedreason=$2 WHERE authid=$3 AND revoked_at IS NULL`
+	_, err := r.DB.Exec(ctx, query, time.Now(), reason, authID)
+	return err
+}
+
+// RevokeAllTx revokes every active session of a user except keepSessionID (pass "" to revoke all)
+func (r *SessionRepository) RevokeAllTx(ctx context.Context, tx pgx.Tx, authID int64, keepSessionID, reason string) error {
+	query := `UPDATE authsessions SET revoked_at=$1, revokedreason=$2 WHERE authid=$3 AND revoked_at IS NULL AND sessionid <> $4`
+	_, err := tx.Exec(ctx, query, time.Now(), reason, authID, keepSessionID)
+	return err
+}
+
+// IsActive reports whether the session exists for the user, is neither revoked nor expired,
+// and the user is neither deleted nor banned
+func (r *SessionRepository) IsActive(ctx context.Context, authID int64, sessionID string) (bool, error) {
+	var ok bool
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM authsessions s
+			JOIN userauth u ON u.authid = s.authid
+			WHERE s.sessionid=$1 AND s.authid=$2 AND s.revoked_at IS NULL AND s.expires_at > $3
+			  AND u.deleted_at IS NULL AND (u.banned_at IS NULL OR u.banned_until <= $3)
+		)
+	`
+	if err := r.DB.QueryRow(ctx, query, sessionID, authID, time.Now()).Scan(&ok); err != nil {
+		return false, err
+	}
+	return ok, nil
+}
+
+// ListActive returns the active sessions of a user, most recently used first
+func (r *SessionRepository) ListActive(ctx context.Context, authID int64) ([]model.Session, error) {
+	query := `
+		SELECT sessionid, authid, useragent, ipaddress, created_at, last_used_at, expires_at, revoked_at, revokedreason
+		FROM authsessions
+		WHERE authid=$1 AND revoked_at IS NULL AND expires_at > $2
+		ORDER BY last_used_at DESC
+	`
+	rows, err := r.DB.Query(ctx, query, authID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []model.Session{}
+	for rows.Next() {
+		var s model.Session
+		if err := rows.Scan(&s.SessionID, &s.AuthID, &s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt, &s.RevokedAt, &s.RevokedReason); err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"GameStoreAPI/internal/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type TwoFactorRepository struct {
+	DB *pgxpool.Pool
+}
+
+func NewTwoFactorRepository(db *pgxpool.Pool) *TwoFactorRepository {
+	return &TwoFactorRepository{DB: db}
+}
+
+// Get returns the 2FA row of a user (enabled or pending)
+func (r *TwoFactorRepository) Get(ctx context.Context, authID int64) (*model.TwoFactor, error) {
+	var tf model.TwoFactor
+	query := `SELECT authid, secret, created_at, enabled_at, last_used_step FROM twofactor WHERE authid=$1`
+	if err := r.DB.QueryRow(ctx, query, authID).Scan(&tf.AuthID, &tf.Secret, &tf.CreatedAt, &tf.EnabledAt, &tf.LastUsedStep); err != nil {
+		return nil, errors.New("two-factor authentication is not set up")
+	}
+	return &tf, nil
+}
+
+// IsEnabled reports whether a user has 2FA turned on
+func (r *TwoFactorRepository) IsEnabled(ctx context.Context, authID int64) (bool, error) {
+	var on bool
+	query := `SELECT EXISTS (SELECT 1 FROM twofactor WHERE authid=$1 AND enabled_at IS NOT NULL)`
+	if err := r.DB.QueryRow(ctx, query, authID).Scan(&on); err != nil {
+		return false, err
+	}
+	return on, nil
+}
+
+// LockTx loads the 2FA row of a user for update
+func (r *TwoFactorRepository) LockTx(ctx context.Context, tx pgx.Tx, authID int64) (*model.TwoFactor, error) {
+	var tf model.TwoFactor
+	query := `SELECT authid, secret, created_at, enabled_at, last_used_step FROM twofactor WHERE authid=$1 FOR UPDATE`
+	if err := tx.QueryRow(ctx, query, authID).Scan(&tf.AuthID, &tf.Secret, &tf.CreatedAt, &tf.EnabledAt, &tf.LastUsedStep); err != nil {
+		return nil, errors.New("two-factor authentication is not set up")
+	}
+	return &tf, nil
+}
+
+// SavePending stores a new secret awaiting confirmation, replacing an earlier pending one
+func (r *TwoFactorRepository) SavePending(ctx context.Context, authID int64, secret string) error {
+	query := `
+		INSERT INTO twofactor (authid, secret, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT (authid) DO UPDATE SET secret=EXCLUDED.secret, created_at=EXCLUDED.created_at, last_used_step=0
+		WHERE twofactor.enabled_at IS NULL
+	`
+	tag, err := r.DB.Exec(ctx, query, authID, secret, time.Now())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("two-factor authentication is already enabled")
+	}
+	return nil
+}
+
+// EnableTx turns 2FA on, recording the step of the code that confirmed it
+func (r *TwoFactorRepository) EnableTx(ctx context.Context, tx pgx.Tx, authID, step int64) error {
+	query := `UPDATE twofactor SET enabled_at=$1, last_used_step=$2 WHERE authid=$3 AND enabled_at IS NULL`
+	tag, err := tx.Exec(ctx, query, time.Now(), step, authID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("two-factor authentication is already enabled")
+	}
+	return nil
+}
+
+// UseStepTx records an accepted TOTP step; a step that is not newer than the last one is a replay
+func (r *TwoFactorRepository) UseStepTx(ctx context.Context, tx pgx.Tx, authID, step int64) error {
+	tag, err := tx.Exec(ctx, `UPDATE twofactor SET last_used_step=$1 WHERE authid=$2 AND last_used_step < $1`, step, authID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("code already used")
+	}
+	return nil
+}
+
+// DeleteTx removes the 2FA setup and recovery codes of a user
+func (r *TwoFactorRepository) DeleteTx(ctx context.Context, tx pgx.Tx, authID int64) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM recoverycodes WHERE authid=$1`, authID); err != nil {
+		return err
+	}
+	tag, err := tx.Exec(ctx, `DELETE FROM twofactor WHERE authid=$1`, authID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("two-factor authentication is not set up")
+	}
+	return nil
+}
+
+// PurgeTx removes any 2FA data of a user (no error when there is none)
+func (r *TwoFactorRepository) PurgeTx(ctx context.Context, tx pgx.Tx, authID int64) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM recoverycodes WHERE authid=$1`, authID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `DELETE FROM twofactor WHERE authid=$1`, authID)
+	return err
+}
+
+// ReplaceRecoveryCodesTx discards the user's recovery codes and stores new ones (hashed)
+func (r *TwoFactorRepository) ReplaceRecoveryCodesTx(ctx context.Context, tx pgx.Tx, authID int64, hashes []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM recoverycodes WHERE authid=$1`, authID); err != nil {
+		return err
+	}
+	query := `INSERT INTO recoverycodes (authid, codehash, created_at) SELECT $1, UNNEST($2::text[]), $3`
+	_, err := tx.Exec(ctx, query, authID, hashes, time.Now())
+	return err
+}
+
+// UseRecoveryCodeTx marks an unused recovery code as used
+func (r *TwoFactorRepository) UseRecoveryCodeTx(ctx context.Context, tx pgx.Tx, authID int64, hash string) error {
+	query := `UPDATE recoverycodes SET used_at=$1 WHERE authid=$2 AND codehash=$3 AND used_at IS NULL`
+	tag, err := tx.Exec(ctx, query, time.Now(), authID, hash)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("invalid code")
+	}
+	return nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes a user has left
+func (r *TwoFactorRepository) CountRecoveryCodes(ctx context.Context, authID int64) (int, error) {
+	var n int
+	query := `SELECT COUNT(*) FROM recoverycodes WHERE authid=$1 AND used_at IS NULL`
+	if err := r.DB.QueryRow(ctx, query, authID).Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
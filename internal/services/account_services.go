@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/pagination"
+	"GameStoreAPI/internal/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// blocked reports whether a user may not sign in or act: deleted, banned, or suspended
+// until a time that has not come yet
+func blocked(u *model.Auth, now time.Time) bool {
+	if u.DeletedAt != nil {
+		return true
+	}
+	return u.BannedAt != nil && (u.BannedUntil == nil || u.BannedUntil.After(now))
+}
+
+// AccountService covers the account lifecycle: bans and suspensions, self-service
+// deletion and the personal data export.
+type AccountService struct {
+	Users         *repository.AuthRepository
+	Customers     *repository.CustomerRepository
+	Sessions      *repository.SessionRepository
+	TwoFactor     *repository.TwoFactorRepository
+	Tokens        *repository.AuthTokenRepository
+	Attempts      *repository.AuthAttemptRepository
+	Cart          *repository.CartRepository
+	CustomerGames *repository.CustomerGamesRepository
+	Identities    *repository.IdentityRepository
+	APIKeys       *repository.APIKeyRepository
+	Audit         *repository.AuditRepository
+}
+
+func NewAccountService(u *repository.AuthRepository, cr *repository.CustomerRepository, sr *repository.SessionRepository,
+	tfr *repository.TwoFactorRepository, tr *repository.AuthTokenRepository, aar *repository.AuthAttemptRepository,
+	cart *repository.CartRepository, cgr *repository.CustomerGamesRepository, ir *repository.IdentityRepository,
+	akr *repository.APIKeyRepository, ar *repository.AuditRepository) *AccountService {
+	return &AccountService{Users: u, Customers: cr, Sessions: sr, TwoFactor: tfr, Tokens: tr, Attempts: aar,
+		Cart: cart, CustomerGames: cgr, Identities: ir, APIKeys: akr, Audit: ar}
+}
+
+// Ban bans a user and signs them out everywhere. With until set the ban is a suspension
+// that ends by itself at that time.
+func (s *AccountService) Ban(ctx context.Context, actorAuthID, authID int64, until *time.Time, reason, ip string) error {
+	if actorAuthID == authID {
+		return errors.New("you cannot ban yourself")
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return errors.New("reason is required")
+	}
+	if len(reason) > 255 {
+		return errors.New("reason must be at most 255 characters")
+	}
+	if until != nil && !until.After(time.Now()) {
+		return errors.New("suspension end must be in the future")
+	}
+	tx, err := s.Users.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.Users.BanTx(ctx, tx, authID, until, reason); err != nil {
+		return err
+	}
+	if err := s.Customers.SetActiveTx(ctx, tx, authID, false); err != nil {
+		return err
+	}
+	if err := s.Sessions.RevokeAllTx(ctx, tx, authID, "", model.SessionUserBlocked); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	detail := reason
+	if until != nil {
+		detail = fmt.Sprintf("suspended until %s: %s", until.Format(time.RFC3339), reason)
+	}
+	return s.audit(ctx, &actorAuthID, model.AuditUserBanned, authID, detail, ip)
+}
+
+// Unban lifts a ban or suspension before it ends
+func (s *AccountService) Unban(ctx context.Context, actorAuthID, authID int64, ip string) error {
+	tx, err := s.Users.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.Users.UnbanTx(ctx, tx, authID); err != nil {
+		return err
+	}
+	if err := s.Customers.SetActiveTx(ctx, tx, authID, true); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return s.audit(ctx, &actorAuthID, model.AuditUserUnbanned, authID, "", ip)
+}
+
+// Delete deletes the account of a customer after checking their password. Personal data
+// is erased from customers and userauth, credentials, linked identities, API keys, 2FA and
+// sessions are dropped and the open cart is discarded; orders stay (with their billing
+// country and region for tax records) but no longer point at anything that identifies the
+// person.
+func (s *AccountService) Delete(ctx context.Context, authID int64, password, ip string) error {
+	u, err := s.Users.GetByID(ctx, authID)
+	if err != nil {
+		return err
+	}
+	hash, err := s.Users.GetPasswordHash(ctx, authID)
+	if err != nil {
+		return err
+	}
+	if hash == model.NoPassword {
+		// accounts created through an identity provider set one with the password reset
+		return errors.New("set a password before deleting your account")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return errors.New("invalid password")
+	}
+	tx, err := s.Users.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	customerID, err := s.Customers.AnonymizeTx(ctx, tx, authID)
+	if err != nil {
+		return err
+	}
+	if err := s.Cart.DiscardOpenOrderTx(ctx, tx, customerID); err != nil {
+		return err
+	}
+	if err := s.Users.AnonymizeTx(ctx, tx, authID); err != nil {
+		return err
+	}
+	if err := s.TwoFactor.PurgeTx(ctx, tx, authID); err != nil {
+		return err
+	}
+	if err := s.Tokens.DeleteAllTx(ctx, tx, authID); err != nil {
+		return err
+	}
+	if err := s.Identities.DeleteAllTx(ctx, tx, authID); err != nil {
+		return err
+	}
+	if err := s.APIKeys.RevokeAllTx(ctx, tx, authID); err != nil {
+		return err
+	}
+	if err := s.Sessions.RevokeAllTx(ctx, tx, authID, "", model.SessionAccountDeleted); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	// the failed-login counter is keyed by the (now erased) email address
+	if _, err := s.Attempts.Clear(ctx, model.AttemptAccount, accountKey(u.Email)); err != nil {
+		return err
+	}
+	return s.audit(ctx, &authID, model.AuditAccountDeleted, authID, "", ip)
+}
+
+// Export collects the personal data held about a customer
+func (s *AccountService) Export(ctx context.Context, authID int64) (*model.AccountExport, error) {
+	u, err := s.Users.GetByID(ctx, authID)
+	if err != nil {
+		return nil, err
+	}
+	cust, err := s.Customers.GetByAuthID(ctx, authID)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := pagination.Collect(func(p pagination.Params) ([]model.Order, error) {
+		return s.CustomerGames.ListOrders(ctx, cust.CustomerID, p)
+	}, func(o model.Order) int64 { return o.OrderID })
+	if err != nil {
+		return nil, err
+	}
+	exp := &model.AccountExport{
+		ExportedAt: time.Now(),
+		Account:    u,
+		Profile:    cust,
+		Orders:     make([]model.ExportedOrder, 0, len(orders)),
+	}
+	for _, o := range orders {
+		_, items, err := s.CustomerGames.GetOrderDetails(ctx, cust.CustomerID, o.OrderID)
+		if err != nil {
+			return nil, err
+		}
+		if items == nil {
+			items = []model.OrderItem{}
+		}
+		exp.Orders = append(exp.Orders, model.ExportedOrder{Order: o, Items: items})
+	}
+	exp.OwnedGames, err = pagination.Collect(func(p pagination.Params) ([]model.Game, error) {
+		return s.CustomerGames.ListOwnedGames(ctx, cust.CustomerID, p)
+	}, func(g model.Game) int64 { return g.GameID })
+	if err != nil {
+		return nil, err
+	}
+	if exp.Identities, err = s.Identities.ListByAuthID(ctx, authID); err != nil {
+		return nil, err
+	}
+	return exp, nil
+}
+
+func (s *AccountService) audit(ctx context.Context, actor *int64, event string, authID int64, detail, ip string) error {
+	subject := "authid:" + strconv.FormatInt(authID, 10)
+	return s.Audit.Record(ctx, &model.AuditEntry{ActorAuthID: actor, Event: event, Subject: &subject,
+		Detail: optionalString(detail), IPAddress: optionalString(ip)})
+}
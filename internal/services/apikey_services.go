@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"GameStoreAPI/internal/middleware"
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/pagination"
+	"GameStoreAPI/internal/repository"
+)
+
+const (
+	// DefaultAPIKeyTTL applies when a key is created without an expiry
+	DefaultAPIKeyTTL = 90 * 24 * time.Hour
+	MaxAPIKeyTTL     = 365 * 24 * time.Hour
+	// MaxActiveAPIKeys caps the usable keys of one account
+	MaxActiveAPIKeys = 20
+)
+
+// APIKeyService manages the API keys developers use from CI instead of their password
+type APIKeyService struct {
+	Repo  *repository.APIKeyRepository
+	Roles *repository.RoleRepository
+	Audit *repository.AuditRepository
+}
+
+func NewAPIKeyService(r *repository.APIKeyRepository, rr *repository.RoleRepository, ar *repository.AuditRepository) *APIKeyService {
+	return &APIKeyService{Repo: r, Roles: rr, Audit: ar}
+}
+
+// Create issues a key for the account. Scopes must be permissions the account holds;
+// the key is returned in full only here.
+func (s *APIKeyService) Create(ctx context.Context, authID int64, name string, scopes []string, expiresAt *time.Time, ip string) (*model.CreatedAPIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	if len(name) > 100 {
+		return nil, errors.New("name must be at most 100 characters")
+	}
+	now := time.Now()
+	expires := now.Add(DefaultAPIKeyTTL)
+	if expiresAt != nil {
+		if !expiresAt.After(now) {
+			return nil, errors.New("expiry must be in the future")
+		}
+		if expiresAt.After(now.Add(MaxAPIKeyTTL)) {
+			return nil, fmt.Errorf("expiry must be within %d days", int(MaxAPIKeyTTL.Hours()/24))
+		}
+		expires = *expiresAt
+	}
+	granted, err := s.Roles.PermissionsOf(ctx, authID)
+	if err != nil {
+		return nil, err
+	}
+	held := make(map[string]bool, len(granted))
+	for _, p := range granted {
+		held[p] = true
+	}
+	seen := map[string]bool{}
+	clean := make([]string, 0, len(scopes))
+	for _, sc := range scopes {
+		sc = strings.TrimSpace(sc)
+		if !held[sc] {
+			return nil, fmt.Errorf("scope %q is not a permission of your account", sc)
+		}
+		if !seen[sc] {
+			seen[sc] = true
+			clean = append(clean, sc)
+		}
+	}
+	if len(clean) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+	active, err := s.Repo.CountActive(ctx, authID)
+	if err != nil {
+		return nil, err
+	}
+	if active >= MaxActiveAPIKeys {
+		return nil, fmt.Errorf("at most %d active API keys are allowed, revoke one first", MaxActiveAPIKeys)
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	key := middleware.APIKeyPrefix + secret
+	k := model.APIKey{AuthID: authID, Name: name, Prefix: key[:len(middleware.APIKeyPrefix)+8], Scopes: clean, ExpiresAt: expires}
+	if err := s.Repo.Create(ctx, &k, hashToken(key)); err != nil {
+		return nil, err
+	}
+	if err := s.audit(ctx, authID, model.AuditAPIKeyCreated, k, ip); err != nil {
+		return nil, err
+	}
+	return &model.CreatedAPIKey{APIKey: k, Key: key}, nil
+}
+
+// List returns the keys of an account (without the keys themselves), newest first. Revoked
+// and expired keys are kept, so the list is paginated although few keys are active.
+func (s *APIKeyService) List(ctx context.Context, authID int64, p pagination.Params) (*pagination.Page[model.APIKey], error) {
+	rows, err := s.Repo.ListByAuthID(ctx, authID, p)
+	if err != nil {
+		return nil, err
+	}
+	return pagination.NewPage(rows, p, func(k model.APIKey) int64 { return k.KeyID }, func() (int64, error) { return s.Repo.CountByAuthID(ctx, authID) })
+}
+
+// Revoke disables a key of the account right away
+func (s *APIKeyService) Revoke(ctx context.Context, authID, keyID int64, ip string) error {
+	name, err := s.Repo.Revoke(ctx, authID, keyID)
+	if err != nil {
+		return err
+	}
+	return s.audit(ctx, authID, model.AuditAPIKeyRevoked, model.APIKey{KeyID: keyID, Name: name}, ip)
+}
+
+// Authenticate is the middleware.APIKeyAuthenticator: it resolves a key to its owner and
+// records its use. Keys of banned or deleted accounts are refused.
+func (s *APIKeyService) Authenticate(ctx context.Context, key, ip string) (*middleware.Claims, error) {
+	k, u, err := s.Repo.GetActiveByHash(ctx, hashToken(key))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if blocked(u, now) {
+		return nil, errors.New("account is not active")
+	}
+	if err := s.Repo.TouchUsed(ctx, k.KeyID, ip, now); err != nil {
+		return nil, err
+	}
+	return &middleware.Claims{AuthID: u.AuthID, Email: u.Email, Role: u.Role, APIKeyID: k.KeyID, Scopes: k.Scopes}, nil
+}
+
+func (s *APIKeyService) audit(ctx context.Context, authID int64, event string, k model.APIKey, ip string) error {
+	subject := "authid:" + strconv.FormatInt(authID, 10)
+	detail := fmt.Sprintf("key %d (%s)", k.KeyID, k.Name)
+	if len(k.Scopes) > 0 {
+		detail += " scopes " + strings.Join(k.Scopes, ",")
+	}
+	return s.Audit.Record(ctx, &model.AuditEntry{ActorAuthID: &authID, Event: event, Subject: &subject,
+		Detail: &detail, IPAddress: optionalString(ip)})
+}
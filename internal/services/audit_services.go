@@ -0,0 +1,27 @@
+package services
+
+import (
+	"context"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/pagination"
+	"GameStoreAPI/internal/repository"
+)
+
+type AuditService struct {
+	Repo *repository.AuditRepository
+}
+
+func NewAuditService(r *repository.AuditRepository) *AuditService {
+	return &AuditService{Repo: r}
+}
+
+// List returns a page of audit entries, newest first, optionally of one event only
+func (s *AuditService) List(ctx context.Context, event string, p pagination.Params) (*pagination.Page[model.AuditEntry], error) {
+	rows, err := s.Repo.List(ctx, event, p)
+	if err != nil {
+		return nil, err
+	}
+	return pagination.NewPage(rows, p, func(e model.AuditEntry) int64 { return e.AuditID },
+		func() (int64, error) { return s.Repo.Count(ctx, event) })
+}
@@ -4,8 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/url"
 	"regexp"
+	"strings"
+	"time"
 
+	"GameStoreAPI/internal/mailer"
 	"GameStoreAPI/internal/model"
 	"GameStoreAPI/internal/repository"
 
@@ -14,19 +18,31 @@ import (
 
 const (
 	MinPasswordLen = 8
+
+	VerifyEmailTokenTTL   = 48 * time.Hour
+	PasswordResetTokenTTL = time.Hour
 )
 
 var (
 	emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
+
+	ErrEmailNotVerified = errors.New("email address not verified")
 )
 
 type AuthService struct {
 	Users    *repository.AuthRepository
 	Customer *repository.CustomerRepository // for auto-create
+	Tokens   *repository.AuthTokenRepository
+	Sessions *repository.SessionRepository
+	Mailer   mailer.Mailer
+	Lockout  *LockoutService
+	// BaseURL prefixes the links sent by email (e.g. https://store.example.com)
+	BaseURL string
 }
 
-func NewAuthService(u *repository.AuthRepository, cr *repository.CustomerRepository) *AuthService {
-	return &AuthService{Users: u, Customer: cr}
+func NewAuthService(u *repository.AuthRepository, cr *repository.CustomerRepository, tr *repository.AuthTokenRepository,
+	sr *repository.SessionRepository, m mailer.Mailer, lo *LockoutService, baseURL string) *AuthService {
+	return &AuthService{Users: u, Customer: cr, Tokens: tr, Sessions: sr, Mailer: m, Lockout: lo, BaseURL: strings.TrimRight(baseURL, "/")}
 }
 
 func (s *AuthService) validateEmail(email string) error {
@@ -47,7 +63,14 @@ func (s *AuthService) validatePassword(pw string) error {
 }
 
 // RegisterPublic creates a user with role "user" AND creates the customer row.
-func (s *AuthService) RegisterPublic(ctx context.Context, email, password string) (int64, error) {
+// Registrations are throttled per client IP.
+func (s *AuthService) RegisterPublic(ctx context.Context, email, password, ip string) (int64, error) {
+	if err := s.Lockout.CheckRegistration(ctx, ip); err != nil {
+		return 0, err
+	}
+	if err := s.Lockout.RegistrationAttempt(ctx, ip); err != nil {
+		return 0, err
+	}
 	if err := s.validateEmail(email); err != nil {
 		return 0, err
 	}
@@ -65,7 +88,7 @@ func (s *AuthService) RegisterPublic(ctx context.Context, email, password string
 	if err != nil {
 		return 0, err
 	}
-	authID, err := s.Users.CreateUser(ctx, email, string(hash), "user")
+	authID, err := s.Users.CreateUser(ctx, email, string(hash), "user", false)
 	if err != nil {
 		return 0, err
 	}
@@ -75,6 +98,41 @@ func (s *AuthService) RegisterPublic(ctx context.Context, email, password string
 		// For now, return the authID and the error so caller can decide.
 		return authID, err
 	}
+	// the account is usable right away; a failed email can be re-sent from /auth/email/resend
+	_ = s.sendToken(ctx, authID, email, model.TokenVerifyEmail)
+	return authID, nil
+}
+
+// RegisterExternal creates a "user" account without a password, with its customer row,
+// for someone signing in with an identity provider for the first time. The email counts
+// as verified when the provider says so. Registrations are throttled per client IP.
+func (s *AuthService) RegisterExternal(ctx context.Context, email string, verified bool, ip string) (int64, error) {
+	if err := s.Lockout.CheckRegistration(ctx, ip); err != nil {
+		return 0, err
+	}
+	if err := s.Lockout.RegistrationAttempt(ctx, ip); err != nil {
+		return 0, err
+	}
+	if err := s.validateEmail(email); err != nil {
+		return 0, err
+	}
+	exists, err := s.Users.EmailExists(ctx, email)
+	if err != nil {
+		return 0, err
+	}
+	if exists {
+		return 0, errors.New("email already registered")
+	}
+	authID, err := s.Users.CreateUser(ctx, email, model.NoPassword, "user", verified)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.Customer.Create(ctx, authID, email); err != nil {
+		return authID, err
+	}
+	if !verified {
+		_ = s.sendToken(ctx, authID, email, model.TokenVerifyEmail)
+	}
 	return authID, nil
 }
 
@@ -103,20 +161,202 @@ func (s *AuthService) RegisterByAdmin(ctx context.Context, email, password, role
 	if err != nil {
 		return 0, err
 	}
-	return s.Users.CreateUser(ctx, email, string(hash), role)
+	// accounts created by an admin are trusted, no verification email
+	return s.Users.CreateUser(ctx, email, string(hash), role, true)
 }
 
 // Login authenticates using email + password and returns the user (without passwordhash).
-func (s *AuthService) Login(ctx context.Context, email, password string) (*model.Auth, error) {
+// Locked out accounts and client IPs get a *LockedError without the password being checked.
+// A right password does not reset the failure counter, only a complete login does (see
+// TwoFactorService.LoginSucceeded).
+func (s *AuthService) Login(ctx context.Context, email, password, ip string) (*model.Auth, error) {
+	if err := s.Lockout.CheckLogin(ctx, email, ip); err != nil {
+		return nil, err
+	}
 	u, err := s.Users.GetByEmail(ctx, email)
 	if err != nil {
 		// do not reveal whether email exists
-		return nil, errors.New("invalid credentials")
+		return nil, s.loginFailed(ctx, email, ip)
 	}
 	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)); err != nil {
-		return nil, errors.New("invalid credentials")
+		return nil, s.loginFailed(ctx, email, ip)
+	}
+	if err := s.checkBan(ctx, u); err != nil {
+		return nil, err
 	}
 	// zero out password before returning
 	u.PasswordHash = ""
 	return u, nil
 }
+
+// checkBan rejects banned users. A suspension that has run out is lifted here, on the
+// first login after it ended (the user's sessions were revoked when it started).
+func (s *AuthService) checkBan(ctx context.Context, u *model.Auth) error {
+	if u.DeletedAt != nil {
+		return errors.New("account is deleted")
+	}
+	if u.BannedAt == nil {
+		return nil
+	}
+	if u.BannedUntil == nil {
+		return errors.New("account is banned")
+	}
+	if u.BannedUntil.After(time.Now()) {
+		return fmt.Errorf("account is suspended until %s", u.BannedUntil.Format(time.RFC3339))
+	}
+	tx, err := s.Users.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.Users.UnbanTx(ctx, tx, u.AuthID); err != nil {
+		return err
+	}
+	if err := s.Customer.SetActiveTx(ctx, tx, u.AuthID, true); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	u.BannedAt, u.BannedUntil, u.BanReason = nil, nil, nil
+	return nil
+}
+
+// loginFailed counts the failed attempt and returns the error to answer with
+func (s *AuthService) loginFailed(ctx context.Context, email, ip string) error {
+	if err := s.Lockout.LoginFailed(ctx, email, ip); err != nil {
+		return err
+	}
+	return errors.New("invalid credentials")
+}
+
+// sendToken issues a single-use token for purpose and emails its link to the user
+func (s *AuthService) sendToken(ctx context.Context, authID int64, email, purpose string) error {
+	token, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+	ttl, subject, path, text := VerifyEmailTokenTTL, "Verify your email address", "/verify-email",
+		"Confirm your email address to be able to buy games:"
+	if purpose == model.TokenPasswordReset {
+		ttl, subject, path, text = PasswordResetTokenTTL, "Reset your password", "/reset-password",
+			"Someone asked to reset the password of your account. If it was you, choose a new password here:"
+	}
+	if err := s.Tokens.Create(ctx, authID, purpose, hashToken(token), time.Now().Add(ttl)); err != nil {
+		return err
+	}
+	body := fmt.Sprintf("%s\n\n%s%s?token=%s\n\nThe link expires in %s and can be used once.\n",
+		text, s.BaseURL, path, url.QueryEscape(token), ttl)
+	if err := s.Mailer.Send(ctx, mailer.Message{To: email, Subject: subject, Body: body}); err != nil {
+		return fmt.Errorf("send email: %w", err)
+	}
+	return nil
+}
+
+// ResendVerification emails a new verification link, invalidating the previous one
+func (s *AuthService) ResendVerification(ctx context.Context, authID int64) error {
+	u, err := s.Users.GetByID(ctx, authID)
+	if err != nil {
+		return err
+	}
+	if u.EmailVerifiedAt != nil {
+		return errors.New("email already verified")
+	}
+	return s.sendToken(ctx, u.AuthID, u.Email, model.TokenVerifyEmail)
+}
+
+// VerifyEmail consumes a verification token
+func (s *AuthService) VerifyEmail(ctx context.Context, token string) error {
+	tx, err := s.Users.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	authID, err := s.Tokens.ConsumeTx(ctx, tx, model.TokenVerifyEmail, hashToken(token))
+	if err != nil {
+		return err
+	}
+	if err := s.Users.MarkEmailVerifiedTx(ctx, tx, authID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ForgotPassword emails a password reset link. It succeeds for unknown addresses too so
+// that the endpoint cannot be used to find out which emails have an account.
+func (s *AuthService) ForgotPassword(ctx context.Context, email string) error {
+	u, err := s.Users.GetByEmail(ctx, email)
+	if err != nil || blocked(u, time.Now()) {
+		return nil
+	}
+	return s.sendToken(ctx, u.AuthID, u.Email, model.TokenPasswordReset)
+}
+
+// ResetPassword sets a new password using a reset token and signs the user out everywhere.
+// Receiving the link also proves ownership of the address, so the email becomes verified.
+func (s *AuthService) ResetPassword(ctx context.Context, token, password string) error {
+	if err := s.validatePassword(password); err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	tx, err := s.Users.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	authID, err := s.Tokens.ConsumeTx(ctx, tx, model.TokenPasswordReset, hashToken(token))
+	if err != nil {
+		return err
+	}
+	if err := s.Users.SetPasswordTx(ctx, tx, authID, string(hash)); err != nil {
+		return err
+	}
+	if err := s.Users.MarkEmailVerifiedTx(ctx, tx, authID); err != nil {
+		return err
+	}
+	if err := s.Sessions.RevokeAllTx(ctx, tx, authID, "", model.SessionPasswordReset); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ChangePassword replaces the password of a signed-in user after checking the current one.
+// Every other session is revoked; the session making the change stays signed in.
+func (s *AuthService) ChangePassword(ctx context.Context, authID int64, sessionID, current, password string) error {
+	stored, err := s.Users.GetPasswordHash(ctx, authID)
+	if err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(stored), []byte(current)); err != nil {
+		return errors.New("current password is incorrect")
+	}
+	if current == password {
+		return errors.New("new password must differ from the current one")
+	}
+	if err := s.validatePassword(password); err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	tx, err := s.Users.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.Users.SetPasswordTx(ctx, tx, authID, string(hash)); err != nil {
+		return err
+	}
+	if err := s.Sessions.RevokeAllTx(ctx, tx, authID, sessionID, model.SessionPasswordChanged); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -4,31 +4,62 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/money"
 	"GameStoreAPI/internal/repository"
+	"GameStoreAPI/internal/tax"
 )
 
+// CartChangedError is returned by Checkout when cart lines no longer match the catalog
+type CartChangedError struct {
+	Changes []model.CartItemChange
+}
+
+func (e *CartChangedError) Error() string {
+	return "cart changed since items were added, review the changes"
+}
+
+// ErrCartChangedDuringCheckout is returned when the cart was edited while it was being checked out
+var ErrCartChangedDuringCheckout = errors.New("cart changed during checkout, review it and try again")
+
 type CartService struct {
 	Repo              *repository.CartRepository
 	OrderRepo         *repository.OrderRepository
 	CustomerGamesRepo *repository.CustomerGamesRepository
 	AuthRepo          *repository.AuthRepository
 	CustomerRepo      *repository.CustomerRepository
+	PaymentRepo       *repository.PaymentRepository
+	Payments          *PaymentService
+	Pricing           *PricingService
+	Promos            *PromoService
+	Tax               tax.Calculator
+	GiftRepo          *repository.GiftRepository
 }
 
-func NewCartService(r *repository.CartRepository, or *repository.OrderRepository, cgr *repository.CustomerGamesRepository, ar *repository.AuthRepository, cr *repository.CustomerRepository) *CartService {
+func NewCartService(r *repository.CartRepository, or *repository.OrderRepository, cgr *repository.CustomerGamesRepository, ar *repository.AuthRepository, cr *repository.CustomerRepository, pr *repository.PaymentRepository, ps *PaymentService, prs *PricingService, pms *PromoService, tc tax.Calculator, gr *repository.GiftRepository) *CartService {
 	return &CartService{
 		Repo:              r,
 		OrderRepo:         or,
 		CustomerGamesRepo: cgr,
 		AuthRepo:          ar,
 		CustomerRepo:      cr,
+		PaymentRepo:       pr,
+		Payments:          ps,
+		Pricing:           prs,
+		Promos:            pms,
+		Tax:               tc,
+		GiftRepo:          gr,
 	}
 }
 
-// Add adds qty to cart for the authenticated user's authid
-func (s *CartService) Add(ctx context.Context, authID, gameID int64, qty int) error {
+// Add adds qty to cart for the authenticated user's authid.
+// The cart is priced in the customer's preferred currency at the time it was opened;
+// an empty cart follows a later change of preference. With giftTo (email or username of
+// another customer) the line is bought as a gift for that customer.
+func (s *CartService) Add(ctx context.Context, authID, gameID int64, qty int, giftTo, giftMessage string) error {
 	if qty <= 0 {
 		return errors.New("quantity must be > 0")
 	}
@@ -37,22 +68,195 @@ func (s *CartService) Add(ctx context.Context, authID, gameID int64, qty int) er
 	if err != nil {
 		return err
 	}
+	var recipientID *int64
+	var message *string
+	if giftTo != "" {
+		if recipientID, message, err = s.giftTarget(ctx, cid, giftTo, giftMessage); err != nil {
+			return err
+		}
+	}
+	currency, err := s.Pricing.Resolve(ctx, s.Pricing.CustomerCurrency(ctx, authID))
+	if err != nil {
+		return err
+	}
 	// get or create order (open cart)
 	orderID, err := s.Repo.FindOpenOrder(ctx, cid)
 	if err != nil {
 		// create new order
-		orderID, err = s.Repo.CreateOpenOrder(ctx, cid)
+		orderID, err = s.Repo.CreateOpenOrder(ctx, cid, currency)
 		if err != nil {
 			return err
 		}
+	} else if currency, err = s.cartCurrency(ctx, orderID, currency); err != nil {
+		return err
+	}
+	// get current price for game (sale price while a sale runs), in the cart currency
+	g, err := s.Pricing.CurrentGame(ctx, gameID, currency)
+	if err != nil || g.DeletedAt != nil {
+		return errors.New("game not found")
 	}
-	// get current price for game
-	_, price, err := s.Repo.GetGameInfo(ctx, gameID)
+	if g.Status != model.GameStatusPublished {
+		return errors.New("game is not available for purchase")
+	}
+	if g.ReleaseDate != nil && g.ReleaseDate.After(time.Now()) {
+		return errors.New("game is not released yet")
+	}
+	// add or increment item
+	if err := s.Repo.AddOrIncrementOrderItem(ctx, orderID, gameID, qty, g.Price, currency); err != nil {
+		return err
+	}
+	if recipientID == nil {
+		return nil
+	}
+	return s.Repo.SetOrderItemGift(ctx, orderID, gameID, recipientID, message)
+}
+
+// giftTarget resolves the recipient of a gift line and validates the message
+func (s *CartService) giftTarget(ctx context.Context, buyerID int64, to, message string) (*int64, *string, error) {
+	recipient, err := s.CustomerRepo.FindByEmailOrUsername(ctx, strings.TrimSpace(to))
+	if err != nil {
+		return nil, nil, errors.New("gift recipient not found")
+	}
+	if recipient.CustomerID == buyerID {
+		return nil, nil, errors.New("you cannot send a gift to yourself")
+	}
+	message = strings.TrimSpace(message)
+	if len(message) > 500 {
+		return nil, nil, errors.New("gift message must be at most 500 characters")
+	}
+	if message == "" {
+		return &recipient.CustomerID, nil, nil
+	}
+	return &recipient.CustomerID, &message, nil
+}
+
+// SetGift marks a cart line as a gift to another customer, or with an empty giftTo
+// makes it a line for the buyer again
+func (s *CartService) SetGift(ctx context.Context, authID, gameID int64, giftTo, giftMessage string) error {
+	cid, err := s.Repo.GetCustomerID(ctx, authID)
 	if err != nil {
 		return err
 	}
-	// add or increment item
-	return s.Repo.AddOrIncrementOrderItem(ctx, orderID, gameID, qty, price)
+	orderID, err := s.Repo.FindOpenOrder(ctx, cid)
+	if err != nil {
+		return errors.New("no open cart")
+	}
+	var recipientID *int64
+	var message *string
+	if strings.TrimSpace(giftTo) != "" {
+		if recipientID, message, err = s.giftTarget(ctx, cid, giftTo, giftMessage); err != nil {
+			return err
+		}
+	}
+	return s.Repo.SetOrderItemGift(ctx, orderID, gameID, recipientID, message)
+}
+
+// cartCurrency returns the currency of an open order, switching an empty one to preferred
+func (s *CartService) cartCurrency(ctx context.Context, orderID int64, preferred string) (string, error) {
+	current, err := s.Repo.GetOrderCurrency(ctx, orderID)
+	if err != nil {
+		return "", err
+	}
+	if current == preferred {
+		return current, nil
+	}
+	items, _, err := s.Repo.GetOrderItems(ctx, orderID)
+	if err != nil {
+		return "", err
+	}
+	if len(items) > 0 {
+		return current, nil
+	}
+	if err := s.Repo.SetOrderCurrency(ctx, orderID, preferred); err != nil {
+		return "", err
+	}
+	return preferred, nil
+}
+
+// billingAddress returns the customer's billing address as a tax address (empty country when unset)
+func (s *CartService) billingAddress(ctx context.Context, authID int64) (tax.Address, error) {
+	c, err := s.CustomerRepo.GetByAuthID(ctx, authID)
+	if err != nil {
+		return tax.Address{}, err
+	}
+	var addr tax.Address
+	if c.BillingCountry != nil {
+		addr.Country = *c.BillingCountry
+	}
+	if c.BillingRegion != nil {
+		addr.Region = *c.BillingRegion
+	}
+	if c.BillingPostalCode != nil {
+		addr.PostalCode = *c.BillingPostalCode
+	}
+	return addr, nil
+}
+
+// computeTax computes the tax of the cart lines after their share of the promo discount.
+// It returns the tax result and what is paid per order item (tax included).
+func (s *CartService) computeTax(ctx context.Context, addr tax.Address, items []model.CartItem, perItemDiscount map[int64]money.Amount, currency string) (*tax.Result, map[int64]money.Amount, error) {
+	lines := make([]tax.Line, 0, len(items))
+	for _, it := range items {
+		lines = append(lines, tax.Line{ID: it.OrderItemID, Amount: it.Subtotal - perItemDiscount[it.OrderItemID]})
+	}
+	res, err := s.Tax.Calculate(ctx, addr, currency, lines)
+	if err != nil {
+		return nil, nil, err
+	}
+	totals := make(map[int64]money.Amount, len(lines))
+	for _, l := range lines {
+		totals[l.ID] = l.Amount
+		if !res.Inclusive {
+			totals[l.ID] += res.PerLine[l.ID]
+		}
+	}
+	return res, totals, nil
+}
+
+// changes compares each cart line with the current catalog: deleted, unpublished and unreleased games,
+// and lines whose stored price differs from the current price in the cart currency.
+func (s *CartService) changes(ctx context.Context, items []model.CartItem, currency string) ([]model.CartItemChange, error) {
+	var out []model.CartItemChange
+	for _, it := range items {
+		change := model.CartItemChange{OrderItemID: it.OrderItemID, GameID: it.GameID, Title: it.Title, OldPrice: it.PriceAtPurchase}
+		g, err := s.Pricing.CurrentGame(ctx, it.GameID, currency)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case g.DeletedAt != nil:
+			change.Reason = model.CartChangeDeleted
+		case g.Status != model.GameStatusPublished:
+			change.Reason = model.CartChangeUnavailable
+		case g.ReleaseDate != nil && g.ReleaseDate.After(time.Now()):
+			change.Reason = model.CartChangeUnreleased
+		case g.Price != it.PriceAtPurchase:
+			change.Reason = model.CartChangePrice
+			price := g.Price
+			change.NewPrice = &price
+		default:
+			continue
+		}
+		out = append(out, change)
+	}
+	return out, nil
+}
+
+// ensureGiftable rejects a gift the recipient already owns or has pending
+func (s *CartService) ensureGiftable(ctx context.Context, recipientID, gameID int64, title string) error {
+	owned, err := s.CustomerGamesRepo.ExistsAnyOwned(ctx, recipientID, []int64{gameID})
+	if err != nil {
+		return fmt.Errorf("ownership check failed: %w", err)
+	}
+	if owned == 0 {
+		if owned, err = s.GiftRepo.HasPending(ctx, recipientID, []int64{gameID}); err != nil {
+			return fmt.Errorf("ownership check failed: %w", err)
+		}
+	}
+	if owned != 0 {
+		return fmt.Errorf("checkout rejected: gift recipient already owns game '%s' (id=%d)", title, gameID)
+	}
+	return nil
 }
 
 // Update sets quantity for an item in the cart
@@ -106,62 +310,198 @@ func (s *CartService) Get(ctx context.Context, authID int64) (*model.CartRespons
 	orderID, err := s.Repo.FindOpenOrder(ctx, cid)
 	if err != nil {
 		// empty cart
-		return &model.CartResponse{Items: []model.CartItem{}, Total: 0}, nil
+		return &model.CartResponse{Items: []model.CartItem{}, Total: 0, Currency: s.Pricing.CustomerCurrency(ctx, authID)}, nil
+	}
+	items, subtotal, err := s.Repo.GetOrderItems(ctx, orderID)
+	if err != nil {
+		return nil, err
 	}
-	items, total, err := s.Repo.GetOrderItems(ctx, orderID)
+	currency, err := s.Repo.GetOrderCurrency(ctx, orderID)
 	if err != nil {
 		return nil, err
 	}
 	resp := &model.CartResponse{
-		Items: items,
-		Total: total,
+		Items:    items,
+		Subtotal: subtotal,
+		Total:    subtotal,
+		Currency: currency,
+	}
+	if resp.Changes, err = s.changes(ctx, items, currency); err != nil {
+		return nil, err
+	}
+
+	// show the discount line of an applied code; it is only redeemed at checkout
+	promoID, err := s.Repo.GetOrderPromo(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	var perItemDiscount map[int64]money.Amount
+	if promoID != nil {
+		p, d, err := s.Promos.Quote(ctx, *promoID, cid, items, subtotal, currency)
+		if p != nil {
+			resp.PromoCode = &p.Code
+		}
+		if err != nil {
+			resp.PromoError = err.Error()
+		} else {
+			resp.Discount = d.Discount
+			resp.Total = subtotal - d.Discount
+			perItemDiscount = d.PerItem
+		}
+	}
+
+	// estimated tax from the billing address; it is recomputed at checkout
+	addr, err := s.billingAddress(ctx, authID)
+	if err != nil {
+		return nil, err
+	}
+	res, _, err := s.computeTax(ctx, addr, items, perItemDiscount, currency)
+	switch {
+	case errors.Is(err, tax.ErrNoAddress):
+		resp.TaxNote = "add a billing address to see the tax"
+	case err != nil:
+		resp.TaxNote = err.Error()
+	default:
+		resp.Tax = res.Tax
+		resp.TaxName = res.Name
+		resp.TaxInclusive = res.Inclusive
+		if !res.Inclusive {
+			resp.Total += res.Tax
+		}
 	}
 	return resp, nil
 }
 
-func (s *CartService) Checkout(ctx context.Context, authID int64) (int64, error) {
+// ApplyCoupon validates a promo code against the open cart and attaches it to the order
+func (s *CartService) ApplyCoupon(ctx context.Context, authID int64, code string) (*model.CartResponse, error) {
+	if code == "" {
+		return nil, errors.New("code is required")
+	}
+	cid, err := s.Repo.GetCustomerID(ctx, authID)
+	if err != nil {
+		return nil, err
+	}
+	orderID, err := s.Repo.FindOpenOrder(ctx, cid)
+	if err != nil {
+		return nil, errors.New("no open cart")
+	}
+	items, subtotal, err := s.Repo.GetOrderItems(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, errors.New("cart is empty")
+	}
+	currency, err := s.Repo.GetOrderCurrency(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	p, _, err := s.Promos.Check(ctx, code, cid, items, subtotal, currency)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Repo.SetOrderPromo(ctx, orderID, &p.PromoID); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, authID)
+}
+
+// RemoveCoupon detaches the promo code from the open cart
+func (s *CartService) RemoveCoupon(ctx context.Context, authID int64) error {
+	cid, err := s.Repo.GetCustomerID(ctx, authID)
+	if err != nil {
+		return err
+	}
+	orderID, err := s.Repo.FindOpenOrder(ctx, cid)
+	if err != nil {
+		return errors.New("no open cart")
+	}
+	return s.Repo.SetOrderPromo(ctx, orderID, nil)
+}
+
+// sameLines reports whether items are exactly the lines that were validated, with the same
+// game, quantity and (validated) price
+func sameLines(items []model.CartItem, validated map[int64]model.CartItem) bool {
+	if len(items) != len(validated) {
+		return false
+	}
+	for _, it := range items {
+		v, ok := validated[it.OrderItemID]
+		if !ok || v.GameID != it.GameID || v.Quantity != it.Quantity || v.PriceAtPurchase != it.PriceAtPurchase {
+			return false
+		}
+	}
+	return true
+}
+
+// Checkout finalizes the open order, creates a Pending payment for it and charges it
+// through the payment gateway. Ownership of the games is granted once the payment is Paid
+// (see PaymentService); a declined or timed-out charge leaves the order awaiting payment.
+func (s *CartService) Checkout(ctx context.Context, authID int64, req model.CheckoutRequest) (*model.CheckoutResult, error) {
 	// check user exists and not banned
 	u, err := s.AuthRepo.GetByID(ctx, authID)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	if blocked(u, time.Now()) {
+		return nil, errors.New("user is banned")
+	}
+	// unverified accounts can browse and fill their cart, but not buy
+	if u.EmailVerifiedAt == nil {
+		return nil, ErrEmailNotVerified
+	}
+
+	if req.PaymentMethodID <= 0 {
+		return nil, errors.New("payment method is required")
+	}
+	ok, err := s.PaymentRepo.MethodExists(ctx, req.PaymentMethodID)
+	if err != nil {
+		return nil, err
 	}
-	if u.DeletedAt != nil {
-		return 0, errors.New("user is banned")
+	if !ok {
+		return nil, errors.New("payment method not found")
 	}
 
 	// get customer id
 	cid, err := s.Repo.GetCustomerID(ctx, authID)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	// find open order
 	orderID, err := s.Repo.FindOpenOrder(ctx, cid)
 	if err != nil {
-		return 0, errors.New("no open cart")
+		return nil, errors.New("no open cart")
 	}
 
-	// get cart items (cart repo returns items + total)
-	items, total, err := s.Repo.GetOrderItems(ctx, orderID)
+	// get cart items (cart repo returns items + subtotal)
+	items, subtotal, err := s.Repo.GetOrderItems(ctx, orderID)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	if len(items) == 0 {
-		return 0, errors.New("cart is empty")
+		return nil, errors.New("cart is empty")
 	}
 
-	// build gameIDs slice and map gameid->title for error messages
+	// build gameIDs slice and map gameid->title for error messages; gift lines are
+	// checked against their recipient instead of the buyer
 	gameIDs := make([]int64, 0, len(items))
 	gameTitles := make(map[int64]string, len(items))
 	for _, it := range items {
-		gameIDs = append(gameIDs, it.GameID)
 		gameTitles[it.GameID] = it.Title
+		if it.GiftTo != nil {
+			if err := s.ensureGiftable(ctx, *it.GiftTo, it.GameID, it.Title); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		gameIDs = append(gameIDs, it.GameID)
 	}
 
 	// check ownership: if any owned -> reject entire checkout (Option A)
 	ownedGameID, err := s.CustomerGamesRepo.ExistsAnyOwned(ctx, cid, gameIDs)
 	if err != nil {
-		return 0, fmt.Errorf("ownership check failed: %w", err)
+		return nil, fmt.Errorf("ownership check failed: %w", err)
 	}
 	if ownedGameID != 0 {
 		title := gameTitles[ownedGameID]
@@ -173,31 +513,136 @@ func (s *CartService) Checkout(ctx context.Context, authID int64) (int64, error)
 				title = "owned_game"
 			}
 		}
-		return 0, fmt.Errorf("checkout rejected: already own game '%s' (id=%d)", title, ownedGameID)
+		return nil, fmt.Errorf("checkout rejected: already own game '%s' (id=%d)", title, ownedGameID)
+	}
+
+	currency, err := s.Repo.GetOrderCurrency(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	// re-validate every line against the catalog; price changes are only taken over when accepted
+	changes, err := s.changes(ctx, items, currency)
+	if err != nil {
+		return nil, err
+	}
+	for _, ch := range changes {
+		if ch.Reason != model.CartChangePrice || !req.AcceptPriceChanges {
+			return nil, &CartChangedError{Changes: changes}
+		}
+	}
+
+	promoID, err := s.Repo.GetOrderPromo(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := s.billingAddress(ctx, authID)
+	if err != nil {
+		return nil, err
+	}
+	if addr.Country == "" {
+		return nil, errors.New("billing address is required before checkout")
 	}
 
 	// Begin transaction using cart repo's DB
 	tx, err := s.Repo.DB.Begin(ctx)
 	if err != nil {
-		return 0, fmt.Errorf("begin tx: %w", err)
+		return nil, fmt.Errorf("begin tx: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
+	// a second checkout of the same cart waits for this one and then finds the cart closed
+	open, err := s.Repo.LockOpenOrderTx(ctx, tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if !open {
+		return nil, ErrCartChangedDuringCheckout
+	}
+
+	// take over the accepted price changes, then re-read the lines (locked until commit) so
+	// the order is priced from what the tx sees, not from the reads above
+	validated := make(map[int64]model.CartItem, len(items))
+	for _, it := range items {
+		validated[it.OrderItemID] = it
+	}
+	for _, ch := range changes {
+		if err := s.Repo.SetOrderItemPriceTx(ctx, tx, orderID, ch.OrderItemID, *ch.NewPrice); err != nil {
+			return nil, fmt.Errorf("re-price item: %w", err)
+		}
+		it := validated[ch.OrderItemID]
+		it.PriceAtPurchase = *ch.NewPrice
+		validated[ch.OrderItemID] = it
+	}
+	if items, subtotal, err = s.Repo.GetOrderItemsTx(ctx, tx, orderID); err != nil {
+		return nil, err
+	}
+	if !sameLines(items, validated) {
+		return nil, ErrCartChangedDuringCheckout
+	}
+
+	// redeem the promo code, if any (locks the promo row so caps hold)
+	var discount money.Amount
+	var perItemDiscount map[int64]money.Amount
+	if promoID != nil {
+		d, err := s.Promos.RedeemTx(ctx, tx, *promoID, cid, orderID, items, subtotal, currency)
+		if err != nil {
+			return nil, fmt.Errorf("promo code: %w", err)
+		}
+		if err := s.Repo.SetDiscountTx(ctx, tx, orderID, d.Discount, d.PerItem); err != nil {
+			return nil, fmt.Errorf("apply discount: %w", err)
+		}
+		discount = d.Discount
+		perItemDiscount = d.PerItem
+	}
+
+	// tax on the discounted lines; with inclusive pricing it is already part of the price
+	res, lineTotals, err := s.computeTax(ctx, addr, items, perItemDiscount, currency)
+	if err != nil {
+		return nil, fmt.Errorf("compute tax: %w", err)
+	}
+	if err := s.Repo.SetTaxTx(ctx, tx, orderID, subtotal, res, addr); err != nil {
+		return nil, fmt.Errorf("store tax: %w", err)
+	}
+	if err := s.Repo.SetLineTotalsTx(ctx, tx, orderID, res.PerLine, lineTotals); err != nil {
+		return nil, fmt.Errorf("store line totals: %w", err)
+	}
+	total := subtotal - discount
+	if !res.Inclusive {
+		total += res.Tax
+	}
+
 	// 1) finalize order (update totalprice and orderdate) using tx method
 	if err := s.Repo.CheckoutOrderTx(ctx, tx, orderID, total); err != nil {
-		return 0, fmt.Errorf("finalize order: %w", err)
+		return nil, fmt.Errorf("finalize order: %w", err)
 	}
 
-	// 2) insert customer_games (ownership) using tx
-	if err := s.CustomerGamesRepo.CreateCustomerGamesTx(ctx, tx, cid, gameIDs); err != nil {
-		return 0, fmt.Errorf("record ownership: %w", err)
+	// 2) create the Pending payment; ownership is granted when it is Paid
+	paymentID, err := s.PaymentRepo.CreatePaymentTx(ctx, tx, orderID, req.PaymentMethodID, total)
+	if err != nil {
+		return nil, fmt.Errorf("create payment: %w", err)
 	}
 
 	// Commit
 	if err := tx.Commit(ctx); err != nil {
-		return 0, fmt.Errorf("commit tx: %w", err)
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+
+	// 3) charge through the gateway (outside the order tx: the order stays checked out either way)
+	p, err := s.Payments.Charge(ctx, paymentID, req.CardToken)
+	if err != nil {
+		return nil, fmt.Errorf("order %d created but payment failed: %w", orderID, err)
 	}
 
-	// Return finalized orderID
-	return orderID, nil
+	return &model.CheckoutResult{
+		OrderID:       orderID,
+		PaymentID:     paymentID,
+		PaymentStatus: p.PaymentStatus,
+		Subtotal:      subtotal,
+		Discount:      discount,
+		Tax:           res.Tax,
+		TaxInclusive:  res.Inclusive,
+		Total:         total,
+		Currency:      currency,
+	}, nil
 }
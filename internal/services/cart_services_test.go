@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/payment"
+)
+
+func TestCheckoutCharges(t *testing.T) {
+	tests := []struct {
+		token  string
+		status string
+		owned  bool
+	}{
+		{"tok_ok", model.PaymentPaid, true},
+		{"tok_decline", model.PaymentFailed, false},
+		{"tok_timeout", model.PaymentPending, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			s := newTestStore(t)
+			authID, customerID := s.account(t, "bob", "user")
+			gameID := s.game(t, "Dust Protocol", "24.50")
+
+			res, err := s.buy(t, authID, gameID, tt.token)
+			if err != nil {
+				t.Fatalf("checkout: %v", err)
+			}
+			if res.PaymentStatus != tt.status {
+				t.Errorf("payment status = %s, want %s", res.PaymentStatus, tt.status)
+			}
+			if res.Total.Cents() != 2450 {
+				t.Errorf("total = %s, want 24.50", res.Total)
+			}
+			if got := s.owns(t, customerID, gameID); got != tt.owned {
+				t.Errorf("owned = %v, want %v", got, tt.owned)
+			}
+			// the order is checked out whatever the outcome, the cart is empty again
+			cart, err := s.cart.Get(context.Background(), authID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cart.Items) != 0 {
+				t.Errorf("cart has %d items after checkout", len(cart.Items))
+			}
+		})
+	}
+}
+
+func TestCheckoutRejectsOwnedGame(t *testing.T) {
+	s := newTestStore(t)
+	authID, _ := s.account(t, "carol", "user")
+	gameID := s.game(t, "Tidebreaker", "9.99")
+	if _, err := s.buy(t, authID, gameID, "tok_ok"); err != nil {
+		t.Fatalf("first checkout: %v", err)
+	}
+	if _, err := s.buy(t, authID, gameID, "tok_ok"); err == nil {
+		t.Fatal("second checkout of an owned game succeeded")
+	}
+}
+
+func TestWebhookReplay(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	authID, customerID := s.account(t, "dave", "user")
+	gameID := s.game(t, "Lanternfall", "14.00")
+
+	res, err := s.buy(t, authID, gameID, "tok_timeout")
+	if err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+	body, sig, err := s.gateway.Event(payment.EventCaptured, res.PaymentID, res.Total)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// providers deliver at least once: the same event may arrive again
+	for i := 0; i < 3; i++ {
+		if err := s.payments.HandleWebhook(ctx, body, sig); err != nil {
+			t.Fatalf("delivery %d: %v", i+1, err)
+		}
+	}
+	p, err := s.payments.Repo.GetByID(ctx, res.PaymentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.PaymentStatus != model.PaymentPaid {
+		t.Fatalf("payment status = %s, want Paid", p.PaymentStatus)
+	}
+	if !s.owns(t, customerID, gameID) {
+		t.Fatal("game not owned after the captured webhook")
+	}
+	if n := s.queryInt(t, `SELECT count(*) FROM paymentlogs WHERE paymentid=$1 AND newstatus=$2`, res.PaymentID, model.PaymentPaid); n != 1 {
+		t.Fatalf("payment moved to Paid %d times, want once", n)
+	}
+}
+
+func TestWebhookBadSignature(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	authID, customerID := s.account(t, "erin", "user")
+	gameID := s.game(t, "Copper Orchard", "5.00")
+
+	res, err := s.buy(t, authID, gameID, "tok_timeout")
+	if err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+	body, _, err := s.gateway.Event(payment.EventCaptured, res.PaymentID, res.Total)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := payment.SignPayload([]byte("not-the-secret"), body)
+	for name, signature := range map[string]string{"missing": "", "forged": forged, "other payload": payment.SignPayload(webhookSecret, []byte("{}"))} {
+		if err := s.payments.HandleWebhook(ctx, body, signature); !errors.Is(err, payment.ErrInvalidSignature) {
+			t.Errorf("%s signature: got %v, want ErrInvalidSignature", name, err)
+		}
+	}
+	p, err := s.payments.Repo.GetByID(ctx, res.PaymentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.PaymentStatus != model.PaymentPending || s.owns(t, customerID, gameID) {
+		t.Fatalf("payment = %s after rejected webhooks, want it untouched", p.PaymentStatus)
+	}
+}
+
+func TestCheckoutAcceptsPriceChange(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	authID, customerID := s.account(t, "olga", "user")
+	gameID := s.game(t, "Tidewright", "10.00")
+	if err := s.cart.Add(ctx, authID, gameID, 1, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	s.exec(t, `UPDATE games SET price = 12.00 WHERE gameid=$1`, gameID)
+	req := model.CheckoutRequest{PaymentMethodID: s.creditCard(t), CardToken: "tok_ok"}
+
+	var changed *CartChangedError
+	if _, err := s.cart.Checkout(ctx, authID, req); !errors.As(err, &changed) {
+		t.Fatalf("checkout of a re-priced cart: %v, want CartChangedError", err)
+	}
+
+	// an expired promo code fails the checkout tx after the re-pricing: it must roll back too
+	promoID := s.queryInt(t, `INSERT INTO promocodes (code, discounttype, value, expires_at)
+		VALUES ('GONE', 'percent', 10, now() - interval '1 day') RETURNING promoid`)
+	s.exec(t, `UPDATE orders SET promoid=$1 WHERE customerid=$2 AND totalprice IS NULL`, promoID, customerID)
+	req.AcceptPriceChanges = true
+	if _, err := s.cart.Checkout(ctx, authID, req); err == nil {
+		t.Fatal("checkout with an expired promo code succeeded")
+	}
+	if n := s.queryInt(t, `SELECT count(*) FROM orderitems WHERE gameid=$1 AND priceatpurchase = 10.00`, gameID); n != 1 {
+		t.Fatal("the accepted price was kept although the checkout failed")
+	}
+
+	s.exec(t, `UPDATE orders SET promoid=NULL WHERE customerid=$1 AND totalprice IS NULL`, customerID)
+	res, err := s.cart.Checkout(ctx, authID, req)
+	if err != nil {
+		t.Fatalf("checkout accepting the new price: %v", err)
+	}
+	if res.Total.String() != "12.00" || res.PaymentStatus != model.PaymentPaid {
+		t.Fatalf("result = total %s status %s, want 12.00 Paid", res.Total, res.PaymentStatus)
+	}
+}
+
+func TestCheckoutConcurrently(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	authID, customerID := s.account(t, "sven", "user")
+	gameID := s.game(t, "Harbor Nine", "12.00")
+	if err := s.cart.Add(ctx, authID, gameID, 1, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	req := model.CheckoutRequest{PaymentMethodID: s.creditCard(t), CardToken: "tok_ok"}
+
+	ok := 0
+	for _, err := range concurrently(5, func() error {
+		_, err := s.cart.Checkout(ctx, authID, req)
+		return err
+	}) {
+		if err == nil {
+			ok++
+		}
+	}
+	if ok != 1 {
+		t.Fatalf("%d checkouts of one cart went through, want 1", ok)
+	}
+	if n := s.queryInt(t, `SELECT count(*) FROM payments p JOIN orders o ON o.orderid = p.orderid WHERE o.customerid=$1`, customerID); n != 1 {
+		t.Fatalf("payments = %d, want 1", n)
+	}
+}
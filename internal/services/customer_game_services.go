@@ -1,17 +1,21 @@
 package services
 
 import (
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/pagination"
 	"GameStoreAPI/internal/repository"
 	"context"
+	"errors"
 )
 
 type CustomerGamesService struct {
-	Repo     *repository.CustomerGamesRepository
-	CartRepo *repository.CartRepository // for order items
+	Repo        *repository.CustomerGamesRepository
+	CartRepo    *repository.CartRepository // for order items
+	PaymentRepo *repository.PaymentRepository
 }
 
-func NewCustomerGamesService(r *repository.CustomerGamesRepository, cart *repository.CartRepository) *CustomerGamesService {
-	return &CustomerGamesService{Repo: r, CartRepo: cart}
+func NewCustomerGamesService(r *repository.CustomerGamesRepository, cart *repository.CartRepository, pr *repository.PaymentRepository) *CustomerGamesService {
+	return &CustomerGamesService{Repo: r, CartRepo: cart, PaymentRepo: pr}
 }
 
 // Called by checkout flow
@@ -19,22 +23,87 @@ func (s *CustomerGamesService) InsertOwnership(ctx context.Context, customerID i
 	return s.Repo.InsertPurchased(ctx, customerID, gameIDs)
 }
 
-func (s *CustomerGamesService) ListOwned(ctx context.Context, customerID int64) (interface{}, error) {
-	return s.Repo.ListOwnedGames(ctx, customerID)
+func (s *CustomerGamesService) ListOwned(ctx context.Context, customerID int64, p pagination.Params) (*pagination.Page[model.Game], error) {
+	rows, err := s.Repo.ListOwnedGames(ctx, customerID, p)
+	if err != nil {
+		return nil, err
+	}
+	return pagination.NewPage(rows, p, func(g model.Game) int64 { return g.GameID }, func() (int64, error) { return s.Repo.CountOwnedGames(ctx, customerID) })
 }
 
-func (s *CustomerGamesService) ListOrders(ctx context.Context, customerID int64) (interface{}, error) {
-	return s.Repo.ListOrders(ctx, customerID)
+func (s *CustomerGamesService) ListOrders(ctx context.Context, customerID int64, p pagination.Params) (*pagination.Page[model.Order], error) {
+	rows, err := s.Repo.ListOrders(ctx, customerID, p)
+	if err != nil {
+		return nil, err
+	}
+	return pagination.NewPage(rows, p, orderID, func() (int64, error) { return s.Repo.CountOrders(ctx, customerID) })
 }
 
 func (s *CustomerGamesService) OrderDetails(ctx context.Context, customerID, orderID int64) (interface{}, interface{}, error) {
 	return s.Repo.GetOrderDetails(ctx, customerID, orderID)
 }
 
-func (s *CustomerGamesService) ListAllOrders(ctx context.Context) (interface{}, error) {
-	return s.Repo.ListAllOrders(ctx)
+// Receipt returns the subtotal / discount / tax / total breakdown of a checked-out order
+func (s *CustomerGamesService) Receipt(ctx context.Context, customerID, orderID int64) (*model.Receipt, error) {
+	o, items, err := s.Repo.GetOrderDetails(ctx, customerID, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if o.TotalPrice == nil {
+		return nil, errors.New("order not found")
+	}
+	rc := &model.Receipt{
+		OrderID:       o.OrderID,
+		OrderDate:     o.OrderDate,
+		Status:        o.Status,
+		Currency:      o.Currency,
+		Lines:         items,
+		Discount:      o.Discount,
+		TaxName:       o.TaxName,
+		TaxRate:       o.TaxRate,
+		TaxInclusive:  o.TaxInclusive,
+		Tax:           o.TaxAmount,
+		Total:         *o.TotalPrice,
+		RefundedTotal: o.RefundedTotal,
+	}
+	if o.Subtotal != nil {
+		rc.Subtotal = *o.Subtotal
+	} else {
+		// orders placed before tax was recorded
+		for _, it := range items {
+			rc.Subtotal += it.PriceAtPurchase.Mul(it.Quantity)
+		}
+	}
+	if o.BillingCountry != nil {
+		rc.BillingAddress = &model.BillingAddress{Country: *o.BillingCountry}
+		if o.BillingRegion != nil {
+			rc.BillingAddress.Region = *o.BillingRegion
+		}
+		if o.BillingPostalCode != nil {
+			rc.BillingAddress.PostalCode = *o.BillingPostalCode
+		}
+	}
+	if p, err := s.PaymentRepo.GetLatestByOrder(ctx, orderID); err == nil {
+		rc.Payment = p
+	}
+	return rc, nil
 }
 
+func (s *CustomerGamesService) ListAllOrders(ctx context.Context, p pagination.Params) (*pagination.Page[model.Order], error) {
+	rows, err := s.Repo.ListAllOrders(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return pagination.NewPage(rows, p, orderID, func() (int64, error) { return s.Repo.CountAllOrders(ctx) })
+}
+
+func orderID(o model.Order) int64 { return o.OrderID }
+
 func (s *CustomerGamesService) GetOrderDetailsAdmin(ctx context.Context, orderID int64) (interface{}, interface{}, error) {
 	return s.Repo.GetOrderDetailsAdmin(ctx, orderID)
 }
+
+// MarkDownloaded records that the customer downloaded an owned game (which ends self-service refunds)
+func (s *CustomerGamesService) MarkDownloaded(ctx context.Context, customerID, gameID int64) error {
+	return s.Repo.MarkDownloaded(ctx, customerID, gameID)
+}
@@ -3,18 +3,21 @@ package services
 import (
 	"context"
 	"errors"
+	"strings"
 
 	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/pagination"
 	"GameStoreAPI/internal/repository"
 )
 
 type CustomerService struct {
 	Customers *repository.CustomerRepository
 	Users     *repository.AuthRepository
+	Pricing   *PricingService
 }
 
-func NewCustomerService(cr *repository.CustomerRepository, ur *repository.AuthRepository) *CustomerService {
-	return &CustomerService{Customers: cr, Users: ur}
+func NewCustomerService(cr *repository.CustomerRepository, ur *repository.AuthRepository, ps *PricingService) *CustomerService {
+	return &CustomerService{Customers: cr, Users: ur, Pricing: ps}
 }
 
 // CreateForNewUser creates a customer row for a newly-registered public user.
@@ -31,6 +34,15 @@ func (s *CustomerService) CreateForNewUser(ctx context.Context, authID int64, em
 	return s.Customers.Create(ctx, authID, email)
 }
 
+// ListUsers returns a page of the accounts with role "user"
+func (s *CustomerService) ListUsers(ctx context.Context, p pagination.Params) (*pagination.Page[repository.MinimalUser], error) {
+	rows, err := s.Users.ListUsersOnly(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return pagination.NewPage(rows, p, func(u repository.MinimalUser) int64 { return u.AuthID }, func() (int64, error) { return s.Users.CountUsersOnly(ctx) })
+}
+
 func (s *CustomerService) GetByAuthID(ctx context.Context, authID int64) (*model.Customer, error) {
 	return s.Customers.GetByAuthID(ctx, authID)
 }
@@ -39,6 +51,39 @@ func (s *CustomerService) UpdateSelf(ctx context.Context, customerID int64, full
 	return s.Customers.Update(ctx, customerID, fullname, address, phone)
 }
 
-func (s *CustomerService) BanUser(ctx context.Context, authID int64) error {
-	return s.Users.BanUser(ctx, authID)
+// UpdatePreferences sets the region and preferred currency used for pricing.
+// A nil value leaves the preference unchanged, an empty string clears it.
+func (s *CustomerService) UpdatePreferences(ctx context.Context, customerID int64, region, currency *string) error {
+	if region != nil {
+		r := strings.ToUpper(strings.TrimSpace(*region))
+		if len(r) > 10 {
+			return errors.New("region must be at most 10 characters")
+		}
+		region = &r
+	}
+	if currency != nil && strings.TrimSpace(*currency) != "" {
+		code, err := s.Pricing.Resolve(ctx, *currency)
+		if err != nil {
+			return err
+		}
+		currency = &code
+	}
+	return s.Customers.UpdatePreferences(ctx, customerID, region, currency)
+}
+
+// UpdateBillingAddress sets the billing address that determines the tax applied at checkout
+func (s *CustomerService) UpdateBillingAddress(ctx context.Context, customerID int64, addr model.BillingAddress) error {
+	addr.Country = strings.ToUpper(strings.TrimSpace(addr.Country))
+	addr.Region = strings.ToUpper(strings.TrimSpace(addr.Region))
+	addr.PostalCode = strings.TrimSpace(addr.PostalCode)
+	if len(addr.Country) != 2 {
+		return errors.New("country must be a 2-letter ISO code")
+	}
+	if len(addr.Region) > 50 {
+		return errors.New("region must be at most 50 characters")
+	}
+	if len(addr.PostalCode) > 20 {
+		return errors.New("postalcode must be at most 20 characters")
+	}
+	return s.Customers.UpdateBillingAddress(ctx, customerID, addr)
 }
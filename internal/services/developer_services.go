@@ -3,20 +3,38 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strconv"
 	"strings"
 
 	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/pagination"
 	"GameStoreAPI/internal/repository"
 )
 
+var (
+	ErrNotTeamMember  = errors.New("you are not a member of this developer")
+	ErrNotTeamManager = errors.New("only owners and admins of the developer can manage its team")
+	ErrNotTeamOwner   = errors.New("only owners of the developer can manage its owners")
+
+	errLastOwner = errors.New("a developer needs at least one owner: make someone else owner first")
+)
+
 type DeveloperService struct {
-	Repo *repository.DeveloperRepository
+	Repo         *repository.DeveloperRepository
+	Members      *repository.DeveloperMemberRepository
+	Applications *repository.DeveloperApplicationRepository
+	Users        *repository.AuthRepository
+	Roles        *repository.RoleRepository
+	Audit        *repository.AuditRepository
 }
 
-func NewDeveloperService(r *repository.DeveloperRepository) *DeveloperService {
-	return &DeveloperService{Repo: r}
+func NewDeveloperService(r *repository.DeveloperRepository, mr *repository.DeveloperMemberRepository, apr *repository.DeveloperApplicationRepository,
+	ur *repository.AuthRepository, rr *repository.RoleRepository, ar *repository.AuditRepository) *DeveloperService {
+	return &DeveloperService{Repo: r, Members: mr, Applications: apr, Users: ur, Roles: rr, Audit: ar}
 }
 
+// CreateDeveloper creates a developer; a linked account becomes its owner
 func (s *DeveloperService) CreateDeveloper(ctx context.Context, name string, authID *int64) (int64, error) {
 	name = strings.TrimSpace(name)
 	if name == "" {
@@ -29,17 +47,40 @@ func (s *DeveloperService) CreateDeveloper(ctx context.Context, name string, aut
 	if exists {
 		return 0, errors.New("developer with this name already exists")
 	}
-	return s.Repo.CreateDeveloper(ctx, name, authID)
+	tx, err := s.Repo.DB.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	id, err := s.Repo.CreateDeveloperTx(ctx, tx, name, authID)
+	if err != nil {
+		return 0, err
+	}
+	if authID != nil {
+		if err := s.Members.AddTx(ctx, tx, id, *authID, model.DevRoleOwner, nil); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit tx: %w", err)
+	}
+	return id, nil
 }
 
 func (s *DeveloperService) GetDeveloper(ctx context.Context, id int64) (*model.Developer, error) {
 	return s.Repo.GetByID(ctx, id)
 }
 
-func (s *DeveloperService) ListDevelopers(ctx context.Context) ([]model.Developer, error) {
-	return s.Repo.GetAll(ctx)
+func (s *DeveloperService) ListDevelopers(ctx context.Context, p pagination.Params) (*pagination.Page[model.Developer], error) {
+	rows, err := s.Repo.GetAll(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return pagination.NewPage(rows, p, func(d model.Developer) int64 { return d.DeveloperID }, func() (int64, error) { return s.Repo.Count(ctx) })
 }
 
+// UpdateDeveloper renames a developer; a linked account is made an owner of it
 func (s *DeveloperService) UpdateDeveloper(ctx context.Context, id int64, name string, authID *int64) error {
 	name = strings.TrimSpace(name)
 	if name == "" {
@@ -47,9 +88,399 @@ func (s *DeveloperService) UpdateDeveloper(ctx context.Context, id int64, name s
 	}
 	// optional: check uniqueness (skip if same as current)
 	// For simplicity, check name exists and is not the same id is left to DB constraints or frontend
-	return s.Repo.UpdateDeveloper(ctx, id, name, authID)
+	tx, err := s.Repo.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.Repo.UpdateDeveloperTx(ctx, tx, id, name, authID); err != nil {
+		return err
+	}
+	if authID != nil {
+		if err := s.Members.AddTx(ctx, tx, id, *authID, model.DevRoleOwner, nil); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
 }
 
 func (s *DeveloperService) DeleteDeveloper(ctx context.Context, id int64) error {
 	return s.Repo.DeleteDeveloper(ctx, id)
 }
+
+// Apply records an account's application to become a developer. Only one application
+// can wait for review at a time.
+func (s *DeveloperService) Apply(ctx context.Context, authID int64, name, website, message string) (*model.DeveloperApplication, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("developer name is required")
+	}
+	if len(name) > 150 {
+		return nil, errors.New("developer name must be at most 150 characters")
+	}
+	website = strings.TrimSpace(website)
+	if website != "" {
+		if len(website) > 255 {
+			return nil, errors.New("website must be at most 255 characters")
+		}
+		if !strings.HasPrefix(website, "https://") && !strings.HasPrefix(website, "http://") {
+			return nil, errors.New("website must be an http(s) URL")
+		}
+	}
+	message = strings.TrimSpace(message)
+	if len(message) > 2000 {
+		return nil, errors.New("message must be at most 2000 characters")
+	}
+	exists, err := s.Repo.NameExists(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, errors.New("developer with this name already exists")
+	}
+	pending, err := s.Applications.HasPending(ctx, authID)
+	if err != nil {
+		return nil, err
+	}
+	if pending {
+		return nil, errors.New("you already have an application waiting for review")
+	}
+	a := &model.DeveloperApplication{AuthID: authID, DeveloperName: name, Website: optionalString(website), Message: optionalString(message)}
+	if err := s.Applications.Create(ctx, a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// MyApplications returns the applications of an account, newest first
+func (s *DeveloperService) MyApplications(ctx context.Context, authID int64, p pagination.Params) (*pagination.Page[model.DeveloperApplication], error) {
+	rows, err := s.Applications.ListByAuthID(ctx, authID, p)
+	if err != nil {
+		return nil, err
+	}
+	return pagination.NewPage(rows, p, applicationID, func() (int64, error) { return s.Applications.CountByAuthID(ctx, authID) })
+}
+
+// ListApplications returns applications, optionally only those with a status (admin use)
+func (s *DeveloperService) ListApplications(ctx context.Context, status string, p pagination.Params) (*pagination.Page[model.DeveloperApplication], error) {
+	switch status {
+	case "", model.DevApplicationPending, model.DevApplicationApproved, model.DevApplicationRejected:
+	default:
+		return nil, errors.New("status must be pending, approved or rejected")
+	}
+	rows, err := s.Applications.ListAll(ctx, status, p)
+	if err != nil {
+		return nil, err
+	}
+	return pagination.NewPage(rows, p, applicationID, func() (int64, error) { return s.Applications.CountAll(ctx, status) })
+}
+
+func applicationID(a model.DeveloperApplication) int64 { return a.ApplicationID }
+
+// ApproveApplication creates the developer applied for with the applicant as its owner.
+// Customer accounts are given the developer role; other roles are left as they are.
+func (s *DeveloperService) ApproveApplication(ctx context.Context, id, reviewerID int64, comment, ip string) (*model.DeveloperApplication, error) {
+	a, err := s.Applications.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if a.Status != model.DevApplicationPending {
+		return nil, errors.New("application already reviewed")
+	}
+	exists, err := s.Repo.NameExists(ctx, a.DeveloperName)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, errors.New("developer with this name already exists")
+	}
+	tx, err := s.Repo.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	devID, err := s.Repo.CreateDeveloperTx(ctx, tx, a.DeveloperName, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Members.AddTx(ctx, tx, devID, a.AuthID, model.DevRoleOwner, &reviewerID); err != nil {
+		return nil, err
+	}
+	if err := s.Roles.PromoteTx(ctx, tx, a.AuthID, "user", "developer"); err != nil {
+		return nil, err
+	}
+	if err := s.Applications.ReviewTx(ctx, tx, id, model.DevApplicationApproved, &devID, reviewerID, optionalString(strings.TrimSpace(comment))); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+	detail := fmt.Sprintf("application %d: developer %d (%s)", id, devID, a.DeveloperName)
+	if err := s.audit(ctx, reviewerID, model.AuditDevApplicationApproved, a.AuthID, detail, ip); err != nil {
+		return nil, err
+	}
+	return s.Applications.GetByID(ctx, id)
+}
+
+// RejectApplication turns an application down; the applicant may apply again
+func (s *DeveloperService) RejectApplication(ctx context.Context, id, reviewerID int64, comment, ip string) error {
+	a, err := s.Applications.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	tx, err := s.Repo.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.Applications.ReviewTx(ctx, tx, id, model.DevApplicationRejected, nil, reviewerID, optionalString(strings.TrimSpace(comment))); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return s.audit(ctx, reviewerID, model.AuditDevApplicationRejected, a.AuthID, fmt.Sprintf("application %d (%s)", id, a.DeveloperName), ip)
+}
+
+// Memberships returns the developers an account is on the team of
+func (s *DeveloperService) Memberships(ctx context.Context, authID int64) ([]model.DeveloperMembership, error) {
+	return s.Members.ListByAuthID(ctx, authID)
+}
+
+// teamRole returns the team role the actor acts with on a developer. Callers allowed to
+// manage any developer (developers:manage) act as owners.
+func (s *DeveloperService) teamRole(ctx context.Context, developerID, actorAuthID int64, manageAny bool) (string, error) {
+	dev, err := s.Repo.GetByID(ctx, developerID)
+	if err != nil || dev.DeletedAt != nil {
+		return "", errors.New("developer not found")
+	}
+	if manageAny {
+		return model.DevRoleOwner, nil
+	}
+	role, err := s.Members.RoleOf(ctx, developerID, actorAuthID)
+	if err != nil {
+		return "", err
+	}
+	if role == "" {
+		return "", ErrNotTeamMember
+	}
+	return role, nil
+}
+
+func validTeamRole(role string) bool {
+	return role == model.DevRoleOwner || role == model.DevRoleAdmin || role == model.DevRoleMember
+}
+
+// ListMembers returns the team of a developer; any member may see it
+func (s *DeveloperService) ListMembers(ctx context.Context, developerID, actorAuthID int64, manageAny bool, p pagination.Params) (*pagination.Page[model.DeveloperMember], error) {
+	if _, err := s.teamRole(ctx, developerID, actorAuthID, manageAny); err != nil {
+		return nil, err
+	}
+	if p.After != nil && p.AfterKey == "" {
+		return nil, pagination.ErrInvalidCursor
+	}
+	rows, err := s.Members.List(ctx, developerID, p)
+	if err != nil {
+		return nil, err
+	}
+	return pagination.NewKeyedPage(rows, p, repository.MemberKey, func() (int64, error) { return s.Members.Count(ctx, developerID) })
+}
+
+// InviteMember invites the account with the given email to the team of a developer; it joins
+// when it accepts (AcceptInvite). Owners and admins invite members and admins, only owners
+// invite owners. Inviting an account again replaces its open invite.
+func (s *DeveloperService) InviteMember(ctx context.Context, developerID, actorAuthID int64, manageAny bool, email, role, ip string) (*model.DeveloperInvite, error) {
+	if role == "" {
+		role = model.DevRoleMember
+	}
+	if !validTeamRole(role) {
+		return nil, errors.New("role must be owner, admin or member")
+	}
+	actorRole, err := s.teamRole(ctx, developerID, actorAuthID, manageAny)
+	if err != nil {
+		return nil, err
+	}
+	if actorRole == model.DevRoleMember {
+		return nil, ErrNotTeamManager
+	}
+	if role == model.DevRoleOwner && actorRole != model.DevRoleOwner {
+		return nil, ErrNotTeamOwner
+	}
+	u, err := s.Users.GetByEmail(ctx, accountKey(email))
+	if err != nil || u.DeletedAt != nil {
+		return nil, errors.New("user not found")
+	}
+	existing, err := s.Members.RoleOf(ctx, developerID, u.AuthID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != "" {
+		return nil, errors.New("account is already a member of this developer")
+	}
+	if err := s.Members.Invite(ctx, developerID, u.AuthID, role, actorAuthID); err != nil {
+		return nil, err
+	}
+	if err := s.audit(ctx, actorAuthID, model.AuditDevMemberInvited, u.AuthID, fmt.Sprintf("developer %d as %s", developerID, role), ip); err != nil {
+		return nil, err
+	}
+	return &model.DeveloperInvite{DeveloperID: developerID, AuthID: u.AuthID, Email: u.Email, Role: role, InvitedBy: &actorAuthID}, nil
+}
+
+// Invites returns the open invites of an account
+func (s *DeveloperService) Invites(ctx context.Context, authID int64) ([]model.DeveloperInvite, error) {
+	return s.Members.ListInvitesByAuthID(ctx, authID)
+}
+
+// AcceptInvite puts the account on the team with the role it was invited with. Customer
+// accounts are given the developer role so they can manage the developer's games.
+func (s *DeveloperService) AcceptInvite(ctx context.Context, developerID, authID int64, ip string) (*model.DeveloperMember, error) {
+	if d, err := s.Repo.GetByID(ctx, developerID); err != nil || d.DeletedAt != nil {
+		return nil, errors.New("invite not found")
+	}
+	tx, err := s.Repo.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	inv, err := s.Members.TakeInviteTx(ctx, tx, developerID, authID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Members.AddTx(ctx, tx, developerID, authID, inv.Role, inv.InvitedBy); err != nil {
+		return nil, err
+	}
+	if err := s.Roles.PromoteTx(ctx, tx, authID, "user", "developer"); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+	if err := s.audit(ctx, authID, model.AuditDevMemberAdded, authID, fmt.Sprintf("developer %d as %s", developerID, inv.Role), ip); err != nil {
+		return nil, err
+	}
+	return &model.DeveloperMember{DeveloperID: developerID, AuthID: authID, Role: inv.Role, AddedBy: inv.InvitedBy}, nil
+}
+
+// DeclineInvite turns down an open invite
+func (s *DeveloperService) DeclineInvite(ctx context.Context, developerID, authID int64) error {
+	return s.Members.DeleteInvite(ctx, developerID, authID)
+}
+
+// UpdateMember changes the team role of a member. Owners and admins manage members and
+// admins, only owners promote to or demote from owner; the last owner stays an owner.
+func (s *DeveloperService) UpdateMember(ctx context.Context, developerID, actorAuthID int64, manageAny bool, authID int64, role, ip string) error {
+	if !validTeamRole(role) {
+		return errors.New("role must be owner, admin or member")
+	}
+	actorRole, err := s.teamRole(ctx, developerID, actorAuthID, manageAny)
+	if err != nil {
+		return err
+	}
+	if actorRole == model.DevRoleMember {
+		return ErrNotTeamManager
+	}
+	current, err := s.Members.RoleOf(ctx, developerID, authID)
+	if err != nil {
+		return err
+	}
+	if current == "" {
+		return errors.New("member not found")
+	}
+	if current == role {
+		return nil
+	}
+	if (current == model.DevRoleOwner || role == model.DevRoleOwner) && actorRole != model.DevRoleOwner {
+		return ErrNotTeamOwner
+	}
+	tx, err := s.Repo.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if current == model.DevRoleOwner {
+		owners, err := s.Members.CountOwnersTx(ctx, tx, developerID)
+		if err != nil {
+			return err
+		}
+		if owners <= 1 {
+			return errLastOwner
+		}
+	}
+	if err := s.Members.UpdateRoleTx(ctx, tx, developerID, authID, role); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return s.audit(ctx, actorAuthID, model.AuditDevMemberUpdated, authID, fmt.Sprintf("developer %d: %s -> %s", developerID, current, role), ip)
+}
+
+// RemoveMember takes an account off the team of a developer. Members may always leave;
+// removing others follows the rules of UpdateMember, and the last owner cannot go. An
+// account left on no team loses the developer role again (other roles are left as they are).
+func (s *DeveloperService) RemoveMember(ctx context.Context, developerID, actorAuthID int64, manageAny bool, authID int64, ip string) error {
+	actorRole, err := s.teamRole(ctx, developerID, actorAuthID, manageAny)
+	if err != nil {
+		return err
+	}
+	current, err := s.Members.RoleOf(ctx, developerID, authID)
+	if err != nil {
+		return err
+	}
+	if current == "" {
+		return errors.New("member not found")
+	}
+	if authID != actorAuthID {
+		if actorRole == model.DevRoleMember {
+			return ErrNotTeamManager
+		}
+		if current == model.DevRoleOwner && actorRole != model.DevRoleOwner {
+			return ErrNotTeamOwner
+		}
+	}
+	tx, err := s.Repo.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if current == model.DevRoleOwner {
+		owners, err := s.Members.CountOwnersTx(ctx, tx, developerID)
+		if err != nil {
+			return err
+		}
+		if owners <= 1 {
+			return errLastOwner
+		}
+	}
+	if err := s.Members.RemoveTx(ctx, tx, developerID, authID); err != nil {
+		return err
+	}
+	teams, err := s.Members.CountByAuthIDTx(ctx, tx, authID)
+	if err != nil {
+		return err
+	}
+	if teams == 0 {
+		if err := s.Roles.PromoteTx(ctx, tx, authID, "developer", "user"); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return s.audit(ctx, actorAuthID, model.AuditDevMemberRemoved, authID, fmt.Sprintf("developer %d (was %s)", developerID, current), ip)
+}
+
+func (s *DeveloperService) audit(ctx context.Context, actorAuthID int64, event string, subjectAuthID int64, detail, ip string) error {
+	subject := "authid:" + strconv.FormatInt(subjectAuthID, 10)
+	return s.Audit.Record(ctx, &model.AuditEntry{ActorAuthID: &actorAuthID, Event: event, Subject: &subject,
+		Detail: optionalString(detail), IPAddress: optionalString(ip)})
+}
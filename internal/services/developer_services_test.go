@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/repository"
+)
+
+// newTeamTest returns a store with a developer owned by a developer account "owner"
+func newTeamTest(t *testing.T) (*testStore, *DeveloperService, int64, int64) {
+	t.Helper()
+	s := newTestStore(t)
+	ds := NewDeveloperService(repository.NewDeveloperRepository(s.pool), repository.NewDeveloperMemberRepository(s.pool),
+		repository.NewDeveloperApplicationRepository(s.pool), repository.NewAuthRepository(s.pool), repository.NewRoleRepository(s.pool),
+		repository.NewAuditRepository(s.pool))
+	ownerID, _ := s.account(t, "owner", "developer")
+	devID, err := ds.CreateDeveloper(context.Background(), "Kestrel Works", &ownerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, ds, devID, ownerID
+}
+
+// join invites the account to the team with the role and accepts the invite
+func join(t *testing.T, ds *DeveloperService, devID, inviterID int64, email, role string) {
+	t.Helper()
+	ctx := context.Background()
+	inv, err := ds.InviteMember(ctx, devID, inviterID, false, email, role, "")
+	if err != nil {
+		t.Fatalf("invite %s: %v", email, err)
+	}
+	if _, err := ds.AcceptInvite(ctx, devID, inv.AuthID, ""); err != nil {
+		t.Fatalf("accept %s: %v", email, err)
+	}
+}
+
+func (s *testStore) accountRole(t *testing.T, authID int64) string {
+	t.Helper()
+	var role string
+	if err := s.pool.QueryRow(context.Background(), `SELECT role FROM userauth WHERE authid=$1`, authID).Scan(&role); err != nil {
+		t.Fatal(err)
+	}
+	return role
+}
+
+func TestInviteMember(t *testing.T) {
+	s, ds, devID, ownerID := newTeamTest(t)
+	ctx := context.Background()
+	userID, _ := s.account(t, "dina", "user")
+
+	inv, err := ds.InviteMember(ctx, devID, ownerID, false, "dina@example.com", model.DevRoleAdmin, "")
+	if err != nil {
+		t.Fatalf("invite: %v", err)
+	}
+	// nothing changes before the account accepts
+	if role, _ := ds.Members.RoleOf(ctx, devID, userID); role != "" {
+		t.Fatalf("invited account is already a %s", role)
+	}
+	if role := s.accountRole(t, userID); role != "user" {
+		t.Fatalf("invited account has role %s before accepting", role)
+	}
+	invites, err := ds.Invites(ctx, userID)
+	if err != nil || len(invites) != 1 || invites[0].Role != model.DevRoleAdmin {
+		t.Fatalf("invites = %+v (%v), want the admin invite", invites, err)
+	}
+
+	if _, err := ds.AcceptInvite(ctx, devID, inv.AuthID, ""); err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	if role, _ := ds.Members.RoleOf(ctx, devID, userID); role != model.DevRoleAdmin {
+		t.Fatalf("team role = %q, want admin", role)
+	}
+	if role := s.accountRole(t, userID); role != "developer" {
+		t.Fatalf("account role = %s after accepting, want developer", role)
+	}
+	if _, err := ds.AcceptInvite(ctx, devID, userID, ""); err == nil {
+		t.Fatal("an invite was accepted twice")
+	}
+}
+
+func TestDeclineInvite(t *testing.T) {
+	s, ds, devID, ownerID := newTeamTest(t)
+	ctx := context.Background()
+	userID, _ := s.account(t, "eli", "user")
+
+	if _, err := ds.InviteMember(ctx, devID, ownerID, false, "eli@example.com", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.DeclineInvite(ctx, devID, userID); err != nil {
+		t.Fatalf("decline: %v", err)
+	}
+	if _, err := ds.AcceptInvite(ctx, devID, userID, ""); err == nil {
+		t.Fatal("a declined invite was accepted")
+	}
+	if role := s.accountRole(t, userID); role != "user" {
+		t.Fatalf("account role = %s, want user", role)
+	}
+}
+
+func TestInviteOwnershipRules(t *testing.T) {
+	s, ds, devID, ownerID := newTeamTest(t)
+	ctx := context.Background()
+	s.account(t, "fay", "user")
+	s.account(t, "gus", "user")
+	s.account(t, "hal", "user")
+	join(t, ds, devID, ownerID, "fay@example.com", model.DevRoleAdmin)
+	join(t, ds, devID, ownerID, "gus@example.com", model.DevRoleMember)
+	adminID := s.queryInt(t, `SELECT authid FROM userauth WHERE email='fay@example.com'`)
+	memberID := s.queryInt(t, `SELECT authid FROM userauth WHERE email='gus@example.com'`)
+
+	tests := []struct {
+		name      string
+		actor     int64
+		manageAny bool
+		role      string
+		want      error
+	}{
+		{"member invites", memberID, false, model.DevRoleMember, ErrNotTeamManager},
+		{"admin invites owner", adminID, false, model.DevRoleOwner, ErrNotTeamOwner},
+		{"admin invites admin", adminID, false, model.DevRoleAdmin, nil},
+		{"owner invites owner", ownerID, false, model.DevRoleOwner, nil},
+		{"developers:manage invites owner", memberID, true, model.DevRoleOwner, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ds.InviteMember(ctx, devID, tt.actor, tt.manageAny, "hal@example.com", tt.role, "")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if err := ds.UpdateMember(ctx, devID, adminID, false, memberID, model.DevRoleOwner, ""); !errors.Is(err, ErrNotTeamOwner) {
+		t.Fatalf("admin promotes to owner: %v, want ErrNotTeamOwner", err)
+	}
+	if err := ds.RemoveMember(ctx, devID, adminID, false, ownerID, ""); !errors.Is(err, ErrNotTeamOwner) {
+		t.Fatalf("admin removes the owner: %v, want ErrNotTeamOwner", err)
+	}
+}
+
+func TestLastOwnerStays(t *testing.T) {
+	s, ds, devID, ownerID := newTeamTest(t)
+	ctx := context.Background()
+
+	if err := ds.RemoveMember(ctx, devID, ownerID, false, ownerID, ""); !errors.Is(err, errLastOwner) {
+		t.Fatalf("last owner leaves: %v, want errLastOwner", err)
+	}
+	if err := ds.UpdateMember(ctx, devID, ownerID, false, ownerID, model.DevRoleAdmin, ""); !errors.Is(err, errLastOwner) {
+		t.Fatalf("last owner steps down: %v, want errLastOwner", err)
+	}
+
+	s.account(t, "ida", "user")
+	join(t, ds, devID, ownerID, "ida@example.com", model.DevRoleOwner)
+	if err := ds.RemoveMember(ctx, devID, ownerID, false, ownerID, ""); err != nil {
+		t.Fatalf("owner leaves with another owner left: %v", err)
+	}
+}
+
+func TestRemoveMemberDemotes(t *testing.T) {
+	s, ds, devID, ownerID := newTeamTest(t)
+	ctx := context.Background()
+	otherDevID, err := ds.CreateDeveloper(ctx, "Heron Forge", &ownerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID, _ := s.account(t, "jon", "user")
+	join(t, ds, devID, ownerID, "jon@example.com", model.DevRoleMember)
+	join(t, ds, otherDevID, ownerID, "jon@example.com", model.DevRoleMember)
+
+	if err := ds.RemoveMember(ctx, devID, ownerID, false, userID, ""); err != nil {
+		t.Fatal(err)
+	}
+	if role := s.accountRole(t, userID); role != "developer" {
+		t.Fatalf("account role = %s while still on a team, want developer", role)
+	}
+	// leaving the last team gives the customer account its old role back
+	if err := ds.RemoveMember(ctx, otherDevID, userID, false, userID, ""); err != nil {
+		t.Fatal(err)
+	}
+	if role := s.accountRole(t, userID); role != "user" {
+		t.Fatalf("account role = %s after leaving every team, want user", role)
+	}
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"GameStoreAPI/internal/model"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// languageTagRegex accepts simple BCP 47 tags such as "en", "pt-BR" or "zh-Hans"
+var languageTagRegex = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+const (
+	maxBlurbLength       = 300
+	maxDescriptions      = 30
+	maxMedia             = 50
+	maxLanguages         = 100
+	maxMediaURLLength    = 2000
+	maxRequirementLength = 200
+	maxRequirementNotes  = 500
+)
+
+// cleanMetadata validates the blurb, age ratings, localized descriptions, media, system
+// requirements and languages of a game, trimming text and numbering media in list order
+func cleanMetadata(g *model.Game) error {
+	if g.Blurb != nil {
+		b := strings.TrimSpace(*g.Blurb)
+		switch {
+		case b == "":
+			g.Blurb = nil
+		case len(b) > maxBlurbLength:
+			return fmt.Errorf("blurb must be at most %d characters", maxBlurbLength)
+		default:
+			g.Blurb = &b
+		}
+	}
+	if g.PEGIRating != nil {
+		switch *g.PEGIRating {
+		case 3, 7, 12, 16, 18:
+		default:
+			return errors.New("pegirating must be 3, 7, 12, 16 or 18")
+		}
+	}
+	if g.ESRBRating != nil {
+		r := strings.ToUpper(strings.TrimSpace(*g.ESRBRating))
+		switch r {
+		case "":
+			g.ESRBRating = nil
+		case "E", "E10+", "T", "M", "AO", "RP":
+			g.ESRBRating = &r
+		default:
+			return errors.New("esrbrating must be E, E10+, T, M, AO or RP")
+		}
+	}
+	if err := cleanDescriptions(g.Descriptions); err != nil {
+		return err
+	}
+	if err := cleanMedia(g.Media); err != nil {
+		return err
+	}
+	if err := cleanRequirements(g.Requirements); err != nil {
+		return err
+	}
+	return cleanLanguages(g.Languages)
+}
+
+func cleanDescriptions(list []model.GameDescription) error {
+	if len(list) > maxDescriptions {
+		return fmt.Errorf("at most %d localized descriptions are allowed", maxDescriptions)
+	}
+	seen := map[string]bool{}
+	for i := range list {
+		d := &list[i]
+		d.Locale = strings.TrimSpace(d.Locale)
+		if !languageTagRegex.MatchString(d.Locale) {
+			return fmt.Errorf("invalid description locale %q", d.Locale)
+		}
+		if seen[strings.ToLower(d.Locale)] {
+			return fmt.Errorf("duplicate description locale %q", d.Locale)
+		}
+		seen[strings.ToLower(d.Locale)] = true
+		d.Description = strings.TrimSpace(d.Description)
+		if d.Description == "" {
+			return fmt.Errorf("description for %q is empty", d.Locale)
+		}
+		if len(d.Description) > 20000 {
+			return errors.New("description must be at most 20000 characters")
+		}
+	}
+	return nil
+}
+
+func cleanMedia(list []model.GameMedia) error {
+	if len(list) > maxMedia {
+		return fmt.Errorf("at most %d media are allowed", maxMedia)
+	}
+	covers := 0
+	for i := range list {
+		m := &list[i]
+		switch m.Kind {
+		case model.MediaCover:
+			covers++
+		case model.MediaScreenshot, model.MediaTrailer:
+		default:
+			return errors.New("media kind must be cover, screenshot or trailer")
+		}
+		m.URL = strings.TrimSpace(m.URL)
+		if !validMediaURL(m.URL) {
+			return fmt.Errorf("invalid media url %q", m.URL)
+		}
+		m.ThumbnailURL, m.Width, m.Height = nil, nil, nil
+		m.MediaID = 0
+		m.Position = i
+	}
+	if covers > 1 {
+		return errors.New("a game has at most one cover")
+	}
+	return nil
+}
+
+// validMediaURL accepts http(s) URLs and paths on this server (uploads in a local store)
+func validMediaURL(s string) bool {
+	if len(s) > maxMediaURLLength {
+		return false
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	if u.Scheme == "" && u.Host == "" {
+		return strings.HasPrefix(s, "/") && !strings.HasPrefix(s, "//")
+	}
+	return (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
+
+func cleanRequirements(list []model.SystemRequirement) error {
+	seen := map[string]bool{}
+	for i := range list {
+		q := &list[i]
+		switch q.Platform {
+		case model.PlatformWindows, model.PlatformMacOS, model.PlatformLinux:
+		default:
+			return errors.New("requirement platform must be windows, macos or linux")
+		}
+		switch q.Tier {
+		case model.RequirementMinimum, model.RequirementRecommended:
+		default:
+			return errors.New("requirement tier must be minimum or recommended")
+		}
+		key := q.Platform + "/" + q.Tier
+		if seen[key] {
+			return fmt.Errorf("duplicate %s requirements for %s", q.Tier, q.Platform)
+		}
+		seen[key] = true
+
+		set := false
+		for _, f := range []**string{&q.OS, &q.Processor, &q.Memory, &q.Graphics, &q.Storage, &q.Notes} {
+			if *f == nil {
+				continue
+			}
+			v := strings.TrimSpace(**f)
+			if v == "" {
+				*f = nil
+				continue
+			}
+			max := maxRequirementLength
+			if f == &q.Notes {
+				max = maxRequirementNotes
+			}
+			if len(v) > max {
+				return fmt.Errorf("%s requirements for %s: values must be at most %d characters", q.Tier, q.Platform, max)
+			}
+			*f = &v
+			set = true
+		}
+		if !set {
+			return fmt.Errorf("%s requirements for %s are empty", q.Tier, q.Platform)
+		}
+	}
+	return nil
+}
+
+func cleanLanguages(list []model.GameLanguage) error {
+	if len(list) > maxLanguages {
+		return fmt.Errorf("at most %d languages are allowed", maxLanguages)
+	}
+	seen := map[string]bool{}
+	for i := range list {
+		l := &list[i]
+		l.Language = strings.TrimSpace(l.Language)
+		if !languageTagRegex.MatchString(l.Language) {
+			return fmt.Errorf("invalid language %q", l.Language)
+		}
+		if seen[strings.ToLower(l.Language)] {
+			return fmt.Errorf("duplicate language %q", l.Language)
+		}
+		seen[strings.ToLower(l.Language)] = true
+		if !l.Interface && !l.Audio && !l.Subtitles {
+			return fmt.Errorf("language %q must support interface, audio or subtitles", l.Language)
+		}
+	}
+	return nil
+}
+
+// saveMetadataTx stores the metadata collections of a game that are not nil
+func (s *GameService) saveMetadataTx(ctx context.Context, tx pgx.Tx, gameID int64, g *model.Game) error {
+	if g.Descriptions != nil {
+		if err := s.Repo.ReplaceDescriptionsTx(ctx, tx, gameID, g.Descriptions); err != nil {
+			return err
+		}
+	}
+	if g.Media != nil {
+		if err := s.Repo.ReplaceMediaTx(ctx, tx, gameID, g.Media); err != nil {
+			return err
+		}
+	}
+	if g.Requirements != nil {
+		if err := s.Repo.ReplaceRequirementsTx(ctx, tx, gameID, g.Requirements); err != nil {
+			return err
+		}
+	}
+	if g.Languages != nil {
+		if err := s.Repo.ReplaceLanguagesTx(ctx, tx, gameID, g.Languages); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// localizedDescription picks the description for a locale, falling back from "pt-BR" to
+// "pt"; nil when there is none
+func localizedDescription(list []model.GameDescription, locale string) *string {
+	if locale == "" {
+		return nil
+	}
+	for _, d := range list {
+		if strings.EqualFold(d.Locale, locale) {
+			return &d.Description
+		}
+	}
+	base, _, _ := strings.Cut(locale, "-")
+	for _, d := range list {
+		if strings.EqualFold(d.Locale, base) {
+			return &d.Description
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/pagination"
+)
+
+// ErrGameNotEditable is returned when the team edits a game outside of the draft and
+// rejected states
+var ErrGameNotEditable = errors.New("only draft and rejected games can be edited; withdraw or revise the game first")
+
+// gameTransition is a publishing action: the states it applies to and the state it leads to
+type gameTransition struct {
+	from []string
+	to   string
+}
+
+var gameTransitions = map[string]gameTransition{
+	model.GameActionSubmit:   {[]string{model.GameStatusDraft, model.GameStatusRejected}, model.GameStatusSubmitted},
+	model.GameActionWithdraw: {[]string{model.GameStatusSubmitted}, model.GameStatusDraft},
+	model.GameActionApprove:  {[]string{model.GameStatusSubmitted}, model.GameStatusApproved},
+	model.GameActionReject:   {[]string{model.GameStatusSubmitted}, model.GameStatusRejected},
+	model.GameActionPublish:  {[]string{model.GameStatusApproved}, model.GameStatusPublished},
+	model.GameActionDelist:   {[]string{model.GameStatusPublished}, model.GameStatusDelisted},
+	model.GameActionRelist:   {[]string{model.GameStatusDelisted}, model.GameStatusPublished},
+	model.GameActionRevise:   {[]string{model.GameStatusDelisted}, model.GameStatusDraft},
+}
+
+// ChangeStatus applies a publishing action to a game and records the transition. Whether
+// the actor may take the action (team member or reviewer) is checked by the caller; a
+// rejection needs a comment for the developer.
+func (s *GameService) ChangeStatus(ctx context.Context, gameID int64, action string, actorID int64, comment string) (*model.GameTransition, error) {
+	t, ok := gameTransitions[action]
+	if !ok {
+		return nil, fmt.Errorf("unknown action %q", action)
+	}
+	comment = strings.TrimSpace(comment)
+	if action == model.GameActionReject && comment == "" {
+		return nil, errors.New("a comment is required to reject a game")
+	}
+	if len(comment) > 2000 {
+		return nil, errors.New("comment must be at most 2000 characters")
+	}
+	g, err := s.Repo.GetByID(ctx, gameID)
+	if err != nil || g.DeletedAt != nil {
+		return nil, errors.New("game not found")
+	}
+	allowed := false
+	for _, from := range t.from {
+		allowed = allowed || g.Status == from
+	}
+	if !allowed {
+		return nil, fmt.Errorf("cannot %s a game that is %s", action, g.Status)
+	}
+
+	tx, err := s.Repo.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.Repo.SetStatusTx(ctx, tx, gameID, g.Status, t.to); err != nil {
+		return nil, err
+	}
+	from := g.Status
+	tr := &model.GameTransition{
+		GameID:     gameID,
+		FromStatus: &from,
+		ToStatus:   t.to,
+		ActorID:    &actorID,
+		Comment:    optionalString(comment),
+		CreatedAt:  time.Now(),
+	}
+	if err := s.Repo.AddTransitionTx(ctx, tx, tr); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+	return tr, nil
+}
+
+// EnsureEditable returns ErrGameNotEditable unless the team may edit the game in its
+// current state; published games change through delist and revise, and a new review
+func (s *GameService) EnsureEditable(ctx context.Context, gameID int64) error {
+	g, err := s.Repo.GetByID(ctx, gameID)
+	if err != nil {
+		return err
+	}
+	if g.Status != model.GameStatusDraft && g.Status != model.GameStatusRejected {
+		return ErrGameNotEditable
+	}
+	return nil
+}
+
+// Transitions returns the publishing history of a game, oldest first
+func (s *GameService) Transitions(ctx context.Context, gameID int64) ([]model.GameTransition, error) {
+	return s.Repo.ListTransitions(ctx, gameID)
+}
+
+// ListByStatus returns a page of the games in one publishing state, by default the
+// submissions waiting for review
+func (s *GameService) ListByStatus(ctx context.Context, status string, p pagination.Params) (*pagination.Page[model.Game], error) {
+	switch status {
+	case "":
+		status = model.GameStatusSubmitted
+	case model.GameStatusDraft, model.GameStatusSubmitted, model.GameStatusApproved, model.GameStatusRejected,
+		model.GameStatusPublished, model.GameStatusDelisted:
+	default:
+		return nil, errors.New("status must be draft, submitted, approved, rejected, published or delisted")
+	}
+	rows, err := s.Repo.ListByStatus(ctx, status, p)
+	if err != nil {
+		return nil, err
+	}
+	return pagination.NewPage(rows, p, gameID, func() (int64, error) { return s.Repo.CountByStatus(ctx, status) })
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/money"
+	"GameStoreAPI/internal/pagination"
+	"GameStoreAPI/internal/repository"
+)
+
+// searchAll pages through a search two games at a time and returns the ids in order
+func searchAll(t *testing.T, gs *GameService, p model.GameSearch) []int64 {
+	t.Helper()
+	var ids []int64
+	for _, g := range searchIn(t, gs, p, "") {
+		ids = append(ids, g.GameID)
+	}
+	return ids
+}
+
+// searchIn pages through a search with prices in the currency, two games at a time
+func searchIn(t *testing.T, gs *GameService, p model.GameSearch, currency string) []model.Game {
+	t.Helper()
+	var games []model.Game
+	pg := pagination.Params{Limit: 2}
+	for page := 0; ; page++ {
+		if page > 10 {
+			t.Fatal("search does not end")
+		}
+		q := p
+		res, err := gs.SearchGames(context.Background(), &q, pg, currency)
+		if err != nil {
+			t.Fatalf("search page %d: %v", page, err)
+		}
+		games = append(games, res.Items...)
+		if res.NextCursor == "" {
+			return games
+		}
+		if pg, err = pagination.Parse("2", res.NextCursor, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newSearchTest(t *testing.T) (*testStore, *GameService) {
+	t.Helper()
+	s := newTestStore(t)
+	gameRepo := repository.NewGameRepository(s.pool)
+	pricing := NewPricingService(repository.NewPricingRepository(s.pool), gameRepo, repository.NewCustomerRepository(s.pool), "USD")
+	return s, NewGameService(gameRepo, repository.NewDeveloperRepository(s.pool), repository.NewDeveloperMemberRepository(s.pool), pricing)
+}
+
+func TestSearchGamesPages(t *testing.T) {
+	s, gs := newSearchTest(t)
+
+	// ties on the price and on the release date, and a game without release date
+	a := s.game(t, "Amber Road", "5.00")
+	b := s.game(t, "Birch Hollow", "5.00")
+	c := s.game(t, "Cinder Bay", "5.00")
+	d := s.game(t, "Dune Relay", "8.00")
+	e := s.game(t, "Echo Field", "12.00")
+	s.exec(t, `UPDATE games SET releasedate = current_date - 30 WHERE gameid = ANY($1)`, []int64{a, d})
+	s.exec(t, `UPDATE games SET releasedate = NULL WHERE gameid=$1`, e)
+
+	if got, want := searchAll(t, gs, model.GameSearch{Sort: model.SortPrice}), []int64{a, b, c, d, e}; !slices.Equal(got, want) {
+		t.Errorf("by price = %v, want %v", got, want)
+	}
+	if got, want := searchAll(t, gs, model.GameSearch{Sort: model.SortPrice, Order: "desc"}), []int64{e, d, c, b, a}; !slices.Equal(got, want) {
+		t.Errorf("by price descending = %v, want %v", got, want)
+	}
+	// newest first, ties by id in the same direction, no release date last
+	if got, want := searchAll(t, gs, model.GameSearch{Sort: model.SortReleaseDate}), []int64{c, b, d, a, e}; !slices.Equal(got, want) {
+		t.Errorf("by release date = %v, want %v", got, want)
+	}
+	if got, want := searchAll(t, gs, model.GameSearch{Sort: model.SortReleaseDate, Order: "asc"}), []int64{a, d, b, c, e}; !slices.Equal(got, want) {
+		t.Errorf("by release date ascending = %v, want %v", got, want)
+	}
+	if got := searchAll(t, gs, model.GameSearch{Sort: model.SortPopularity}); len(got) != 5 {
+		t.Errorf("by popularity = %v, want all 5 games", got)
+	}
+
+	first, err := gs.SearchGames(context.Background(), &model.GameSearch{Sort: model.SortPrice}, pagination.Params{Limit: 2, WithTotal: true}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Total == nil || *first.Total != 5 {
+		t.Fatalf("total = %v, want 5", first.Total)
+	}
+	// a cursor only continues the order it was issued for
+	pg, err := pagination.Parse("2", first.NextCursor, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gs.SearchGames(context.Background(), &model.GameSearch{Sort: model.SortReleaseDate}, pg, ""); !errors.Is(err, pagination.ErrInvalidCursor) {
+		t.Fatalf("price cursor on a release date search: %v, want ErrInvalidCursor", err)
+	}
+	pg, _ = pagination.Parse("2", pagination.Encode(b), "")
+	if _, err := gs.SearchGames(context.Background(), &model.GameSearch{Sort: model.SortPrice}, pg, ""); !errors.Is(err, pagination.ErrInvalidCursor) {
+		t.Fatalf("id cursor on a search: %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestSearchGamesPriceInCurrency(t *testing.T) {
+	s, gs := newSearchTest(t)
+	a := s.game(t, "Ashen Quay", "10.00")
+	b := s.game(t, "Bramble Gate", "20.00")
+	c := s.game(t, "Cobalt Spire", "18.00")
+	d := s.game(t, "Drift Harbor", "30.00")
+	s.exec(t, `INSERT INTO exchangerates (currency, rate) VALUES ('EUR', 0.5), ('JPY', 149.99)`)
+	// b has a regional price, c a percentage sale and d a fixed sale price (in USD)
+	s.exec(t, `INSERT INTO gameprices (gameid, currency, amount) VALUES ($1, 'EUR', 4.00)`, b)
+	s.exec(t, `INSERT INTO gamesales (gameid, discountpercent, starts_at, ends_at) VALUES ($1, 50, now() - interval '1 day', now() + interval '1 day')`, c)
+	s.exec(t, `INSERT INTO gamesales (gameid, saleprice, starts_at, ends_at) VALUES ($1, 12.00, now() - interval '1 day', now() + interval '1 day')`, d)
+
+	ids := func(games []model.Game) []int64 {
+		out := make([]int64, len(games))
+		for i, g := range games {
+			out[i] = g.GameID
+		}
+		return out
+	}
+	// USD 10, 20, 9, 12; EUR 5, 4, 4.50, 6
+	if got, want := ids(searchIn(t, gs, model.GameSearch{Sort: model.SortPrice}, "")), []int64{c, a, d, b}; !slices.Equal(got, want) {
+		t.Errorf("by USD price = %v, want %v", got, want)
+	}
+	if got, want := ids(searchIn(t, gs, model.GameSearch{Sort: model.SortPrice}, "EUR")), []int64{b, c, a, d}; !slices.Equal(got, want) {
+		t.Errorf("by EUR price = %v, want %v", got, want)
+	}
+	five := money.Amount(500)
+	if got, want := ids(searchIn(t, gs, model.GameSearch{Sort: model.SortPrice, MaxPrice: &five}, "EUR")), []int64{b, c, a}; !slices.Equal(got, want) {
+		t.Errorf("EUR price <= 5.00 = %v, want %v", got, want)
+	}
+	if got := searchIn(t, gs, model.GameSearch{MaxPrice: &five}, ""); len(got) != 0 {
+		t.Errorf("USD price <= 5.00 = %v, want none", ids(got))
+	}
+
+	// whatever the currency, the filter and the order match the prices shown
+	for _, currency := range []string{"", "EUR", "JPY"} {
+		for _, order := range []string{"asc", "desc"} {
+			games := searchIn(t, gs, model.GameSearch{Sort: model.SortPrice, Order: order}, currency)
+			if len(games) != 4 {
+				t.Fatalf("%s %s: %d games, want 4", currency, order, len(games))
+			}
+			for i := 1; i < len(games); i++ {
+				if (order == "asc" && games[i].Price < games[i-1].Price) || (order == "desc" && games[i].Price > games[i-1].Price) {
+					t.Fatalf("%s %s: %s before %s", currency, order, games[i-1].Price, games[i].Price)
+				}
+			}
+			lo, hi := games[1].Price, games[2].Price
+			if order == "desc" {
+				lo, hi = hi, lo
+			}
+			between := searchIn(t, gs, model.GameSearch{MinPrice: &lo, MaxPrice: &hi}, currency)
+			for _, g := range between {
+				if g.Price < lo || g.Price > hi {
+					t.Fatalf("%s: %s is not between %s and %s", currency, g.Price, lo, hi)
+				}
+			}
+			if len(between) < 2 {
+				t.Fatalf("%s: %d games between %s and %s, want at least 2", currency, len(between), lo, hi)
+			}
+		}
+	}
+
+	// a price cursor only continues a search in the same currency
+	first, err := gs.SearchGames(context.Background(), &model.GameSearch{Sort: model.SortPrice}, pagination.Params{Limit: 2}, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pg, err := pagination.Parse("2", first.NextCursor, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gs.SearchGames(context.Background(), &model.GameSearch{Sort: model.SortPrice}, pg, "JPY"); !errors.Is(err, pagination.ErrInvalidCursor) {
+		t.Fatalf("EUR cursor on a JPY search: %v, want ErrInvalidCursor", err)
+	}
+}
@@ -3,63 +3,258 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
+	"time"
 
 	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/pagination"
 	"GameStoreAPI/internal/repository"
 )
 
 type GameService struct {
 	Repo          *repository.GameRepository
 	DeveloperRepo *repository.DeveloperRepository
+	MemberRepo    *repository.DeveloperMemberRepository
+	Pricing       *PricingService
 }
 
-func NewGameService(r *repository.GameRepository, dr *repository.DeveloperRepository) *GameService {
-	return &GameService{Repo: r, DeveloperRepo: dr}
+func NewGameService(r *repository.GameRepository, dr *repository.DeveloperRepository, mr *repository.DeveloperMemberRepository, ps *PricingService) *GameService {
+	return &GameService{Repo: r, DeveloperRepo: dr, MemberRepo: mr, Pricing: ps}
 }
 
-func (s *GameService) CreateGame(ctx context.Context, g *model.Game) (int64, error) {
-	// validate title and developer existence
-	g.Title = strings.TrimSpace(g.Title)
-	if g.Title == "" {
-		return 0, errors.New("title is required")
+// CreateGame creates a game as a draft; actorID is recorded as the author of its first
+// publishing transition
+func (s *GameService) CreateGame(ctx context.Context, g *model.Game, actorID int64) (int64, error) {
+	// validate fields and developer existence
+	if err := s.validateGame(ctx, g); err != nil {
+		return 0, err
 	}
-	if g.Price < 0 {
-		return 0, errors.New("price must be >= 0")
+	tx, err := s.Repo.DB.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
 	}
-	ok, err := s.Repo.ExistsByDeveloperID(ctx, g.DeveloperID)
+	defer tx.Rollback(ctx)
+
+	id, err := s.Repo.CreateGameTx(ctx, tx, g)
 	if err != nil {
 		return 0, err
 	}
-	if !ok {
-		return 0, errors.New("developer not found")
+	if err := s.saveMetadataTx(ctx, tx, id, g); err != nil {
+		return 0, err
+	}
+	created := &model.GameTransition{GameID: id, ToStatus: model.GameStatusDraft, ActorID: &actorID, CreatedAt: time.Now()}
+	if err := s.Repo.AddTransitionTx(ctx, tx, created); err != nil {
+		return 0, err
 	}
-	return s.Repo.CreateGame(ctx, g)
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit tx: %w", err)
+	}
+	return id, nil
 }
 
-func (s *GameService) GetGame(ctx context.Context, id int64) (*model.Game, error) {
-	return s.Repo.GetByID(ctx, id)
+// IsDeveloperMember reports whether the account is on the team of the developer; every
+// team role may manage the developer's games
+func (s *GameService) IsDeveloperMember(ctx context.Context, authID, developerID int64) (bool, error) {
+	if _, err := s.DeveloperRepo.GetByID(ctx, developerID); err != nil {
+		return false, errors.New("developer not found")
+	}
+	role, err := s.MemberRepo.RoleOf(ctx, developerID, authID)
+	if err != nil {
+		return false, err
+	}
+	return role != "", nil
 }
 
-func (s *GameService) ListGames(ctx context.Context, limit, offset int) ([]model.Game, error) {
-	if limit <= 0 || limit > 200 {
-		limit = 50
+// GetGameDetails returns a game with its metadata and its price in the given currency
+// ("" = base currency). With a locale, the description is the one written for it when
+// there is one. Deleted games are not found.
+func (s *GameService) GetGameDetails(ctx context.Context, id int64, currency, locale string) (*model.Game, error) {
+	g, err := s.GetGame(ctx, id, currency)
+	if err != nil {
+		return nil, err
 	}
-	if offset < 0 {
-		offset = 0
+	if g.DeletedAt != nil {
+		return nil, errors.New("game not found")
 	}
-	return s.Repo.List(ctx, limit, offset)
+	if err := s.Repo.LoadMetadata(ctx, g); err != nil {
+		return nil, err
+	}
+	if d := localizedDescription(g.Descriptions, locale); d != nil {
+		g.Description = d
+	}
+	return g, nil
 }
 
-func (s *GameService) ListGamesByDeveloper(ctx context.Context, developerID int64, limit, offset int) ([]model.Game, error) {
-	// simple validation
-	if developerID <= 0 {
+// GetGame returns a game with its price in the given currency ("" = base currency),
+// deleted games included
+func (s *GameService) GetGame(ctx context.Context, id int64, currency string) (*model.Game, error) {
+	g, err := s.Repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	code, err := s.Pricing.Resolve(ctx, currency)
+	if err != nil {
+		return nil, err
+	}
+	games := []model.Game{*g}
+	if err := s.Pricing.ApplyCurrency(ctx, games, code); err != nil {
+		return nil, err
+	}
+	return &games[0], nil
+}
+
+// ListGames returns games with prices in the given currency ("" = base currency)
+func (s *GameService) ListGames(ctx context.Context, p pagination.Params, currency string) (*pagination.Page[model.Game], error) {
+	code, err := s.Pricing.Resolve(ctx, currency)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.Repo.List(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	page, err := pagination.NewPage(rows, p, gameID, func() (int64, error) { return s.Repo.Count(ctx) })
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Pricing.ApplyCurrency(ctx, page.Items, code); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// ListGamesForMember returns the games of the developers the account is a member of,
+// limited to one developer when developerID is not 0
+func (s *GameService) ListGamesForMember(ctx context.Context, authID, developerID int64, p pagination.Params) (*pagination.Page[model.Game], error) {
+	if developerID < 0 {
 		return nil, errors.New("invalid developer id")
 	}
-	return s.Repo.ListByDeveloper(ctx, developerID, limit, offset)
+	rows, err := s.Repo.ListByMember(ctx, authID, developerID, p)
+	if err != nil {
+		return nil, err
+	}
+	return pagination.NewPage(rows, p, gameID, func() (int64, error) { return s.Repo.CountByMember(ctx, authID, developerID) })
 }
 
+func gameID(g model.Game) int64 { return g.GameID }
+
+// SearchGames runs a catalog search and returns the page with prices in the given
+// currency ("" = base currency) and the genre and developer facets. Price bounds and the
+// price sort apply to the prices in that currency. Page cursors carry the sort and order
+// (and the currency of a price sort) they were issued for and only continue the same
+// search order.
+func (s *GameService) SearchGames(ctx context.Context, p *model.GameSearch, pg pagination.Params, currency string) (*model.GameSearchResult, error) {
+	p.Query = strings.TrimSpace(p.Query)
+	if len(p.Query) > 200 {
+		return nil, errors.New("query must be at most 200 characters")
+	}
+	switch p.Sort {
+	case "":
+		p.Sort = model.SortRelevance
+	case model.SortRelevance, model.SortPrice, model.SortReleaseDate, model.SortPopularity:
+	default:
+		return nil, errors.New("sort must be relevance, price, release_date or popularity")
+	}
+	switch p.Order {
+	case "":
+		p.Order = "desc"
+		if p.Sort == model.SortPrice {
+			p.Order = "asc"
+		}
+	case "asc", "desc":
+	default:
+		return nil, errors.New("order must be asc or desc")
+	}
+	if (p.MinPrice != nil && *p.MinPrice < 0) || (p.MaxPrice != nil && *p.MaxPrice < 0) {
+		return nil, errors.New("price bounds must be >= 0")
+	}
+	if p.MinPrice != nil && p.MaxPrice != nil && *p.MinPrice > *p.MaxPrice {
+		return nil, errors.New("min_price must not exceed max_price")
+	}
+	if p.ReleasedFrom != nil && p.ReleasedTo != nil && p.ReleasedFrom.After(*p.ReleasedTo) {
+		return nil, errors.New("released_from must not be after released_to")
+	}
+	// an empty id list means no filter, not "match nothing"
+	if len(p.GenreIDs) == 0 {
+		p.GenreIDs = nil
+	}
+	if len(p.DeveloperIDs) == 0 {
+		p.DeveloperIDs = nil
+	}
+	code, err := s.Pricing.Resolve(ctx, currency)
+	if err != nil {
+		return nil, err
+	}
+	// prices are filtered and sorted the way they are shown
+	p.Currency = ""
+	if code != s.Pricing.BaseCurrency {
+		p.Currency = code
+	}
+	order := p.Sort + ":" + p.Order + ":"
+	if p.Sort == model.SortPrice {
+		// price keys are amounts in the currency of the page
+		order += code + ":"
+	}
+	if pg.After != nil {
+		key, ok := strings.CutPrefix(pg.AfterKey, order)
+		if !ok || key == "" {
+			return nil, pagination.ErrInvalidCursor
+		}
+		pg.AfterKey = key
+	}
+	hits, err := s.Repo.Search(ctx, p, pg)
+	if err != nil {
+		return nil, err
+	}
+	page, err := pagination.NewKeyedPage(hits, pg, func(h repository.SearchHit) (string, int64) { return order + h.SortKey, h.GameID },
+		func() (int64, error) { return s.Repo.CountSearch(ctx, p) })
+	if err != nil {
+		return nil, err
+	}
+	items := make([]model.Game, len(page.Items))
+	for i, h := range page.Items {
+		items[i] = h.Game
+	}
+	if err := s.Pricing.ApplyCurrency(ctx, items, code); err != nil {
+		return nil, err
+	}
+	facets, err := s.Repo.SearchFacets(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return &model.GameSearchResult{
+		Page:   &pagination.Page[model.Game]{Items: items, NextCursor: page.NextCursor, Total: page.Total},
+		Facets: *facets,
+	}, nil
+}
+
+// UpdateGame replaces the fields of a game. Descriptions, media, requirements and languages
+// are only replaced when not nil, so updates may leave them out.
 func (s *GameService) UpdateGame(ctx context.Context, g *model.Game) error {
+	if err := s.validateGame(ctx, g); err != nil {
+		return err
+	}
+	tx, err := s.Repo.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.Repo.UpdateGameTx(ctx, tx, g); err != nil {
+		return err
+	}
+	if err := s.saveMetadataTx(ctx, tx, g.GameID, g); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// validateGame checks and normalizes the fields of a game to create or update
+func (s *GameService) validateGame(ctx context.Context, g *model.Game) error {
 	g.Title = strings.TrimSpace(g.Title)
 	if g.Title == "" {
 		return errors.New("title is required")
@@ -67,6 +262,12 @@ func (s *GameService) UpdateGame(ctx context.Context, g *model.Game) error {
 	if g.Price < 0 {
 		return errors.New("price must be >= 0")
 	}
+	if err := cleanDescription(g); err != nil {
+		return err
+	}
+	if err := cleanMetadata(g); err != nil {
+		return err
+	}
 	ok, err := s.Repo.ExistsByDeveloperID(ctx, g.DeveloperID)
 	if err != nil {
 		return err
@@ -74,9 +275,26 @@ func (s *GameService) UpdateGame(ctx context.Context, g *model.Game) error {
 	if !ok {
 		return errors.New("developer not found")
 	}
-	return s.Repo.UpdateGame(ctx, g)
+	return nil
 }
 
 func (s *GameService) DeleteGame(ctx context.Context, id int64) error {
 	return s.Repo.DeleteGame(ctx, id)
 }
+
+// cleanDescription trims the description of a game; a blank description is removed
+func cleanDescription(g *model.Game) error {
+	if g.Description == nil {
+		return nil
+	}
+	d := strings.TrimSpace(*g.Description)
+	if d == "" {
+		g.Description = nil
+		return nil
+	}
+	if len(d) > 20000 {
+		return errors.New("description must be at most 20000 characters")
+	}
+	g.Description = &d
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/pagination"
 	"GameStoreAPI/internal/repository"
 )
 
@@ -36,8 +37,12 @@ func (s *GenreService) Get(ctx context.Context, id int64) (*model.Genre, error)
 	return s.Repo.GetByID(ctx, id)
 }
 
-func (s *GenreService) List(ctx context.Context) ([]model.Genre, error) {
-	return s.Repo.List(ctx)
+func (s *GenreService) List(ctx context.Context, p pagination.Params) (*pagination.Page[model.Genre], error) {
+	rows, err := s.Repo.List(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return pagination.NewPage(rows, p, func(g model.Genre) int64 { return g.GenreID }, func() (int64, error) { return s.Repo.Count(ctx) })
 }
 
 func (s *GenreService) Update(ctx context.Context, id int64, name string) error {
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/pagination"
+	"GameStoreAPI/internal/repository"
+)
+
+type GiftService struct {
+	Repo              *repository.GiftRepository
+	CustomerGamesRepo *repository.CustomerGamesRepository
+	PaymentRepo       *repository.PaymentRepository
+	Payments          *PaymentService
+}
+
+func NewGiftService(r *repository.GiftRepository, cgr *repository.CustomerGamesRepository, pr *repository.PaymentRepository, ps *PaymentService) *GiftService {
+	return &GiftService{Repo: r, CustomerGamesRepo: cgr, PaymentRepo: pr, Payments: ps}
+}
+
+// ListReceived returns the gifts sent to the customer (status "" for all of them)
+func (s *GiftService) ListReceived(ctx context.Context, customerID int64, status string, p pagination.Params) (*pagination.Page[model.Gift], error) {
+	switch status {
+	case "", model.GiftPending, model.GiftAccepted, model.GiftDeclined, model.GiftRevoked:
+	default:
+		return nil, errors.New("invalid status")
+	}
+	rows, err := s.Repo.ListReceived(ctx, customerID, status, p)
+	if err != nil {
+		return nil, err
+	}
+	return pagination.NewPage(rows, p, giftID, func() (int64, error) { return s.Repo.CountReceived(ctx, customerID, status) })
+}
+
+// ListSent returns the gifts the customer bought for others
+func (s *GiftService) ListSent(ctx context.Context, customerID int64, p pagination.Params) (*pagination.Page[model.Gift], error) {
+	rows, err := s.Repo.ListSent(ctx, customerID, p)
+	if err != nil {
+		return nil, err
+	}
+	return pagination.NewPage(rows, p, giftID, func() (int64, error) { return s.Repo.CountSent(ctx, customerID) })
+}
+
+func giftID(g model.Gift) int64 { return g.GiftID }
+
+// Accept grants the recipient ownership of a pending gift
+func (s *GiftService) Accept(ctx context.Context, customerID, giftID int64) (*model.Gift, error) {
+	tx, err := s.Repo.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	gf, err := s.Repo.LockPendingTx(ctx, tx, giftID, customerID)
+	if err != nil {
+		return nil, err
+	}
+	owned, err := s.CustomerGamesRepo.ExistsAnyOwned(ctx, customerID, []int64{gf.GameID})
+	if err != nil {
+		return nil, fmt.Errorf("ownership check failed: %w", err)
+	}
+	if owned != 0 {
+		return nil, errors.New("you already own this game, decline the gift to refund the sender")
+	}
+	if err := s.CustomerGamesRepo.CreateCustomerGamesTx(ctx, tx, customerID, []int64{gf.GameID}); err != nil {
+		return nil, fmt.Errorf("record ownership: %w", err)
+	}
+	if err := s.Repo.SetStatusTx(ctx, tx, gf.GiftID, model.GiftAccepted); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+	return s.Repo.GetByID(ctx, giftID)
+}
+
+// Decline turns down a pending gift and refunds the order item to the sender. The refund
+// comes first and revokes the gift; only then is it marked declined. When the refund fails
+// the gift stays pending and the recipient can decline again.
+func (s *GiftService) Decline(ctx context.Context, authID, customerID, giftID int64) (*model.Gift, error) {
+	gf, err := s.Repo.GetByID(ctx, giftID)
+	if err != nil || gf.RecipientID != customerID || gf.Status != model.GiftPending {
+		return nil, errors.New("pending gift not found")
+	}
+
+	p, err := s.PaymentRepo.GetLatestByOrder(ctx, gf.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	reason := "gift declined"
+	rf := &model.Refund{
+		OrderID:     gf.OrderID,
+		OrderItemID: &gf.OrderItemID,
+		PaymentID:   p.PaymentID,
+		Reason:      &reason,
+		RequestedBy: &authID,
+	}
+	if err := s.Payments.ExecuteRefund(ctx, rf); err != nil {
+		return nil, fmt.Errorf("refund the sender: %w", err)
+	}
+	if err := s.Repo.MarkDeclined(ctx, gf.GiftID); err != nil {
+		return nil, err
+	}
+	return s.Repo.GetByID(ctx, giftID)
+}
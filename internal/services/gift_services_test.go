@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"GameStoreAPI/internal/model"
+)
+
+func TestAcceptGift(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	buyerAuth, buyerID := s.account(t, "vera", "user")
+	_, recipientID := s.account(t, "walt", "user")
+	gameID := s.game(t, "Copper Meridian", "18.00")
+	res := s.buyGift(t, buyerAuth, gameID, "walt")
+	giftID := s.queryInt(t, `SELECT giftid FROM gifts WHERE orderid=$1`, res.OrderID)
+
+	if _, err := s.gifts.Accept(ctx, buyerID, giftID); err == nil {
+		t.Fatal("the sender accepted a gift meant for someone else")
+	}
+	gf, err := s.gifts.Accept(ctx, recipientID, giftID)
+	if err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	if gf.Status != model.GiftAccepted {
+		t.Fatalf("gift = %s, want accepted", gf.Status)
+	}
+	if !s.owns(t, recipientID, gameID) || s.owns(t, buyerID, gameID) {
+		t.Fatal("only the recipient should own an accepted gift")
+	}
+	if _, err := s.gifts.Accept(ctx, recipientID, giftID); err == nil {
+		t.Fatal("a gift was accepted twice")
+	}
+	if _, err := s.gifts.Decline(ctx, 0, recipientID, giftID); err == nil {
+		t.Fatal("an accepted gift was declined")
+	}
+}
+
+func TestDeclineGift(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	buyerAuth, _ := s.account(t, "xena", "user")
+	recipientAuth, recipientID := s.account(t, "yuri", "user")
+	gameID := s.game(t, "Tidewater Rails", "13.00")
+	res := s.buyGift(t, buyerAuth, gameID, "yuri")
+	giftID := s.queryInt(t, `SELECT giftid FROM gifts WHERE orderid=$1`, res.OrderID)
+
+	gf, err := s.gifts.Decline(ctx, recipientAuth, recipientID, giftID)
+	if err != nil {
+		t.Fatalf("decline: %v", err)
+	}
+	if gf.Status != model.GiftDeclined {
+		t.Fatalf("gift = %s, want declined", gf.Status)
+	}
+	if s.owns(t, recipientID, gameID) {
+		t.Fatal("recipient owns a declined gift")
+	}
+	if held := s.gateway.Held(s.providerRef(t, res.PaymentID)); held != 0 {
+		t.Fatalf("sender was not refunded, provider holds %s", held)
+	}
+	if n := s.queryInt(t, `SELECT count(*) FROM refunds WHERE orderid=$1 AND status=$2`, res.OrderID, model.RefundCompleted); n != 1 {
+		t.Fatalf("completed refunds = %d, want 1", n)
+	}
+}
+
+func TestDeclineGiftRefundFails(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	buyerAuth, _ := s.account(t, "zack", "user")
+	recipientAuth, recipientID := s.account(t, "abby", "user")
+	gameID := s.game(t, "Pale Orchard", "10.00")
+	res := s.buyGift(t, buyerAuth, gameID, "abby")
+	giftID := s.queryInt(t, `SELECT giftid FROM gifts WHERE orderid=$1`, res.OrderID)
+
+	// the provider has nothing left to give back, so the refund is declined
+	ref := s.providerRef(t, res.PaymentID)
+	if err := s.gateway.Refund(ctx, ref, s.gateway.Held(ref)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.gifts.Decline(ctx, recipientAuth, recipientID, giftID); err == nil {
+		t.Fatal("decline succeeded without refunding the sender")
+	}
+	// still pending: the recipient can accept it or decline again later
+	if _, err := s.gifts.Accept(ctx, recipientID, giftID); err != nil {
+		t.Fatalf("accept after a failed decline: %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/repository"
+)
+
+// LockoutPolicy configures login and registration throttling. Once a counter goes past its
+// free attempts, every further failure locks it for BaseLockout, doubled per extra failure
+// up to MaxLockout.
+type LockoutPolicy struct {
+	AccountFreeAttempts int // failed logins per email before lockouts start
+	IPFreeAttempts      int // failed logins per client IP before lockouts start
+	RegistrationsPerIP  int // registrations per client IP before lockouts start
+	BaseLockout         time.Duration
+	MaxLockout          time.Duration
+	// ResetAfter restarts a counter when its last failure is older than this
+	ResetAfter time.Duration
+}
+
+var DefaultLockoutPolicy = LockoutPolicy{
+	AccountFreeAttempts: 5,
+	IPFreeAttempts:      20,
+	RegistrationsPerIP:  5,
+	BaseLockout:         30 * time.Second,
+	MaxLockout:          time.Hour,
+	ResetAfter:          24 * time.Hour,
+}
+
+// LockedError is returned while an account or client IP is locked out
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("too many attempts, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+// LockoutService tracks failed attempts per account and per client IP
+type LockoutService struct {
+	Repo   *repository.AuthAttemptRepository
+	Audit  *repository.AuditRepository
+	Policy LockoutPolicy
+}
+
+func NewLockoutService(r *repository.AuthAttemptRepository, ar *repository.AuditRepository, p LockoutPolicy) *LockoutService {
+	return &LockoutService{Repo: r, Audit: ar, Policy: p}
+}
+
+func accountKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// check returns a LockedError when any of the counters is locked
+func (s *LockoutService) check(ctx context.Context, now time.Time, counters ...[2]string) error {
+	for _, c := range counters {
+		if c[1] == "" {
+			continue
+		}
+		until, err := s.Repo.LockedUntil(ctx, c[0], c[1], now)
+		if err != nil {
+			return err
+		}
+		if until != nil {
+			return &LockedError{RetryAfter: until.Sub(now)}
+		}
+	}
+	return nil
+}
+
+// fail counts a failure and locks the counter once it is past its free attempts
+func (s *LockoutService) fail(ctx context.Context, now time.Time, kind, key string, free int, event, ip string) error {
+	if key == "" {
+		return nil
+	}
+	a, err := s.Repo.RecordFailure(ctx, kind, key, now, now.Add(-s.Policy.ResetAfter))
+	if err != nil {
+		return err
+	}
+	over := a.Failures - free
+	if over <= 0 {
+		return nil
+	}
+	d := s.Policy.MaxLockout
+	if over <= 30 {
+		d = min(s.Policy.BaseLockout<<(over-1), s.Policy.MaxLockout)
+	}
+	if err := s.Repo.Lock(ctx, kind, key, now.Add(d)); err != nil {
+		return err
+	}
+	subject := kind + ":" + key
+	detail := fmt.Sprintf("%d failures, locked for %s", a.Failures, d)
+	return s.Audit.Record(ctx, &model.AuditEntry{Event: event, Subject: &subject, Detail: &detail, IPAddress: optionalString(ip)})
+}
+
+// CheckLogin rejects a login attempt while the account or the client IP is locked out
+func (s *LockoutService) CheckLogin(ctx context.Context, email, ip string) error {
+	return s.check(ctx, time.Now(), [2]string{model.AttemptAccount, accountKey(email)}, [2]string{model.AttemptIP, ip})
+}
+
+// LoginFailed counts a failed login for the account and the client IP
+func (s *LockoutService) LoginFailed(ctx context.Context, email, ip string) error {
+	now := time.Now()
+	if err := s.fail(ctx, now, model.AttemptAccount, accountKey(email), s.Policy.AccountFreeAttempts, model.AuditLoginLocked, ip); err != nil {
+		return err
+	}
+	return s.fail(ctx, now, model.AttemptIP, ip, s.Policy.IPFreeAttempts, model.AuditLoginLocked, ip)
+}
+
+// LoginSucceeded resets the account counter (the IP counter keeps running)
+func (s *LockoutService) LoginSucceeded(ctx context.Context, email string) error {
+	_, err := s.Repo.Clear(ctx, model.AttemptAccount, accountKey(email))
+	return err
+}
+
+// CheckRegistration rejects a registration while the client IP is locked out
+func (s *LockoutService) CheckRegistration(ctx context.Context, ip string) error {
+	return s.check(ctx, time.Now(), [2]string{model.AttemptRegister, ip})
+}
+
+// RegistrationAttempt counts a registration attempt from the client IP
+func (s *LockoutService) RegistrationAttempt(ctx context.Context, ip string) error {
+	return s.fail(ctx, time.Now(), model.AttemptRegister, ip, s.Policy.RegistrationsPerIP, model.AuditRegisterLocked, ip)
+}
+
+// ListLocked returns the current lockouts (admin use)
+func (s *LockoutService) ListLocked(ctx context.Context) ([]model.AuthAttempt, error) {
+	return s.Repo.ListLocked(ctx, time.Now())
+}
+
+// Clear lifts a lockout and resets its counter (admin use)
+func (s *LockoutService) Clear(ctx context.Context, actorAuthID int64, kind, key, ip string) error {
+	if kind == model.AttemptAccount {
+		key = accountKey(key)
+	}
+	found, err := s.Repo.Clear(ctx, kind, key)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("lockout not found")
+	}
+	subject := kind + ":" + key
+	return s.Audit.Record(ctx, &model.AuditEntry{ActorAuthID: &actorAuthID, Event: model.AuditLockoutCleared, Subject: &subject, IPAddress: optionalString(ip)})
+}
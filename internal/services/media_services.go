@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"GameStoreAPI/internal/blob"
+	"GameStoreAPI/internal/media"
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/repository"
+)
+
+// MediaService stores uploaded images of game pages in a BlobStore
+type MediaService struct {
+	Games  *repository.GameRepository
+	Assets *repository.MediaAssetRepository
+	Store  blob.BlobStore
+}
+
+func NewMediaService(gr *repository.GameRepository, ar *repository.MediaAssetRepository, store blob.BlobStore) *MediaService {
+	return &MediaService{Games: gr, Assets: ar, Store: store}
+}
+
+// Upload stores an image and appends it to the media of a game; a cover replaces the
+// previous cover. Uploading an image the game already shows returns the existing media.
+func (s *MediaService) Upload(ctx context.Context, gameID int64, kind string, data []byte, uploadedBy int64) (*model.GameMedia, error) {
+	if kind != model.MediaCover && kind != model.MediaScreenshot {
+		return nil, errors.New("kind must be cover or screenshot")
+	}
+	if len(data) > media.MaxUploadBytes {
+		return nil, media.ErrTooLarge
+	}
+	g, err := s.Games.GetByID(ctx, gameID)
+	if err != nil || g.DeletedAt != nil {
+		return nil, errors.New("game not found")
+	}
+	asset, err := s.storeAsset(ctx, data, uploadedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := s.Games.FindMedia(ctx, gameID, kind, asset.URL)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		n, err := s.Games.CountMedia(ctx, gameID)
+		if err != nil {
+			return nil, err
+		}
+		if n >= maxMedia {
+			return nil, fmt.Errorf("at most %d media are allowed", maxMedia)
+		}
+		tx, err := s.Games.DB.Begin(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("begin tx: %w", err)
+		}
+		defer tx.Rollback(ctx)
+
+		m = &model.GameMedia{Kind: kind, URL: asset.URL}
+		if err := s.Games.AppendMediaTx(ctx, tx, gameID, m); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, fmt.Errorf("commit tx: %w", err)
+		}
+	}
+	m.ThumbnailURL, m.Width, m.Height = &asset.ThumbnailURL, &asset.Width, &asset.Height
+	return m, nil
+}
+
+// storeAsset returns the asset of an image. The image and its thumbnail are stored under
+// keys derived from the content hash, so content uploaded before is not stored again.
+func (s *MediaService) storeAsset(ctx context.Context, data []byte, uploadedBy int64) (*model.MediaAsset, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if a, err := s.Assets.GetBySHA256(ctx, hash); err != nil || a != nil {
+		return a, err
+	}
+
+	info, err := media.Inspect(data)
+	if err != nil {
+		return nil, err
+	}
+	thumb, thumbType, thumbExt, err := media.Thumbnail(data, info)
+	if err != nil {
+		return nil, err
+	}
+	key := "games/" + hash[:2] + "/" + hash + info.Ext
+	thumbKey := "games/" + hash[:2] + "/" + hash + "_thumb" + thumbExt
+	if err := s.putOnce(ctx, key, info.ContentType, data); err != nil {
+		return nil, err
+	}
+	if err := s.putOnce(ctx, thumbKey, thumbType, thumb); err != nil {
+		return nil, err
+	}
+
+	a := &model.MediaAsset{
+		SHA256:       hash,
+		ContentType:  info.ContentType,
+		Size:         len(data),
+		Width:        info.Width,
+		Height:       info.Height,
+		URL:          s.Store.URL(key),
+		ThumbnailURL: s.Store.URL(thumbKey),
+		UploadedBy:   &uploadedBy,
+	}
+	if err := s.Assets.Create(ctx, a); err != nil {
+		return nil, err
+	}
+	// a concurrent upload of the same content may have been stored first
+	return s.Assets.GetBySHA256(ctx, hash)
+}
+
+// putOnce stores a blob unless its key exists already; keys name the content, so an
+// existing blob is identical
+func (s *MediaService) putOnce(ctx context.Context, key, contentType string, data []byte) error {
+	exists, err := s.Store.Exists(ctx, key)
+	if err != nil {
+		return fmt.Errorf("blob store: %w", err)
+	}
+	if exists {
+		return nil
+	}
+	if err := s.Store.Put(ctx, key, contentType, data); err != nil {
+		return fmt.Errorf("blob store: %w", err)
+	}
+	return nil
+}
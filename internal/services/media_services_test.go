@@ -0,0 +1,152 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"GameStoreAPI/internal/blob"
+	"GameStoreAPI/internal/blob/blobtest"
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/repository"
+)
+
+// countingStore counts the blobs written through it
+type countingStore struct {
+	blob.BlobStore
+	puts int
+}
+
+func (s *countingStore) Put(ctx context.Context, key, contentType string, data []byte) error {
+	s.puts++
+	return s.BlobStore.Put(ctx, key, contentType, data)
+}
+
+func testPNG(t *testing.T, w, h int, shade uint8) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = shade
+	}
+	img.Set(0, 0, color.RGBA{255, 0, 0, 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func newMediaTest(t *testing.T) (*testStore, *MediaService, *countingStore, *blobtest.Server) {
+	t.Helper()
+	s := newTestStore(t)
+	srv := blobtest.NewServer("media")
+	t.Cleanup(srv.Close)
+	s3, err := blob.NewS3Store(srv.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &countingStore{BlobStore: s3}
+	return s, NewMediaService(repository.NewGameRepository(s.pool), repository.NewMediaAssetRepository(s.pool), store), store, srv
+}
+
+func TestMediaUploadDedupesByContent(t *testing.T) {
+	s, svc, store, srv := newMediaTest(t)
+	ctx := context.Background()
+	adminID, _ := s.account(t, "admin", "admin")
+	first := s.game(t, "Glass Harbor", "10.00")
+	second := s.game(t, "Ember Vale", "12.00")
+	data := testPNG(t, 640, 360, 40)
+
+	m, err := svc.Upload(ctx, first, model.MediaScreenshot, data, adminID)
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if m.Width == nil || *m.Width != 640 || m.ThumbnailURL == nil {
+		t.Fatalf("media = %+v, want dimensions and a thumbnail", m)
+	}
+	if store.puts != 2 || len(srv.Keys()) != 2 {
+		t.Fatalf("blobs written = %d (stored %v), want the image and its thumbnail", store.puts, srv.Keys())
+	}
+
+	// the same image again on the same game: nothing new is stored or listed
+	again, err := svc.Upload(ctx, first, model.MediaScreenshot, data, adminID)
+	if err != nil {
+		t.Fatalf("second upload: %v", err)
+	}
+	if again.URL != m.URL {
+		t.Fatalf("second upload URL = %s, want %s", again.URL, m.URL)
+	}
+	if n := s.queryInt(t, `SELECT count(*) FROM gamemedia WHERE gameid=$1`, first); n != 1 {
+		t.Fatalf("media of the game = %d, want 1", n)
+	}
+
+	// on another game the stored asset is reused
+	other, err := svc.Upload(ctx, second, model.MediaScreenshot, data, adminID)
+	if err != nil {
+		t.Fatalf("upload to another game: %v", err)
+	}
+	if other.URL != m.URL || *other.ThumbnailURL != *m.ThumbnailURL {
+		t.Fatalf("other game got %s, want the stored %s", other.URL, m.URL)
+	}
+	if store.puts != 2 {
+		t.Fatalf("blobs written = %d after re-uploads, want 2", store.puts)
+	}
+	if n := s.queryInt(t, `SELECT count(*) FROM mediaassets`); n != 1 {
+		t.Fatalf("assets = %d, want 1", n)
+	}
+
+	// different content is a new asset
+	if _, err := svc.Upload(ctx, first, model.MediaScreenshot, testPNG(t, 640, 360, 41), adminID); err != nil {
+		t.Fatalf("upload of other content: %v", err)
+	}
+	if n := s.queryInt(t, `SELECT count(*) FROM mediaassets`); n != 2 {
+		t.Fatalf("assets = %d, want 2", n)
+	}
+}
+
+func TestMediaUploadReplacesCover(t *testing.T) {
+	s, svc, _, _ := newMediaTest(t)
+	ctx := context.Background()
+	adminID, _ := s.account(t, "admin", "admin")
+	gameID := s.game(t, "Quiet Meridian", "8.00")
+
+	if _, err := svc.Upload(ctx, gameID, model.MediaCover, testPNG(t, 300, 400, 10), adminID); err != nil {
+		t.Fatal(err)
+	}
+	cover, err := svc.Upload(ctx, gameID, model.MediaCover, testPNG(t, 300, 400, 20), adminID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var url string
+	if err := s.pool.QueryRow(ctx, `SELECT url FROM gamemedia WHERE gameid=$1 AND kind=$2`, gameID, model.MediaCover).Scan(&url); err != nil {
+		t.Fatalf("covers of the game: %v (want exactly one)", err)
+	}
+	if url != cover.URL {
+		t.Fatalf("cover = %s, want the last upload %s", url, cover.URL)
+	}
+}
+
+func TestMediaUploadRejectsInvalidImages(t *testing.T) {
+	s, svc, store, _ := newMediaTest(t)
+	ctx := context.Background()
+	adminID, _ := s.account(t, "admin", "admin")
+	gameID := s.game(t, "Paper Comet", "3.00")
+
+	for name, data := range map[string][]byte{
+		"not an image": []byte("GIF89a? no"),
+		"too small":    testPNG(t, 64, 64, 0),
+	} {
+		if _, err := svc.Upload(ctx, gameID, model.MediaScreenshot, data, adminID); err == nil {
+			t.Errorf("%s: upload succeeded", name)
+		}
+	}
+	if _, err := svc.Upload(ctx, gameID, "trailer", testPNG(t, 300, 300, 0), adminID); err == nil {
+		t.Error("upload with an unknown kind succeeded")
+	}
+	if store.puts != 0 {
+		t.Fatalf("blobs written for rejected uploads = %d", store.puts)
+	}
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/oidc"
+	"GameStoreAPI/internal/repository"
+)
+
+// OIDCFlowTTL is how long a user has to complete the sign-in at the provider
+const OIDCFlowTTL = 10 * time.Minute
+
+// ErrIdentityConflict is returned when a provider account carries the email of an
+// existing account that could not be linked automatically
+var ErrIdentityConflict = errors.New("an account with this email already exists: sign in with your password and link the provider from your account")
+
+// OIDCService signs users in with external OpenID Connect providers and links provider
+// accounts to existing users.
+type OIDCService struct {
+	Providers  *oidc.Registry
+	Identities *repository.IdentityRepository
+	Users      *repository.AuthRepository
+	Auth       *AuthService // account creation, ban checks and registration throttling
+	Audit      *repository.AuditRepository
+}
+
+func NewOIDCService(p *oidc.Registry, ir *repository.IdentityRepository, u *repository.AuthRepository, as *AuthService,
+	ar *repository.AuditRepository) *OIDCService {
+	return &OIDCService{Providers: p, Identities: ir, Users: u, Auth: as, Audit: ar}
+}
+
+// OIDCResult is the outcome of a provider callback: the signed-in user, or the user a
+// provider was linked to
+type OIDCResult struct {
+	User   *model.Auth
+	Linked bool
+}
+
+// ProviderNames lists the configured providers
+func (s *OIDCService) ProviderNames() []string {
+	return s.Providers.Names()
+}
+
+// Start begins an authorization request and returns the provider URL to send the user to.
+// With linkAuthID set the callback links the provider to that user instead of signing in.
+func (s *OIDCService) Start(ctx context.Context, provider string, linkAuthID *int64) (string, error) {
+	p, err := s.Providers.Get(provider)
+	if err != nil {
+		return "", err
+	}
+	state, err := oidc.RandomString(32)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := oidc.RandomString(32)
+	if err != nil {
+		return "", err
+	}
+	verifier, err := oidc.RandomString(48)
+	if err != nil {
+		return "", err
+	}
+	flow := &model.OIDCFlow{Provider: p.Config.Name, Nonce: nonce, CodeVerifier: verifier, AuthID: linkAuthID,
+		ExpiresAt: time.Now().Add(OIDCFlowTTL)}
+	if err := s.Identities.SaveFlow(ctx, hashToken(state), flow); err != nil {
+		return "", err
+	}
+	return p.AuthCodeURL(ctx, state, nonce, verifier)
+}
+
+// Callback redeems the code the provider redirected back with
+func (s *OIDCService) Callback(ctx context.Context, provider, code, state, ip string) (*OIDCResult, error) {
+	p, err := s.Providers.Get(provider)
+	if err != nil {
+		return nil, err
+	}
+	if code == "" || state == "" {
+		return nil, errors.New("code and state are required")
+	}
+	flow, err := s.Identities.ConsumeFlow(ctx, p.Config.Name, hashToken(state))
+	if err != nil {
+		return nil, err
+	}
+	claims, err := p.Exchange(ctx, code, flow.CodeVerifier, flow.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	if flow.AuthID != nil {
+		u, err := s.link(ctx, *flow.AuthID, p.Config.Name, claims, ip)
+		if err != nil {
+			return nil, err
+		}
+		return &OIDCResult{User: u, Linked: true}, nil
+	}
+	u, err := s.login(ctx, p.Config.Name, claims, ip)
+	if err != nil {
+		return nil, err
+	}
+	return &OIDCResult{User: u}, nil
+}
+
+// login signs in the user linked to the provider account. Unknown provider accounts are
+// linked to the account with the same email when both sides verified it, otherwise a new
+// customer account is created the way public registration does.
+func (s *OIDCService) login(ctx context.Context, provider string, claims *oidc.Claims, ip string) (*model.Auth, error) {
+	email := optionalString(strings.TrimSpace(claims.Email))
+	if id, err := s.Identities.Find(ctx, provider, claims.Subject); err == nil {
+		u, err := s.Users.GetByID(ctx, id.AuthID)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.Auth.checkBan(ctx, u); err != nil {
+			return nil, err
+		}
+		if err := s.Identities.TouchLogin(ctx, id.IdentityID, email); err != nil {
+			return nil, err
+		}
+		return u, nil
+	}
+	if email == nil {
+		return nil, errors.New("the provider did not share an email address")
+	}
+	if existing, err := s.Users.GetByEmail(ctx, *email); err == nil {
+		if !claims.EmailVerified || existing.EmailVerifiedAt == nil {
+			return nil, ErrIdentityConflict
+		}
+		if err := s.Auth.checkBan(ctx, existing); err != nil {
+			return nil, err
+		}
+		if err := s.Identities.Create(ctx, existing.AuthID, provider, claims.Subject, email); err != nil {
+			return nil, err
+		}
+		existing.PasswordHash = ""
+		return existing, s.audit(ctx, existing.AuthID, model.AuditIdentityLinked, provider, "linked by verified email", ip)
+	}
+	authID, err := s.Auth.RegisterExternal(ctx, *email, claims.EmailVerified, ip)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Identities.Create(ctx, authID, provider, claims.Subject, email); err != nil {
+		return nil, err
+	}
+	return s.Users.GetByID(ctx, authID)
+}
+
+// link attaches the provider account to a signed-in user
+func (s *OIDCService) link(ctx context.Context, authID int64, provider string, claims *oidc.Claims, ip string) (*model.Auth, error) {
+	u, err := s.Users.GetByID(ctx, authID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked(u, time.Now()) {
+		return nil, errors.New("account is not active")
+	}
+	if id, err := s.Identities.Find(ctx, provider, claims.Subject); err == nil {
+		if id.AuthID == authID {
+			return u, nil
+		}
+		return nil, errors.New("this provider account is already linked to another user")
+	}
+	if err := s.Identities.Create(ctx, authID, provider, claims.Subject, optionalString(strings.TrimSpace(claims.Email))); err != nil {
+		return nil, err
+	}
+	return u, s.audit(ctx, authID, model.AuditIdentityLinked, provider, "", ip)
+}
+
+// Unlink removes a provider from an account. The last provider of an account without a
+// password cannot be removed, the user would have no way to sign in left.
+func (s *OIDCService) Unlink(ctx context.Context, authID int64, provider, ip string) error {
+	provider = strings.ToLower(provider)
+	list, err := s.Identities.ListByAuthID(ctx, authID)
+	if err != nil {
+		return err
+	}
+	linked := false
+	for _, id := range list {
+		linked = linked || id.Provider == provider
+	}
+	if !linked {
+		return errors.New("identity not found")
+	}
+	hash, err := s.Users.GetPasswordHash(ctx, authID)
+	if err != nil {
+		return err
+	}
+	if hash == model.NoPassword && len(list) == 1 {
+		return errors.New("set a password before unlinking your last sign-in provider")
+	}
+	if err := s.Identities.Delete(ctx, authID, provider); err != nil {
+		return err
+	}
+	return s.audit(ctx, authID, model.AuditIdentityUnlinked, provider, "", ip)
+}
+
+// List returns the providers linked to an account
+func (s *OIDCService) List(ctx context.Context, authID int64) ([]model.Identity, error) {
+	return s.Identities.ListByAuthID(ctx, authID)
+}
+
+func (s *OIDCService) audit(ctx context.Context, authID int64, event, provider, detail, ip string) error {
+	subject := "authid:" + strconv.FormatInt(authID, 10)
+	if detail == "" {
+		detail = "provider " + provider
+	} else {
+		detail = fmt.Sprintf("provider %s: %s", provider, detail)
+	}
+	return s.Audit.Record(ctx, &model.AuditEntry{ActorAuthID: &authID, Event: event, Subject: &subject,
+		Detail: &detail, IPAddress: optionalString(ip)})
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"GameStoreAPI/internal/mailer"
+	"GameStoreAPI/internal/oidc"
+	"GameStoreAPI/internal/oidc/oidctest"
+	"GameStoreAPI/internal/repository"
+)
+
+// newOIDCTest returns a store whose OIDCService signs in through the oidctest provider "stub"
+func newOIDCTest(t *testing.T) (*testStore, *OIDCService, *oidctest.Server) {
+	t.Helper()
+	s := newTestStore(t)
+	srv, err := oidctest.NewServer("store", "store-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	providers, err := oidc.NewRegistry([]oidc.Config{srv.Config("stub", "http://store.test/auth/oidc/stub/callback")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := repository.NewAuthRepository(s.pool)
+	audit := repository.NewAuditRepository(s.pool)
+	lockout := NewLockoutService(repository.NewAuthAttemptRepository(s.pool), audit, DefaultLockoutPolicy)
+	auth := NewAuthService(users, repository.NewCustomerRepository(s.pool), repository.NewAuthTokenRepository(s.pool),
+		repository.NewSessionRepository(s.pool), mailer.NewMemoryMailer(), lockout, "http://store.test")
+	return s, NewOIDCService(providers, repository.NewIdentityRepository(s.pool), users, auth, audit), srv
+}
+
+// signIn starts a flow (linking to linkAuthID when set) and returns the code and state the
+// provider redirects back with
+func signIn(t *testing.T, svc *OIDCService, srv *oidctest.Server, linkAuthID *int64) (string, string) {
+	t.Helper()
+	u, err := svc.Start(context.Background(), "stub", linkAuthID)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	code, state, err := srv.Authorize(u)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	return code, state
+}
+
+func TestOIDCCallbackState(t *testing.T) {
+	s, svc, srv := newOIDCTest(t)
+	ctx := context.Background()
+
+	t.Run("unknown", func(t *testing.T) {
+		code, _ := signIn(t, svc, srv, nil)
+		if _, err := svc.Callback(ctx, "stub", code, "never-issued", ""); err == nil {
+			t.Fatal("callback with an unknown state succeeded")
+		}
+	})
+
+	t.Run("reused", func(t *testing.T) {
+		u, err := svc.Start(ctx, "stub", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, state, err := srv.Authorize(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := svc.Callback(ctx, "stub", code, state, ""); err != nil {
+			t.Fatalf("first callback: %v", err)
+		}
+		// a fresh code for the same authorization request: the state is spent
+		code, state, err = srv.Authorize(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := svc.Callback(ctx, "stub", code, state, ""); err == nil {
+			t.Fatal("second callback with the same state succeeded")
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		code, state := signIn(t, svc, srv, nil)
+		s.exec(t, `UPDATE oidcflows SET expires_at = now() - interval '1 minute'`)
+		if _, err := svc.Callback(ctx, "stub", code, state, ""); err == nil {
+			t.Fatal("callback with an expired state succeeded")
+		}
+	})
+
+	t.Run("other provider", func(t *testing.T) {
+		code, state := signIn(t, svc, srv, nil)
+		if _, err := svc.Callback(ctx, "elsewhere", code, state, ""); !errors.Is(err, oidc.ErrUnknownProvider) {
+			t.Fatalf("callback for another provider: %v, want ErrUnknownProvider", err)
+		}
+	})
+}
+
+func TestOIDCCallbackChecksFlow(t *testing.T) {
+	s, svc, srv := newOIDCTest(t)
+	ctx := context.Background()
+
+	// the ID token must carry the nonce of the stored request
+	code, state := signIn(t, svc, srv, nil)
+	s.exec(t, `UPDATE oidcflows SET nonce = 'another-nonce'`)
+	if _, err := svc.Callback(ctx, "stub", code, state, ""); err == nil {
+		t.Fatal("callback with a nonce mismatch succeeded")
+	}
+
+	// the code can only be redeemed with the verifier of the stored request
+	code, state = signIn(t, svc, srv, nil)
+	s.exec(t, `UPDATE oidcflows SET codeverifier = 'another-verifier-another-verifier-another'`)
+	if _, err := svc.Callback(ctx, "stub", code, state, ""); err == nil {
+		t.Fatal("callback with a wrong PKCE verifier succeeded")
+	}
+
+	if n := s.queryInt(t, `SELECT count(*) FROM identities`); n != 0 {
+		t.Fatalf("%d identities after failed callbacks, want none", n)
+	}
+}
+
+func TestOIDCLoginCreatesAccount(t *testing.T) {
+	s, svc, srv := newOIDCTest(t)
+	ctx := context.Background()
+	srv.SetUser(oidctest.User{Subject: "new-1", Email: "newcomer@example.com", EmailVerified: true})
+
+	code, state := signIn(t, svc, srv, nil)
+	res, err := svc.Callback(ctx, "stub", code, state, "203.0.113.7")
+	if err != nil {
+		t.Fatalf("callback: %v", err)
+	}
+	if res.Linked || res.User.Email != "newcomer@example.com" || res.User.Role != "user" || res.User.EmailVerifiedAt == nil {
+		t.Fatalf("result = %+v, want a new verified customer", res.User)
+	}
+	if n := s.queryInt(t, `SELECT count(*) FROM customers WHERE authid=$1`, res.User.AuthID); n != 1 {
+		t.Fatalf("customer records of the new account = %d, want 1", n)
+	}
+
+	// signing in again finds the same account through the identity
+	code, state = signIn(t, svc, srv, nil)
+	again, err := svc.Callback(ctx, "stub", code, state, "203.0.113.7")
+	if err != nil {
+		t.Fatalf("second sign-in: %v", err)
+	}
+	if again.User.AuthID != res.User.AuthID {
+		t.Fatalf("second sign-in is account %d, want %d", again.User.AuthID, res.User.AuthID)
+	}
+	if n := s.queryInt(t, `SELECT count(*) FROM userauth`); n != 1 {
+		t.Fatalf("accounts = %d, want 1", n)
+	}
+}
+
+func TestOIDCLoginLinksVerifiedEmail(t *testing.T) {
+	s, svc, srv := newOIDCTest(t)
+	ctx := context.Background()
+	authID, _ := s.account(t, "frank", "user") // verified frank@example.com
+	srv.SetUser(oidctest.User{Subject: "frank-sub", Email: "frank@example.com", EmailVerified: true})
+
+	code, state := signIn(t, svc, srv, nil)
+	res, err := svc.Callback(ctx, "stub", code, state, "")
+	if err != nil {
+		t.Fatalf("callback: %v", err)
+	}
+	if res.User.AuthID != authID {
+		t.Fatalf("signed in as %d, want the existing account %d", res.User.AuthID, authID)
+	}
+	if n := s.queryInt(t, `SELECT count(*) FROM identities WHERE authid=$1 AND subject='frank-sub'`, authID); n != 1 {
+		t.Fatalf("identities linked to the account = %d, want 1", n)
+	}
+}
+
+func TestOIDCLoginUnverifiedEmailConflicts(t *testing.T) {
+	s, svc, srv := newOIDCTest(t)
+	ctx := context.Background()
+	s.account(t, "grace", "user")
+	srv.SetUser(oidctest.User{Subject: "grace-sub", Email: "grace@example.com", EmailVerified: false})
+
+	code, state := signIn(t, svc, srv, nil)
+	if _, err := svc.Callback(ctx, "stub", code, state, ""); !errors.Is(err, ErrIdentityConflict) {
+		t.Fatalf("callback: %v, want ErrIdentityConflict", err)
+	}
+	if n := s.queryInt(t, `SELECT count(*) FROM userauth`); n != 1 {
+		t.Fatalf("accounts = %d, want no new one", n)
+	}
+}
+
+func TestOIDCLinkToSignedInUser(t *testing.T) {
+	s, svc, srv := newOIDCTest(t)
+	ctx := context.Background()
+	authID, _ := s.account(t, "heidi", "user")
+	otherID, _ := s.account(t, "ivan", "user")
+	// the provider account's email does not have to match when linking explicitly
+	srv.SetUser(oidctest.User{Subject: "heidi-sub", Email: "heidi.work@example.com", EmailVerified: true})
+
+	code, state := signIn(t, svc, srv, &authID)
+	res, err := svc.Callback(ctx, "stub", code, state, "")
+	if err != nil {
+		t.Fatalf("link callback: %v", err)
+	}
+	if !res.Linked || res.User.AuthID != authID {
+		t.Fatalf("result = linked %v account %d, want linked to %d", res.Linked, res.User.AuthID, authID)
+	}
+
+	// the same provider account cannot be linked to someone else
+	code, state = signIn(t, svc, srv, &otherID)
+	if _, err := svc.Callback(ctx, "stub", code, state, ""); err == nil {
+		t.Fatal("linking a provider account to a second user succeeded")
+	}
+}
@@ -0,0 +1,517 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/pagination"
+	"GameStoreAPI/internal/payment"
+	"GameStoreAPI/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrPaymentInProgress is returned for a Pending payment that is being charged or that the
+// provider already holds (its capture timed out): charging it again could take the money
+// twice, the provider's webhook settles it instead
+var ErrPaymentInProgress = errors.New("payment is being processed by the provider, its outcome will be applied once the provider reports it")
+
+// allowed payment status transitions
+var paymentTransitions = map[string][]string{
+	model.PaymentPending: {model.PaymentPaid, model.PaymentFailed, model.PaymentCancelled},
+	model.PaymentFailed:  {model.PaymentCancelled},
+	model.PaymentPaid:    {model.PaymentRefunded},
+}
+
+func canTransition(from, to string) bool {
+	for _, s := range paymentTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+type PaymentService struct {
+	Repo              *repository.PaymentRepository
+	OrderRepo         *repository.OrderRepository
+	CustomerGamesRepo *repository.CustomerGamesRepository
+	RefundRepo        *repository.RefundRepository
+	PromoRepo         *repository.PromoRepository
+	GiftRepo          *repository.GiftRepository
+	Gateway           payment.PaymentGateway
+}
+
+func NewPaymentService(r *repository.PaymentRepository, or *repository.OrderRepository, cgr *repository.CustomerGamesRepository, rr *repository.RefundRepository, pr *repository.PromoRepository, gr *repository.GiftRepository, gw payment.PaymentGateway) *PaymentService {
+	return &PaymentService{Repo: r, OrderRepo: or, CustomerGamesRepo: cgr, RefundRepo: rr, PromoRepo: pr, GiftRepo: gr, Gateway: gw}
+}
+
+// ListMethods returns the payment methods the store accepts. Not paginated: it is the fixed
+// set of methods, not a list that grows with use.
+func (s *PaymentService) ListMethods(ctx context.Context) ([]model.PaymentMethod, error) {
+	return s.Repo.ListMethods(ctx)
+}
+
+func (s *PaymentService) CreateMethod(ctx context.Context, name string) (int64, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, errors.New("payment method name is required")
+	}
+	return s.Repo.CreateMethod(ctx, name)
+}
+
+// customerOrder loads an order and ensures it belongs to the customer and has been checked out
+func (s *PaymentService) customerOrder(ctx context.Context, customerID, orderID int64) (*model.Order, error) {
+	o, err := s.OrderRepo.GetOrderByID(ctx, orderID)
+	if err != nil || o.CustomerID != customerID {
+		return nil, errors.New("order not found")
+	}
+	if o.TotalPrice == nil {
+		return nil, errors.New("order has not been checked out")
+	}
+	return o, nil
+}
+
+// Pay charges the pending payment of a checked-out order through the gateway.
+// If the last attempt failed, a new Pending payment is created first (optionally with a different method).
+func (s *PaymentService) Pay(ctx context.Context, customerID, orderID int64, methodID *int64, cardToken string) (*model.Payment, error) {
+	o, err := s.customerOrder(ctx, customerID, orderID)
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.Repo.GetLatestByOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if o.CancelledAt != nil {
+		return nil, errors.New("order is cancelled")
+	}
+	switch p.PaymentStatus {
+	case model.PaymentPaid, model.PaymentRefunded:
+		return nil, errors.New("order already paid")
+	case model.PaymentPending:
+		if p.ProviderRef != nil {
+			return nil, ErrPaymentInProgress
+		}
+	case model.PaymentFailed:
+		mid := p.PaymentMethodID
+		if methodID != nil {
+			mid = *methodID
+		}
+		if ok, err := s.Repo.MethodExists(ctx, mid); err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, errors.New("payment method not found")
+		}
+		tx, err := s.Repo.DB.Begin(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("begin tx: %w", err)
+		}
+		defer tx.Rollback(ctx)
+		// under the order lock, only one retry creates the next attempt
+		if _, err := s.OrderRepo.LockOrderTx(ctx, tx, orderID); err != nil {
+			return nil, err
+		}
+		latest, err := s.Repo.GetLatestByOrderTx(ctx, tx, orderID)
+		if err != nil {
+			return nil, err
+		}
+		if latest.PaymentID != p.PaymentID {
+			return nil, ErrPaymentInProgress
+		}
+		newID, err := s.Repo.CreatePaymentTx(ctx, tx, orderID, mid, *o.TotalPrice)
+		if err != nil {
+			return nil, fmt.Errorf("create payment: %w", err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, fmt.Errorf("commit tx: %w", err)
+		}
+		if p, err = s.Repo.GetByID(ctx, newID); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.Charge(ctx, p.PaymentID, cardToken)
+}
+
+// Charge authorizes and captures a Pending payment with the gateway.
+// The payment is claimed before the gateway is called, so it is charged at most once:
+// concurrent and later calls get ErrPaymentInProgress. A decline moves the payment to
+// Failed; a timeout leaves it Pending until the provider's webhook reports the outcome.
+// The returned payment reflects the new status.
+func (s *PaymentService) Charge(ctx context.Context, paymentID int64, cardToken string) (*model.Payment, error) {
+	p, err := s.Repo.GetByID(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if p.PaymentStatus != model.PaymentPending {
+		return nil, fmt.Errorf("payment is %s", p.PaymentStatus)
+	}
+	if p.ProviderRef != nil {
+		return nil, ErrPaymentInProgress
+	}
+	o, err := s.OrderRepo.GetOrderByID(ctx, p.OrderID)
+	if err != nil {
+		return nil, errors.New("order not found")
+	}
+	// never take money for a game the customer already owns
+	if err := s.ensureNotOwned(ctx, o.CustomerID, p.OrderID); err != nil {
+		return nil, err
+	}
+	claimed, err := s.Repo.ClaimCharge(ctx, p.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrPaymentInProgress
+	}
+
+	auth, err := s.Gateway.Authorize(ctx, payment.AuthorizeRequest{
+		PaymentID: p.PaymentID,
+		OrderID:   p.OrderID,
+		Amount:    p.AmountPaid,
+		Currency:  p.Currency,
+		CardToken: cardToken,
+	})
+	if err == nil {
+		if err := s.Repo.SetProviderRef(ctx, p.PaymentID, auth.Reference); err != nil {
+			return nil, err
+		}
+		err = s.Gateway.Capture(ctx, auth.Reference, p.AmountPaid)
+	}
+	switch {
+	case err == nil:
+		if err := s.MarkPaid(ctx, p.PaymentID); err != nil {
+			if err := s.recoverCapture(ctx, p, auth.Reference, err); err != nil {
+				return nil, err
+			}
+		}
+	case errors.Is(err, payment.ErrDeclined):
+		if err := s.MarkFailed(ctx, p.PaymentID); err != nil {
+			return nil, err
+		}
+	case errors.Is(err, payment.ErrTimeout):
+		// outcome unknown: stays Pending, resolved by webhook
+	default:
+		if auth == nil {
+			// refused before anything was authorized: the payment may be charged again
+			if rerr := s.Repo.ReleaseCharge(ctx, p.PaymentID); rerr != nil {
+				return nil, rerr
+			}
+		}
+		return nil, fmt.Errorf("payment gateway: %w", err)
+	}
+	return s.Repo.GetByID(ctx, p.PaymentID)
+}
+
+// recoverCapture handles a capture the gateway took while recording it failed. Unless the
+// payment became Paid meanwhile (a webhook was first), the money is given back and the
+// payment fails. If giving it back fails too, the payment stays Pending with its reference
+// and the provider's payment.captured webhook records it later.
+func (s *PaymentService) recoverCapture(ctx context.Context, p *model.Payment, ref string, cause error) error {
+	if cur, err := s.Repo.GetByID(ctx, p.PaymentID); err == nil && cur.PaymentStatus == model.PaymentPaid {
+		return nil
+	}
+	if err := s.Gateway.Refund(ctx, ref, p.AmountPaid); err != nil {
+		return fmt.Errorf("record payment: %w (captured, left for the provider's webhook: %v)", cause, err)
+	}
+	if err := s.MarkFailed(ctx, p.PaymentID); err != nil {
+		return fmt.Errorf("record payment: %w (capture refunded, marking the payment failed: %v)", cause, err)
+	}
+	return fmt.Errorf("record payment: %w (the capture was refunded)", cause)
+}
+
+func (s *PaymentService) ensureNotOwned(ctx context.Context, customerID, orderID int64) error {
+	gameIDs, err := s.OrderRepo.GetOrderGameIDs(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	ownedGameID, err := s.CustomerGamesRepo.ExistsAnyOwned(ctx, customerID, gameIDs)
+	if err != nil {
+		return fmt.Errorf("ownership check failed: %w", err)
+	}
+	if ownedGameID != 0 {
+		return fmt.Errorf("payment rejected: already own game id=%d", ownedGameID)
+	}
+	return nil
+}
+
+// MarkPaid moves a Pending payment to Paid and grants ownership of the order's games
+// to the buyer in the same transaction. Gift lines become pending gifts for their recipients.
+func (s *PaymentService) MarkPaid(ctx context.Context, paymentID int64) error {
+	p, err := s.Repo.GetByID(ctx, paymentID)
+	if err != nil {
+		return err
+	}
+	if !canTransition(p.PaymentStatus, model.PaymentPaid) {
+		return fmt.Errorf("cannot move payment from %s to %s", p.PaymentStatus, model.PaymentPaid)
+	}
+	o, err := s.OrderRepo.GetOrderByID(ctx, p.OrderID)
+	if err != nil {
+		return errors.New("order not found")
+	}
+	gameIDs, err := s.OrderRepo.GetOrderGameIDs(ctx, p.OrderID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.Repo.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.Repo.UpdateStatusTx(ctx, tx, p.PaymentID, p.PaymentStatus, model.PaymentPaid); err != nil {
+		return err
+	}
+	if err := s.CustomerGamesRepo.CreateCustomerGamesTx(ctx, tx, o.CustomerID, gameIDs); err != nil {
+		return fmt.Errorf("record ownership: %w", err)
+	}
+	if err := s.GiftRepo.CreateForOrderTx(ctx, tx, p.OrderID, o.CustomerID); err != nil {
+		return fmt.Errorf("record gifts: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed moves a Pending payment to Failed
+func (s *PaymentService) MarkFailed(ctx context.Context, paymentID int64) error {
+	p, err := s.Repo.GetByID(ctx, paymentID)
+	if err != nil {
+		return err
+	}
+	if !canTransition(p.PaymentStatus, model.PaymentFailed) {
+		return fmt.Errorf("cannot move payment from %s to %s", p.PaymentStatus, model.PaymentFailed)
+	}
+	tx, err := s.Repo.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.Repo.UpdateStatusTx(ctx, tx, p.PaymentID, p.PaymentStatus, model.PaymentFailed); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ExecuteRefund returns money through the gateway and records the refund: the order
+// item (or every remaining item when OrderItemID is nil) is flagged refunded, ownership
+// of its games is revoked (from gift recipients too), the order's refunded total grows
+// and the payment moves to Refunded once nothing is left. The order row stays locked
+// across the gateway call so concurrent refunds of the same order cannot both go through.
+func (s *PaymentService) ExecuteRefund(ctx context.Context, rf *model.Refund) error {
+	return s.applyRefund(ctx, rf, true)
+}
+
+// ExecuteRefundTx is ExecuteRefund inside the caller's tx, which already holds the lock of
+// the order o (OrderRepository.LockOrderTx). Nothing is recorded unless the tx commits.
+func (s *PaymentService) ExecuteRefundTx(ctx context.Context, tx pgx.Tx, o *model.Order, rf *model.Refund) error {
+	return s.refundLockedTx(ctx, tx, o, rf, true)
+}
+
+func (s *PaymentService) applyRefund(ctx context.Context, rf *model.Refund, viaGateway bool) error {
+	tx, err := s.Repo.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	o, err := s.OrderRepo.LockOrderTx(ctx, tx, rf.OrderID)
+	if err != nil {
+		return err
+	}
+	if err := s.refundLockedTx(ctx, tx, o, rf, viaGateway); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// refundLockedTx records a refund of the order o, whose row the tx holds locked
+func (s *PaymentService) refundLockedTx(ctx context.Context, tx pgx.Tx, o *model.Order, rf *model.Refund, viaGateway bool) error {
+	p, err := s.Repo.GetByIDTx(ctx, tx, rf.PaymentID)
+	if err != nil {
+		return err
+	}
+	if p.PaymentStatus != model.PaymentPaid {
+		return fmt.Errorf("cannot refund a payment that is %s", p.PaymentStatus)
+	}
+	if viaGateway && p.ProviderRef == nil {
+		return errors.New("payment has no provider reference")
+	}
+	gameIDs, amount, err := s.RefundRepo.MarkItemsRefundedTx(ctx, tx, rf.OrderID, rf.OrderItemID)
+	if err != nil {
+		return err
+	}
+	if rf.OrderItemID == nil {
+		// whole order: return whatever has not been refunded yet
+		amount = *o.TotalPrice - o.RefundedTotal
+	}
+	rf.Amount = amount
+
+	if viaGateway {
+		if err := s.Gateway.Refund(ctx, *p.ProviderRef, amount); err != nil {
+			return fmt.Errorf("payment gateway: %w", err)
+		}
+	}
+	if err := s.CustomerGamesRepo.DeleteCustomerGamesTx(ctx, tx, o.CustomerID, gameIDs); err != nil {
+		return fmt.Errorf("revoke ownership: %w", err)
+	}
+	accepted, err := s.GiftRepo.RevokeTx(ctx, tx, rf.OrderID, rf.OrderItemID)
+	if err != nil {
+		return fmt.Errorf("revoke gifts: %w", err)
+	}
+	for _, gf := range accepted {
+		if err := s.CustomerGamesRepo.DeleteCustomerGamesTx(ctx, tx, gf.RecipientID, []int64{gf.GameID}); err != nil {
+			return fmt.Errorf("revoke gift ownership: %w", err)
+		}
+	}
+	if err := s.RefundRepo.CompleteTx(ctx, tx, rf); err != nil {
+		return err
+	}
+	refunded, err := s.OrderRepo.AddRefundedTx(ctx, tx, rf.OrderID, amount)
+	if err != nil {
+		return err
+	}
+	if refunded >= *o.TotalPrice {
+		if err := s.Repo.UpdateStatusTx(ctx, tx, p.PaymentID, model.PaymentPaid, model.PaymentRefunded); err != nil {
+			return err
+		}
+	}
+	rf.Status = model.RefundCompleted
+	return nil
+}
+
+// Cancel moves an unpaid payment to Cancelled, marks its order cancelled and gives back
+// any promo code redemption.
+// Payments the provider may still capture (Pending with a provider reference) cannot be cancelled.
+func (s *PaymentService) Cancel(ctx context.Context, p *model.Payment) error {
+	if !canTransition(p.PaymentStatus, model.PaymentCancelled) {
+		return fmt.Errorf("cannot cancel a payment that is %s", p.PaymentStatus)
+	}
+	if p.PaymentStatus == model.PaymentPending && p.ProviderRef != nil {
+		return errors.New("payment is being processed and cannot be cancelled")
+	}
+	tx, err := s.Repo.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.Repo.UpdateStatusTx(ctx, tx, p.PaymentID, p.PaymentStatus, model.PaymentCancelled); err != nil {
+		return err
+	}
+	if err := s.OrderRepo.CancelOrderTx(ctx, tx, p.OrderID); err != nil {
+		return err
+	}
+	if err := s.PromoRepo.ReleaseTx(ctx, tx, p.OrderID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ListForOrder returns the payment attempts of one of the customer's orders
+func (s *PaymentService) ListForOrder(ctx context.Context, customerID, orderID int64) ([]model.Payment, error) {
+	if _, err := s.customerOrder(ctx, customerID, orderID); err != nil {
+		return nil, err
+	}
+	return s.Repo.ListByOrder(ctx, orderID)
+}
+
+func (s *PaymentService) ListForCustomer(ctx context.Context, customerID int64, p pagination.Params) (*pagination.Page[model.Payment], error) {
+	rows, err := s.Repo.ListByCustomer(ctx, customerID, p)
+	if err != nil {
+		return nil, err
+	}
+	return pagination.NewPage(rows, p, paymentID, func() (int64, error) { return s.Repo.CountByCustomer(ctx, customerID) })
+}
+
+func (s *PaymentService) ListAll(ctx context.Context, status string, p pagination.Params) (*pagination.Page[model.Payment], error) {
+	rows, err := s.Repo.ListAll(ctx, status, p)
+	if err != nil {
+		return nil, err
+	}
+	return pagination.NewPage(rows, p, paymentID, func() (int64, error) { return s.Repo.CountAll(ctx, status) })
+}
+
+func paymentID(p model.Payment) int64 { return p.PaymentID }
+
+// GetWithLogs returns a payment and its status history (admin use)
+func (s *PaymentService) GetWithLogs(ctx context.Context, paymentID int64) (*model.Payment, []model.PaymentLog, error) {
+	p, err := s.Repo.GetByID(ctx, paymentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	logs, err := s.Repo.ListLogs(ctx, paymentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, logs, nil
+}
+
+// HandleWebhook verifies a provider notification and applies it to the payment.
+// Deliveries are idempotent: events for payments already in the target status are ignored.
+// Captures must be for the payment's amount; a refund is only applied when the provider
+// refunded the whole payment, refunds of some items are made through the store.
+func (s *PaymentService) HandleWebhook(ctx context.Context, body []byte, signature string) error {
+	ev, err := s.Gateway.ParseWebhook(body, signature)
+	if err != nil {
+		return err
+	}
+	p, err := s.Repo.GetByID(ctx, ev.PaymentID)
+	if err != nil {
+		return err
+	}
+	if p.ProviderRef != nil && ev.Reference != "" && *p.ProviderRef != ev.Reference {
+		return errors.New("webhook reference does not match payment")
+	}
+	if p.ProviderRef == nil && ev.Reference != "" {
+		if err := s.Repo.SetProviderRef(ctx, p.PaymentID, ev.Reference); err != nil {
+			return err
+		}
+	}
+
+	switch ev.Type {
+	case payment.EventCaptured:
+		if p.PaymentStatus == model.PaymentPaid {
+			return nil
+		}
+		// a capture of another amount does not pay the order, it is left for a manual check
+		if ev.Amount != p.AmountPaid {
+			return fmt.Errorf("provider captured %s but the payment is for %s", ev.Amount, p.AmountPaid)
+		}
+		return s.MarkPaid(ctx, p.PaymentID)
+	case payment.EventFailed:
+		if p.PaymentStatus == model.PaymentFailed {
+			return nil
+		}
+		return s.MarkFailed(ctx, p.PaymentID)
+	case payment.EventRefunded:
+		if p.PaymentStatus == model.PaymentRefunded {
+			return nil
+		}
+		o, err := s.OrderRepo.GetOrderByID(ctx, p.OrderID)
+		if err != nil {
+			return err
+		}
+		switch {
+		case ev.Amount <= o.RefundedTotal:
+			// the refunds the store made itself
+			return nil
+		case ev.Amount != p.AmountPaid:
+			// which items a partial refund was for is unknown here, it has to go through the store
+			return fmt.Errorf("partial refund of %s by the payment provider is not supported, refund the items in the store", ev.Amount)
+		}
+		reason := "refunded by payment provider"
+		return s.applyRefund(ctx, &model.Refund{OrderID: p.OrderID, PaymentID: p.PaymentID, Reason: &reason}, false)
+	}
+	return fmt.Errorf("unsupported webhook event %q", ev.Type)
+}
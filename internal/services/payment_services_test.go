@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/money"
+	"GameStoreAPI/internal/payment"
+)
+
+func TestPayAfterCaptureTimeout(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	authID, customerID := s.account(t, "alice", "user")
+	gameID := s.game(t, "Hollow Signal", "19.99")
+
+	res, err := s.buy(t, authID, gameID, "tok_timeout")
+	if err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+	p, err := s.payments.Repo.GetByID(ctx, res.PaymentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.PaymentStatus != model.PaymentPending || p.ProviderRef == nil {
+		t.Fatalf("payment after capture timeout = %s (ref %v), want Pending with a provider reference", p.PaymentStatus, p.ProviderRef)
+	}
+
+	// the customer retries with a working card while the provider may still capture
+	if _, err := s.payments.Pay(ctx, customerID, res.OrderID, nil, "tok_ok"); !errors.Is(err, ErrPaymentInProgress) {
+		t.Fatalf("Pay retry: got %v, want ErrPaymentInProgress", err)
+	}
+	if _, err := s.payments.Charge(ctx, res.PaymentID, "tok_ok"); !errors.Is(err, ErrPaymentInProgress) {
+		t.Fatalf("Charge retry: got %v, want ErrPaymentInProgress", err)
+	}
+	if n := s.queryInt(t, `SELECT count(*) FROM payments WHERE orderid=$1`, res.OrderID); n != 1 {
+		t.Fatalf("payments of the order = %d, want 1", n)
+	}
+
+	// the webhook settles it
+	body, sig, err := s.gateway.Event("payment.captured", res.PaymentID, p.AmountPaid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.payments.HandleWebhook(ctx, body, sig); err != nil {
+		t.Fatalf("webhook: %v", err)
+	}
+	if !s.owns(t, customerID, gameID) {
+		t.Fatal("game not owned after the captured webhook")
+	}
+	if _, err := s.payments.Pay(ctx, customerID, res.OrderID, nil, "tok_ok"); err == nil {
+		t.Fatal("Pay after the captured webhook succeeded, want already paid")
+	}
+}
+
+// concurrently runs fn n times at once and returns the errors
+func concurrently(n int, fn func() error) []error {
+	errs := make([]error, n)
+	var start, done sync.WaitGroup
+	start.Add(1)
+	for i := range errs {
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			start.Wait()
+			errs[i] = fn()
+		}()
+	}
+	start.Done()
+	done.Wait()
+	return errs
+}
+
+// checkOneCharge expects exactly one nil error, the others ErrPaymentInProgress
+func checkOneCharge(t *testing.T, errs []error) {
+	t.Helper()
+	ok := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			ok++
+		case !errors.Is(err, ErrPaymentInProgress):
+			t.Errorf("concurrent charge: %v, want ErrPaymentInProgress", err)
+		}
+	}
+	if ok != 1 {
+		t.Fatalf("%d concurrent charges went through, want 1", ok)
+	}
+}
+
+func TestChargeConcurrently(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	authID, customerID := s.account(t, "pia", "user")
+	gameID := s.game(t, "Lantern Drift", "15.00")
+	res, err := s.buy(t, authID, gameID, "tok_decline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paymentID := s.queryInt(t, `INSERT INTO payments (orderid, paymentmethodid, amountpaid, currency)
+		SELECT orderid, paymentmethodid, amountpaid, currency FROM payments WHERE paymentid=$1 RETURNING paymentid`, res.PaymentID)
+
+	checkOneCharge(t, concurrently(5, func() error {
+		_, err := s.payments.Charge(ctx, paymentID, "tok_ok")
+		return err
+	}))
+	if held := s.gateway.Held(fmt.Sprintf("fake_%d", paymentID)); held.String() != "15.00" {
+		t.Fatalf("provider holds %s, want one charge of 15.00", held)
+	}
+	if !s.owns(t, customerID, gameID) {
+		t.Fatal("game not owned after the charge")
+	}
+}
+
+func TestPayRetryConcurrently(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	authID, customerID := s.account(t, "quinn", "user")
+	gameID := s.game(t, "Velvet Static", "9.00")
+	res, err := s.buy(t, authID, gameID, "tok_decline")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every retry sees the Failed attempt, only one may create and charge the next one
+	checkOneCharge(t, concurrently(5, func() error {
+		_, err := s.payments.Pay(ctx, customerID, res.OrderID, nil, "tok_ok")
+		return err
+	}))
+	if n := s.queryInt(t, `SELECT count(*) FROM payments WHERE orderid=$1`, res.OrderID); n != 2 {
+		t.Fatalf("payments of the order = %d, want the declined one and one retry", n)
+	}
+}
+
+func TestChargeRefundsUnrecordedCapture(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	authID, customerID := s.account(t, "rosa", "user")
+	gameID := s.game(t, "Quarry Lights", "20.00")
+	// recording the ownership fails after the provider captured the money
+	s.exec(t, `CREATE FUNCTION refuse_ownership() RETURNS trigger LANGUAGE plpgsql AS 'BEGIN RAISE EXCEPTION ''refused''; END'`)
+	s.exec(t, `CREATE TRIGGER refuse_ownership BEFORE INSERT ON customer_games FOR EACH ROW EXECUTE FUNCTION refuse_ownership()`)
+
+	res, err := s.buy(t, authID, gameID, "tok_ok")
+	if err == nil {
+		t.Fatalf("checkout succeeded although the payment could not be recorded: %+v", res)
+	}
+	paymentID := s.queryInt(t, `SELECT p.paymentid FROM payments p JOIN orders o ON o.orderid = p.orderid WHERE o.customerid=$1`, customerID)
+	p, err := s.payments.Repo.GetByID(ctx, paymentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.PaymentStatus != model.PaymentFailed {
+		t.Fatalf("payment = %s, want Failed", p.PaymentStatus)
+	}
+	if held := s.gateway.Held(*p.ProviderRef); held != 0 {
+		t.Fatalf("provider still holds %s after the failed recording", held)
+	}
+}
+
+// webhook delivers a signed provider event for the payment
+func (s *testStore) webhook(t *testing.T, typ string, paymentID int64, amount string) error {
+	t.Helper()
+	a, err := money.Parse(amount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, sig, err := s.gateway.Event(typ, paymentID, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.payments.HandleWebhook(context.Background(), body, sig)
+}
+
+func (s *testStore) paymentStatus(t *testing.T, paymentID int64) string {
+	t.Helper()
+	p, err := s.payments.Repo.GetByID(context.Background(), paymentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.PaymentStatus
+}
+
+func TestWebhookCaptureAmount(t *testing.T) {
+	s := newTestStore(t)
+	authID, customerID := s.account(t, "bea", "user")
+	gameID := s.game(t, "Glass Canal", "19.00")
+	res, err := s.buy(t, authID, gameID, "tok_timeout")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.webhook(t, payment.EventCaptured, res.PaymentID, "1.00"); err == nil {
+		t.Fatal("a capture of another amount paid the order")
+	}
+	if st := s.paymentStatus(t, res.PaymentID); st != model.PaymentPending || s.owns(t, customerID, gameID) {
+		t.Fatalf("payment = %s after a wrong capture, want Pending and no game", st)
+	}
+	if err := s.webhook(t, payment.EventCaptured, res.PaymentID, "19.00"); err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+	if st := s.paymentStatus(t, res.PaymentID); st != model.PaymentPaid {
+		t.Fatalf("payment = %s, want Paid", st)
+	}
+}
+
+func TestWebhookRefundAmount(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	authID, customerID := s.account(t, "cole", "user")
+	first := s.game(t, "Moth Parade", "10.00")
+	second := s.game(t, "Salt Choir", "5.00")
+	for _, id := range []int64{first, second} {
+		if err := s.cart.Add(ctx, authID, id, 1, "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, err := s.cart.Checkout(ctx, authID, model.CheckoutRequest{PaymentMethodID: s.creditCard(t), CardToken: "tok_ok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	itemID := s.queryInt(t, `SELECT orderitemid FROM orderitems WHERE orderid=$1 AND gameid=$2`, res.OrderID, second)
+	if _, err := s.refunds.RequestRefund(ctx, authID, customerID, res.OrderID, &itemID, ""); err != nil {
+		t.Fatalf("refund item: %v", err)
+	}
+
+	// the provider reports the store's own refund: nothing to do
+	if err := s.webhook(t, payment.EventRefunded, res.PaymentID, "5.00"); err != nil {
+		t.Fatalf("refund the store made: %v", err)
+	}
+	// a partial refund made at the provider cannot be mapped to an item
+	if err := s.webhook(t, payment.EventRefunded, res.PaymentID, "8.00"); err == nil {
+		t.Fatal("partial provider refund was applied")
+	}
+	if st := s.paymentStatus(t, res.PaymentID); st != model.PaymentPaid || !s.owns(t, customerID, first) {
+		t.Fatalf("payment = %s after the partial refund, want Paid with the game kept", st)
+	}
+	if err := s.webhook(t, payment.EventRefunded, res.PaymentID, "15.00"); err != nil {
+		t.Fatalf("full refund: %v", err)
+	}
+	if st := s.paymentStatus(t, res.PaymentID); st != model.PaymentRefunded || s.owns(t, customerID, first) {
+		t.Fatalf("payment = %s after the full refund, want Refunded and the game revoked", st)
+	}
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/money"
+	"GameStoreAPI/internal/repository"
+)
+
+// DefaultBaseCurrency is the currency games.price is stored in when BASE_CURRENCY is not set
+const DefaultBaseCurrency = "USD"
+
+// PricingService resolves game prices in a caller's currency: an explicit regional price
+// wins, otherwise the base price is converted through the exchange-rate table and rounded
+// to the currency's minor unit. A running sale is then applied on top.
+type PricingService struct {
+	Repo         *repository.PricingRepository
+	GameRepo     *repository.GameRepository
+	CustomerRepo *repository.CustomerRepository
+	BaseCurrency string
+}
+
+func NewPricingService(r *repository.PricingRepository, gr *repository.GameRepository, cr *repository.CustomerRepository, baseCurrency string) *PricingService {
+	base, err := NormalizeCurrency(baseCurrency)
+	if err != nil {
+		base = DefaultBaseCurrency
+	}
+	return &PricingService{Repo: r, GameRepo: gr, CustomerRepo: cr, BaseCurrency: base}
+}
+
+// NormalizeCurrency upper-cases and validates a three-letter ISO 4217 code
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 {
+		return "", errors.New("currency must be a 3-letter ISO code")
+	}
+	for _, ch := range code {
+		if ch < 'A' || ch > 'Z' {
+			return "", errors.New("currency must be a 3-letter ISO code")
+		}
+	}
+	return code, nil
+}
+
+// Resolve normalizes a requested currency, defaulting to the base currency when empty,
+// and checks that prices can be produced in it (base currency or a configured rate).
+func (s *PricingService) Resolve(ctx context.Context, currency string) (string, error) {
+	if strings.TrimSpace(currency) == "" {
+		return s.BaseCurrency, nil
+	}
+	code, err := NormalizeCurrency(currency)
+	if err != nil {
+		return "", err
+	}
+	if code == s.BaseCurrency {
+		return code, nil
+	}
+	_, ok, err := s.Repo.GetRate(ctx, code)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("currency %s is not supported", code)
+	}
+	return code, nil
+}
+
+// CustomerCurrency returns the preferred currency of the customer behind authID,
+// or the base currency when the caller is not a customer or has no preference.
+func (s *PricingService) CustomerCurrency(ctx context.Context, authID int64) string {
+	cust, err := s.CustomerRepo.GetByAuthID(ctx, authID)
+	if err != nil || cust.Currency == nil || *cust.Currency == "" {
+		return s.BaseCurrency
+	}
+	return *cust.Currency
+}
+
+// GamePrice returns what one game costs right now in the currency (which must already
+// be resolved), sale included.
+func (s *PricingService) GamePrice(ctx context.Context, gameID int64, currency string) (money.Amount, error) {
+	g, err := s.CurrentGame(ctx, gameID, currency)
+	if err != nil {
+		return 0, err
+	}
+	return g.Price, nil
+}
+
+// CurrentGame returns the catalog state of a game (including soft-deleted ones) priced in the currency
+func (s *PricingService) CurrentGame(ctx context.Context, gameID int64, currency string) (*model.Game, error) {
+	g, err := s.GameRepo.GetByID(ctx, gameID)
+	if err != nil {
+		return nil, err
+	}
+	games := []model.Game{*g}
+	if err := s.ApplyCurrency(ctx, games, currency); err != nil {
+		return nil, err
+	}
+	return &games[0], nil
+}
+
+// ApplyCurrency rewrites the original and effective price of each game into the
+// currency (which must already be resolved)
+func (s *PricingService) ApplyCurrency(ctx context.Context, games []model.Game, currency string) error {
+	bases := make(map[int64]money.Amount, len(games))
+	for _, g := range games {
+		bases[g.GameID] = g.OriginalPrice
+	}
+	prices, err := s.pricesIn(ctx, bases, currency)
+	if err != nil {
+		return err
+	}
+	for i := range games {
+		g := &games[i]
+		g.OriginalPrice = prices[g.GameID]
+		g.Price = g.OriginalPrice
+		if g.Sale != nil {
+			if g.Price, err = s.salePrice(ctx, g.Sale, g.OriginalPrice, currency); err != nil {
+				return err
+			}
+		}
+		g.Currency = currency
+	}
+	return nil
+}
+
+// salePrice applies a sale to a price already expressed in the currency
+func (s *PricingService) salePrice(ctx context.Context, sale *model.GameSale, original money.Amount, currency string) (money.Amount, error) {
+	if sale.DiscountPercent != nil {
+		return original.MulRat(big.NewRat(100*100-sale.DiscountPercent.Cents(), 100*100)).Round(currency), nil
+	}
+	if sale.SalePrice == nil {
+		return original, nil
+	}
+	p, err := s.FromBase(ctx, *sale.SalePrice, currency)
+	if err != nil {
+		return 0, err
+	}
+	if p > original {
+		p = original
+	}
+	return p, nil
+}
+
+// FromBase converts an amount expressed in the base currency into the currency
+func (s *PricingService) FromBase(ctx context.Context, amount money.Amount, currency string) (money.Amount, error) {
+	if currency == s.BaseCurrency {
+		return amount, nil
+	}
+	rate, ok, err := s.Repo.GetRate(ctx, currency)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("no exchange rate for currency %s", currency)
+	}
+	return amount.MulRat(rate).Round(currency), nil
+}
+
+func (s *PricingService) pricesIn(ctx context.Context, bases map[int64]money.Amount, currency string) (map[int64]money.Amount, error) {
+	if currency == s.BaseCurrency {
+		return bases, nil
+	}
+	ids := make([]int64, 0, len(bases))
+	for id := range bases {
+		ids = append(ids, id)
+	}
+	regional, err := s.Repo.GetRegionalPrices(ctx, ids, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	var rate *big.Rat
+	out := make(map[int64]money.Amount, len(bases))
+	for id, base := range bases {
+		if p, ok := regional[id]; ok {
+			out[id] = p
+			continue
+		}
+		if rate == nil {
+			r, ok, err := s.Repo.GetRate(ctx, currency)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, fmt.Errorf("no price or exchange rate for currency %s", currency)
+			}
+			rate = r
+		}
+		out[id] = base.MulRat(rate).Round(currency)
+	}
+	return out, nil
+}
+
+func (s *PricingService) ListGamePrices(ctx context.Context, gameID int64) ([]model.GamePrice, error) {
+	return s.Repo.ListGamePrices(ctx, gameID)
+}
+
+// SetGamePrice stores an explicit regional price for a game
+func (s *PricingService) SetGamePrice(ctx context.Context, gameID int64, currency string, amount money.Amount) error {
+	code, err := NormalizeCurrency(currency)
+	if err != nil {
+		return err
+	}
+	if code == s.BaseCurrency {
+		return errors.New("the base currency price is the game's price; update the game instead")
+	}
+	if amount < 0 {
+		return errors.New("amount must be >= 0")
+	}
+	if amount.Round(code) != amount {
+		return fmt.Errorf("amount has more decimals than %s allows", code)
+	}
+	return s.Repo.UpsertGamePrice(ctx, gameID, code, amount)
+}
+
+func (s *PricingService) DeleteGamePrice(ctx context.Context, gameID int64, currency string) error {
+	code, err := NormalizeCurrency(currency)
+	if err != nil {
+		return err
+	}
+	return s.Repo.DeleteGamePrice(ctx, gameID, code)
+}
+
+// ListRates returns the exchange rates. Not paginated: there is at most one per currency.
+func (s *PricingService) ListRates(ctx context.Context) ([]model.ExchangeRate, error) {
+	return s.Repo.ListRates(ctx)
+}
+
+// SetRate stores the exchange rate of a currency against the base currency
+func (s *PricingService) SetRate(ctx context.Context, currency, rate string) error {
+	code, err := NormalizeCurrency(currency)
+	if err != nil {
+		return err
+	}
+	if code == s.BaseCurrency {
+		return errors.New("cannot set a rate for the base currency")
+	}
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(rate))
+	if !ok || r.Sign() <= 0 {
+		return errors.New("rate must be a positive decimal number")
+	}
+	return s.Repo.UpsertRate(ctx, code, r.FloatString(8))
+}
+
+func (s *PricingService) DeleteRate(ctx context.Context, currency string) error {
+	code, err := NormalizeCurrency(currency)
+	if err != nil {
+		return err
+	}
+	return s.Repo.DeleteRate(ctx, code)
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/money"
+	"GameStoreAPI/internal/pagination"
+	"GameStoreAPI/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type PromoService struct {
+	Repo    *repository.PromoRepository
+	Pricing *PricingService
+}
+
+func NewPromoService(r *repository.PromoRepository, ps *PricingService) *PromoService {
+	return &PromoService{Repo: r, Pricing: ps}
+}
+
+// PromoDiscount is what a promo code takes off a cart: the total discount and its
+// allocation over the eligible order items (keyed by orderitemid), which refunds use.
+type PromoDiscount struct {
+	Discount money.Amount
+	PerItem  map[int64]money.Amount
+}
+
+func (s *PromoService) validate(p *model.PromoCode) error {
+	p.Code = strings.ToUpper(strings.TrimSpace(p.Code))
+	if p.Code == "" {
+		return errors.New("code is required")
+	}
+	switch p.DiscountType {
+	case model.PromoPercent:
+		if p.Value <= 0 || p.Value > money.FromUnits(100) {
+			return errors.New("percent value must be between 0 and 100")
+		}
+	case model.PromoFixed:
+		if p.Value <= 0 {
+			return errors.New("fixed value must be > 0")
+		}
+	default:
+		return errors.New("discounttype must be 'percent' or 'fixed'")
+	}
+	if p.MinimumTotal != nil && *p.MinimumTotal < 0 {
+		return errors.New("minimumtotal must be >= 0")
+	}
+	if p.MaxRedemptions != nil && *p.MaxRedemptions <= 0 {
+		return errors.New("maxredemptions must be > 0")
+	}
+	if p.MaxPerCustomer != nil && *p.MaxPerCustomer <= 0 {
+		return errors.New("maxpercustomer must be > 0")
+	}
+	if p.StartsAt != nil && p.ExpiresAt != nil && !p.ExpiresAt.After(*p.StartsAt) {
+		return errors.New("expires_at must be after starts_at")
+	}
+	for _, t := range p.Targets {
+		switch t.TargetType {
+		case model.PromoTargetGame, model.PromoTargetGenre, model.PromoTargetDeveloper:
+		default:
+			return fmt.Errorf("invalid target type %q", t.TargetType)
+		}
+		if t.TargetID <= 0 {
+			return errors.New("invalid target id")
+		}
+	}
+	return nil
+}
+
+func (s *PromoService) Create(ctx context.Context, p *model.PromoCode) (int64, error) {
+	if err := s.validate(p); err != nil {
+		return 0, err
+	}
+	return s.Repo.Create(ctx, p)
+}
+
+func (s *PromoService) Update(ctx context.Context, p *model.PromoCode) error {
+	if err := s.validate(p); err != nil {
+		return err
+	}
+	return s.Repo.Update(ctx, p)
+}
+
+func (s *PromoService) Delete(ctx context.Context, id int64) error {
+	return s.Repo.Delete(ctx, id)
+}
+
+func (s *PromoService) Get(ctx context.Context, id int64) (*model.PromoCode, error) {
+	return s.Repo.GetByID(ctx, id)
+}
+
+func (s *PromoService) List(ctx context.Context, p pagination.Params) (*pagination.Page[model.PromoCode], error) {
+	rows, err := s.Repo.List(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return pagination.NewPage(rows, p, func(pc model.PromoCode) int64 { return pc.PromoID }, func() (int64, error) { return s.Repo.Count(ctx) })
+}
+
+// ListRedemptions returns the redemptions of a promo code, newest first
+func (s *PromoService) ListRedemptions(ctx context.Context, id int64, p pagination.Params) (*pagination.Page[model.PromoRedemption], error) {
+	rows, err := s.Repo.ListRedemptions(ctx, id, p)
+	if err != nil {
+		return nil, err
+	}
+	return pagination.NewPage(rows, p, func(r model.PromoRedemption) int64 { return r.RedemptionID }, func() (int64, error) { return s.Repo.CountRedemptions(ctx, id) })
+}
+
+// checkUsable verifies the validity period and the global and per-customer caps
+func checkUsable(p *model.PromoCode, customerUses int, now time.Time) error {
+	if p.DeletedAt != nil {
+		return errors.New("promo code not found")
+	}
+	if p.StartsAt != nil && now.Before(*p.StartsAt) {
+		return errors.New("promo code is not active yet")
+	}
+	if p.ExpiresAt != nil && !now.Before(*p.ExpiresAt) {
+		return errors.New("promo code has expired")
+	}
+	if p.MaxRedemptions != nil && p.RedemptionCount >= *p.MaxRedemptions {
+		return errors.New("promo code has reached its usage limit")
+	}
+	if p.MaxPerCustomer != nil && customerUses >= *p.MaxPerCustomer {
+		return errors.New("you have already used this promo code")
+	}
+	return nil
+}
+
+// compute works out the discount of a usable promo on the cart items (priced in currency)
+func (s *PromoService) compute(ctx context.Context, p *model.PromoCode, items []model.CartItem, subtotal money.Amount, currency string) (*PromoDiscount, error) {
+	if p.MinimumTotal != nil {
+		min, err := s.Pricing.FromBase(ctx, *p.MinimumTotal, currency)
+		if err != nil {
+			return nil, err
+		}
+		if subtotal < min {
+			return nil, fmt.Errorf("cart total must be at least %s %s for this code", min, currency)
+		}
+	}
+
+	gameIDs := make([]int64, 0, len(items))
+	for _, it := range items {
+		gameIDs = append(gameIDs, it.GameID)
+	}
+	eligible, err := s.Repo.EligibleGameIDs(ctx, p.PromoID, gameIDs)
+	if err != nil {
+		return nil, err
+	}
+	var base money.Amount
+	var lines []model.CartItem
+	for _, it := range items {
+		if eligible[it.GameID] {
+			base += it.Subtotal
+			lines = append(lines, it)
+		}
+	}
+	if len(lines) == 0 || base == 0 {
+		return nil, errors.New("promo code does not apply to any item in your cart")
+	}
+
+	var discount money.Amount
+	if p.DiscountType == model.PromoPercent {
+		discount = base.MulRat(big.NewRat(p.Value.Cents(), 100*100)).Round(currency)
+	} else {
+		discount, err = s.Pricing.FromBase(ctx, p.Value, currency)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if discount > base {
+		discount = base
+	}
+
+	// allocate proportionally, the rounding remainder goes to the last eligible line
+	perItem := make(map[int64]money.Amount, len(lines))
+	var allocated money.Amount
+	for i, it := range lines {
+		share := discount - allocated
+		if i < len(lines)-1 {
+			share = discount.MulRat(big.NewRat(it.Subtotal.Cents(), base.Cents())).Round(currency)
+		}
+		perItem[it.OrderItemID] = share
+		allocated += share
+	}
+	return &PromoDiscount{Discount: discount, PerItem: perItem}, nil
+}
+
+// Check validates a code against a customer's cart and returns the promo and its discount
+func (s *PromoService) Check(ctx context.Context, code string, customerID int64, items []model.CartItem, subtotal money.Amount, currency string) (*model.PromoCode, *PromoDiscount, error) {
+	p, err := s.Repo.GetByCode(ctx, strings.TrimSpace(code))
+	if err != nil {
+		return nil, nil, err
+	}
+	d, err := s.evaluate(ctx, p, customerID, items, subtotal, currency)
+	return p, d, err
+}
+
+// Quote re-evaluates the promo applied to an open order
+func (s *PromoService) Quote(ctx context.Context, promoID, customerID int64, items []model.CartItem, subtotal money.Amount, currency string) (*model.PromoCode, *PromoDiscount, error) {
+	p, err := s.Repo.GetByID(ctx, promoID)
+	if err != nil {
+		return nil, nil, err
+	}
+	d, err := s.evaluate(ctx, p, customerID, items, subtotal, currency)
+	return p, d, err
+}
+
+func (s *PromoService) evaluate(ctx context.Context, p *model.PromoCode, customerID int64, items []model.CartItem, subtotal money.Amount, currency string) (*PromoDiscount, error) {
+	uses, err := s.Repo.CountCustomerRedemptions(ctx, p.PromoID, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUsable(p, uses, time.Now()); err != nil {
+		return nil, err
+	}
+	return s.compute(ctx, p, items, subtotal, currency)
+}
+
+// RedeemTx locks the promo row, re-checks every cap inside the checkout transaction and
+// records the redemption, so concurrent checkouts cannot exceed the limits.
+func (s *PromoService) RedeemTx(ctx context.Context, tx pgx.Tx, promoID, customerID, orderID int64, items []model.CartItem, subtotal money.Amount, currency string) (*PromoDiscount, error) {
+	p, err := s.Repo.LockTx(ctx, tx, promoID)
+	if err != nil {
+		return nil, err
+	}
+	uses, err := s.Repo.CountCustomerRedemptionsTx(ctx, tx, promoID, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUsable(p, uses, time.Now()); err != nil {
+		return nil, err
+	}
+	d, err := s.compute(ctx, p, items, subtotal, currency)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Repo.RedeemTx(ctx, tx, promoID, customerID, orderID, d.Discount, currency); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/pagination"
+	"GameStoreAPI/internal/repository"
+)
+
+// DefaultRefundWindow is how long after payment a customer may refund without admin review
+const DefaultRefundWindow = 14 * 24 * time.Hour
+
+type RefundService struct {
+	Repo              *repository.RefundRepository
+	OrderRepo         *repository.OrderRepository
+	PaymentRepo       *repository.PaymentRepository
+	CustomerGamesRepo *repository.CustomerGamesRepository
+	Payments          *PaymentService
+	Window            time.Duration
+}
+
+func NewRefundService(r *repository.RefundRepository, or *repository.OrderRepository, pr *repository.PaymentRepository, cgr *repository.CustomerGamesRepository, ps *PaymentService, window time.Duration) *RefundService {
+	if window <= 0 {
+		window = DefaultRefundWindow
+	}
+	return &RefundService{Repo: r, OrderRepo: or, PaymentRepo: pr, CustomerGamesRepo: cgr, Payments: ps, Window: window}
+}
+
+// paidOrder loads a paid order of the customer together with its Paid payment
+func (s *RefundService) paidOrder(ctx context.Context, customerID, orderID int64) (*model.Order, *model.Payment, error) {
+	o, err := s.OrderRepo.GetOrderByID(ctx, orderID)
+	if err != nil || o.CustomerID != customerID {
+		return nil, nil, errors.New("order not found")
+	}
+	p, err := s.PaymentRepo.GetLatestByOrder(ctx, orderID)
+	if err != nil || p.PaymentStatus != model.PaymentPaid {
+		return nil, nil, errors.New("order has no refundable payment")
+	}
+	return o, p, nil
+}
+
+// RequestRefund lets a customer refund a whole order or a single order item.
+// Within the refund window, before any of the games was downloaded and before any gift
+// among the items was accepted (or downloaded) by its recipient, the refund is executed
+// immediately; otherwise it is recorded as Requested for an admin to review.
+// Everything is decided and done under the order lock, so concurrent requests for the same
+// order are taken one after the other and each sees what the previous one did.
+func (s *RefundService) RequestRefund(ctx context.Context, authID, customerID, orderID int64, orderItemID *int64, reason string) (*model.Refund, error) {
+	_, p, err := s.paidOrder(ctx, customerID, orderID)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.Repo.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	o, err := s.OrderRepo.LockOrderTx(ctx, tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	amount, items, gameIDs, err := s.Repo.RefundableAmountTx(ctx, tx, orderID, orderItemID)
+	if err != nil {
+		return nil, err
+	}
+	if items == 0 {
+		return nil, errors.New("nothing left to refund")
+	}
+	open, err := s.Repo.HasOpenRequestTx(ctx, tx, orderID, orderItemID)
+	if err != nil {
+		return nil, err
+	}
+	if open {
+		return nil, errors.New("a refund request is already pending for this order")
+	}
+
+	rf := &model.Refund{
+		OrderID:     o.OrderID,
+		OrderItemID: orderItemID,
+		PaymentID:   p.PaymentID,
+		Amount:      amount,
+		Status:      model.RefundRequested,
+		RequestedBy: &authID,
+	}
+	if r := strings.TrimSpace(reason); r != "" {
+		rf.Reason = &r
+	}
+
+	// the buyer's own games; gifts are judged by what their recipients did with them
+	downloaded, err := s.CustomerGamesRepo.AnyDownloadedTx(ctx, tx, customerID, gameIDs)
+	if err != nil {
+		return nil, err
+	}
+	claimed, err := s.Repo.AnyGiftClaimedTx(ctx, tx, orderID, orderItemID)
+	if err != nil {
+		return nil, err
+	}
+	inWindow := p.PaidAt != nil && time.Since(*p.PaidAt) <= s.Window
+	if inWindow && !downloaded && !claimed {
+		err = s.Payments.ExecuteRefundTx(ctx, tx, o, rf)
+	} else {
+		rf.RefundID, err = s.Repo.CreateTx(ctx, tx, rf)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+	return s.Repo.GetByID(ctx, rf.RefundID)
+}
+
+// RefundByAdmin refunds an order (or one item) immediately, regardless of the window
+func (s *RefundService) RefundByAdmin(ctx context.Context, adminAuthID, orderID int64, orderItemID *int64, reason string) (*model.Refund, error) {
+	o, err := s.OrderRepo.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return nil, errors.New("order not found")
+	}
+	_, p, err := s.paidOrder(ctx, o.CustomerID, orderID)
+	if err != nil {
+		return nil, err
+	}
+	rf := &model.Refund{
+		OrderID:     orderID,
+		OrderItemID: orderItemID,
+		PaymentID:   p.PaymentID,
+		RequestedBy: &adminAuthID,
+		ReviewedBy:  &adminAuthID,
+	}
+	if r := strings.TrimSpace(reason); r != "" {
+		rf.Reason = &r
+	}
+	if err := s.Payments.ExecuteRefund(ctx, rf); err != nil {
+		return nil, err
+	}
+	return s.Repo.GetByID(ctx, rf.RefundID)
+}
+
+// Approve executes a Requested refund
+func (s *RefundService) Approve(ctx context.Context, refundID, adminAuthID int64, comment string) (*model.Refund, error) {
+	rf, err := s.Repo.GetByID(ctx, refundID)
+	if err != nil {
+		return nil, err
+	}
+	if rf.Status != model.RefundRequested {
+		return nil, errors.New("refund already reviewed")
+	}
+	rf.ReviewedBy = &adminAuthID
+	if c := strings.TrimSpace(comment); c != "" {
+		rf.ReviewComment = &c
+	}
+	if err := s.Payments.ExecuteRefund(ctx, rf); err != nil {
+		return nil, err
+	}
+	return s.Repo.GetByID(ctx, refundID)
+}
+
+// Reject declines a Requested refund
+func (s *RefundService) Reject(ctx context.Context, refundID, adminAuthID int64, comment string) error {
+	var c *string
+	if t := strings.TrimSpace(comment); t != "" {
+		c = &t
+	}
+	return s.Repo.Reject(ctx, refundID, adminAuthID, c)
+}
+
+// CancelOrder cancels a checked-out order that has not been paid
+func (s *RefundService) CancelOrder(ctx context.Context, customerID, orderID int64) error {
+	o, err := s.OrderRepo.GetOrderByID(ctx, orderID)
+	if err != nil || o.CustomerID != customerID {
+		return errors.New("order not found")
+	}
+	if o.TotalPrice == nil {
+		return errors.New("order has not been checked out")
+	}
+	p, err := s.PaymentRepo.GetLatestByOrder(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	if p.PaymentStatus == model.PaymentPaid {
+		return errors.New("order is paid: request a refund instead")
+	}
+	return s.Payments.Cancel(ctx, p)
+}
+
+func (s *RefundService) ListForCustomer(ctx context.Context, customerID int64, p pagination.Params) (*pagination.Page[model.Refund], error) {
+	rows, err := s.Repo.ListByCustomer(ctx, customerID, p)
+	if err != nil {
+		return nil, err
+	}
+	return pagination.NewPage(rows, p, refundID, func() (int64, error) { return s.Repo.CountByCustomer(ctx, customerID) })
+}
+
+func (s *RefundService) ListAll(ctx context.Context, status string, p pagination.Params) (*pagination.Page[model.Refund], error) {
+	rows, err := s.Repo.ListAll(ctx, status, p)
+	if err != nil {
+		return nil, err
+	}
+	return pagination.NewPage(rows, p, refundID, func() (int64, error) { return s.Repo.CountAll(ctx, status) })
+}
+
+func refundID(rf model.Refund) int64 { return rf.RefundID }
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"GameStoreAPI/internal/model"
+)
+
+// buyGift checks out the game as a gift from the buyer to the recipient (a username)
+func (s *testStore) buyGift(t *testing.T, buyerAuthID, gameID int64, recipient string) *model.CheckoutResult {
+	t.Helper()
+	ctx := context.Background()
+	if err := s.cart.Add(ctx, buyerAuthID, gameID, 1, recipient, "enjoy"); err != nil {
+		t.Fatalf("add gift to cart: %v", err)
+	}
+	res, err := s.cart.Checkout(ctx, buyerAuthID, model.CheckoutRequest{PaymentMethodID: s.creditCard(t), CardToken: "tok_ok"})
+	if err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+	return res
+}
+
+func TestRefundPendingGift(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	buyerAuth, buyerID := s.account(t, "judy", "user")
+	s.account(t, "kim", "user")
+	gameID := s.game(t, "Saltmarsh", "11.00")
+	res := s.buyGift(t, buyerAuth, gameID, "kim")
+
+	rf, err := s.refunds.RequestRefund(ctx, buyerAuth, buyerID, res.OrderID, nil, "wrong friend")
+	if err != nil {
+		t.Fatalf("refund: %v", err)
+	}
+	if rf.Status != model.RefundCompleted {
+		t.Fatalf("refund of an unclaimed gift = %s, want Completed", rf.Status)
+	}
+	if n := s.queryInt(t, `SELECT count(*) FROM gifts WHERE orderid=$1 AND status='revoked'`, res.OrderID); n != 1 {
+		t.Fatalf("revoked gifts = %d, want 1", n)
+	}
+}
+
+func TestRefundAcceptedGiftNeedsReview(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	buyerAuth, buyerID := s.account(t, "leo", "user")
+	_, recipientID := s.account(t, "mia", "user")
+	gameID := s.game(t, "Brightwater", "16.00")
+	res := s.buyGift(t, buyerAuth, gameID, "mia")
+
+	giftID := s.queryInt(t, `SELECT giftid FROM gifts WHERE orderid=$1`, res.OrderID)
+	if _, err := s.gifts.Accept(ctx, recipientID, giftID); err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	// the buyer never downloads a gift, the recipient's acceptance is what counts
+	rf, err := s.refunds.RequestRefund(ctx, buyerAuth, buyerID, res.OrderID, nil, "")
+	if err != nil {
+		t.Fatalf("refund: %v", err)
+	}
+	if rf.Status != model.RefundRequested {
+		t.Fatalf("refund of an accepted gift = %s, want Requested", rf.Status)
+	}
+	if !s.owns(t, recipientID, gameID) {
+		t.Fatal("recipient lost the gift before the refund was reviewed")
+	}
+}
+
+func TestRefundDownloadedGameNeedsReview(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	authID, customerID := s.account(t, "nora", "user")
+	gameID := s.game(t, "Ashen Lanes", "7.00")
+	res, err := s.buy(t, authID, gameID, "tok_ok")
+	if err != nil {
+		t.Fatalf("checkout: %v", err)
+	}
+	if err := s.payments.CustomerGamesRepo.MarkDownloaded(ctx, customerID, gameID); err != nil {
+		t.Fatal(err)
+	}
+	rf, err := s.refunds.RequestRefund(ctx, authID, customerID, res.OrderID, nil, "")
+	if err != nil {
+		t.Fatalf("refund: %v", err)
+	}
+	if rf.Status != model.RefundRequested {
+		t.Fatalf("refund of a downloaded game = %s, want Requested", rf.Status)
+	}
+}
+
+func TestRefundWithinWindow(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	authID, customerID := s.account(t, "omar", "user")
+	gameID := s.game(t, "Lowland Hymn", "21.00")
+	res, err := s.buy(t, authID, gameID, "tok_ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rf, err := s.refunds.RequestRefund(ctx, authID, customerID, res.OrderID, nil, "changed my mind")
+	if err != nil {
+		t.Fatalf("refund: %v", err)
+	}
+	if rf.Status != model.RefundCompleted || rf.Amount.String() != "21.00" {
+		t.Fatalf("refund = %s of %s, want Completed of 21.00", rf.Status, rf.Amount)
+	}
+	if s.owns(t, customerID, gameID) {
+		t.Fatal("game still owned after the refund")
+	}
+	if held := s.gateway.Held(s.providerRef(t, res.PaymentID)); held != 0 {
+		t.Fatalf("provider still holds %s", held)
+	}
+	if st := s.paymentStatus(t, res.PaymentID); st != model.PaymentRefunded {
+		t.Fatalf("payment = %s, want Refunded", st)
+	}
+	if _, err := s.refunds.RequestRefund(ctx, authID, customerID, res.OrderID, nil, ""); err == nil {
+		t.Fatal("a refunded order was refunded again")
+	}
+}
+
+func TestRefundOutsideWindowNeedsReview(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	authID, customerID := s.account(t, "pam", "user")
+	gameID := s.game(t, "Cinder Loom", "9.00")
+	res, err := s.buy(t, authID, gameID, "tok_ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.exec(t, `UPDATE payments SET paidat = now() - interval '30 days' WHERE paymentid=$1`, res.PaymentID)
+
+	rf, err := s.refunds.RequestRefund(ctx, authID, customerID, res.OrderID, nil, "")
+	if err != nil {
+		t.Fatalf("refund: %v", err)
+	}
+	if rf.Status != model.RefundRequested || !s.owns(t, customerID, gameID) {
+		t.Fatalf("refund after the window = %s, want Requested with the game kept", rf.Status)
+	}
+}
+
+func TestRefundConcurrently(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	authID, customerID := s.account(t, "rex", "user")
+	gameID := s.game(t, "Thistle Engine", "14.00")
+	res, err := s.buy(t, authID, gameID, "tok_ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok := 0
+	for _, err := range concurrently(5, func() error {
+		_, err := s.refunds.RequestRefund(ctx, authID, customerID, res.OrderID, nil, "")
+		return err
+	}) {
+		if err == nil {
+			ok++
+		}
+	}
+	if ok != 1 {
+		t.Fatalf("%d refunds of one order went through, want 1", ok)
+	}
+	if n := s.queryInt(t, `SELECT count(*) FROM refunds WHERE orderid=$1`, res.OrderID); n != 1 {
+		t.Fatalf("refund rows = %d, want 1", n)
+	}
+}
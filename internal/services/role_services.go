@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"GameStoreAPI/internal/middleware"
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/repository"
+)
+
+var roleNameRegex = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,19}$`)
+
+type RoleService struct {
+	Repo *repository.RoleRepository
+}
+
+func NewRoleService(r *repository.RoleRepository) *RoleService {
+	return &RoleService{Repo: r}
+}
+
+// Permissions is the middleware.PermissionResolver: the permissions of the account's current role
+func (s *RoleService) Permissions(ctx context.Context, claims *middleware.Claims) ([]string, error) {
+	return s.Repo.PermissionsOf(ctx, claims.AuthID)
+}
+
+// List returns all roles. Not paginated: roles are a short list kept by admins.
+func (s *RoleService) List(ctx context.Context) ([]model.Role, error) {
+	return s.Repo.List(ctx)
+}
+
+func (s *RoleService) ListPermissions(ctx context.Context) ([]model.Permission, error) {
+	return s.Repo.ListPermissions(ctx)
+}
+
+// validatePermissions checks that every permission exists and removes duplicates
+func (s *RoleService) validatePermissions(ctx context.Context, perms []string) ([]string, error) {
+	known, err := s.Repo.ListPermissions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[string]bool, len(known))
+	for _, p := range known {
+		exists[p.Permission] = true
+	}
+	seen := map[string]bool{}
+	out := make([]string, 0, len(perms))
+	for _, p := range perms {
+		p = strings.TrimSpace(p)
+		if !exists[p] {
+			return nil, errors.New("unknown permission " + p)
+		}
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// Create adds a custom role
+func (s *RoleService) Create(ctx context.Context, ro *model.Role) error {
+	ro.Role = strings.ToLower(strings.TrimSpace(ro.Role))
+	if !roleNameRegex.MatchString(ro.Role) {
+		return errors.New("role must be 2-20 lowercase letters, digits, '_' or '-'")
+	}
+	if _, err := s.Repo.Get(ctx, ro.Role); err == nil {
+		return errors.New("role already exists")
+	}
+	perms, err := s.validatePermissions(ctx, ro.Permissions)
+	if err != nil {
+		return err
+	}
+	ro.Permissions = perms
+	return s.Repo.Create(ctx, ro)
+}
+
+// Update replaces the description and permission set of a role. The admin role always
+// keeps roles:manage so that nobody can lock themselves out of role management.
+func (s *RoleService) Update(ctx context.Context, ro *model.Role) error {
+	existing, err := s.Repo.Get(ctx, ro.Role)
+	if err != nil {
+		return err
+	}
+	perms, err := s.validatePermissions(ctx, ro.Permissions)
+	if err != nil {
+		return err
+	}
+	if existing.Builtin && existing.Role == "admin" {
+		keeps := false
+		for _, p := range perms {
+			keeps = keeps || p == model.PermRolesManage
+		}
+		if !keeps {
+			return errors.New("the admin role must keep " + model.PermRolesManage)
+		}
+	}
+	ro.Permissions = perms
+	return s.Repo.Update(ctx, ro)
+}
+
+// Delete removes a custom role that is not assigned to anyone
+func (s *RoleService) Delete(ctx context.Context, role string) error {
+	return s.Repo.Delete(ctx, role)
+}
+
+// Assign changes the role of an account; nobody can change their own role
+func (s *RoleService) Assign(ctx context.Context, actorAuthID, authID int64, role string) error {
+	if actorAuthID == authID {
+		return errors.New("you cannot change your own role")
+	}
+	if _, err := s.Repo.Get(ctx, role); err != nil {
+		return err
+	}
+	return s.Repo.Assign(ctx, authID, role)
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/money"
+	"GameStoreAPI/internal/pagination"
+	"GameStoreAPI/internal/repository"
+)
+
+type SaleService struct {
+	Repo     *repository.SaleRepository
+	GameRepo *repository.GameRepository
+}
+
+func NewSaleService(r *repository.SaleRepository, gr *repository.GameRepository) *SaleService {
+	return &SaleService{Repo: r, GameRepo: gr}
+}
+
+func (s *SaleService) validate(ctx context.Context, sale *model.GameSale) error {
+	// timestamps are stored as local wall-clock time, like every other timestamp column
+	sale.StartsAt, sale.EndsAt = sale.StartsAt.Local(), sale.EndsAt.Local()
+	if (sale.DiscountPercent == nil) == (sale.SalePrice == nil) {
+		return errors.New("provide either discountpercent or saleprice")
+	}
+	if sale.DiscountPercent != nil && (*sale.DiscountPercent <= 0 || *sale.DiscountPercent >= money.FromUnits(100)) {
+		return errors.New("discountpercent must be between 0 and 100")
+	}
+	if !sale.EndsAt.After(sale.StartsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+	if !sale.EndsAt.After(time.Now()) {
+		return errors.New("sale would already be over")
+	}
+	g, err := s.GameRepo.GetByID(ctx, sale.GameID)
+	if err != nil || g.DeletedAt != nil {
+		return errors.New("game not found")
+	}
+	if sale.SalePrice != nil && (*sale.SalePrice < 0 || *sale.SalePrice >= g.OriginalPrice) {
+		return errors.New("saleprice must be below the game's price")
+	}
+	overlap, err := s.Repo.HasOverlap(ctx, sale.GameID, sale.StartsAt, sale.EndsAt, sale.SaleID)
+	if err != nil {
+		return err
+	}
+	if overlap {
+		return errors.New("another sale is scheduled in that period")
+	}
+	return nil
+}
+
+// Schedule creates a sale for a game
+func (s *SaleService) Schedule(ctx context.Context, sale *model.GameSale) (int64, error) {
+	if err := s.validate(ctx, sale); err != nil {
+		return 0, err
+	}
+	return s.Repo.Create(ctx, sale)
+}
+
+// Update changes a scheduled or running sale
+func (s *SaleService) Update(ctx context.Context, sale *model.GameSale) error {
+	if err := s.validate(ctx, sale); err != nil {
+		return err
+	}
+	return s.Repo.Update(ctx, sale)
+}
+
+func (s *SaleService) Cancel(ctx context.Context, gameID, saleID int64) error {
+	return s.Repo.Delete(ctx, gameID, saleID)
+}
+
+// List returns upcoming and running sales of a game (all of them, including past ones, if all is set)
+func (s *SaleService) List(ctx context.Context, gameID int64, all bool, p pagination.Params) (*pagination.Page[model.GameSale], error) {
+	if p.After != nil && p.AfterKey == "" {
+		return nil, pagination.ErrInvalidCursor
+	}
+	rows, err := s.Repo.ListByGame(ctx, gameID, all, p)
+	if err != nil {
+		return nil, err
+	}
+	return pagination.NewKeyedPage(rows, p, repository.SaleKey, func() (int64, error) { return s.Repo.CountByGame(ctx, gameID, all) })
+}
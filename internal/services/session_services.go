@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"GameStoreAPI/internal/middleware"
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/repository"
+)
+
+// Default token lifetimes
+const (
+	DefaultAccessTokenTTL  = 15 * time.Minute
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used: session revoked, please log in again")
+	ErrSessionRevoked      = errors.New("session revoked or expired")
+)
+
+// SessionService issues access/refresh token pairs. Refresh tokens rotate on every use;
+// presenting a token that was already rotated revokes its whole family (the session).
+type SessionService struct {
+	Repo       *repository.SessionRepository
+	Users      *repository.AuthRepository
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+func NewSessionService(r *repository.SessionRepository, u *repository.AuthRepository, accessTTL, refreshTTL time.Duration) *SessionService {
+	if accessTTL <= 0 {
+		accessTTL = DefaultAccessTokenTTL
+	}
+	if refreshTTL <= 0 {
+		refreshTTL = DefaultRefreshTokenTTL
+	}
+	return &SessionService{Repo: r, Users: u, AccessTTL: accessTTL, RefreshTTL: refreshTTL}
+}
+
+// randomToken returns n random bytes, base64url encoded
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// Issue starts a new session for an authenticated user
+func (s *SessionService) Issue(ctx context.Context, u *model.Auth, userAgent, ip string) (*model.TokenPair, error) {
+	sid, err := randomToken(24)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	sess := &model.Session{
+		SessionID: sid,
+		AuthID:    u.AuthID,
+		UserAgent: optionalString(userAgent),
+		IPAddress: optionalString(ip),
+		ExpiresAt: time.Now().Add(s.RefreshTTL),
+	}
+	if err := s.Repo.Create(ctx, sess, hashToken(refresh)); err != nil {
+		return nil, fmt.Errorf("create session: %w", err)
+	}
+	return s.pair(u, sid, refresh, sess.ExpiresAt)
+}
+
+func (s *SessionService) pair(u *model.Auth, sid, refresh string, refreshExpires time.Time) (*model.TokenPair, error) {
+	access, err := middleware.GenerateToken(u.AuthID, u.Email, u.Role, sid, s.AccessTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &model.TokenPair{
+		AccessToken:      access,
+		ExpiresIn:        int(s.AccessTTL / time.Second),
+		RefreshToken:     refresh,
+		RefreshExpiresAt: refreshExpires,
+		SessionID:        sid,
+	}, nil
+}
+
+// Refresh exchanges a refresh token for a new pair and invalidates the presented token
+func (s *SessionService) Refresh(ctx context.Context, refreshToken string) (*model.TokenPair, error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+	tx, err := s.Repo.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rt, sess, err := s.Repo.LockTokenTx(ctx, tx, hashToken(refreshToken))
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if sess.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if rt.UsedAt != nil {
+		// a rotated token came back: it was stolen or replayed, kill the family
+		if err := s.Repo.RevokeTx(ctx, tx, sess.SessionID, model.SessionTokenReuse); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, fmt.Errorf("commit tx: %w", err)
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	if !rt.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidRefreshToken
+	}
+	u, err := s.Users.GetByID(ctx, sess.AuthID)
+	if err != nil || blocked(u, time.Now()) {
+		if err := s.Repo.RevokeTx(ctx, tx, sess.SessionID, model.SessionUserBlocked); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return nil, fmt.Errorf("commit tx: %w", err)
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	next, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	expires := time.Now().Add(s.RefreshTTL)
+	if err := s.Repo.RotateTx(ctx, tx, rt.TokenID, sess.SessionID, hashToken(next), expires); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+	return s.pair(u, sess.SessionID, next, expires)
+}
+
+// Logout revokes the current session, or every session of the user when all is set
+func (s *SessionService) Logout(ctx context.Context, authID int64, sessionID string, all bool) error {
+	if all {
+		return s.Repo.RevokeAll(ctx, authID, model.SessionLogoutAll)
+	}
+	if sessionID == "" {
+		return errors.New("token has no session")
+	}
+	return s.Repo.Revoke(ctx, authID, sessionID, model.SessionLogout)
+}
+
+// ListActive returns the user's active sessions. Not paginated: revoked and expired
+// sessions are left out, so the list stays as short as the devices the user signs in on.
+func (s *SessionService) ListActive(ctx context.Context, authID int64) ([]model.Session, error) {
+	return s.Repo.ListActive(ctx, authID)
+}
+
+// Validate is the middleware.SessionValidator: the session must be active and the user not banned.
+// Tokens issued without a session only get the ban check.
+func (s *SessionService) Validate(ctx context.Context, claims *middleware.Claims) error {
+	if claims.SessionID == "" {
+		u, err := s.Users.GetByID(ctx, claims.AuthID)
+		if err != nil || blocked(u, time.Now()) {
+			return ErrSessionRevoked
+		}
+		return nil
+	}
+	ok, err := s.Repo.IsActive(ctx, claims.AuthID, claims.SessionID)
+	if err != nil {
+		return fmt.Errorf("session check failed: %w", err)
+	}
+	if !ok {
+		return ErrSessionRevoked
+	}
+	return nil
+}
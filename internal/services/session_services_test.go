@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	"GameStoreAPI/internal/middleware"
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/repository"
+)
+
+// newSessionTest returns a store with a session service; access tokens are signed with a
+// throwaway key
+func newSessionTest(t *testing.T) (*testStore, *SessionService) {
+	t.Helper()
+	s := newTestStore(t)
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks := middleware.NewKeySet()
+	if err := ks.AddPEM("test", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.SetSigningKey("test"); err != nil {
+		t.Fatal(err)
+	}
+	prev := middleware.CurrentKeySet()
+	middleware.SetKeySet(ks)
+	t.Cleanup(func() { middleware.SetKeySet(prev) })
+	return s, NewSessionService(repository.NewSessionRepository(s.pool), repository.NewAuthRepository(s.pool), 0, 0)
+}
+
+// login starts a session for the account
+func login(t *testing.T, ss *SessionService, authID int64) *model.TokenPair {
+	t.Helper()
+	u, err := ss.Users.GetByID(context.Background(), authID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, err := ss.Issue(context.Background(), u, "test", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pair
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	s, ss := newSessionTest(t)
+	ctx := context.Background()
+	authID, _ := s.account(t, "kai", "user")
+	first := login(t, ss, authID)
+	other := login(t, ss, authID)
+
+	second, err := ss.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if second.SessionID != first.SessionID || second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh did not rotate the token within the session")
+	}
+
+	// the rotated token comes back: whoever holds the current one loses the session too
+	if _, err := ss.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reused token: %v, want ErrRefreshTokenReused", err)
+	}
+	if _, err := ss.Refresh(ctx, second.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("current token of a revoked session: %v, want ErrInvalidRefreshToken", err)
+	}
+	claims := &middleware.Claims{AuthID: authID, SessionID: first.SessionID}
+	if err := ss.Validate(ctx, claims); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("access token of a revoked session: %v, want ErrSessionRevoked", err)
+	}
+	var reason string
+	if err := s.pool.QueryRow(ctx, `SELECT revokedreason FROM authsessions WHERE sessionid=$1`, first.SessionID).Scan(&reason); err != nil {
+		t.Fatal(err)
+	}
+	if reason != model.SessionTokenReuse {
+		t.Fatalf("revoked for %q, want %q", reason, model.SessionTokenReuse)
+	}
+
+	// other sessions of the account are not part of the family
+	if err := ss.Validate(ctx, &middleware.Claims{AuthID: authID, SessionID: other.SessionID}); err != nil {
+		t.Fatalf("other session: %v", err)
+	}
+	if _, err := ss.Refresh(ctx, other.RefreshToken); err != nil {
+		t.Fatalf("refresh of another session: %v", err)
+	}
+}
+
+func TestValidateBlockedUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		block   string // applied to the account after login, without revoking its sessions
+		blocked bool
+	}{
+		{"active", ``, false},
+		{"banned", `UPDATE userauth SET banned_at=now() WHERE authid=$1`, true},
+		{"suspended", `UPDATE userauth SET banned_at=now(), banned_until=now() + interval '1 day' WHERE authid=$1`, true},
+		{"suspension over", `UPDATE userauth SET banned_at=now() - interval '2 days', banned_until=now() - interval '1 day' WHERE authid=$1`, false},
+		{"deleted", `UPDATE userauth SET deleted_at=now() WHERE authid=$1`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, ss := newSessionTest(t)
+			ctx := context.Background()
+			authID, _ := s.account(t, "lea", "user")
+			pair := login(t, ss, authID)
+			if tt.block != "" {
+				s.exec(t, tt.block, authID)
+			}
+
+			for _, claims := range []*middleware.Claims{
+				{AuthID: authID, SessionID: pair.SessionID},
+				{AuthID: authID}, // tokens issued without a session
+			} {
+				err := ss.Validate(ctx, claims)
+				if tt.blocked && !errors.Is(err, ErrSessionRevoked) {
+					t.Fatalf("Validate(sid=%q) = %v, want ErrSessionRevoked", claims.SessionID, err)
+				}
+				if !tt.blocked && err != nil {
+					t.Fatalf("Validate(sid=%q) = %v", claims.SessionID, err)
+				}
+			}
+
+			_, err := ss.Refresh(ctx, pair.RefreshToken)
+			if tt.blocked != (err != nil) {
+				t.Fatalf("refresh: %v, blocked=%v", err, tt.blocked)
+			}
+			if tt.blocked {
+				if n := s.queryInt(t, `SELECT count(*) FROM authsessions WHERE sessionid=$1 AND revokedreason=$2`,
+					pair.SessionID, model.SessionUserBlocked); n != 1 {
+					t.Fatal("refreshing a blocked account did not revoke its session")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"GameStoreAPI/internal/db/dbtest"
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/payment"
+	"GameStoreAPI/internal/repository"
+	"GameStoreAPI/internal/tax"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testStore wires the services the way cmd/app/main.go does, on a database of its own
+type testStore struct {
+	pool    *pgxpool.Pool
+	gateway *payment.FakeGateway
+
+	payments *PaymentService
+	cart     *CartService
+	refunds  *RefundService
+	gifts    *GiftService
+}
+
+// webhookSecret signs the fake gateway's webhook deliveries in tests
+var webhookSecret = []byte("test-webhook-secret")
+
+func newTestStore(t *testing.T) *testStore {
+	t.Helper()
+	pool := dbtest.New(t)
+
+	gameRepo := repository.NewGameRepository(pool)
+	customerRepo := repository.NewCustomerRepository(pool)
+	orderRepo := repository.NewOrderRepository(pool)
+	customerGamesRepo := repository.NewCustomerGamesRepository(pool)
+	paymentRepo := repository.NewPaymentRepository(pool)
+	promoRepo := repository.NewPromoRepository(pool)
+	giftRepo := repository.NewGiftRepository(pool)
+
+	taxCalc, err := tax.NewTableCalculator(tax.DefaultRates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateway := payment.NewFakeGateway(payment.DefaultFakeConfig(webhookSecret))
+	pricing := NewPricingService(repository.NewPricingRepository(pool), gameRepo, customerRepo, "USD")
+	payments := NewPaymentService(paymentRepo, orderRepo, customerGamesRepo, repository.NewRefundRepository(pool), promoRepo, giftRepo, gateway)
+	cart := NewCartService(repository.NewCartRepository(pool), orderRepo, customerGamesRepo, repository.NewAuthRepository(pool), customerRepo,
+		paymentRepo, payments, pricing, NewPromoService(promoRepo, pricing), taxCalc, giftRepo)
+
+	refunds := NewRefundService(repository.NewRefundRepository(pool), orderRepo, paymentRepo, customerGamesRepo, payments, DefaultRefundWindow)
+	gifts := NewGiftService(giftRepo, customerGamesRepo, paymentRepo, payments)
+
+	return &testStore{pool: pool, gateway: gateway, payments: payments, cart: cart, refunds: refunds, gifts: gifts}
+}
+
+func (s *testStore) exec(t *testing.T, sql string, args ...interface{}) {
+	t.Helper()
+	if _, err := s.pool.Exec(context.Background(), sql, args...); err != nil {
+		t.Fatalf("exec %q: %v", sql, err)
+	}
+}
+
+func (s *testStore) queryInt(t *testing.T, sql string, args ...interface{}) int64 {
+	t.Helper()
+	var n int64
+	if err := s.pool.QueryRow(context.Background(), sql, args...).Scan(&n); err != nil {
+		t.Fatalf("query %q: %v", sql, err)
+	}
+	return n
+}
+
+// account creates a verified account with the role and, for users, its customer record
+// with a US billing address (no sales tax). It returns the authid and customerid.
+func (s *testStore) account(t *testing.T, name, role string) (int64, int64) {
+	t.Helper()
+	email := name + "@example.com"
+	authID := s.queryInt(t, `INSERT INTO userauth (email, passwordhash, role, email_verified_at)
+		VALUES ($1, 'x', $2, now()) RETURNING authid`, email, role)
+	if role != "user" {
+		return authID, 0
+	}
+	customerID := s.queryInt(t, `INSERT INTO customers (authid, username, email, currency, billing_country)
+		VALUES ($1, $2, $3, 'USD', 'US') RETURNING customerid`, authID, name, email)
+	return authID, customerID
+}
+
+// game creates a published game of a new developer and returns its id
+func (s *testStore) game(t *testing.T, title, price string) int64 {
+	t.Helper()
+	devID := s.queryInt(t, `INSERT INTO developers (developername) VALUES ($1) RETURNING developerid`, title+" Studio")
+	return s.queryInt(t, `INSERT INTO games (developerid, title, price, releasedate, status)
+		VALUES ($1, $2, $3::numeric, current_date - 1, 'published') RETURNING gameid`, devID, title, price)
+}
+
+// owns reports whether the customer owns the game
+func (s *testStore) owns(t *testing.T, customerID, gameID int64) bool {
+	t.Helper()
+	return s.queryInt(t, `SELECT count(*) FROM customer_games WHERE customerid=$1 AND gameid=$2`, customerID, gameID) == 1
+}
+
+// creditCard is the id of the seeded "Credit Card" payment method
+func (s *testStore) creditCard(t *testing.T) int64 {
+	t.Helper()
+	return s.queryInt(t, `SELECT paymentmethodid FROM paymentmethods WHERE name='Credit Card'`)
+}
+
+// buy puts the game in the customer's cart and checks out paying with the card token
+func (s *testStore) buy(t *testing.T, authID, gameID int64, cardToken string) (*model.CheckoutResult, error) {
+	t.Helper()
+	ctx := context.Background()
+	if err := s.cart.Add(ctx, authID, gameID, 1, "", ""); err != nil {
+		t.Fatalf("add to cart: %v", err)
+	}
+	return s.cart.Checkout(ctx, authID, model.CheckoutRequest{PaymentMethodID: s.creditCard(t), CardToken: cardToken})
+}
+
+// providerRef is the gateway reference of a payment
+func (s *testStore) providerRef(t *testing.T, paymentID int64) string {
+	t.Helper()
+	var ref string
+	if err := s.pool.QueryRow(context.Background(), `SELECT providerref FROM payments WHERE paymentid=$1`, paymentID).Scan(&ref); err != nil {
+		t.Fatalf("provider reference of payment %d: %v", paymentID, err)
+	}
+	return ref
+}
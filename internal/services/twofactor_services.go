@@ -0,0 +1,330 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"GameStoreAPI/internal/model"
+	"GameStoreAPI/internal/repository"
+	"GameStoreAPI/internal/totp"
+
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// LoginChallengeTTL is how long the second login step may take
+	LoginChallengeTTL = 5 * time.Minute
+	RecoveryCodeCount = 10
+)
+
+var ErrInvalid2FACode = errors.New("invalid two-factor code")
+
+// TwoFactorService manages TOTP enrollment, recovery codes and the second login step
+type TwoFactorService struct {
+	Repo     *repository.TwoFactorRepository
+	Users    *repository.AuthRepository
+	Roles    *repository.RoleRepository
+	Tokens   *repository.AuthTokenRepository
+	Sessions *repository.SessionRepository
+	// Lockout counts wrong second factors like wrong passwords
+	Lockout *LockoutService
+	// Issuer is the account name prefix shown in authenticator apps
+	Issuer string
+}
+
+func NewTwoFactorService(r *repository.TwoFactorRepository, u *repository.AuthRepository, ro *repository.RoleRepository,
+	tr *repository.AuthTokenRepository, sr *repository.SessionRepository, lo *LockoutService, issuer string) *TwoFactorService {
+	if issuer == "" {
+		issuer = "GameStore"
+	}
+	return &TwoFactorService{Repo: r, Users: u, Roles: ro, Tokens: tr, Sessions: sr, Lockout: lo, Issuer: issuer}
+}
+
+// Enabled reports whether a user has 2FA turned on
+func (s *TwoFactorService) Enabled(ctx context.Context, authID int64) (bool, error) {
+	return s.Repo.IsEnabled(ctx, authID)
+}
+
+// SetupRequired reports whether the user's role enforces 2FA but the user has not enabled it
+// yet (the role's permissions are withheld until then)
+func (s *TwoFactorService) SetupRequired(ctx context.Context, authID int64) (bool, error) {
+	required, err := s.Roles.Requires2FA(ctx, authID)
+	if err != nil || !required {
+		return false, err
+	}
+	on, err := s.Repo.IsEnabled(ctx, authID)
+	return !on, err
+}
+
+// Status returns the 2FA state of a user
+func (s *TwoFactorService) Status(ctx context.Context, authID int64) (*model.TwoFactorStatus, error) {
+	required, err := s.Roles.Requires2FA(ctx, authID)
+	if err != nil {
+		return nil, err
+	}
+	st := &model.TwoFactorStatus{Required: required}
+	if tf, err := s.Repo.Get(ctx, authID); err == nil && tf.EnabledAt != nil {
+		st.Enabled = true
+		st.EnabledAt = tf.EnabledAt
+		if st.RecoveryCodesLeft, err = s.Repo.CountRecoveryCodes(ctx, authID); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+// Setup generates a new secret for the user. 2FA stays off until Enable confirms a code.
+func (s *TwoFactorService) Setup(ctx context.Context, authID int64) (*model.TwoFactorSetup, error) {
+	u, err := s.Users.GetByID(ctx, authID)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Repo.SavePending(ctx, authID, secret); err != nil {
+		return nil, err
+	}
+	return &model.TwoFactorSetup{Secret: secret, ProvisioningURI: totp.ProvisioningURI(s.Issuer, u.Email, secret)}, nil
+}
+
+// Enable turns 2FA on after checking a code from the authenticator app, and returns the
+// recovery codes. They are only shown this once.
+func (s *TwoFactorService) Enable(ctx context.Context, authID int64, code string) ([]string, error) {
+	tx, err := s.Repo.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tf, err := s.Repo.LockTx(ctx, tx, authID)
+	if err != nil {
+		return nil, err
+	}
+	if tf.EnabledAt != nil {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+	step, ok := totp.Validate(tf.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalid2FACode
+	}
+	if err := s.Repo.EnableTx(ctx, tx, authID, step); err != nil {
+		return nil, err
+	}
+	codes, err := s.newRecoveryCodesTx(ctx, tx, authID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+	return codes, nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes; a valid 2FA code is required
+func (s *TwoFactorService) RegenerateRecoveryCodes(ctx context.Context, authID int64, code string) ([]string, error) {
+	tx, err := s.Repo.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.verifyTx(ctx, tx, authID, code); err != nil {
+		return nil, err
+	}
+	codes, err := s.newRecoveryCodesTx(ctx, tx, authID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+	return codes, nil
+}
+
+// Disable turns 2FA off; both the password and a 2FA code are required. Accounts whose
+// role enforces 2FA cannot turn it off.
+func (s *TwoFactorService) Disable(ctx context.Context, authID int64, password, code string) error {
+	required, err := s.Roles.Requires2FA(ctx, authID)
+	if err != nil {
+		return err
+	}
+	if required {
+		return errors.New("your role requires two-factor authentication")
+	}
+	hash, err := s.Users.GetPasswordHash(ctx, authID)
+	if err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return errors.New("invalid password")
+	}
+	tx, err := s.Repo.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.verifyTx(ctx, tx, authID, code); err != nil {
+		return err
+	}
+	if err := s.Repo.DeleteTx(ctx, tx, authID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Reset removes the 2FA setup of another account (lost authenticator) and signs it out
+// everywhere. The user has to enroll again.
+func (s *TwoFactorService) Reset(ctx context.Context, actorAuthID, authID int64) error {
+	if actorAuthID == authID {
+		return errors.New("you cannot reset your own two-factor authentication")
+	}
+	tx, err := s.Repo.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.Repo.DeleteTx(ctx, tx, authID); err != nil {
+		return err
+	}
+	if err := s.Sessions.RevokeAllTx(ctx, tx, authID, "", model.SessionTwoFactorReset); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Challenge starts the second login step for a user whose password was just checked
+func (s *TwoFactorService) Challenge(ctx context.Context, authID int64) (*model.LoginChallenge, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Tokens.Create(ctx, authID, model.TokenLogin2FA, hashToken(token), time.Now().Add(LoginChallengeTTL)); err != nil {
+		return nil, err
+	}
+	return &model.LoginChallenge{TwoFactorRequired: true, ChallengeToken: token, ExpiresIn: int(LoginChallengeTTL / time.Second)}, nil
+}
+
+// CompleteLogin checks the code for a challenge and returns the user to start a session for.
+// A challenge allows a single attempt: after a wrong code the user logs in again. Wrong codes
+// count as failed logins of the account and the client IP, and lock them out the same way.
+func (s *TwoFactorService) CompleteLogin(ctx context.Context, challenge, code, ip string) (*model.Auth, error) {
+	tx, err := s.Repo.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	authID, err := s.Tokens.ConsumeTx(ctx, tx, model.TokenLogin2FA, hashToken(challenge))
+	if err != nil {
+		return nil, errors.New("invalid or expired login challenge")
+	}
+	u, err := s.Users.GetByID(ctx, authID)
+	if err != nil {
+		return nil, err
+	}
+	// a locked out account or IP gets no guess, the challenge is spent anyway
+	verifyErr := s.Lockout.CheckLogin(ctx, u.Email, ip)
+	if verifyErr == nil {
+		verifyErr = s.verifyTx(ctx, tx, authID, code)
+	}
+	// commit either way: the challenge is spent even when the code was wrong
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit tx: %w", err)
+	}
+	if errors.Is(verifyErr, ErrInvalid2FACode) {
+		if err := s.Lockout.LoginFailed(ctx, u.Email, ip); err != nil {
+			return nil, err
+		}
+	}
+	if verifyErr != nil {
+		return nil, verifyErr
+	}
+	if blocked(u, time.Now()) {
+		return nil, errors.New("account is banned")
+	}
+	return u, nil
+}
+
+// LoginSucceeded resets the failed logins of the account once its login is complete, with
+// the second factor when 2FA is on
+func (s *TwoFactorService) LoginSucceeded(ctx context.Context, u *model.Auth) error {
+	return s.Lockout.LoginSucceeded(ctx, u.Email)
+}
+
+// verifyTx accepts either a current TOTP code (each one works once) or an unused recovery code
+func (s *TwoFactorService) verifyTx(ctx context.Context, tx pgx.Tx, authID int64, code string) error {
+	tf, err := s.Repo.LockTx(ctx, tx, authID)
+	if err != nil || tf.EnabledAt == nil {
+		return errors.New("two-factor authentication is not enabled")
+	}
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(tf.Secret, code, time.Now())
+		if !ok || step <= tf.LastUsedStep {
+			return ErrInvalid2FACode
+		}
+		return s.Repo.UseStepTx(ctx, tx, authID, step)
+	}
+	raw, ok := normalizeRecoveryCode(code)
+	if !ok {
+		return ErrInvalid2FACode
+	}
+	if err := s.Repo.UseRecoveryCodeTx(ctx, tx, authID, hashToken(raw)); err != nil {
+		return ErrInvalid2FACode
+	}
+	return nil
+}
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// recoveryCodeBytes is the entropy of a recovery code: 80 bits, so the unsalted SHA-256
+// stored for it cannot be reversed by trying every code, unlike a password hash
+const recoveryCodeBytes = 10
+
+// newRecoveryCode returns a random recovery code ("xxxx-xxxx-xxxx-xxxx") and its hash
+func newRecoveryCode() (string, string, error) {
+	b := make([]byte, recoveryCodeBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	raw := strings.ToLower(recoveryEncoding.EncodeToString(b))
+	groups := make([]string, 0, len(raw)/4)
+	for i := 0; i < len(raw); i += 4 {
+		groups = append(groups, raw[i:i+4])
+	}
+	return strings.Join(groups, "-"), hashToken(raw), nil
+}
+
+// newRecoveryCodesTx generates and stores a fresh set of recovery codes
+func (s *TwoFactorService) newRecoveryCodesTx(ctx context.Context, tx pgx.Tx, authID int64) ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	hashes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		code, hash, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i], hashes[i] = code, hash
+	}
+	if err := s.Repo.ReplaceRecoveryCodesTx(ctx, tx, authID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode strips the separators users may type; it reports false when code
+// cannot be a recovery code
+func normalizeRecoveryCode(code string) (string, bool) {
+	code = strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+	return code, len(code) == recoveryEncoding.EncodedLen(recoveryCodeBytes)
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"GameStoreAPI/internal/mailer"
+	"GameStoreAPI/internal/repository"
+	"GameStoreAPI/internal/totp"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// twoFactorSecret is the TOTP secret of enrolled test accounts
+const twoFactorSecret = "JBSWY3DPEHPK3PXP"
+
+func newTwoFactorTest(t *testing.T) (*testStore, *TwoFactorService) {
+	t.Helper()
+	s := newTestStore(t)
+	lockout := NewLockoutService(repository.NewAuthAttemptRepository(s.pool), repository.NewAuditRepository(s.pool), DefaultLockoutPolicy)
+	return s, NewTwoFactorService(repository.NewTwoFactorRepository(s.pool), repository.NewAuthRepository(s.pool), repository.NewRoleRepository(s.pool),
+		repository.NewAuthTokenRepository(s.pool), repository.NewSessionRepository(s.pool), lockout, "GameStore")
+}
+
+// secondFactor starts a login challenge for the account and answers it with code
+func secondFactor(t *testing.T, tfs *TwoFactorService, authID int64, code, ip string) error {
+	t.Helper()
+	ch, err := tfs.Challenge(context.Background(), authID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tfs.CompleteLogin(context.Background(), ch.ChallengeToken, code, ip)
+	return err
+}
+
+func TestCompleteLoginLocksOut(t *testing.T) {
+	s, tfs := newTwoFactorTest(t)
+	authID, _ := s.account(t, "tara", "user")
+	s.exec(t, `INSERT INTO twofactor (authid, secret, enabled_at) VALUES ($1, $2, now())`, authID, twoFactorSecret)
+
+	for i := 0; i <= DefaultLockoutPolicy.AccountFreeAttempts; i++ {
+		if err := secondFactor(t, tfs, authID, "not-a-code", "10.0.0.1"); !errors.Is(err, ErrInvalid2FACode) {
+			t.Fatalf("wrong code %d: %v", i+1, err)
+		}
+	}
+
+	// the account is locked now: even the right code from another IP is not checked
+	code, err := totp.CodeAt(twoFactorSecret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var locked *LockedError
+	if err := secondFactor(t, tfs, authID, code, "10.0.0.2"); !errors.As(err, &locked) {
+		t.Fatalf("right code on a locked account: %v, want *LockedError", err)
+	}
+	if n := s.queryInt(t, `SELECT count(*) FROM twofactor WHERE authid=$1 AND last_used_step <> 0`, authID); n != 0 {
+		t.Fatal("the code of a locked out login was used up")
+	}
+}
+
+func TestPasswordAloneKeepsFailedLogins(t *testing.T) {
+	s, tfs := newTwoFactorTest(t)
+	ctx := context.Background()
+	auth := NewAuthService(repository.NewAuthRepository(s.pool), repository.NewCustomerRepository(s.pool), repository.NewAuthTokenRepository(s.pool),
+		repository.NewSessionRepository(s.pool), mailer.NewMemoryMailer(), tfs.Lockout, "http://store.test")
+	authID, _ := s.account(t, "uma", "user")
+	hash, err := bcrypt.GenerateFromPassword([]byte("right horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.exec(t, `UPDATE userauth SET passwordhash=$1 WHERE authid=$2`, string(hash), authID)
+	s.exec(t, `INSERT INTO twofactor (authid, secret, enabled_at) VALUES ($1, $2, now())`, authID, twoFactorSecret)
+
+	for i := 1; i < DefaultLockoutPolicy.AccountFreeAttempts; i++ {
+		if _, err := auth.Login(ctx, "uma@example.com", "wrong horse", "10.0.0.1"); err == nil {
+			t.Fatal("wrong password accepted")
+		}
+	}
+	// the password is right, the second factors are not: the failures keep adding up
+	if _, err := auth.Login(ctx, "uma@example.com", "right horse", "10.0.0.1"); err != nil {
+		t.Fatalf("right password: %v", err)
+	}
+	for range 2 {
+		if err := secondFactor(t, tfs, authID, "not-a-code", "10.0.0.1"); !errors.Is(err, ErrInvalid2FACode) {
+			t.Fatalf("wrong code: %v", err)
+		}
+	}
+	var locked *LockedError
+	if _, err := auth.Login(ctx, "uma@example.com", "right horse", "10.0.0.1"); !errors.As(err, &locked) {
+		t.Fatalf("login after %d failures: %v, want *LockedError", DefaultLockoutPolicy.AccountFreeAttempts+1, err)
+	}
+}
+
+func TestNewRecoveryCode(t *testing.T) {
+	format := regexp.MustCompile(`^[a-z2-7]{4}(-[a-z2-7]{4}){3}$`)
+	seen := map[string]bool{}
+	for range 50 {
+		code, hash, err := newRecoveryCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !format.MatchString(code) {
+			t.Fatalf("code %q is not xxxx-xxxx-xxxx-xxxx", code)
+		}
+		if seen[code] {
+			t.Fatalf("code %q generated twice", code)
+		}
+		seen[code] = true
+		// however the user types it, the code hashes to what was stored
+		for _, typed := range []string{code, strings.ToUpper(code), strings.ReplaceAll(code, "-", ""), strings.ReplaceAll(code, "-", " ")} {
+			raw, ok := normalizeRecoveryCode(typed)
+			if !ok || hashToken(raw) != hash {
+				t.Fatalf("typed as %q the code does not match", typed)
+			}
+		}
+	}
+	for _, code := range []string{"", "abcd-efgh", "abcd-efgh-ijkl-mnop-q", "123456"} {
+		if _, ok := normalizeRecoveryCode(code); ok {
+			t.Fatalf("%q taken for a recovery code", code)
+		}
+	}
+}
+
+func TestSecondFactorReplay(t *testing.T) {
+	s, tfs := newTwoFactorTest(t)
+	authID, _ := s.account(t, "vic", "user")
+	s.exec(t, `INSERT INTO twofactor (authid, secret, enabled_at) VALUES ($1, $2, now())`, authID, twoFactorSecret)
+
+	step := totp.Step(time.Now())
+	code, err := totp.CodeAt(twoFactorSecret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := secondFactor(t, tfs, authID, code, "10.0.0.1"); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := secondFactor(t, tfs, authID, code, "10.0.0.1"); !errors.Is(err, ErrInvalid2FACode) {
+		t.Fatalf("replayed code: %v, want ErrInvalid2FACode", err)
+	}
+	// an older code still inside the skew window is refused too
+	older, err := totp.CodeAt(twoFactorSecret, step-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := secondFactor(t, tfs, authID, older, "10.0.0.1"); !errors.Is(err, ErrInvalid2FACode) {
+		t.Fatalf("code of an earlier step: %v, want ErrInvalid2FACode", err)
+	}
+	if n := s.queryInt(t, `SELECT last_used_step FROM twofactor WHERE authid=$1`, authID); n < step {
+		t.Fatalf("last used step = %d, want at least %d", n, step)
+	}
+}
+
+func TestRecoveryCodeWorksOnce(t *testing.T) {
+	s, tfs := newTwoFactorTest(t)
+	ctx := context.Background()
+	authID, _ := s.account(t, "wes", "user")
+	s.exec(t, `INSERT INTO twofactor (authid, secret, enabled_at) VALUES ($1, $2, now())`, authID, twoFactorSecret)
+	code, err := totp.CodeAt(twoFactorSecret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes, err := tfs.RegenerateRecoveryCodes(ctx, authID, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("%d recovery codes, want %d", len(codes), RecoveryCodeCount)
+	}
+
+	if err := secondFactor(t, tfs, authID, strings.ToUpper(codes[0]), "10.0.0.1"); err != nil {
+		t.Fatalf("recovery code: %v", err)
+	}
+	if err := secondFactor(t, tfs, authID, codes[0], "10.0.0.1"); !errors.Is(err, ErrInvalid2FACode) {
+		t.Fatalf("used recovery code: %v, want ErrInvalid2FACode", err)
+	}
+	if err := secondFactor(t, tfs, authID, codes[1][:9], "10.0.0.1"); !errors.Is(err, ErrInvalid2FACode) {
+		t.Fatalf("truncated recovery code: %v, want ErrInvalid2FACode", err)
+	}
+	if n := s.queryInt(t, `SELECT count(*) FROM recoverycodes WHERE authid=$1 AND used_at IS NULL`, authID); n != RecoveryCodeCount-1 {
+		t.Fatalf("%d unused recovery codes, want %d", n, RecoveryCodeCount-1)
+	}
+}
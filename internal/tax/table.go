@@ -0,0 +1,135 @@
+package tax
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"GameStoreAPI/internal/money"
+)
+
+// Rate is one row of the tax table. A rate with a Region overrides the country-wide rate
+// for that region (e.g. Canadian HST provinces, US states).
+type Rate struct {
+	Country   string `json:"country"`
+	Region    string `json:"region,omitempty"`
+	Name      string `json:"name"`
+	Rate      string `json:"rate"` // decimal fraction, "0.20" = 20%
+	Inclusive bool   `json:"inclusive"`
+}
+
+// DefaultRates is the built-in table: standard VAT/GST rates for the markets the store
+// sells in. Countries that are not listed are not taxed.
+var DefaultRates = []Rate{
+	{Country: "GB", Name: "VAT", Rate: "0.20", Inclusive: true},
+	{Country: "DE", Name: "VAT", Rate: "0.19", Inclusive: true},
+	{Country: "FR", Name: "VAT", Rate: "0.20", Inclusive: true},
+	{Country: "NL", Name: "VAT", Rate: "0.21", Inclusive: true},
+	{Country: "IE", Name: "VAT", Rate: "0.23", Inclusive: true},
+	{Country: "ES", Name: "VAT", Rate: "0.21", Inclusive: true},
+	{Country: "IT", Name: "VAT", Rate: "0.22", Inclusive: true},
+	{Country: "AU", Name: "GST", Rate: "0.10", Inclusive: true},
+	{Country: "NZ", Name: "GST", Rate: "0.15", Inclusive: true},
+	{Country: "JP", Name: "Consumption tax", Rate: "0.10", Inclusive: true},
+	{Country: "SG", Name: "GST", Rate: "0.09", Inclusive: true},
+	{Country: "ID", Name: "VAT", Rate: "0.11", Inclusive: true},
+	{Country: "CA", Name: "GST", Rate: "0.05"},
+	{Country: "CA", Region: "ON", Name: "HST", Rate: "0.13"},
+	{Country: "CA", Region: "NS", Name: "HST", Rate: "0.15"},
+	{Country: "CA", Region: "NB", Name: "HST", Rate: "0.15"},
+	{Country: "CA", Region: "QC", Name: "GST+QST", Rate: "0.14975"},
+	{Country: "US", Name: "Sales tax", Rate: "0"},
+	{Country: "US", Region: "CA", Name: "Sales tax", Rate: "0.0725"},
+	{Country: "US", Region: "NY", Name: "Sales tax", Rate: "0.04"},
+	{Country: "US", Region: "TX", Name: "Sales tax", Rate: "0.0625"},
+	{Country: "US", Region: "WA", Name: "Sales tax", Rate: "0.065"},
+}
+
+type tableRate struct {
+	Rate
+	rat *big.Rat
+}
+
+// TableCalculator looks the rate up in a static table by country and region
+type TableCalculator struct {
+	rates map[string]tableRate
+}
+
+// NewTableCalculator builds a calculator from a rate table
+func NewTableCalculator(rates []Rate) (*TableCalculator, error) {
+	t := &TableCalculator{rates: make(map[string]tableRate, len(rates))}
+	for _, r := range rates {
+		r.Country = strings.ToUpper(strings.TrimSpace(r.Country))
+		r.Region = strings.ToUpper(strings.TrimSpace(r.Region))
+		if len(r.Country) != 2 {
+			return nil, fmt.Errorf("tax table: invalid country %q", r.Country)
+		}
+		rat, ok := new(big.Rat).SetString(r.Rate)
+		if !ok || rat.Sign() < 0 || rat.Cmp(big.NewRat(1, 1)) >= 0 {
+			return nil, fmt.Errorf("tax table: invalid rate %q for %s", r.Rate, key(r.Country, r.Region))
+		}
+		t.rates[key(r.Country, r.Region)] = tableRate{Rate: r, rat: rat}
+	}
+	return t, nil
+}
+
+// LoadTableCalculator reads a JSON array of Rate from path
+func LoadTableCalculator(path string) (*TableCalculator, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rates []Rate
+	if err := json.Unmarshal(b, &rates); err != nil {
+		return nil, fmt.Errorf("tax table %s: %w", path, err)
+	}
+	return NewTableCalculator(rates)
+}
+
+func key(country, region string) string {
+	if region == "" {
+		return country
+	}
+	return country + "-" + region
+}
+
+// Lookup returns the rate that applies to an address, if any
+func (t *TableCalculator) Lookup(addr Address) (Rate, bool) {
+	r, ok := t.lookup(addr)
+	return r.Rate, ok
+}
+
+func (t *TableCalculator) lookup(addr Address) (tableRate, bool) {
+	country := strings.ToUpper(strings.TrimSpace(addr.Country))
+	region := strings.ToUpper(strings.TrimSpace(addr.Region))
+	if r, ok := t.rates[key(country, region)]; ok && region != "" {
+		return r, true
+	}
+	r, ok := t.rates[country]
+	return r, ok
+}
+
+// Calculate implements Calculator
+func (t *TableCalculator) Calculate(ctx context.Context, addr Address, currency string, lines []Line) (*Result, error) {
+	if strings.TrimSpace(addr.Country) == "" {
+		return nil, ErrNoAddress
+	}
+	res := &Result{Rate: "0", PerLine: make(map[int64]money.Amount, len(lines))}
+	r, ok := t.lookup(addr)
+	if !ok {
+		return res, nil
+	}
+	res.Jurisdiction = key(r.Country, r.Region)
+	res.Name = r.Name
+	res.Rate = r.Rate.Rate
+	res.Inclusive = r.Inclusive
+	for _, l := range lines {
+		tax := lineTax(l.Amount, r.rat, r.Inclusive, currency)
+		res.PerLine[l.ID] = tax
+		res.Tax += tax
+	}
+	return res, nil
+}
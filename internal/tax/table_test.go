@@ -0,0 +1,201 @@
+package tax
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"GameStoreAPI/internal/money"
+)
+
+func amount(t *testing.T, s string) money.Amount {
+	t.Helper()
+	a, err := money.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func defaultTable(t *testing.T) *TableCalculator {
+	t.Helper()
+	tc, err := NewTableCalculator(DefaultRates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tc
+}
+
+func TestTableLookup(t *testing.T) {
+	tc := defaultTable(t)
+	tests := []struct {
+		name         string
+		addr         Address
+		jurisdiction string
+		rate         string
+	}{
+		{"country", Address{Country: "DE"}, "DE", "0.19"},
+		{"region without its own rate", Address{Country: "DE", Region: "BY"}, "DE", "0.19"},
+		{"region", Address{Country: "CA", Region: "ON"}, "CA-ON", "0.13"},
+		{"region of another country", Address{Country: "CA", Region: "NY"}, "CA", "0.05"},
+		{"province without HST", Address{Country: "CA", Region: "BC"}, "CA", "0.05"},
+		{"state", Address{Country: "US", Region: "WA"}, "US-WA", "0.065"},
+		{"state without sales tax", Address{Country: "US", Region: "OR"}, "US", "0"},
+		{"no region", Address{Country: "US"}, "US", "0"},
+		{"lower case and spaces", Address{Country: " ca ", Region: "qc "}, "CA-QC", "0.14975"},
+		// the table is keyed by country and region only, postal codes do not narrow it
+		{"postal code", Address{Country: "US", Region: "NY", PostalCode: "10001"}, "US-NY", "0.04"},
+		{"postal code without region", Address{Country: "GB", PostalCode: "SW1A 1AA"}, "GB", "0.20"},
+		{"untaxed country", Address{Country: "BR", Region: "SP"}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ok := tc.Lookup(tt.addr)
+			if ok != (tt.jurisdiction != "") {
+				t.Fatalf("Lookup found = %v, want %v", ok, tt.jurisdiction != "")
+			}
+			if !ok {
+				return
+			}
+			if got := key(r.Country, r.Region); got != tt.jurisdiction || r.Rate != tt.rate {
+				t.Fatalf("Lookup = %s %s, want %s %s", got, r.Rate, tt.jurisdiction, tt.rate)
+			}
+		})
+	}
+}
+
+func TestTableCalculate(t *testing.T) {
+	tc := defaultTable(t)
+	tests := []struct {
+		name      string
+		addr      Address
+		currency  string
+		lines     []string
+		perLine   []string
+		inclusive bool
+	}{
+		// exclusive: tax is added on top of the price
+		{"exclusive", Address{Country: "CA", Region: "ON"}, "CAD", []string{"10.00", "19.99"}, []string{"1.30", "2.60"}, false},
+		{"exclusive, fractional rate", Address{Country: "CA", Region: "QC"}, "CAD", []string{"19.99"}, []string{"2.99"}, false},
+		// inclusive: the tax is the part of the price above the net amount
+		{"inclusive", Address{Country: "DE"}, "EUR", []string{"11.90", "9.99"}, []string{"1.90", "1.60"}, true},
+		{"inclusive, rounds down", Address{Country: "GB"}, "GBP", []string{"4.99"}, []string{"0.83"}, true},
+		// whole-unit currencies round each line's tax to whole units
+		{"inclusive, whole yen", Address{Country: "JP"}, "JPY", []string{"1100", "1000"}, []string{"100", "91"}, true},
+		{"free line", Address{Country: "AU"}, "AUD", []string{"0", "11.00"}, []string{"0", "1.00"}, true},
+		{"zero rate", Address{Country: "US", Region: "OR"}, "USD", []string{"59.99"}, []string{"0"}, false},
+		{"untaxed country", Address{Country: "BR"}, "BRL", []string{"59.99"}, []string{"0"}, false},
+		{"no lines", Address{Country: "DE"}, "EUR", nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := make([]Line, len(tt.lines))
+			for i, s := range tt.lines {
+				lines[i] = Line{ID: int64(i + 1), Amount: amount(t, s)}
+			}
+			res, err := tc.Calculate(context.Background(), tt.addr, tt.currency, lines)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Inclusive != tt.inclusive {
+				t.Fatalf("Inclusive = %v, want %v", res.Inclusive, tt.inclusive)
+			}
+			var sum money.Amount
+			for i, want := range tt.perLine {
+				got := res.PerLine[int64(i+1)]
+				if got != amount(t, want) {
+					t.Errorf("line %d (%s): tax = %s, want %s", i+1, tt.lines[i], got, want)
+				}
+				sum += got
+			}
+			if res.Tax != sum {
+				t.Fatalf("Tax = %s, lines add up to %s", res.Tax, sum)
+			}
+		})
+	}
+}
+
+// TestTableCalculatePerLineRounding shows each line's tax is rounded on its own and the
+// order tax is their sum, so a refund of one line gives back exactly that line's tax
+func TestTableCalculatePerLineRounding(t *testing.T) {
+	tc := defaultTable(t)
+	lines := []Line{{ID: 10, Amount: 7}, {ID: 11, Amount: 7}, {ID: 12, Amount: 7}}
+	res, err := tc.Calculate(context.Background(), Address{Country: "US", Region: "CA"}, "USD", lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 0.07 * 7.25% = 0.005075 rounds to 0.01 on each line; 0.21 * 7.25% would be 0.02
+	for _, l := range lines {
+		if res.PerLine[l.ID] != 1 {
+			t.Fatalf("line %d tax = %s, want 0.01", l.ID, res.PerLine[l.ID])
+		}
+	}
+	if res.Tax != 3 {
+		t.Fatalf("Tax = %s, want 0.03", res.Tax)
+	}
+	if res.Jurisdiction != "US-CA" || res.Name != "Sales tax" || res.Rate != "0.0725" {
+		t.Fatalf("result = %+v", res)
+	}
+}
+
+func TestTableCalculateNoAddress(t *testing.T) {
+	tc := defaultTable(t)
+	if _, err := tc.Calculate(context.Background(), Address{Region: "ON", PostalCode: "M5V"}, "CAD", nil); !errors.Is(err, ErrNoAddress) {
+		t.Fatalf("err = %v, want ErrNoAddress", err)
+	}
+}
+
+func TestNewTableCalculator(t *testing.T) {
+	tests := []struct {
+		name string
+		rate Rate
+		ok   bool
+	}{
+		{"valid", Rate{Country: "de", Name: "VAT", Rate: "0.19"}, true},
+		{"zero", Rate{Country: "US", Name: "Sales tax", Rate: "0"}, true},
+		{"fraction", Rate{Country: "CA", Region: "QC", Rate: "5990/40000"}, true},
+		{"country name", Rate{Country: "Germany", Rate: "0.19"}, false},
+		{"no country", Rate{Rate: "0.19"}, false},
+		{"percent", Rate{Country: "DE", Rate: "19"}, false},
+		{"one", Rate{Country: "DE", Rate: "1"}, false},
+		{"negative", Rate{Country: "DE", Rate: "-0.1"}, false},
+		{"not a number", Rate{Country: "DE", Rate: "19%"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewTableCalculator([]Rate{tt.rate}); (err == nil) != tt.ok {
+				t.Fatalf("err = %v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestLoadTableCalculator(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	data := `[{"country": "NO", "name": "MVA", "rate": "0.25", "inclusive": true}, {"country": "US", "region": "NY", "name": "Sales tax", "rate": "0.04"}]`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tc, err := LoadTableCalculator(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, ok := tc.Lookup(Address{Country: "NO"}); !ok || r.Name != "MVA" || !r.Inclusive {
+		t.Fatalf("NO = %+v, %v", r, ok)
+	}
+	if _, ok := tc.Lookup(Address{Country: "US", Region: "TX"}); ok {
+		t.Fatal("loaded table has a US-wide rate it does not define")
+	}
+
+	if err := os.WriteFile(path, []byte(`{"country": "NO"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTableCalculator(path); err == nil {
+		t.Fatal("malformed table loaded")
+	}
+	if _, err := LoadTableCalculator(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatal("missing table loaded")
+	}
+}
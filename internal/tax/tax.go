@@ -0,0 +1,55 @@
+// Package tax computes sales tax (VAT/GST) for an order from the customer's billing address.
+package tax
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"GameStoreAPI/internal/money"
+)
+
+// ErrNoAddress is returned when tax cannot be computed without a billing country
+var ErrNoAddress = errors.New("billing country is required to compute tax")
+
+// Address is the part of a billing address that determines the tax jurisdiction
+type Address struct {
+	Country    string // ISO 3166-1 alpha-2, e.g. "DE"
+	Region     string // state/province code, e.g. "CA" or "ON"
+	PostalCode string // stored with the order; TableCalculator rates do not depend on it
+}
+
+// Line is one taxable order line; Amount is what the customer pays for it before tax
+// (net of discounts). With tax-inclusive pricing the amount already contains the tax.
+type Line struct {
+	ID     int64
+	Amount money.Amount
+}
+
+// Result is the tax breakdown of an order
+type Result struct {
+	Jurisdiction string                 // "DE", "CA-ON", ...; empty when no rate applies
+	Name         string                 // "VAT", "GST", "HST", "Sales tax"
+	Rate         string                 // decimal fraction, e.g. "0.19"
+	Inclusive    bool                   // prices already contain the tax
+	Tax          money.Amount           // total tax of the order
+	PerLine      map[int64]money.Amount // tax of each line, keyed by Line.ID
+}
+
+// Calculator computes the tax of an order. Implementations must be safe for concurrent use.
+type Calculator interface {
+	Calculate(ctx context.Context, addr Address, currency string, lines []Line) (*Result, error)
+}
+
+// lineTax returns the tax contained in (inclusive) or due on top of (exclusive) an amount
+func lineTax(amount money.Amount, rate *big.Rat, inclusive bool, currency string) money.Amount {
+	if rate.Sign() == 0 || amount == 0 {
+		return 0
+	}
+	if !inclusive {
+		return amount.MulRat(rate).Round(currency)
+	}
+	// amount = net * (1 + rate)  =>  tax = amount * rate / (1 + rate)
+	onePlus := new(big.Rat).Add(big.NewRat(1, 1), rate)
+	return amount.MulRat(new(big.Rat).Quo(rate, onePlus)).Round(currency)
+}
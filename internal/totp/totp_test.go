@@ -0,0 +1,126 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 seed of the RFC 6238 test vectors, "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// TestCodeAtRFC6238 checks the SHA-1 vectors of RFC 6238 appendix B; the RFC lists
+// 8 digits, codes here are their last 6
+func TestCodeAtRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		step := Step(time.Unix(tt.unix, 0))
+		got, err := CodeAt(rfcSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("CodeAt(T=%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+		// secrets are accepted the way users paste them
+		if got, _ := CodeAt(" "+strings.ToLower(rfcSecret)+" ", step); got != tt.want {
+			t.Errorf("CodeAt(lower case secret, T=%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+	if _, err := CodeAt("not base32!", 1); err == nil {
+		t.Fatal("invalid secret accepted")
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+	code := func(step int64) string {
+		c, err := CodeAt(rfcSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name string
+		code string
+		want int64 // matched step, 0 when the code is refused
+	}{
+		{"current step", code(step), step},
+		{"previous step", code(step - 1), step - 1},
+		{"next step", code(step + 1), step + 1},
+		{"two steps old", code(step - 2), 0},
+		{"two steps ahead", code(step + 2), 0},
+		{"spaces", code(step)[:3] + " " + code(step)[3:] + " ", step},
+		{"too short", code(step)[:5], 0},
+		{"too long", code(step) + "0", 0},
+		{"empty", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Validate(rfcSecret, tt.code, now)
+			if ok != (tt.want != 0) || got != tt.want {
+				t.Fatalf("Validate(%q) = %d, %v, want step %d", tt.code, got, ok, tt.want)
+			}
+		})
+	}
+
+	// the window moves with the clock: the code of a step is refused once it is 2 steps old
+	if _, ok := Validate(rfcSecret, code(step), now.Add(2*Period*time.Second)); ok {
+		t.Fatal("code accepted two steps later")
+	}
+	if _, ok := Validate("not base32!", code(step), now); ok {
+		t.Fatal("code accepted for an invalid secret")
+	}
+}
+
+// TestValidateReturnsStep covers what replay protection relies on: Validate reports the
+// step a code belongs to, and a code that was accepted keeps matching the same step for
+// as long as it is valid, so callers can refuse steps at or below the last one used.
+func TestValidateReturnsStep(t *testing.T) {
+	issued := time.Unix(2000000000, 0)
+	code, err := CodeAt(rfcSecret, Step(issued))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, later := range []time.Duration{0, Period * time.Second, -Period * time.Second} {
+		step, ok := Validate(rfcSecret, code, issued.Add(later))
+		if !ok || step != Step(issued) {
+			t.Fatalf("at %s: step = %d, %v, want %d", later, step, ok, Step(issued))
+		}
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := GenerateSecret()
+	if len(a) != 32 || a == b {
+		t.Fatalf("secrets %q and %q, want distinct 160-bit secrets", a, b)
+	}
+	if _, err := CodeAt(a, 1); err != nil {
+		t.Fatalf("generated secret is not usable: %v", err)
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	got := ProvisioningURI("Game Store", "ana@example.com", "JBSWY3DPEHPK3PXP")
+	want := "otpauth://totp/Game%20Store:ana@example.com?algorithm=SHA1&digits=6&issuer=Game+Store&period=30&secret=JBSWY3DPEHPK3PXP"
+	if got != want {
+		t.Fatalf("ProvisioningURI = %s\nwant %s", got, want)
+	}
+}